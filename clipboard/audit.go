@@ -0,0 +1,84 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/audit"
+)
+
+// record records an operation that ran with backend b, or with no
+// backend if it failed, copying or pasting text, with the audit sink.
+func (c *clipboard) record(op string, b Backend, text string, err error) error {
+	if c.opts.Audit == nil {
+		return nil
+	}
+	e := audit.Entry{
+		Time:      time.Now(),
+		Op:        op,
+		Selection: c.selection(),
+		MIME:      c.mime(),
+		Result:    "ok",
+		PID:       os.Getpid(),
+		Process:   executable(),
+		Caller:    caller(),
+	}
+	if b != nil {
+		e.Backend = b.Name()
+	}
+	if err != nil {
+		e.Result = ClassifyError(err).String()
+	} else if op == "copy" || op == "paste" {
+		e.Size = len(text)
+		e.SHA256 = audit.ContentHash(text)
+	}
+	if err := c.opts.Audit.Record(e); err != nil {
+		return fmt.Errorf("recording audit entry: %w", err)
+	}
+	return nil
+}
+
+// executable returns the path of the executable of the process.
+var executable = sync.OnceValue(func() string {
+	path, _ := os.Executable()
+	return path
+})
+
+// libraryPackage is the package of the clipboard. Its functions, and
+// those of its subpackages, are skipped when looking for the caller, so
+// that calls through wrappers like PasteTextSafe or through middleware
+// are attributed to the code that made them.
+const libraryPackage = "github.com/tiagomelo/go-clipboard/clipboard"
+
+// caller returns the function, file and line that called the clipboard.
+func caller() string {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !inLibrary(frame) {
+			return fmt.Sprintf("%s (%s:%d)", frame.Function, frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+// inLibrary reports whether frame runs a function of the clipboard
+// package or of its subpackages, other than one of their tests.
+func inLibrary(frame runtime.Frame) bool {
+	if strings.HasSuffix(frame.File, "_test.go") {
+		return false
+	}
+	fn := frame.Function
+	return strings.HasPrefix(fn, libraryPackage+".") || strings.HasPrefix(fn, libraryPackage+"/")
+}
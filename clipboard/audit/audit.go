@@ -0,0 +1,325 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Entry records a clipboard operation.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Op        string    `json:"op"`                // e.g. "copy", "paste" or "types"
+	Selection string    `json:"selection"`         // "clipboard" or "primary"
+	MIME      string    `json:"mime,omitempty"`    // Requested MIME type or target
+	Size      int       `json:"size"`              // Size of the content, in bytes
+	SHA256    string    `json:"sha256,omitempty"`  // Hex SHA-256 of the content
+	Backend   string    `json:"backend,omitempty"` // Backend that ran the operation
+	Result    string    `json:"result"`            // "ok", or the class of the error
+	PID       int       `json:"pid"`               // Process running the operation
+	Process   string    `json:"process,omitempty"` // Executable of the process
+	Caller    string    `json:"caller,omitempty"`  // Function that called the clipboard
+	Prev      string    `json:"prev"`              // Hash of the previous entry
+	Hash      string    `json:"hash"`              // Hash of this entry
+}
+
+// header is the first line of every file of a Log. It carries the hash
+// of the last entry of the previous file, so that a file whose entries
+// all went missing, or the file before it, can be detected even when
+// the file has no entries yet.
+type header struct {
+	Log  string `json:"log"`  // Always logName
+	Prev string `json:"prev"` // Hash of the last entry of the previous file
+}
+
+// logName identifies the header line of a file.
+const logName = "clipboard-audit"
+
+// Sink records entries. Record must be safe for concurrent use.
+type Sink interface {
+	Record(e Entry) error
+}
+
+// ContentHash returns the hex SHA-256 of content, as recorded in entries.
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// hash returns the hash of e: the hex SHA-256 of its JSON
+// encoding without the hash, which includes the previous hash.
+func hash(e Entry) (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Options configures a Log.
+type Options struct {
+	// MaxSize is the size, in bytes, the file may reach before it is
+	// rotated: renamed after the time of the rotation, and replaced by
+	// a new file continuing the chain. Zero means never.
+	MaxSize int64
+
+	// MaxFiles is how many rotated files are kept;
+	// older ones are removed. Zero means all.
+	MaxFiles int
+
+	// Removed, if not nil, is called with every rotated file removed
+	// because of MaxFiles and the hash of its last entry. The files
+	// kept can only be verified from that hash on, with VerifyFiles,
+	// so it should be kept where it can't be tampered with.
+	Removed func(path, last string)
+}
+
+// Log is a Sink appending entries to a file as JSON lines, linked by a
+// hash chain. It is safe for concurrent use, but only one Log, in one
+// process, may write to a file at a time.
+type Log struct {
+	path string
+	opts Options
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	header int64  // size of the header written when the file was created
+	last   string // hash of the last entry
+}
+
+// Open opens the log at path, creating it if needed. New entries
+// continue the chain of the entries already in the file or, if it has
+// none, of the last rotated file. The first entry ever written starts
+// the chain: it has an empty Prev.
+func Open(path string, opts Options) (*Log, error) {
+	l := &Log{path: path, opts: opts}
+	files, err := Files(path)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(files) - 1; i >= 0 && l.last == ""; i-- {
+		if l.last, err = lastHash(files[i]); err != nil {
+			return nil, err
+		}
+	}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Record implements the Sink interface. It fills in the hashes
+// of the entry, appends it and syncs the file.
+func (l *Log) Record(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return os.ErrClosed
+	}
+	e.Time = e.Time.UTC()
+	e.Prev = l.last
+	h, err := hash(e)
+	if err != nil {
+		return err
+	}
+	e.Hash = h
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if l.opts.MaxSize > 0 && l.size > l.header && l.size+int64(len(line)) > l.opts.MaxSize {
+		if err := l.rotate(); err != nil {
+			return fmt.Errorf("rotating audit log: %w", err)
+		}
+	}
+	if _, err := l.f.Write(line); err != nil {
+		return err
+	}
+	if err := l.f.Sync(); err != nil {
+		return err
+	}
+	l.size += int64(len(line))
+	l.last = h
+	return nil
+}
+
+// Close closes the log.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return os.ErrClosed
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// open opens the file for appending. A new file starts with
+// a header holding the hash of the last entry.
+func (l *Log) open() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.size, l.header = info.Size(), 0
+	if l.size == 0 {
+		line, err := json.Marshal(header{Log: logName, Prev: l.last})
+		if err != nil {
+			f.Close()
+			return err
+		}
+		line = append(line, '\n')
+		if _, err := f.Write(line); err != nil {
+			f.Close()
+			return err
+		}
+		l.size, l.header = int64(len(line)), int64(len(line))
+	}
+	l.f = f
+	return nil
+}
+
+// rotate renames the file after the current time,
+// opens a new one and removes the oldest rotated files.
+func (l *Log) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	l.f = nil
+	rotated := l.path + "." + time.Now().UTC().Format(rotatedLayout)
+	if err := os.Rename(l.path, rotated); err != nil {
+		return err
+	}
+	if err := l.open(); err != nil {
+		return err
+	}
+	if l.opts.MaxFiles <= 0 {
+		return nil
+	}
+	files, err := Files(l.path)
+	if err != nil {
+		return err
+	}
+	old := files[:len(files)-1]
+	for len(old) > l.opts.MaxFiles {
+		last, err := lastHash(old[0])
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(old[0]); err != nil {
+			return err
+		}
+		if l.opts.Removed != nil {
+			l.opts.Removed(old[0], last)
+		}
+		old = old[1:]
+	}
+	return nil
+}
+
+// rotatedLayout is the time layout of the suffix of rotated
+// files, which sorts them in the order they were rotated.
+const rotatedLayout = "20060102T150405.000000000Z"
+
+// Files returns the files of the log at path, in order: the rotated
+// files, oldest first, then the file itself, if it exists.
+func Files(path string) ([]string, error) {
+	dir, base := filepath.Split(path)
+	entries, err := os.ReadDir(filepath.Clean(dir + "."))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	var files []string
+	current := false
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == base {
+			current = true
+			continue
+		}
+		suffix, ok := strings.CutPrefix(name, base+".")
+		if _, err := time.Parse(rotatedLayout, suffix); ok && err == nil {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(files)
+	if current {
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// lastHash returns the hash of the last entry in the file at path,
+// the hash in its header if it has none, or "" if it has neither.
+func lastHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	last := ""
+	err = scan(f, func(n int, h header) error {
+		last = h.Prev
+		return nil
+	}, func(n int, e Entry) error {
+		last = e.Hash
+		return nil
+	})
+	return last, err
+}
+
+// scan calls fh with the header read from r, if its first line is one,
+// and fe with every entry read from r, with their line numbers.
+func scan(r io.Reader, fh func(n int, h header) error, fe func(n int, e Entry) error) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	first := true
+	for n := 1; s.Scan(); n++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		if first {
+			first = false
+			var h header
+			if json.Unmarshal(s.Bytes(), &h) == nil && h.Log == logName {
+				if err := fh(n, h); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		var e Entry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			return &VerifyError{Line: n, Reason: "malformed entry: " + err.Error()}
+		}
+		if err := fe(n, e); err != nil {
+			return err
+		}
+	}
+	return s.Err()
+}
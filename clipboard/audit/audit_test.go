@@ -0,0 +1,213 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path, Options{})
+	require.NoError(t, err)
+	require.NoError(t, l.Record(Entry{Op: "copy", Size: 4, SHA256: ContentHash("text")}))
+	require.NoError(t, l.Record(Entry{Op: "paste", Size: 4, SHA256: ContentHash("text")}))
+	require.NoError(t, l.Close())
+	require.ErrorIs(t, l.Record(Entry{Op: "copy"}), os.ErrClosed)
+
+	l, err = Open(path, Options{})
+	require.NoError(t, err)
+	require.NoError(t, l.Record(Entry{Op: "clear"}))
+	require.NoError(t, l.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	if os.PathSeparator == '/' {
+		require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	}
+	n, err := VerifyFiles("", path)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+
+	lines := readLines(t, path)
+	require.Len(t, lines, 4)
+	require.Equal(t, `{"log":"clipboard-audit","prev":""}`, lines[0])
+	require.Contains(t, lines[1], `"op":"copy","selection":"","size":4,"sha256":"982d9e3eb996f559e633f4d194def3761d909f5a3b647d1a851fead67c32c9d1"`)
+	require.Contains(t, lines[1], `"prev":""`)
+	require.NotContains(t, lines[1], "text")
+}
+
+func TestVerify(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		tamper        func(lines []string) []string
+		expectedError error
+	}{
+		{
+			desc:   "untouched",
+			tamper: func(lines []string) []string { return lines },
+		},
+		{
+			desc: "edited entry",
+			tamper: func(lines []string) []string {
+				lines[2] = strings.Replace(lines[2], `"size":2`, `"size":3`, 1)
+				return lines
+			},
+			expectedError: errors.New("audit log has been tampered with: line 3: hash does not match the entry"),
+		},
+		{
+			desc: "removed entry",
+			tamper: func(lines []string) []string {
+				return append(lines[:2], lines[3:]...)
+			},
+			expectedError: errors.New("audit log has been tampered with: line 3: does not follow the previous entry"),
+		},
+		{
+			desc: "reordered entries",
+			tamper: func(lines []string) []string {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			expectedError: errors.New("audit log has been tampered with: line 2: does not start the chain"),
+		},
+		{
+			desc: "truncated entry",
+			tamper: func(lines []string) []string {
+				lines[3] = lines[3][:10]
+				return lines
+			},
+			expectedError: errors.New("audit log has been tampered with: line 4: malformed entry: unexpected end of JSON input"),
+		},
+		{
+			desc: "removed first entry",
+			tamper: func(lines []string) []string {
+				return append(lines[:1], lines[2:]...)
+			},
+			expectedError: errors.New("audit log has been tampered with: line 2: does not start the chain"),
+		},
+		{
+			desc: "removed header and first entries",
+			tamper: func(lines []string) []string {
+				return lines[3:]
+			},
+			expectedError: errors.New("audit log has been tampered with: line 1: does not start the chain"),
+		},
+		{
+			desc: "header following another file",
+			tamper: func(lines []string) []string {
+				lines[0] = strings.Replace(lines[0], `"prev":""`, `"prev":"9f86d0"`, 1)
+				return lines
+			},
+			expectedError: errors.New("audit log has been tampered with: line 1: does not follow the previous file"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			l, err := Open(path, Options{})
+			require.NoError(t, err)
+			for i := 1; i <= 3; i++ {
+				require.NoError(t, l.Record(Entry{Time: time.Now(), Op: "copy", Size: i}))
+			}
+			require.NoError(t, l.Close())
+
+			lines := tc.tamper(readLines(t, path))
+			_, _, err = Verify(strings.NewReader(strings.Join(lines, "\n")), "")
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+				require.ErrorIs(t, err, ErrTampered)
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+			}
+		})
+	}
+}
+
+func TestLog_rotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	l, err := Open(path, Options{MaxSize: 600})
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, l.Record(Entry{Op: "copy", Size: i}))
+	}
+	require.NoError(t, l.Close())
+
+	files, err := Files(path)
+	require.NoError(t, err)
+	require.Greater(t, len(files), 2)
+	require.Equal(t, path, files[len(files)-1])
+	for _, f := range files {
+		info, err := os.Stat(f)
+		require.NoError(t, err)
+		require.LessOrEqual(t, info.Size(), int64(600))
+	}
+	n, err := VerifyFiles("", files...)
+	require.NoError(t, err)
+	require.Equal(t, 10, n)
+
+	// A rotated file that goes missing breaks the chain, even the first one.
+	_, err = VerifyFiles("", append(files[:1:1], files[2:]...)...)
+	require.ErrorIs(t, err, ErrTampered)
+	require.Contains(t, err.Error(), files[2]+":1: does not follow the previous file")
+	_, err = VerifyFiles("", files[1:]...)
+	require.ErrorIs(t, err, ErrTampered)
+	require.Contains(t, err.Error(), files[1]+":1: does not follow the previous file")
+
+	// Reopening an empty log continues the chain of the last rotated
+	// file, which can't go missing even before an entry is recorded.
+	rotated := path + "." + time.Now().UTC().Format(rotatedLayout)
+	require.NoError(t, os.Rename(path, rotated))
+	l, err = Open(path, Options{MaxSize: 600})
+	require.NoError(t, err)
+	require.NoError(t, l.Close())
+	_, err = VerifyFiles("", append(files[:len(files)-1:len(files)-1], path)...)
+	require.ErrorIs(t, err, ErrTampered)
+	require.Contains(t, err.Error(), path+":1: does not follow the previous file")
+
+	// The files kept after others are removed are verified from the
+	// hash of the last entry of the last file removed.
+	var removed []string
+	last := ""
+	l, err = Open(path, Options{MaxSize: 600, MaxFiles: 2, Removed: func(path, hash string) {
+		removed = append(removed, path)
+		last = hash
+	}})
+	require.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, l.Record(Entry{Op: "paste", Size: i}))
+	}
+	require.NoError(t, l.Close())
+	files, err = Files(path)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	require.NotEmpty(t, removed)
+	require.NotContains(t, files, removed[len(removed)-1])
+	n, err = VerifyFiles(last, files...)
+	require.NoError(t, err)
+	require.Greater(t, n, 0)
+	_, err = VerifyFiles("", files...)
+	require.ErrorIs(t, err, ErrTampered)
+}
+
+// readLines returns the lines of the file at path.
+func readLines(t *testing.T, path string) []string {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Split(string(bytes.TrimSuffix(data, []byte("\n"))), "\n")
+}
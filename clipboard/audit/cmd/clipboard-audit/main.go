@@ -0,0 +1,52 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+// clipboard-audit checks the hash chain of a clipboard audit log,
+// including its rotated files, and exits with status 1 if it is broken:
+//
+//	clipboard-audit verify [-after hash] <path>
+//
+// The files must go back to the first entry ever written, unless -after
+// gives the hash they follow, e.g. the one passed to Options.Removed
+// for the last rotated file removed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/audit"
+)
+
+func main() {
+	const usage = "usage: clipboard-audit verify [-after hash] <path>"
+	if len(os.Args) < 2 || os.Args[1] != "verify" {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	fs.Usage = func() { fmt.Fprintln(os.Stderr, usage) }
+	after := fs.String("after", "", "hash of the entry the files follow")
+	fs.Parse(os.Args[2:])
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	path := fs.Arg(0)
+	files, err := audit.Files(path)
+	if err == nil && len(files) == 0 {
+		err = fmt.Errorf("%s: %w", path, os.ErrNotExist)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	n, err := audit.VerifyFiles(*after, files...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("%d entries in %d files verified\n", n, len(files))
+}
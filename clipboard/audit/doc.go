@@ -0,0 +1,18 @@
+// Package audit keeps a tamper-evident record of clipboard access.
+//
+// Entries are appended to a file as JSON lines. Each entry holds the hash
+// of the previous one, and its own hash covers that link, so that editing,
+// removing or reordering entries breaks the chain, which Verify detects:
+//
+//	{"log":"clipboard-audit","prev":""}
+//	{"time":"2023-10-19T10:00:00Z","op":"copy",...,"prev":"","hash":"9f86d0..."}
+//	{"time":"2023-10-19T10:00:05Z","op":"paste",...,"prev":"9f86d0...","hash":"60303a..."}
+//
+// The first entry ever written has an empty prev, so removing the first
+// entries is detected too. Every file starts with a header holding the
+// hash of the last entry of the previous file, which detects a rotated
+// file that went missing, even before the next file has any entries.
+//
+// Entries record what was copied or pasted, but not the content itself:
+// only its size and SHA-256 hash.
+package audit
@@ -0,0 +1,99 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrTampered is wrapped by the errors of Verify and
+// VerifyFiles when the hash chain is broken.
+var ErrTampered = errors.New("audit log has been tampered with")
+
+// VerifyError reports where the hash chain is broken.
+type VerifyError struct {
+	File   string // File holding the entry, if known
+	Line   int    // Line of the entry
+	Reason string // What is wrong with the entry
+}
+
+// Error implements the error interface.
+func (e *VerifyError) Error() string {
+	where := fmt.Sprintf("line %d", e.Line)
+	if e.File != "" {
+		where = e.File + ":" + fmt.Sprint(e.Line)
+	}
+	return fmt.Sprintf("%v: %s: %s", ErrTampered, where, e.Reason)
+}
+
+// Unwrap returns ErrTampered.
+func (e *VerifyError) Unwrap() error {
+	return ErrTampered
+}
+
+// Verify checks the hash chain of the entries read from r, e.g. a file
+// of a Log. The entries, and the header of the file if it has one, must
+// follow the entry whose hash is prev: if prev is empty, the first entry
+// must be the first one ever written, which starts the chain. It returns
+// the hash of the last entry, which is prev if there is none, and the
+// number of entries.
+func Verify(r io.Reader, prev string) (string, int, error) {
+	count := 0
+	err := scan(r, func(n int, h header) error {
+		if h.Prev != prev {
+			return &VerifyError{Line: n, Reason: "does not follow the previous file"}
+		}
+		return nil
+	}, func(n int, e Entry) error {
+		if e.Prev != prev {
+			reason := "does not follow the previous entry"
+			if count == 0 && prev == "" {
+				reason = "does not start the chain"
+			}
+			return &VerifyError{Line: n, Reason: reason}
+		}
+		h, err := hash(e)
+		if err != nil {
+			return err
+		}
+		if h != e.Hash {
+			return &VerifyError{Line: n, Reason: "hash does not match the entry"}
+		}
+		prev = e.Hash
+		count++
+		return nil
+	})
+	return prev, count, err
+}
+
+// VerifyFiles checks the hash chain across the given files, in order,
+// e.g. the files returned by Files, from the entry whose hash is prev:
+// empty if the files go back to the first entry ever written, or the
+// hash passed to Options.Removed for the last file removed. It returns
+// the number of entries.
+func VerifyFiles(prev string, paths ...string) (int, error) {
+	total := 0
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return total, err
+		}
+		last, n, err := Verify(f, prev)
+		f.Close()
+		total += n
+		var verifyErr *VerifyError
+		if errors.As(err, &verifyErr) {
+			verifyErr.File = path
+		}
+		if err != nil {
+			return total, err
+		}
+		prev = last
+	}
+	return total, nil
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard/audit"
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+)
+
+func TestClipboard_audit(t *testing.T) {
+	fakeTools(t)
+	sink := &mockSink{}
+	c := newTestClipboard(t, ClipboardOptions{
+		CopyCmd:  "mycopy",
+		PasteCmd: "mypaste",
+		Policies: []Policy{Detect(Deny, AWSKeys)},
+		Runner: mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+			return &mockCommand{Output: "pasted"}
+		}),
+		Audit: sink,
+	})
+	require.NoError(t, c.CopyText("some text"))
+	text, err := c.PasteText()
+	require.NoError(t, err)
+	require.Equal(t, "pasted", text)
+	require.NoError(t, c.Clear())
+	require.ErrorIs(t, c.CopyText(awsKeyID), ErrDenied)
+	_, _, err = PasteTextSafe(c, SafetyPolicy{})
+	require.NoError(t, err)
+
+	require.Len(t, sink.entries, 5)
+	for _, e := range sink.entries {
+		require.False(t, e.Time.IsZero())
+		require.Equal(t, "clipboard", e.Selection)
+		require.Equal(t, "text/plain;charset=utf-8", e.MIME)
+		require.Equal(t, os.Getpid(), e.PID)
+		require.NotEmpty(t, e.Process)
+		require.Contains(t, e.Caller, "clipboard.TestClipboard_audit (")
+		require.Contains(t, e.Caller, filepath.Join("clipboard", "audit_test.go"))
+	}
+	expected := []audit.Entry{
+		{Op: "copy", Size: 9, SHA256: audit.ContentHash("some text"), Backend: "mycopy", Result: "ok"},
+		{Op: "paste", Size: 6, SHA256: audit.ContentHash("pasted"), Backend: "mycopy", Result: "ok"},
+		{Op: "clear", Backend: "mycopy", Result: "ok"},
+		{Op: "copy", Result: "permission denied"},
+		{Op: "paste", Size: 6, SHA256: audit.ContentHash("pasted"), Backend: "mycopy", Result: "ok"},
+	}
+	for i, e := range sink.entries {
+		e.Time, e.PID, e.Process, e.Caller, e.Selection, e.MIME = expected[i].Time, 0, "", "", "", ""
+		require.Equal(t, expected[i], e)
+	}
+
+	sink.err = errors.New("disk full")
+	require.EqualError(t, c.CopyText("some text"), "recording audit entry: disk full")
+	text, err = c.PasteText()
+	require.EqualError(t, err, "recording audit entry: disk full")
+	require.Empty(t, text)
+}
+
+// mockSink is an audit.Sink keeping the entries in memory.
+type mockSink struct {
+	mu      sync.Mutex
+	entries []audit.Entry
+	err     error
+}
+
+// Record implements the audit.Sink interface.
+func (s *mockSink) Record(e audit.Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.entries = append(s.entries, e)
+	return nil
+}
@@ -0,0 +1,542 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/charset"
+	"github.com/tiagomelo/go-clipboard/clipboard/clipboardtool"
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+	"github.com/tiagomelo/go-clipboard/clipboard/plugin"
+)
+
+// Backend is a way of reaching the system clipboard, such as a pair
+// of clipboard tools or a backend plugin. A Clipboard tries its
+// backends in turn until one of them succeeds.
+type Backend interface {
+	// Name returns the name of the backend, e.g. "xsel".
+	Name() string
+
+	// Capabilities reports what the backend can do.
+	Capabilities() Capabilities
+
+	// CopyText copies s to the clipboard.
+	CopyText(s string) error
+
+	// PasteText returns the text held by the clipboard.
+	PasteText() (string, error)
+
+	// Clear empties the clipboard.
+	Clear() error
+}
+
+// TypeLister is implemented by backends that can list
+// the types of content held by the clipboard.
+type TypeLister interface {
+	// Types returns the MIME types or targets held by the clipboard.
+	Types() ([]string, error)
+}
+
+// Watcher is implemented by backends that can watch the clipboard.
+type Watcher interface {
+	// Watch returns a channel receiving the text of the clipboard every
+	// time it changes, until ctx is done, when the channel is closed.
+	Watch(ctx context.Context) (<-chan string, error)
+}
+
+// DataPaster is implemented by backends that can paste
+// the content of any target as is, e.g. an image.
+type DataPaster interface {
+	// PasteData returns the content of the clipboard as target.
+	PasteData(target string) ([]byte, error)
+}
+
+// DataCopier is implemented by backends that can copy
+// content as any target, as is, e.g. a list of files.
+type DataCopier interface {
+	// CopyData copies data to the clipboard as target.
+	CopyData(target string, data []byte) error
+}
+
+// ItemCopier is implemented by backends that can copy the content of
+// several targets together, so that the clipboard offers all of them.
+type ItemCopier interface {
+	// CopyItems copies items to the clipboard, replacing its content.
+	CopyItems(items []Item) error
+}
+
+// NewPluginBackend returns the backend of the plugin with the given name,
+// found in the PATH, e.g. "file" for go-clipboard-backend-file. The plugin
+// uses the selection and target set in opts, and is found and run with
+// its runner, like the tools. It implements TypeLister, Watcher,
+// DataPaster, DataCopier and ItemCopier.
+func NewPluginBackend(name string, opts ClipboardOptions) (Backend, error) {
+	var registry plugin.Registry
+	info, ok := registry.Lookup(name)
+	if ok {
+		plugins := runnablePlugins(command.OrDefault(opts.Runner), []plugin.Info{info})
+		if ok = len(plugins) == 1; ok {
+			info = plugins[0]
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("plugin %s: %w", name, exec.ErrNotFound)
+	}
+	return &pluginBackend{opts: &opts, info: info}, nil
+}
+
+// runnablePlugins returns the plugins in infos that the runner finds
+// and can run, at the path it finds them at, so that plugins go through
+// the same checks as the tools, e.g. those of command.HardenedRunner.
+// Runners that don't implement command.Execer can't run any.
+func runnablePlugins(runner command.Runner, infos []plugin.Info) []plugin.Info {
+	if _, ok := runner.(command.Execer); !ok {
+		return nil
+	}
+	var plugins []plugin.Info
+	for _, info := range infos {
+		path, err := runner.LookPath(filepath.Base(info.Path))
+		if err != nil {
+			continue
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		plugins = append(plugins, plugin.Info{Name: info.Name, Path: path})
+	}
+	return plugins
+}
+
+// toolBackend is a Backend running a pair of clipboard tools.
+type toolBackend struct {
+	opts   *ClipboardOptions
+	ct     *clipboardtool.ClipboardTool
+	owners *owners
+}
+
+// Name implements the Backend interface.
+func (b *toolBackend) Name() string {
+	return b.ct.CopyTool.Name
+}
+
+// Capabilities implements the Backend interface.
+func (b *toolBackend) Capabilities() Capabilities {
+	return capabilities(b.ct.Capabilities())
+}
+
+// CopyText implements the Backend interface. When the copies are
+// supervised, the copy tool is left running in the foreground to serve
+// the selection, where supported, replacing the previous one. The text
+// is converted to the charset the copy tool reads, e.g. UTF-16 for
+// clip.exe.
+func (b *toolBackend) CopyText(s string) error {
+	data, err := charset.Encode(s, b.ct.CopyTool.Charset)
+	if err != nil {
+		return err
+	}
+	if b.ct.CopyTool.BOM {
+		data = append(charset.BOM(b.ct.CopyTool.Charset), data...)
+	}
+	return b.copy(b.copyArgs(), string(data), b.serving(string(data)))
+}
+
+// CopyData implements the DataCopier interface, copying
+// data as the target with the copy tool, if it can.
+func (b *toolBackend) CopyData(target string, data []byte) error {
+	ct := b.ct.CopyTool
+	if ct.TargetArg == "" {
+		return unsupported(b, "copying "+target)
+	}
+	var ready func() bool
+	if pt := b.ct.PasteTool; pt.TargetArg != "" {
+		ready = b.serving(string(data), pt.TargetArg, target)
+	}
+	return b.copy(append(b.copyArgs(), ct.TargetArg, target), string(data), ready)
+}
+
+// copy runs the copy tool with args and s as input, leaving it running
+// in the foreground when the copies are supervised, until ready
+// reports that it serves the selection.
+func (b *toolBackend) copy(args []string, s string, ready func() bool) error {
+	if b.supervised() {
+		args := append(args, b.ct.CopyTool.ForegroundArgs...)
+		if c, ok := b.command(b.ct.CopyTool.Executable(), args...).(command.Starter); ok {
+			p, err := c.StartInput(s, ready)
+			if err != nil {
+				return err
+			}
+			return b.owners.replace(p)
+		}
+	}
+	return b.command(b.ct.CopyTool.Executable(), args...).TextInput(s)
+}
+
+// PasteText implements the Backend interface. The pasted text is
+// converted to valid UTF-8 according to the requested target, with
+// LF line endings if the paste tool writes CRLF ones.
+func (b *toolBackend) PasteText() (string, error) {
+	out, err := b.command(b.ct.PasteTool.Executable(), b.pasteArgs()...).TextOutput()
+	if err != nil {
+		return "", err
+	}
+	text, err := b.decodeText(out)
+	if err != nil || !b.ct.PasteTool.CRLF {
+		return text, err
+	}
+	return strings.ReplaceAll(strings.TrimSuffix(text, "\r\n"), "\r\n", "\n"), nil
+}
+
+// Types implements the TypeLister interface, running the paste tool
+// with the arguments that make it list the targets, if it can, e.g.
+// "wl-paste --list-types".
+func (b *toolBackend) Types() ([]string, error) {
+	pt := b.ct.PasteTool
+	if pt.TypesArgs == nil {
+		return nil, unsupported(b, "listing types")
+	}
+	out, err := b.command(pt.Executable(), pt.TypesArgs...).TextOutput()
+	if err != nil {
+		return nil, err
+	}
+	types := []string{}
+	for _, line := range strings.Split(out, "\n") {
+		if typ := strings.TrimSpace(line); typ != "" {
+			types = append(types, typ)
+		}
+	}
+	return types, nil
+}
+
+// Watch implements the Watcher interface, running the paste tool with the
+// arguments that make it report changes, if it can, e.g. "wl-paste --watch
+// echo", and pasting the text after each of them. Changes whose text can't
+// be pasted, e.g. because the clipboard was cleared, are skipped. The
+// paste tool keeps running until ctx is done.
+func (b *toolBackend) Watch(ctx context.Context) (<-chan string, error) {
+	pt := b.ct.PasteTool
+	if pt.WatchArgs == nil {
+		return nil, unsupported(b, "watching")
+	}
+	s, ok := b.runner().Command(ctx, pt.Executable(), pt.WatchArgs...).(command.OutputStarter)
+	if !ok {
+		return nil, unsupported(b, "watching")
+	}
+	out, p, err := s.StartOutput()
+	if err != nil {
+		return nil, err
+	}
+	texts := make(chan string)
+	go func() {
+		defer close(texts)
+		defer out.Close()
+		defer p.Stop()
+		changes := bufio.NewScanner(out)
+		for changes.Scan() {
+			text, err := b.PasteText()
+			if err != nil {
+				continue
+			}
+			select {
+			case texts <- text:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return texts, nil
+}
+
+// PasteData implements the DataPaster interface, requesting
+// the target from the paste tool, if it can.
+func (b *toolBackend) PasteData(target string) ([]byte, error) {
+	pt := b.ct.PasteTool
+	if pt.TargetArg == "" {
+		return nil, unsupported(b, "pasting "+target)
+	}
+	args := append([]string{}, pt.CmdArgs...)
+	out, err := b.command(pt.Executable(), append(args, pt.TargetArg, target)...).TextOutput()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
+
+// Clear implements the Backend interface. It runs the copy tool with the
+// arguments that make it clear the clipboard, and empty input, e.g.
+// "wl-copy --clear" or "xsel --clear". When the copies are supervised,
+// the tool serving the selection is stopped once it is cleared.
+func (b *toolBackend) Clear() error {
+	if b.supervised() && b.ct.CopyTool.ClearArgs == nil {
+		return b.CopyText("")
+	}
+	if err := b.command(b.ct.CopyTool.Executable(), b.ct.CopyTool.ClearCmdArgs()...).TextInput(""); err != nil {
+		return err
+	}
+	if b.owners == nil {
+		return nil
+	}
+	return b.owners.release()
+}
+
+// serving returns a function reporting whether the paste tool, run
+// with args, pastes s as is, i.e. whether a copy of s is served.
+func (b *toolBackend) serving(s string, args ...string) func() bool {
+	pt := b.ct.PasteTool
+	return func() bool {
+		out, err := b.command(pt.Executable(), append(append([]string{}, pt.CmdArgs...), args...)...).TextOutput()
+		return err == nil && out == s
+	}
+}
+
+// supervised reports whether copies are served by a supervised copy
+// tool left running in the foreground. One-shot copies aren't: checking
+// that the tool serves them would use up their only paste, and the tool
+// exits after it anyway.
+func (b *toolBackend) supervised() bool {
+	ct := b.ct.CopyTool
+	oneShot := b.opts.OneShot && ct.OneShotArgs != nil
+	return b.opts.Supervise && b.owners != nil && ct.ForegroundArgs != nil && !oneShot
+}
+
+// command returns the command running name with args.
+func (b *toolBackend) command(name string, args ...string) command.Command {
+	return b.runner().Command(context.Background(), name, args...)
+}
+
+// runner returns the runner of the tools.
+func (b *toolBackend) runner() command.Runner {
+	return command.OrDefault(b.opts.Runner)
+}
+
+// copyArgs returns the arguments for the copy tool,
+// serving a single paste when requested and supported.
+func (b *toolBackend) copyArgs() []string {
+	ct := b.ct.CopyTool
+	if !b.opts.OneShot || ct.OneShotArgs == nil {
+		return append([]string{}, ct.CmdArgs...)
+	}
+	args := append([]string{}, ct.CmdArgs...)
+	return append(args, ct.OneShotArgs...)
+}
+
+// pasteArgs returns the arguments for the paste tool,
+// requesting the configured target when the tool supports it.
+func (b *toolBackend) pasteArgs() []string {
+	pt := b.ct.PasteTool
+	if b.opts.Target == "" || pt.TargetArg == "" {
+		return pt.CmdArgs
+	}
+	args := append([]string{}, pt.CmdArgs...)
+	return append(args, pt.TargetArg, b.opts.Target)
+}
+
+// decodeText converts the output of the paste tool to valid UTF-8.
+// The configured target is only trusted when the tools could request it.
+func (b *toolBackend) decodeText(out string) (string, error) {
+	target := ""
+	if b.ct.Capabilities().Types {
+		target = b.opts.Target
+	}
+	return charset.Decode([]byte(out), target, b.opts.StrictCharset)
+}
+
+// pluginBackend is a Backend talking to a plugin. The plugin
+// is started for each operation and stopped right after.
+type pluginBackend struct {
+	opts    *ClipboardOptions
+	info    plugin.Info
+	oneShot bool // Copy for a single paste
+
+	capsOnce sync.Once
+	caps     Capabilities
+}
+
+// Name implements the Backend interface.
+func (b *pluginBackend) Name() string {
+	return b.info.Name
+}
+
+// Capabilities implements the Backend interface. They are asked
+// once; a plugin that can't be started has no capabilities.
+func (b *pluginBackend) Capabilities() Capabilities {
+	b.capsOnce.Do(func() {
+		b.run(func(c *plugin.Client) error {
+			caps, err := c.Capabilities()
+			if err == nil {
+				b.caps = pluginCapabilities(caps)
+			}
+			return err
+		})
+	})
+	return b.caps
+}
+
+// CopyText implements the Backend interface.
+func (b *pluginBackend) CopyText(s string) error {
+	return b.run(func(c *plugin.Client) error {
+		return copyPlugin(c, b.selection(), "", s, b.oneShot || b.opts.OneShot)
+	})
+}
+
+// PasteText implements the Backend interface.
+// The configured target is requested as the MIME type.
+func (b *pluginBackend) PasteText() (string, error) {
+	var text string
+	err := b.run(func(c *plugin.Client) error {
+		var err error
+		text, err = c.Paste(b.selection(), b.opts.Target)
+		return err
+	})
+	return text, err
+}
+
+// Clear implements the Backend interface.
+func (b *pluginBackend) Clear() error {
+	return b.run(func(c *plugin.Client) error {
+		return c.Clear(b.selection())
+	})
+}
+
+// Types implements the TypeLister interface.
+func (b *pluginBackend) Types() ([]string, error) {
+	var types []string
+	err := b.run(func(c *plugin.Client) error {
+		var err error
+		types, err = c.Types(b.selection())
+		return err
+	})
+	return types, err
+}
+
+// PasteData implements the DataPaster interface.
+// The target is requested as the MIME type.
+func (b *pluginBackend) PasteData(target string) ([]byte, error) {
+	var data []byte
+	err := b.run(func(c *plugin.Client) error {
+		text, err := c.Paste(b.selection(), target)
+		data = []byte(text)
+		return err
+	})
+	return data, err
+}
+
+// CopyData implements the DataCopier interface. The target is
+// sent as the MIME type, with data as is if the plugin reports the
+// items capability, or as text otherwise.
+func (b *pluginBackend) CopyData(target string, data []byte) error {
+	if b.Capabilities().Items {
+		return b.CopyItems([]Item{{Target: target, Data: data}})
+	}
+	return b.run(func(c *plugin.Client) error {
+		return copyPlugin(c, b.selection(), target, string(data), b.oneShot || b.opts.OneShot)
+	})
+}
+
+// CopyItems implements the ItemCopier interface, if the
+// plugin reports that it can copy several types together.
+func (b *pluginBackend) CopyItems(items []Item) error {
+	if !b.Capabilities().Items {
+		return unsupported(b, "copying items")
+	}
+	pluginItems := make([]plugin.Item, len(items))
+	for i, item := range items {
+		pluginItems[i] = plugin.Item{Type: item.Target, Data: item.Data}
+	}
+	return b.run(func(c *plugin.Client) error {
+		if b.oneShot || b.opts.OneShot {
+			return c.CopyItemsOneShot(b.selection(), pluginItems)
+		}
+		return c.CopyItems(b.selection(), pluginItems)
+	})
+}
+
+// Watch implements the Watcher interface. The plugin
+// keeps running until ctx is done or it stops.
+func (b *pluginBackend) Watch(ctx context.Context) (<-chan string, error) {
+	c, err := plugin.Start(b.info.Path, b.pluginOptions())
+	if err != nil {
+		return nil, err
+	}
+	events, err := c.Watch(ctx, b.selection())
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	texts := make(chan string)
+	go func() {
+		defer close(texts)
+		defer c.Close()
+		for event := range events {
+			select {
+			case texts <- event.Text:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return texts, nil
+}
+
+// pluginOptions returns the options the plugin is started
+// with: it is run through the runner.
+func (b *pluginBackend) pluginOptions() plugin.Options {
+	opts := plugin.Options{Timeout: b.opts.PluginTimeout}
+	if e, ok := command.OrDefault(b.opts.Runner).(command.Execer); ok {
+		opts.Command = func(path string) (*exec.Cmd, error) {
+			return e.Cmd(context.Background(), path)
+		}
+	}
+	return opts
+}
+
+// run starts the plugin, calls f with it, and stops it.
+func (b *pluginBackend) run(f func(c *plugin.Client) error) error {
+	c, err := plugin.Start(b.info.Path, b.pluginOptions())
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return f(c)
+}
+
+// selection returns the selection to use.
+func (b *pluginBackend) selection() string {
+	if b.opts.Primary {
+		return string(SelectionPrimary)
+	}
+	return string(SelectionClipboard)
+}
+
+// copyPlugin copies text as the given MIME type to the selection
+// of a plugin or of the daemon, for a single paste if oneShot.
+func copyPlugin(c *plugin.Client, selection, typ, text string, oneShot bool) error {
+	if oneShot {
+		return c.CopyOneShot(selection, typ, text)
+	}
+	return c.Copy(selection, typ, text)
+}
+
+// pluginCapabilities converts the capabilities of a plugin.
+func pluginCapabilities(caps plugin.Capabilities) Capabilities {
+	selections := make([]Selection, len(caps.Selections))
+	for i, s := range caps.Selections {
+		selections[i] = Selection(s)
+	}
+	return Capabilities{
+		Selections: selections,
+		Types:      caps.Types,
+		Watch:      caps.Watch,
+		Clear:      caps.Clear,
+		Items:      caps.Items,
+		OneShot:    caps.OneShot,
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard/clipboardtest/fakebin"
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+	"github.com/tiagomelo/go-clipboard/clipboard/plugin"
+)
+
+// pluginDirEnv makes the test binary run as the reference plugin,
+// keeping its selections in the named directory.
+const pluginDirEnv = "GO_CLIPBOARD_TEST_PLUGIN_DIR"
+
+// TestMain lets the test binary run as a plugin or, when it is named
+// after one of them, as a fake clipboard tool, so that tests can run
+// real processes without a display.
+func TestMain(m *testing.M) {
+	fakebin.Main()
+	if dir := os.Getenv(pluginDirEnv); dir != "" {
+		if err := plugin.Serve(os.Stdin, os.Stdout, &plugin.FileHandler{Dir: dir}); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestPluginBackend(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		opts           ClipboardOptions
+		withTools      bool
+		run            func(c Clipboard) (string, error)
+		expectedOutput string
+		expectedError  error
+	}{
+		{
+			desc: "round trip without tools",
+			run: func(c Clipboard) (string, error) {
+				if err := c.CopyText("héllo\r\nwörld"); err != nil {
+					return "", err
+				}
+				return c.PasteText()
+			},
+			expectedOutput: "héllo\r\nwörld",
+		},
+		{
+			desc:      "plugin named in the tools",
+			opts:      ClipboardOptions{Tools: []string{"file"}},
+			withTools: true,
+			run: func(c Clipboard) (string, error) {
+				if err := c.CopyText("some text"); err != nil {
+					return "", err
+				}
+				return c.PasteText()
+			},
+			expectedOutput: "some text",
+		},
+		{
+			desc: "clear",
+			run: func(c Clipboard) (string, error) {
+				if err := c.CopyText("some text"); err != nil {
+					return "", err
+				}
+				if err := c.Clear(); err != nil {
+					return "", err
+				}
+				return c.PasteText()
+			},
+			expectedError: errors.New("plugin file: paste: failed: nothing was copied as text/plain;charset=utf-8"),
+		},
+		{
+			desc: "requested target",
+			opts: ClipboardOptions{Target: "text/html"},
+			run: func(c Clipboard) (string, error) {
+				if err := c.CopyText("some text"); err != nil {
+					return "", err
+				}
+				return c.PasteText()
+			},
+			expectedError: errors.New("plugin file: paste: failed: nothing was copied as text/html"),
+		},
+		{
+			desc:          "strict mode with options the plugin can't honor",
+			opts:          ClipboardOptions{Strict: true, OneShot: true},
+			expectedError: ErrUnsupported,
+		},
+		{
+			desc:      "plugin that isn't installed",
+			opts:      ClipboardOptions{Tools: []string{"memory"}},
+			withTools: true,
+			run: func(c Clipboard) (string, error) {
+				return "", c.CopyText("some text")
+			},
+			expectedError: errors.New("none of the requested clipboard utilities is available"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := tc.opts
+			if tc.withTools {
+				fakeTools(t)
+				opts.Runner = mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+					t.Fatalf("%s should not run", cmdName)
+					return nil
+				})
+			}
+			dir := t.TempDir()
+			pluginExecutable(t, dir, "file")
+			if tc.withTools {
+				dir = os.Getenv("PATH") + string(os.PathListSeparator) + dir
+			}
+			t.Setenv("PATH", dir)
+			t.Setenv(pluginDirEnv, t.TempDir())
+
+			c, err := New(opts)
+			var output string
+			if err == nil {
+				output, err = tc.run(c)
+			}
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				if errors.Is(tc.expectedError, ErrUnsupported) {
+					require.ErrorIs(t, err, ErrUnsupported)
+					return
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+				require.Equal(t, tc.expectedOutput, output)
+			}
+		})
+	}
+}
+
+func TestPluginBackend_selections(t *testing.T) {
+	dir := t.TempDir()
+	pluginExecutable(t, dir, "file")
+	t.Setenv("PATH", dir)
+	t.Setenv(pluginDirEnv, t.TempDir())
+
+	clipboard := newTestClipboard(t)
+	primary := newTestClipboard(t, ClipboardOptions{Primary: true})
+	require.NoError(t, clipboard.CopyText("clipboard text"))
+	require.NoError(t, primary.CopyText("primary text"))
+	text, err := clipboard.PasteText()
+	require.NoError(t, err)
+	require.Equal(t, "clipboard text", text)
+	text, err = primary.PasteText()
+	require.NoError(t, err)
+	require.Equal(t, "primary text", text)
+
+	require.Equal(t, Capabilities{
+		Selections: []Selection{SelectionClipboard, SelectionPrimary},
+		Types:      true,
+		Watch:      true,
+		Clear:      true,
+		Items:      true,
+	}, clipboard.Capabilities())
+}
+
+func TestPluginBackend_Snapshot(t *testing.T) {
+	dir := privateDir(t)
+	pluginExecutable(t, dir, "file")
+	t.Setenv("PATH", dir)
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	t.Setenv(pluginDirEnv, t.TempDir())
+
+	items := []Item{
+		{Target: "image/png", Data: []byte("\x89PNG\r\n\x1a\n\x00\xff")},
+		{Target: "text/plain;charset=utf-8", Data: []byte("a picture")},
+	}
+	b, err := NewPluginBackend("file", ClipboardOptions{})
+	require.NoError(t, err)
+	require.NoError(t, b.(ItemCopier).CopyItems(items))
+	c := newTestClipboard(t)
+	s, err := c.Snapshot()
+	require.NoError(t, err)
+	require.Equal(t, items, s.Selections[SelectionClipboard])
+	require.Empty(t, s.Selections[SelectionPrimary])
+
+	require.NoError(t, c.CopyText("temporary"))
+	require.NoError(t, newTestClipboard(t, ClipboardOptions{Primary: true}).CopyText("temporary"))
+	require.NoError(t, c.Restore(s))
+	types, err := c.Types()
+	require.NoError(t, err)
+	require.Equal(t, []string{"image/png", "text/plain;charset=utf-8"}, types)
+	data, err := b.(DataPaster).PasteData("image/png")
+	require.NoError(t, err)
+	require.Equal(t, items[0].Data, data)
+	text, err := c.PasteText()
+	require.NoError(t, err)
+	require.Equal(t, "a picture", text)
+	primary, err := NewPluginBackend("file", ClipboardOptions{Primary: true})
+	require.NoError(t, err)
+	types, err = primary.(TypeLister).Types()
+	require.NoError(t, err)
+	require.Empty(t, types)
+}
+
+func TestPluginBackend_files(t *testing.T) {
+	dir := privateDir(t)
+	pluginExecutable(t, dir, "file")
+	t.Setenv("PATH", dir)
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	t.Setenv(pluginDirEnv, t.TempDir())
+
+	paths := []string{filepath.Join(dir, "notes.txt"), filepath.Join(dir, "héllo wörld.png")}
+	c := newTestClipboard(t)
+	require.NoError(t, c.CopyFiles(paths, OperationCut))
+	types, err := c.Types()
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{GnomeCopiedFilesTarget, URIListTarget, "text/plain;charset=utf-8", kdeCutTarget}, types)
+	text, err := c.PasteText()
+	require.NoError(t, err)
+	require.Equal(t, paths[0]+"\n"+paths[1], text)
+	data, err := c.pasteData(URIListTarget)
+	require.NoError(t, err)
+	require.Equal(t, fileURI(paths[0])+"\r\n"+fileURI(paths[1])+"\r\n", string(data))
+	pasted, op, err := c.PasteFiles()
+	require.NoError(t, err)
+	require.Equal(t, paths, pasted)
+	require.Equal(t, OperationCut, op)
+}
+
+// pluginExecutable copies the test binary into dir as the plugin
+// with the given name.
+func pluginExecutable(t *testing.T, dir, name string) {
+	data, err := os.ReadFile(os.Args[0])
+	require.NoError(t, err)
+	path := filepath.Join(dir, plugin.Prefix+name)
+	if runtime.GOOS == "windows" {
+		path += ".exe"
+	}
+	require.NoError(t, os.WriteFile(path, data, 0o755))
+}
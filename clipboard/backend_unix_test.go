@@ -0,0 +1,82 @@
+//go:build freebsd || linux || netbsd || openbsd || solaris || dragonfly
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+)
+
+func TestPluginBackend_hardenedRunner(t *testing.T) {
+	dir := t.TempDir()
+	pluginExecutable(t, dir, "file")
+	t.Setenv("PATH", dir)
+
+	testCases := []struct {
+		desc           string
+		runner         command.Runner
+		expectedOutput string
+		expectedError  error
+	}{
+		{
+			desc:           "plugin in a trusted directory",
+			runner:         command.HardenedRunner{Dirs: []string{dir}, Env: []string{pluginDirEnv}},
+			expectedOutput: "some text",
+		},
+		{
+			desc:          "plugin in the PATH only",
+			runner:        command.HardenedRunner{Dirs: []string{t.TempDir()}, Env: []string{pluginDirEnv}},
+			expectedError: errors.New("no clipboard utilities available"),
+		},
+		{
+			desc:          "runner that can't run plugins",
+			runner:        loggingRunner{command.ExecRunner{}},
+			expectedError: errors.New("no clipboard utilities available"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			selections := t.TempDir()
+			t.Setenv(pluginDirEnv, selections)
+			opts := ClipboardOptions{Tools: []string{"file"}, Runner: tc.runner}
+			_, err := NewPluginBackend("file", opts)
+			require.Equal(t, tc.expectedError == nil, err == nil, "%v", err)
+
+			c := newTestClipboard(t, opts)
+			var output string
+			err = c.CopyText("some text")
+			if err == nil {
+				output, err = c.PasteText()
+			}
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+				require.Equal(t, Capabilities{}, c.Capabilities())
+				// The plugin never ran, not even to report its capabilities.
+				entries, err := os.ReadDir(selections)
+				require.NoError(t, err)
+				require.Empty(t, entries)
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+				require.Equal(t, tc.expectedOutput, output)
+			}
+		})
+	}
+}
+
+// loggingRunner wraps a Runner without implementing command.Execer.
+type loggingRunner struct {
+	command.Runner
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"github.com/tiagomelo/go-clipboard/clipboard/clipboardtool"
+)
+
+// Selection identifies one of the clipboards of the system.
+type Selection string
+
+const (
+	// SelectionClipboard is the regular clipboard.
+	SelectionClipboard Selection = "clipboard"
+	// SelectionPrimary is the X11 and Wayland primary selection,
+	// which holds the most recently selected text.
+	SelectionPrimary Selection = "primary"
+)
+
+// ErrUnsupported is returned by New, in strict mode, when none of the
+// available clipboard tools can honor the requested options.
+var ErrUnsupported = clipboardtool.ErrUnsupported
+
+// Capabilities describes what a Clipboard can do with the
+// clipboard tools it uses.
+type Capabilities struct {
+	Selections []Selection // Selections that can be used
+	Types      bool        // Specific targets or MIME types can be requested
+	Watch      bool        // Clipboard changes can be watched
+	Clear      bool        // The clipboard can be emptied
+	OneShot    bool        // A copy can be served for a single paste
+	Streaming  bool        // Content is piped through the tools
+	Items      bool        // Several targets can be copied together
+}
+
+// capabilities converts the capabilities of a pair of clipboard tools.
+func capabilities(caps clipboardtool.Capabilities) Capabilities {
+	var selections []Selection
+	if caps.Copy || caps.Paste {
+		selections = append(selections, SelectionClipboard)
+		if caps.Primary {
+			selections = append(selections, SelectionPrimary)
+		}
+	}
+	return Capabilities{
+		Selections: selections,
+		Types:      caps.Types,
+		Watch:      caps.Watch,
+		Clear:      caps.Clear,
+		OneShot:    caps.OneShot,
+		Streaming:  caps.Streaming,
+	}
+}
+
+// required returns the capabilities the tools need to honor opts.
+func required(opts ClipboardOptions) clipboardtool.Capabilities {
+	return clipboardtool.Capabilities{
+		Primary: opts.Primary,
+		Types:   opts.Target != "",
+		OneShot: opts.OneShot,
+	}
+}
+
+// honors reports whether a backend with the given capabilities can honor opts.
+func honors(caps Capabilities, opts ClipboardOptions) bool {
+	primary := false
+	for _, s := range caps.Selections {
+		primary = primary || s == SelectionPrimary
+	}
+	return len(caps.Selections) > 0 &&
+		(primary || !opts.Primary) &&
+		(caps.Types || opts.Target == "") &&
+		(caps.OneShot || !opts.OneShot)
+}
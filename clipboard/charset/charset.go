@@ -0,0 +1,345 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package charset
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Names of the character sets understood by Decode.
+const (
+	UTF8        = "utf-8"
+	ASCII       = "us-ascii"
+	Latin1      = "iso-8859-1"
+	Windows1252 = "windows-1252"
+	UTF16       = "utf-16"
+	UTF16LE     = "utf-16le"
+	UTF16BE     = "utf-16be"
+)
+
+// ErrUnsupported is returned in strict mode when the target
+// names a character set that cannot be converted.
+var ErrUnsupported = errors.New("unsupported charset")
+
+// InvalidError reports an invalid byte sequence found while
+// decoding text in strict mode.
+type InvalidError struct {
+	Charset string // Charset the text was decoded as
+	Offset  int    // Offset of the first invalid byte
+}
+
+// Error implements the error interface.
+func (e *InvalidError) Error() string {
+	return fmt.Sprintf("invalid %s sequence at byte %d", e.Charset, e.Offset)
+}
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+
+	// aliases maps the lower-cased names used by X11 targets and
+	// MIME charset parameters to the canonical charset names.
+	aliases = map[string]string{
+		"utf-8":        UTF8,
+		"utf8":         UTF8,
+		"utf8_string":  UTF8,
+		"us-ascii":     ASCII,
+		"ascii":        ASCII,
+		"string":       Latin1,
+		"iso-8859-1":   Latin1,
+		"iso8859-1":    Latin1,
+		"iso_8859-1":   Latin1,
+		"latin1":       Latin1,
+		"latin-1":      Latin1,
+		"l1":           Latin1,
+		"windows-1252": Windows1252,
+		"cp1252":       Windows1252,
+		"utf-16":       UTF16,
+		"utf16":        UTF16,
+		"utf-16le":     UTF16LE,
+		"utf-16be":     UTF16BE,
+	}
+
+	// windows1252 holds the code points for bytes 0x80 to 0x9f, which is
+	// where windows-1252 differs from iso-8859-1. Zero marks undefined bytes.
+	windows1252 = [32]rune{
+		0x20ac, 0, 0x201a, 0x0192, 0x201e, 0x2026, 0x2020, 0x2021,
+		0x02c6, 0x2030, 0x0160, 0x2039, 0x0152, 0, 0x017d, 0,
+		0, 0x2018, 0x2019, 0x201c, 0x201d, 0x2022, 0x2013, 0x2014,
+		0x02dc, 0x2122, 0x0161, 0x203a, 0x0153, 0, 0x017e, 0x0178,
+	}
+)
+
+// FromTarget returns the charset implied by a clipboard target, which
+// can be an X11 target such as STRING or UTF8_STRING or a MIME type such
+// as "text/plain;charset=utf-16". It returns an empty string when the
+// target says nothing about the encoding.
+func FromTarget(target string) string {
+	target = strings.ToLower(strings.TrimSpace(target))
+	if target == "" {
+		return ""
+	}
+	mediaType, params, _ := strings.Cut(target, ";")
+	for _, param := range strings.Split(params, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || strings.TrimSpace(key) != "charset" {
+			continue
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+		if cs, ok := aliases[value]; ok {
+			return cs
+		}
+		return value
+	}
+	if cs, ok := aliases[strings.TrimSpace(mediaType)]; ok {
+		return cs
+	}
+	return ""
+}
+
+// Decode converts text read from the clipboard for the given target to
+// valid UTF-8. A charset named by the target takes precedence over a byte
+// order mark, which is only removed when it matches, or gives the byte
+// order of UTF-16. When the charset is unknown it is guessed from the
+// content, falling back to iso-8859-1, which is what X11 tools return for
+// legacy STRING owners. In strict mode invalid sequences and unsupported
+// charsets are reported as errors; otherwise they are replaced with U+FFFD.
+func Decode(data []byte, target string, strict bool) (string, error) {
+	cs := FromTarget(target)
+	bomCharset, n := sniffBOM(data)
+	if cs == "" || cs == bomCharset || cs == UTF16 && (bomCharset == UTF16LE || bomCharset == UTF16BE) {
+		cs, data = bomCharset, data[n:]
+	}
+	switch cs {
+	case "":
+		return decodeAuto(data, strict)
+	case UTF8, ASCII:
+		return decodeUTF8(data, strict)
+	case Latin1:
+		return decodeLatin1(data), nil
+	case Windows1252:
+		return decodeWindows1252(data, strict)
+	case UTF16:
+		return decodeUTF16(data, guessByteOrder(data), strict)
+	case UTF16LE:
+		return decodeUTF16(data, binary.LittleEndian, strict)
+	case UTF16BE:
+		return decodeUTF16(data, binary.BigEndian, strict)
+	}
+	if strict {
+		return "", fmt.Errorf("%w: %s", ErrUnsupported, cs)
+	}
+	return Decode(data, "", strict)
+}
+
+// Encode converts text to the given charset, which may also be a target
+// naming one, without a byte order mark. Only UTF-8 and UTF-16 can be
+// encoded, since they represent any text; UTF-16 is big endian.
+func Encode(text, cs string) ([]byte, error) {
+	if target := FromTarget(cs); target != "" {
+		cs = target
+	}
+	switch cs {
+	case "", UTF8:
+		return []byte(text), nil
+	case UTF16, UTF16BE:
+		return encodeUTF16(text, binary.BigEndian), nil
+	case UTF16LE:
+		return encodeUTF16(text, binary.LittleEndian), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupported, cs)
+}
+
+// BOM returns the byte order mark of the given charset, which may also
+// be a target naming one, or nil if it has none. UTF-16 is big endian.
+func BOM(cs string) []byte {
+	if target := FromTarget(cs); target != "" {
+		cs = target
+	}
+	switch cs {
+	case UTF8:
+		return append([]byte{}, bomUTF8...)
+	case UTF16, UTF16BE:
+		return append([]byte{}, bomUTF16BE...)
+	case UTF16LE:
+		return append([]byte{}, bomUTF16LE...)
+	}
+	return nil
+}
+
+// encodeUTF16 encodes text as UTF-16 with the given byte order.
+func encodeUTF16(text string, order binary.ByteOrder) []byte {
+	units := utf16.Encode([]rune(text))
+	data := make([]byte, 2*len(units))
+	for i, u := range units {
+		order.PutUint16(data[2*i:], u)
+	}
+	return data
+}
+
+// sniffBOM returns the charset announced by a byte order mark at the
+// start of data, and the length of that mark.
+func sniffBOM(data []byte) (string, int) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return UTF8, len(bomUTF8)
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return UTF16LE, len(bomUTF16LE)
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return UTF16BE, len(bomUTF16BE)
+	}
+	return "", 0
+}
+
+// decodeAuto guesses the charset of data that carries no information
+// about its encoding. Valid UTF-8 is kept as is, even holding NUL bytes,
+// as UTF-16 without a byte order mark can't be told apart from it; other
+// data holding NUL bytes is checked for UTF-16.
+func decodeAuto(data []byte, strict bool) (string, error) {
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		if order, ok := looksLikeUTF16(data); ok {
+			return decodeUTF16(data, order, strict)
+		}
+	}
+	if strict {
+		return decodeUTF8(data, strict)
+	}
+	return decodeLatin1(data), nil
+}
+
+// decodeUTF8 validates data as UTF-8.
+func decodeUTF8(data []byte, strict bool) (string, error) {
+	if utf8.Valid(data) {
+		return string(data), nil
+	}
+	if strict {
+		return "", &InvalidError{Charset: UTF8, Offset: firstInvalidUTF8(data)}
+	}
+	return strings.ToValidUTF8(string(data), string(utf8.RuneError)), nil
+}
+
+// firstInvalidUTF8 returns the offset of the first byte
+// that does not start a valid UTF-8 sequence.
+func firstInvalidUTF8(data []byte) int {
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return len(data)
+}
+
+// decodeLatin1 maps every byte to the code point with the same value.
+func decodeLatin1(data []byte) string {
+	var sb strings.Builder
+	sb.Grow(len(data))
+	for _, b := range data {
+		sb.WriteRune(rune(b))
+	}
+	return sb.String()
+}
+
+// decodeWindows1252 decodes data as windows-1252.
+func decodeWindows1252(data []byte, strict bool) (string, error) {
+	var sb strings.Builder
+	sb.Grow(len(data))
+	for i, b := range data {
+		r := rune(b)
+		if b >= 0x80 && b <= 0x9f {
+			if r = windows1252[b-0x80]; r == 0 {
+				if strict {
+					return "", &InvalidError{Charset: Windows1252, Offset: i}
+				}
+				r = utf8.RuneError
+			}
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String(), nil
+}
+
+// decodeUTF16 decodes data as UTF-16 with the given byte order.
+func decodeUTF16(data []byte, order binary.ByteOrder, strict bool) (string, error) {
+	name := UTF16LE
+	if order == binary.BigEndian {
+		name = UTF16BE
+	}
+	if strict && len(data)%2 != 0 {
+		return "", &InvalidError{Charset: name, Offset: len(data) - 1}
+	}
+	var sb strings.Builder
+	sb.Grow(len(data))
+	for i := 0; i+1 < len(data); i += 2 {
+		r := rune(order.Uint16(data[i:]))
+		switch {
+		case utf16.IsSurrogate(r) && r < 0xdc00 && i+3 < len(data):
+			low := rune(order.Uint16(data[i+2:]))
+			if dec := utf16.DecodeRune(r, low); dec != utf8.RuneError {
+				sb.WriteRune(dec)
+				i += 2
+				continue
+			}
+			fallthrough
+		case utf16.IsSurrogate(r):
+			if strict {
+				return "", &InvalidError{Charset: name, Offset: i}
+			}
+			r = utf8.RuneError
+		}
+		sb.WriteRune(r)
+	}
+	if len(data)%2 != 0 {
+		sb.WriteRune(utf8.RuneError)
+	}
+	return sb.String(), nil
+}
+
+// looksLikeUTF16 reports whether data, which carries no byte order mark,
+// looks like mostly-ASCII UTF-16 text, and with which byte order.
+func looksLikeUTF16(data []byte) (binary.ByteOrder, bool) {
+	if len(data) < 2 || len(data)%2 != 0 {
+		return nil, false
+	}
+	var evenZeros, oddZeros int
+	for i, b := range data {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			evenZeros++
+		} else {
+			oddZeros++
+		}
+	}
+	pairs := len(data) / 2
+	switch {
+	case oddZeros*2 >= pairs && evenZeros == 0:
+		return binary.LittleEndian, true
+	case evenZeros*2 >= pairs && oddZeros == 0:
+		return binary.BigEndian, true
+	}
+	return nil, false
+}
+
+// guessByteOrder returns the byte order of UTF-16 data without a byte
+// order mark, defaulting to big endian as RFC 2781 requires.
+func guessByteOrder(data []byte) binary.ByteOrder {
+	if order, ok := looksLikeUTF16(data); ok {
+		return order
+	}
+	return binary.BigEndian
+}
@@ -0,0 +1,258 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package charset
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromTarget(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		target         string
+		expectedOutput string
+	}{
+		{desc: "empty target", target: "", expectedOutput: ""},
+		{desc: "X11 UTF8_STRING", target: "UTF8_STRING", expectedOutput: UTF8},
+		{desc: "X11 STRING", target: "STRING", expectedOutput: Latin1},
+		{desc: "mime type without charset", target: "text/plain", expectedOutput: ""},
+		{desc: "mime type with charset", target: "text/plain;charset=utf-8", expectedOutput: UTF8},
+		{desc: "quoted charset with spaces", target: `text/plain; charset="UTF-16LE"`, expectedOutput: UTF16LE},
+		{desc: "charset alias", target: "text/plain;charset=latin1", expectedOutput: Latin1},
+		{desc: "unknown charset", target: "text/plain;charset=koi8-r", expectedOutput: "koi8-r"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			require.Equal(t, tc.expectedOutput, FromTarget(tc.target))
+		})
+	}
+}
+
+func TestBOM(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		cs             string
+		expectedOutput []byte
+	}{
+		{desc: "utf-8", cs: UTF8, expectedOutput: []byte{0xef, 0xbb, 0xbf}},
+		{desc: "utf-16", cs: UTF16, expectedOutput: []byte{0xfe, 0xff}},
+		{desc: "utf-16le", cs: "utf-16le", expectedOutput: []byte{0xff, 0xfe}},
+		{desc: "mime type with charset", cs: "text/plain;charset=utf-16be", expectedOutput: []byte{0xfe, 0xff}},
+		{desc: "latin1", cs: Latin1},
+		{desc: "empty charset", cs: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			require.Equal(t, tc.expectedOutput, BOM(tc.cs))
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		input          []byte
+		target         string
+		strict         bool
+		expectedOutput string
+		expectedError  error
+	}{
+		{
+			desc:           "plain utf-8",
+			input:          []byte("olá, 世界"),
+			expectedOutput: "olá, 世界",
+		},
+		{
+			desc:           "utf-8 with byte order mark",
+			input:          []byte("\xef\xbb\xbfsome text"),
+			expectedOutput: "some text",
+		},
+		{
+			desc:           "latin-1 from STRING target",
+			input:          []byte("ol\xe1"),
+			target:         "STRING",
+			expectedOutput: "olá",
+		},
+		{
+			desc:           "latin-1 guessed when charset is unknown",
+			input:          []byte("caf\xe9"),
+			expectedOutput: "café",
+		},
+		{
+			desc:           "windows-1252",
+			input:          []byte("\x93quoted\x94 \x80"),
+			target:         "text/plain;charset=windows-1252",
+			expectedOutput: "“quoted” €",
+		},
+		{
+			desc:           "utf-16le with byte order mark",
+			input:          []byte{0xff, 0xfe, 'h', 0, 'i', 0, 0x3d, 0xd8, 0x00, 0xde},
+			expectedOutput: "hi😀",
+		},
+		{
+			desc:           "utf-16be with byte order mark",
+			input:          []byte{0xfe, 0xff, 0, 'h', 0, 'i'},
+			expectedOutput: "hi",
+		},
+		{
+			desc:           "utf-16 without byte order mark",
+			input:          []byte{'h', 0, 0xe9, 0},
+			target:         "text/plain;charset=utf-16",
+			expectedOutput: "hé",
+		},
+		{
+			desc:           "utf-16le without byte order mark",
+			input:          []byte{'h', 0, 0xe9, 0},
+			expectedOutput: "hé",
+		},
+		{
+			desc:           "utf-8 with NUL bytes",
+			input:          []byte("a\x00"),
+			expectedOutput: "a\x00",
+		},
+		{
+			desc:           "STRING target wins over a byte order mark",
+			input:          []byte("\xef\xbb\xbfcaf\xe9"),
+			target:         "STRING",
+			expectedOutput: "ï»¿café",
+		},
+		{
+			desc:           "matching byte order mark removed",
+			input:          []byte{0xff, 0xfe, 'h', 0, 'i', 0},
+			target:         "text/plain;charset=utf-16le",
+			expectedOutput: "hi",
+		},
+		{
+			desc:           "byte order mark gives the order of utf-16",
+			input:          []byte{0xff, 0xfe, 'h', 0, 'i', 0},
+			target:         "text/plain;charset=utf-16",
+			expectedOutput: "hi",
+		},
+		{
+			desc:           "byte order mark of another charset kept",
+			input:          []byte{0xfe, 0xff, 0, 'h'},
+			target:         "text/plain;charset=utf-16le",
+			expectedOutput: "\ufffe\u6800",
+		},
+		{
+			desc:           "invalid utf-8 replaced when not strict",
+			input:          []byte("ab\xffc"),
+			target:         "UTF8_STRING",
+			expectedOutput: "ab�c",
+		},
+		{
+			desc:          "invalid utf-8 in strict mode",
+			input:         []byte("ab\xffc"),
+			target:        "UTF8_STRING",
+			strict:        true,
+			expectedError: errors.New("invalid utf-8 sequence at byte 2"),
+		},
+		{
+			desc:          "unknown charset guessed as latin-1 refused in strict mode",
+			input:         []byte("caf\xe9"),
+			strict:        true,
+			expectedError: errors.New("invalid utf-8 sequence at byte 3"),
+		},
+		{
+			desc:          "unpaired surrogate in strict mode",
+			input:         []byte{0xff, 0xfe, 'a', 0, 0x3d, 0xd8},
+			strict:        true,
+			expectedError: errors.New("invalid utf-16le sequence at byte 2"),
+		},
+		{
+			desc:           "unpaired surrogate replaced when not strict",
+			input:          []byte{0xff, 0xfe, 'a', 0, 0x3d, 0xd8},
+			expectedOutput: "a�",
+		},
+		{
+			desc:          "odd length utf-16 in strict mode",
+			input:         []byte{0xfe, 0xff, 0, 'a', 0},
+			strict:        true,
+			expectedError: errors.New("invalid utf-16be sequence at byte 2"),
+		},
+		{
+			desc:          "unsupported charset in strict mode",
+			input:         []byte("text"),
+			target:        "text/plain;charset=koi8-r",
+			strict:        true,
+			expectedError: errors.New("unsupported charset: koi8-r"),
+		},
+		{
+			desc:           "unsupported charset guessed when not strict",
+			input:          []byte("text"),
+			target:         "text/plain;charset=koi8-r",
+			expectedOutput: "text",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			output, err := Decode(tc.input, tc.target, tc.strict)
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+				require.Equal(t, tc.expectedOutput, output)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		text           string
+		charset        string
+		expectedOutput []byte
+		expectedError  error
+	}{
+		{
+			desc:           "utf-8",
+			text:           "hé",
+			expectedOutput: []byte("hé"),
+		},
+		{
+			desc:           "utf-16le",
+			text:           "hé😀",
+			charset:        UTF16LE,
+			expectedOutput: []byte{'h', 0, 0xe9, 0, 0x3d, 0xd8, 0x00, 0xde},
+		},
+		{
+			desc:           "utf-16 from a target",
+			text:           "hi",
+			charset:        "text/plain;charset=utf-16",
+			expectedOutput: []byte{0, 'h', 0, 'i'},
+		},
+		{
+			desc:          "unsupported charset",
+			text:          "café",
+			charset:       Latin1,
+			expectedError: errors.New("unsupported charset: iso-8859-1"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			output, err := Encode(tc.text, tc.charset)
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+				require.Equal(t, tc.expectedOutput, output)
+			}
+		})
+	}
+}
@@ -0,0 +1,4 @@
+// Package charset detects the character encoding of text read from the
+// clipboard and converts it to valid UTF-8, or converts text to the encoding a tool
+// expects.
+package charset
@@ -4,17 +4,152 @@
 
 package clipboard
 
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/audit"
+	"github.com/tiagomelo/go-clipboard/clipboard/clipboardtool"
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+	"github.com/tiagomelo/go-clipboard/clipboard/plugin"
+)
+
 // clipboard is an unexported type that implements the Clipboard interface.
 type clipboard struct {
-	Primary bool
-}
+	opts    ClipboardOptions
+	tools   *clipboardtool.Cache
+	plugins plugin.Registry
 
-// internal clipboard target flag
-var usePrimary bool
+	mu             sync.Mutex
+	preferred      string
+	pluginBackends map[string]*pluginBackend
+	daemon         *daemonBackend
+	siblings       map[Selection]*clipboard // Other selections, for snapshots
+	owners         owners
+}
 
 // exported flag container
 type ClipboardOptions struct {
 	Primary bool
+
+	// Target is the clipboard target requested when pasting, such as
+	// "STRING" or "text/plain;charset=utf-16". It is only honored by tools
+	// that can request a target; pasted text is decoded according to it.
+	Target string
+
+	// StrictCharset makes PasteText return an error when the pasted
+	// text contains invalid sequences instead of replacing them.
+	StrictCharset bool
+
+	// Tools lists the names of the clipboard tools and plugins to use, in
+	// the order they are tried when one fails with a retryable error, e.g.
+	// []string{"wl-copy", "xsel"}. Plugins are named without their
+	// "go-clipboard-backend-" prefix and tried after the tools. When empty,
+	// every available tool is tried in the default order, followed by
+	// every plugin found in the PATH. The gclipd daemon is tried first
+	// when its socket exists, unless Tools is set and doesn't name it.
+	Tools []string
+
+	// Probe checks that the clipboard tools actually work before using
+	// them, e.g. that xclip can reach an X server, instead of only looking
+	// for them in the PATH. Each tool is checked once per process.
+	Probe bool
+
+	// ProbeTimeout is how long each check may take.
+	// Zero means clipboardtool.DefaultProbeTimeout.
+	ProbeTimeout time.Duration
+
+	// OneShot serves copied text for a single paste, after which the
+	// clipboard is cleared, on tools that support it (xclip, wl-copy).
+	OneShot bool
+
+	// Strict makes New detect the clipboard tools right away and return
+	// ErrUnsupported if none of them can honor the other options, e.g.
+	// Primary on macOS, instead of quietly ignoring what is unsupported.
+	// Only tools that honor every option are used.
+	Strict bool
+
+	// CopyCmd, PasteCmd and ClearCmd are custom commands used before the
+	// detected tools, e.g. "xclip -selection {selection}". Arguments are
+	// split with shell-like quoting; "{selection}" is replaced with
+	// "clipboard" or "primary", and "{mime}" with Target or
+	// "text/plain;charset=utf-8". When empty, they are read from the
+	// GO_CLIPBOARD_COPY_CMD, GO_CLIPBOARD_PASTE_CMD and
+	// GO_CLIPBOARD_CLEAR_CMD environment variables. ClearCmd must run
+	// the same program as CopyCmd; without it, Clear copies empty text.
+	CopyCmd  string
+	PasteCmd string
+	ClearCmd string
+
+	// PluginTimeout is how long a plugin may take to answer each
+	// request. Zero means plugin.DefaultTimeout.
+	PluginTimeout time.Duration
+
+	// Runner finds and runs the clipboard tools. It can wrap
+	// command.ExecRunner, e.g. to log or sandbox the commands.
+	// Nil means command.ExecRunner. Plugins are found and run with it
+	// too, if it implements command.Execer; otherwise none are used.
+	Runner command.Runner
+
+	// Hooks logs, counts and traces the clipboard operations.
+	Hooks Hooks
+
+	// Middleware wraps every backend, in order: the first middleware
+	// is the outermost. It is usually set with WithMiddleware.
+	Middleware []Middleware
+
+	// Policies inspect the text before it is copied, including data copied
+	// as a text target or that is valid UTF-8, and can deny, redact or mark
+	// it as sensitive, e.g. Detect(Deny) refuses to copy secrets.
+	Policies []Policy
+
+	// ReadOnly makes CopyText and Clear fail with ErrReadOnly, and
+	// WriteOnly makes PasteText, Types and Watch fail with ErrWriteOnly.
+	ReadOnly  bool
+	WriteOnly bool
+
+	// Supervise leaves the copy tools that can serve the selection in the
+	// foreground (xclip, wl-copy) running as child processes, instead of
+	// letting them fork, so that they can be stopped: the tool serving a
+	// copy is stopped by the next copy, and by Close. A copy succeeds
+	// once the paste tool gets what was copied, or once the tool has run
+	// for command.StartTimeout without failing. One-shot copies aren't
+	// supervised. The daemon sets it for the clipboards it owns the
+	// selections with.
+	//
+	// It is off by default because, on Linux, a supervised tool gets
+	// SIGTERM when the program exits: what a short-lived program such as
+	// a command-line tool copies would be gone as soon as it exits. The
+	// process forked by the tool instead outlives the program, and exits
+	// on its own once something else is copied. Elsewhere, a supervised
+	// tool that isn't stopped with Close keeps serving the selection
+	// after the program exits, until something else is copied. Programs
+	// that use the daemon, as they do by default when it runs, have it
+	// supervised anyway.
+	Supervise bool
+
+	// NoLock doesn't lock the clipboard while copying to it or clearing
+	// it. By default, the selection is locked with an advisory file lock
+	// in XDG_RUNTIME_DIR, so that processes using this package don't
+	// clobber each other's copies, and copies fail if the lock can't be
+	// taken. The daemon sets it, as it serializes the copies itself
+	// while its clients hold the lock.
+	NoLock bool
+
+	// LockTimeout is how long a copy waits for another process to
+	// release the lock. Zero means DefaultLockTimeout.
+	LockTimeout time.Duration
+
+	// NoDaemon doesn't use the gclipd daemon, even if its socket exists.
+	// The daemon sets it for the clipboards it owns the selections with.
+	NoDaemon bool
+
+	// Audit records every operation, e.g. in an audit.Log. If an
+	// operation can't be recorded, it fails, and pasted text is not
+	// returned.
+	Audit audit.Sink
 }
 
 // Clipboard is the interface that wraps the basic clipboard operations.
@@ -26,28 +161,430 @@ type Clipboard interface {
 	// PasteText retrieves text from the system clipboard.
 	// It returns the text as a string and an error if the paste operation fails.
 	PasteText() (string, error)
+
+	// Clear empties the system clipboard, or the primary selection if
+	// ClipboardOptions.Primary is set, so that nothing can be pasted.
+	// It returns an error if the clearing process fails.
+	Clear() error
+
+	// Capabilities reports what the clipboard can do with the tools it uses.
+	// It reports no capabilities if no tool is available.
+	Capabilities() Capabilities
 }
 
 // New creates and returns a new Clipboard instance that can be used
-// to interact with the system clipboard.
-func New(opts ...ClipboardOptions) Clipboard {
-	cb := &clipboard{}
+// to interact with the system clipboard. The clipboard tools and
+// plugins are detected on first use and reused by later operations, unless
+// ClipboardOptions.Strict is set, in which case they are detected
+// right away and an error is returned if none is suitable. The options
+// are applied in order; the Clipboard also implements TypeLister,
+// Watcher, DataCopier, FileCopier, Swapper, Snapshotter and io.Closer.
+func New(opts ...Option) (Clipboard, error) {
+	cb := &clipboard{pluginBackends: make(map[string]*pluginBackend)}
 
-	if len(opts) == 1 {
-		usePrimary = opts[0].Primary
+	for _, opt := range opts {
+		opt.apply(&cb.opts)
+	}
+	toolOpts := clipboardtool.Options{
+		Primary:      cb.opts.Primary,
+		Order:        cb.opts.Tools,
+		Probe:        cb.opts.Probe,
+		ProbeTimeout: cb.opts.ProbeTimeout,
+		CopyCmd:      cb.opts.CopyCmd,
+		PasteCmd:     cb.opts.PasteCmd,
+		ClearCmd:     cb.opts.ClearCmd,
+		Target:       cb.opts.Target,
+		Runner:       cb.opts.Runner,
 	}
+	if cb.opts.Strict {
+		toolOpts.Require = required(cb.opts)
+	}
+	cb.tools = clipboardtool.NewCache(toolOpts)
 
-	return cb
+	if cb.opts.Strict {
+		if _, err := cb.backends(); err != nil {
+			return nil, err
+		}
+	}
+	return cb, nil
 }
 
 // CopyText implements the Clipboard interface's CopyText method.
-// It calls the copyText function to perform the actual operation.
+// It calls the copyText method to perform the actual operation.
 func (c *clipboard) CopyText(s string) error {
-	return copyText(s)
+	return c.copyText(s)
 }
 
 // PasteText implements the Clipboard interface's PasteText method.
-// It calls the pasteText function to perform the actual operation.
+// It calls the pasteText method to perform the actual operation.
 func (c *clipboard) PasteText() (string, error) {
-	return pasteText()
+	return c.pasteText()
+}
+
+// Clear implements the Clipboard interface's Clear method.
+// It calls the clear method to perform the actual operation.
+func (c *clipboard) Clear() error {
+	return c.clear()
+}
+
+// Types implements the TypeLister interface. The types are listed
+// by the first backend that can, as with the other operations.
+func (c *clipboard) Types() ([]string, error) {
+	var types []string
+	err := c.withFallback("types", false, func(b Backend) (string, error) {
+		var err error
+		types, err = Wrapper{b}.Types()
+		return "", err
+	})
+	return types, err
+}
+
+// Watch implements the Watcher interface. The clipboard is watched
+// by the first backend that can, as with the other operations.
+func (c *clipboard) Watch(ctx context.Context) (<-chan string, error) {
+	var texts <-chan string
+	err := c.withFallback("watch", false, func(b Backend) (string, error) {
+		var err error
+		texts, err = Wrapper{b}.Watch(ctx)
+		return "", err
+	})
+	return texts, err
+}
+
+// CopyData implements the DataCopier interface. The data is copied by
+// the first backend that can, as with the other operations. Text targets,
+// such as text/plain or STRING, are inspected by the policies like the
+// text given to CopyText. Other processes using this package can't copy
+// until it is done.
+func (c *clipboard) CopyData(target string, data []byte) error {
+	unlock, err := c.lock("copy")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	err = c.blocked("copy")
+	items := []Item{{Target: target, Data: data}}
+	sensitive := false
+	if err == nil {
+		items, sensitive, err = c.checkItems(items)
+	}
+	if err != nil {
+		c.record("copy", nil, "", err)
+		return err
+	}
+	data = items[0].Data
+	return c.withFallback("copy", sensitive, func(b Backend) (string, error) {
+		return string(data), Wrapper{b}.CopyData(target, data)
+	})
+}
+
+// Close implements the io.Closer interface. It stops the tool left
+// serving the last copy when ClipboardOptions.Supervise is set, which
+// releases the selection if it still owns it. The clipboard can still
+// be used afterwards.
+func (c *clipboard) Close() error {
+	c.mu.Lock()
+	siblings := c.siblings
+	c.mu.Unlock()
+	var errs []error
+	for _, sc := range siblings {
+		errs = append(errs, sc.Close())
+	}
+	return errors.Join(append(errs, c.owners.release())...)
+}
+
+// Capabilities implements the Clipboard interface's Capabilities method.
+// It reports the capabilities of the backend that is tried first, as
+// established by a probe if ClipboardOptions.Probe is set.
+func (c *clipboard) Capabilities() Capabilities {
+	backends, err := c.backends()
+	if err != nil {
+		return Capabilities{}
+	}
+	if tb, ok := backends[0].(*toolBackend); ok && c.opts.Probe {
+		return capabilities(clipboardtool.ProbeWith(tb.runner(), tb.ct, c.opts.ProbeTimeout))
+	}
+	return backends[0].Capabilities()
+}
+
+// copyText takes a string and copies it to the system clipboard.
+// It uses the cached backends to determine the appropriate tool or plugin
+// to execute the copy operation, falling back to the next available backend when one fails
+// with a retryable error. Other processes using this package can't copy until it is done.
+// An error is returned if no backend can be initialized or succeeds, or if a policy denies
+// the copy.
+func (c *clipboard) copyText(s string) error {
+	unlock, err := c.lock("copy")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return c.copyUnlocked(s)
+}
+
+// copyUnlocked implements copyText, once the clipboard is locked.
+func (c *clipboard) copyUnlocked(s string) error {
+	err := c.blocked("copy")
+	sensitive := false
+	if err == nil {
+		s, sensitive, err = c.checkPolicies(s)
+	}
+	if err != nil {
+		c.record("copy", nil, "", err)
+		return err
+	}
+	return c.withFallback("copy", sensitive, func(b Backend) (string, error) {
+		return s, b.CopyText(s)
+	})
+}
+
+// clear empties the system clipboard.
+// It clears the clipboard with each backend in turn, e.g. by running "wl-copy --clear"
+// or "xsel --clear", falling back to the next available backend when one fails with
+// a retryable error.
+func (c *clipboard) clear() error {
+	unlock, err := c.lock("clear")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return c.withFallback("clear", false, func(b Backend) (string, error) {
+		return "", b.Clear()
+	})
+}
+
+// pasteText retrieves text from the system clipboard.
+// It uses the cached backends to determine the appropriate tool or plugin
+// to execute the paste operation, falling back to the next available backend when one fails
+// with a retryable error. The pasted text is converted to valid UTF-8 according to the
+// requested target. It returns the pasted text and any error encountered.
+func (c *clipboard) pasteText() (string, error) {
+	var text string
+	err := c.withFallback("paste", false, func(b Backend) (string, error) {
+		var err error
+		text, err = b.PasteText()
+		return text, err
+	})
+	if err != nil {
+		return "", err
+	}
+	return text, nil
+}
+
+// withFallback runs op with each backend in turn, until one succeeds or
+// fails with an error that is not retryable. The backend that succeeds is
+// remembered and tried first by later operations. If a tool or plugin cannot
+// be executed, the backends are detected again once. op is called with
+// each backend wrapped in the middlewares, and returns the text it copied
+// or pasted; each attempt is reported to the hooks under the given name,
+// and the operation is recorded by the audit sink, if any. Sensitive text
+// is copied for a single paste, where supported.
+func (c *clipboard) withFallback(name string, sensitive bool, op func(b Backend) (string, error)) error {
+	b, text, err := c.fallback(name, sensitive, op)
+	if auditErr := c.record(name, b, text, err); auditErr != nil && err == nil {
+		return auditErr
+	}
+	return err
+}
+
+// fallback implements withFallback, returning the backend that
+// succeeded, or nil, and the text that op returned.
+func (c *clipboard) fallback(name string, sensitive bool, op func(b Backend) (string, error)) (Backend, string, error) {
+	if err := c.blocked(name); err != nil {
+		return nil, "", err
+	}
+	backends, err := c.backends()
+	if err != nil {
+		return nil, "", err
+	}
+	var attempts []Attempt
+	var tried []Backend
+	redetected := false
+	for i := 0; i < len(backends); i++ {
+		b := backends[i]
+		tried = append(tried, b)
+		tool := b.Name()
+		if name != "copy" && name != "clear" {
+			tool = pasteName(b)
+		}
+		if sensitive {
+			if b = oneShot(b); b == nil {
+				err := unsupported(backends[i], "one-shot copies")
+				attempts = append(attempts, Attempt{Tool: tool, Class: ClassifyError(err), Err: err})
+				continue
+			}
+		}
+		o := c.observe(name, b, sensitive)
+		text, err := op(c.wrap(b))
+		o.end(tool, text, err)
+		if err == nil {
+			c.remember(b)
+			return b, text, nil
+		}
+		class := ClassifyError(err)
+		attempts = append(attempts, Attempt{Tool: tool, Class: class, Err: err})
+		if !class.Retryable() {
+			break
+		}
+		if class == ClassNotFound && !redetected {
+			redetected = true
+			c.tools.Invalidate()
+			c.plugins.Invalidate()
+			if backends, err = c.backends(); err != nil {
+				break
+			}
+			backends = untried(backends, tried)
+			i = -1
+		}
+	}
+	return nil, "", fallbackError(attempts)
+}
+
+// backends returns the backends to use, in the order they should be tried:
+// the daemon, if its socket exists, then the detected clipboard tools, then
+// the plugins, with the backend that last worked first. In strict mode, the
+// daemon and plugins that can't honor the options are left out.
+func (c *clipboard) backends() ([]Backend, error) {
+	var backends []Backend
+	unsupported := false
+	if b := c.daemonBackend(); b != nil {
+		if c.opts.Strict && !honors(b.Capabilities(), c.opts) {
+			unsupported = true
+		} else {
+			backends = append(backends, b)
+		}
+	}
+	cts, toolsErr := c.tools.Candidates()
+	for _, ct := range cts {
+		backends = append(backends, &toolBackend{opts: &c.opts, ct: ct, owners: &c.owners})
+	}
+	for _, info := range c.orderedPlugins() {
+		b := c.pluginBackend(info)
+		if c.opts.Strict && !honors(b.Capabilities(), c.opts) {
+			unsupported = true
+			continue
+		}
+		backends = append(backends, b)
+	}
+	if len(backends) == 0 {
+		if unsupported {
+			return nil, ErrUnsupported
+		}
+		return nil, toolsErr
+	}
+	c.mu.Lock()
+	preferred := c.preferred
+	c.mu.Unlock()
+	for i, b := range backends {
+		if backendKey(b) == preferred {
+			copy(backends[1:i+1], backends[:i])
+			backends[0] = b
+			break
+		}
+	}
+	return backends, nil
+}
+
+// orderedPlugins returns the plugins named in ClipboardOptions.Tools,
+// in that order, or every plugin found if no tools are named, leaving
+// out those the runner doesn't find or can't run.
+func (c *clipboard) orderedPlugins() []plugin.Info {
+	if len(c.opts.Tools) == 0 {
+		return runnablePlugins(command.OrDefault(c.opts.Runner), c.plugins.Plugins())
+	}
+	var plugins []plugin.Info
+	for _, name := range c.opts.Tools {
+		if info, ok := c.plugins.Lookup(name); ok {
+			plugins = append(plugins, info)
+		}
+	}
+	return runnablePlugins(command.OrDefault(c.opts.Runner), plugins)
+}
+
+// pluginBackend returns the backend of the given plugin,
+// reusing it so that its capabilities are only asked once.
+func (c *clipboard) pluginBackend(info plugin.Info) *pluginBackend {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	b, ok := c.pluginBackends[info.Path]
+	if !ok {
+		b = &pluginBackend{opts: &c.opts, info: info}
+		c.pluginBackends[info.Path] = b
+	}
+	return b
+}
+
+// remember makes the given backend the one tried first from now on.
+func (c *clipboard) remember(b Backend) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.preferred = backendKey(b)
+}
+
+// untried returns the backends that are not in tried. Tools found again at
+// another path, e.g. after being reinstalled, are considered untried.
+func untried(backends, tried []Backend) []Backend {
+	var result []Backend
+	for _, b := range backends {
+		isTried := false
+		for _, t := range tried {
+			if backendKey(b) == backendKey(t) {
+				isTried = true
+			}
+		}
+		if !isTried {
+			result = append(result, b)
+		}
+	}
+	return result
+}
+
+// oneShot returns a backend serving a single paste of what is copied, if
+// b can, or else b itself, or nil for a daemon, which would keep it in
+// its history.
+func oneShot(b Backend) Backend {
+	switch b := b.(type) {
+	case *toolBackend:
+		opts := *b.opts
+		opts.OneShot = true
+		return &toolBackend{opts: &opts, ct: b.ct, owners: b.owners}
+	case *pluginBackend:
+		caps := b.Capabilities()
+		if !caps.OneShot {
+			return b
+		}
+		pb := &pluginBackend{opts: b.opts, info: b.info, oneShot: true}
+		pb.capsOnce.Do(func() { pb.caps = caps })
+		return pb
+	case *daemonBackend:
+		caps := b.Capabilities()
+		if !caps.OneShot {
+			return nil
+		}
+		db := &daemonBackend{opts: b.opts, path: b.path, private: b.private, oneShot: true}
+		db.capsOnce.Do(func() { db.caps = caps })
+		return db
+	}
+	return b
+}
+
+// backendKey identifies a backend by the executables it runs.
+func backendKey(b Backend) string {
+	switch b := b.(type) {
+	case *toolBackend:
+		return b.ct.CopyTool.Executable() + "\x00" + b.ct.PasteTool.Executable()
+	case *pluginBackend:
+		return b.info.Path
+	case *daemonBackend:
+		return b.path
+	}
+	return b.Name()
+}
+
+// pasteName returns the name of the tool a backend pastes with.
+func pasteName(b Backend) string {
+	if tb, ok := b.(*toolBackend); ok {
+		return tb.ct.PasteTool.Name
+	}
+	return b.Name()
 }
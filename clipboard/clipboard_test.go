@@ -0,0 +1,374 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+)
+
+func Test_copyText(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		mockClosure   func(m *mockCommand)
+		expectedCalls int
+		expectedError error
+	}{
+		{
+			desc:          "happy path",
+			expectedCalls: 1,
+		},
+		{
+			desc: "error",
+			mockClosure: func(m *mockCommand) {
+				m.ErrTextInput = errors.New("text input error")
+			},
+			expectedCalls: 1,
+			expectedError: errors.New("text input error"),
+		},
+	}
+	for _, tc := range testCases {
+		m := new(mockCommand)
+		var calls int
+		runner := mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+			calls++
+			return m
+		})
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeTools(t)
+			if tc.mockClosure != nil {
+				tc.mockClosure(m)
+			}
+			c := newTestClipboard(t, ClipboardOptions{Runner: runner})
+			err := c.copyText("some text")
+			require.Equal(t, tc.expectedCalls, calls)
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+			}
+		})
+	}
+}
+
+func Test_pasteText(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		mockClosure    func(m *mockCommand)
+		expectedOutput string
+		expectedError  error
+	}{
+		{
+			desc: "happy path",
+			mockClosure: func(m *mockCommand) {
+				m.Output = "some text"
+			},
+			expectedOutput: "some text",
+		},
+		{
+			desc: "latin-1 output is converted to utf-8",
+			mockClosure: func(m *mockCommand) {
+				m.Output = "caf\xe9"
+			},
+			expectedOutput: "café",
+		},
+		{
+			desc: "error",
+			mockClosure: func(m *mockCommand) {
+				m.ErrOutput = errors.New("paste text error")
+			},
+			expectedError: errors.New("paste text error"),
+		},
+	}
+	for _, tc := range testCases {
+		m := new(mockCommand)
+		runner := mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+			return m
+		})
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeTools(t)
+			tc.mockClosure(m)
+			c := newTestClipboard(t, ClipboardOptions{Runner: runner})
+			output, err := c.pasteText()
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+				require.Equal(t, tc.expectedOutput, output)
+			}
+		})
+	}
+}
+
+func Test_customCommands(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		opts           ClipboardOptions
+		env            map[string]string
+		op             func(c *clipboard) (string, error)
+		output         string
+		expectedCmd    []string
+		expectedOutput string
+	}{
+		{
+			desc: "copy",
+			opts: ClipboardOptions{CopyCmd: `mycopy --selection "{selection}"`, PasteCmd: "mypaste"},
+			op: func(c *clipboard) (string, error) {
+				return "", c.copyText("some text")
+			},
+			expectedCmd: []string{"mycopy", "--selection", "clipboard"},
+		},
+		{
+			desc: "paste from the environment",
+			opts: ClipboardOptions{Primary: true},
+			env:  map[string]string{"GO_CLIPBOARD_COPY_CMD": "mycopy", "GO_CLIPBOARD_PASTE_CMD": "mypaste -s {selection}"},
+			op: func(c *clipboard) (string, error) {
+				return c.pasteText()
+			},
+			output:         "some text",
+			expectedCmd:    []string{"mypaste", "-s", "primary"},
+			expectedOutput: "some text",
+		},
+		{
+			desc: "paste decodes the requested target",
+			opts: ClipboardOptions{CopyCmd: "mycopy", PasteCmd: "mypaste --type '{mime}'", Target: "STRING"},
+			op: func(c *clipboard) (string, error) {
+				return c.pasteText()
+			},
+			output:         "caf\xe9",
+			expectedCmd:    []string{"mypaste", "--type", "STRING"},
+			expectedOutput: "café",
+		},
+		{
+			desc: "clear",
+			opts: ClipboardOptions{CopyCmd: "mycopy", PasteCmd: "mypaste", ClearCmd: "mycopy --clear"},
+			op: func(c *clipboard) (string, error) {
+				return "", c.clear()
+			},
+			expectedCmd: []string{"mycopy", "--clear"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeTools(t)
+			for key, value := range tc.env {
+				t.Setenv(key, value)
+			}
+			var cmd []string
+			opts := tc.opts
+			opts.Runner = mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+				cmd = append([]string{filepath.Base(cmdName)}, cmdArgs...)
+				return &mockCommand{Output: tc.output}
+			})
+			c := newTestClipboard(t, opts)
+			output, err := tc.op(c)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedCmd, cmd)
+			require.Equal(t, tc.expectedOutput, output)
+		})
+	}
+}
+
+func Test_withFallback(t *testing.T) {
+	noDisplay := exitError(t, "Error: Can't open display: (null)\n")
+	notFound := &fs.PathError{Op: "fork/exec", Path: "/usr/bin/tool", Err: fs.ErrNotExist}
+	testCases := []struct {
+		desc           string
+		errs           map[string]error
+		expectedTools  []string
+		expectedSecond []string
+		expectedError  string
+		expectedClass  []ErrorClass
+	}{
+		{
+			desc:           "first tool works",
+			expectedTools:  []string{"xsel"},
+			expectedSecond: []string{"xsel"},
+		},
+		{
+			desc:           "next tool is tried and remembered after a retryable error",
+			errs:           map[string]error{"xsel": noDisplay},
+			expectedTools:  []string{"xsel", "xclip"},
+			expectedSecond: []string{"xclip"},
+		},
+		{
+			desc:          "error that is not retryable stops the chain",
+			errs:          map[string]error{"xsel": errors.New("exit status 1")},
+			expectedTools: []string{"xsel"},
+			expectedError: "exit status 1",
+		},
+		{
+			desc: "errors of every attempt are aggregated",
+			errs: map[string]error{
+				"xsel":                 noDisplay,
+				"xclip":                noDisplay,
+				"wl-copy":              notFound,
+				"termux-clipboard-set": errors.New("exit status 1"),
+			},
+			expectedTools: []string{"xsel", "xclip", "wl-copy", "termux-clipboard-set"},
+			expectedError: "all clipboard tools failed: " +
+				"xsel: exit status 2 (unavailable); " +
+				"xclip: exit status 2 (unavailable); " +
+				"wl-copy: fork/exec /usr/bin/tool: file does not exist (not found); " +
+				"termux-clipboard-set: exit status 1 (failed)",
+			expectedClass: []ErrorClass{ClassUnavailable, ClassUnavailable, ClassNotFound, ClassFailed},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeTools(t)
+			var tools []string
+			runner := mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+				tool := filepath.Base(cmdName)
+				tools = append(tools, tool)
+				return &mockCommand{ErrTextInput: tc.errs[tool]}
+			})
+			c := newTestClipboard(t, ClipboardOptions{Tools: []string{"xsel", "xclip", "wl-copy", "termux-clipboard-set"}, Runner: runner})
+			if cts, err := c.tools.Candidates(); err != nil || len(cts) < 4 {
+				t.Skip("needs the X11, Wayland and Termux tools of this platform")
+			}
+			err := c.copyText("some text")
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+				require.Equal(t, tc.expectedTools, tools)
+				tools = nil
+				require.NoError(t, c.copyText("some text"))
+				require.Equal(t, tc.expectedSecond, tools)
+				return
+			}
+			require.Equal(t, tc.expectedTools, tools)
+			require.EqualError(t, err, tc.expectedError)
+			var fallbackErr *FallbackError
+			if tc.expectedClass == nil {
+				require.False(t, errors.As(err, &fallbackErr))
+				return
+			}
+			require.True(t, errors.As(err, &fallbackErr))
+			for i, a := range fallbackErr.Attempts {
+				require.Equal(t, tc.expectedClass[i], a.Class)
+			}
+			require.ErrorIs(t, err, fs.ErrNotExist)
+		})
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		err           error
+		expectedClass ErrorClass
+	}{
+		{desc: "tool not in PATH", err: exec.ErrNotFound, expectedClass: ClassNotFound},
+		{desc: "tool removed", err: &fs.PathError{Err: fs.ErrNotExist}, expectedClass: ClassNotFound},
+		{desc: "tool not executable", err: &fs.PathError{Err: fs.ErrPermission}, expectedClass: ClassPermission},
+		{desc: "tool not trusted", err: fmt.Errorf("%w: /tmp/xclip: not in a trusted directory", command.ErrUntrusted), expectedClass: ClassPermission},
+		{desc: "no X display", err: &exec.ExitError{Stderr: []byte("Error: Can't open display: :0")}, expectedClass: ClassUnavailable},
+		{desc: "broken X auth", err: &exec.ExitError{Stderr: []byte("No protocol specified")}, expectedClass: ClassUnavailable},
+		{desc: "no wayland", err: &exec.ExitError{Stderr: []byte("Failed to connect to a Wayland server")}, expectedClass: ClassUnavailable},
+		{desc: "empty clipboard", err: &exec.ExitError{Stderr: []byte("Nothing is copied")}, expectedClass: ClassFailed},
+		{desc: "other error", err: errors.New("some error"), expectedClass: ClassFailed},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			require.Equal(t, tc.expectedClass, ClassifyError(tc.err))
+		})
+	}
+}
+
+// newTestClipboard returns a new clipboard, failing the test on error.
+func newTestClipboard(t *testing.T, opts ...Option) *clipboard {
+	c, err := New(opts...)
+	require.NoError(t, err)
+	return c.(*clipboard)
+}
+
+// exitError returns an *exec.ExitError with the given standard error,
+// obtained by running the test binary with an unknown flag.
+func exitError(t *testing.T, stderr string) *exec.ExitError {
+	var exitErr *exec.ExitError
+	err := exec.Command(os.Args[0], "-test.unknown-flag").Run()
+	require.True(t, errors.As(err, &exitErr))
+	exitErr.Stderr = []byte(stderr)
+	return exitErr
+}
+
+// fakeTools points PATH at a directory holding an empty executable
+// for every known clipboard tool, so that tool detection succeeds
+// regardless of what is installed, and clears the custom commands.
+func fakeTools(t *testing.T) {
+	dir := privateDir(t)
+	for _, name := range []string{"xsel", "xclip", "wl-copy", "wl-paste", "termux-clipboard-set",
+		"termux-clipboard-get", "pbcopy", "pbpaste", "clip.exe", "powershell.exe"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0o755))
+	}
+	t.Setenv("PATH", dir)
+	for _, name := range []string{"GO_CLIPBOARD_COPY_CMD", "GO_CLIPBOARD_PASTE_CMD", "GO_CLIPBOARD_CLEAR_CMD"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv(DaemonSocketEnv, filepath.Join(dir, "gclipd.sock"))
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+}
+
+// privateDir returns a temporary directory that only the user
+// can access, as XDG_RUNTIME_DIR is.
+func privateDir(t *testing.T) string {
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0o700))
+	return dir
+}
+
+// mockRunner is a command.Runner finding the tools in the PATH,
+// and returning the commands it builds instead of running them.
+type mockRunner func(cmdName string, cmdArgs ...string) command.Command
+
+// Command implements the command.Runner interface.
+func (r mockRunner) Command(ctx context.Context, name string, args ...string) command.Command {
+	return r(name, args...)
+}
+
+// Cmd implements the command.Execer interface, so that plugins run.
+func (r mockRunner) Cmd(ctx context.Context, name string, args ...string) (*exec.Cmd, error) {
+	return exec.CommandContext(ctx, name, args...), nil
+}
+
+// LookPath implements the command.Runner interface.
+func (r mockRunner) LookPath(file string) (string, error) {
+	return exec.LookPath(file)
+}
+
+type mockCommand struct {
+	ErrTextInput error
+	ErrOutput    error
+	Input        string
+	Output       string
+}
+
+func (m *mockCommand) TextInput(text string) error {
+	m.Input = text
+	return m.ErrTextInput
+}
+
+func (m *mockCommand) TextOutput() (string, error) {
+	return m.Output, m.ErrOutput
+}
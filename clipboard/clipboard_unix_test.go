@@ -7,84 +7,269 @@
 package clipboard
 
 import (
+	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard/clipboardtest/fakebin"
 	"github.com/tiagomelo/go-clipboard/clipboard/command"
 )
 
-func Test_copyText(t *testing.T) {
+func TestNew_strict(t *testing.T) {
 	testCases := []struct {
 		desc          string
-		mockClosure   func(m *mockCommand)
+		opts          ClipboardOptions
+		expectedTool  string
+		expectedArgs  []string
 		expectedError error
 	}{
 		{
-			desc: "happy path",
+			desc:         "options every tool honors",
+			opts:         ClipboardOptions{Strict: true},
+			expectedTool: "xsel",
+			expectedArgs: []string{"--input", "--clipboard"},
 		},
 		{
-			desc: "error",
-			mockClosure: func(m *mockCommand) {
-				m.ErrTextInput = errors.New("text input error")
-			},
-			expectedError: errors.New("text input error"),
+			desc:         "one-shot copy skips tools that cannot do it",
+			opts:         ClipboardOptions{Strict: true, OneShot: true},
+			expectedTool: "xclip",
+			expectedArgs: []string{"-in", "-selection", "clipboard", "-loops", "1"},
+		},
+		{
+			desc:         "target skips tools that cannot request it",
+			opts:         ClipboardOptions{Strict: true, Target: "STRING", Tools: []string{"xsel", "wl-copy"}},
+			expectedTool: "wl-copy",
+		},
+		{
+			desc:          "primary selection on termux",
+			opts:          ClipboardOptions{Strict: true, Primary: true, Tools: []string{"termux-clipboard-set"}},
+			expectedError: ErrUnsupported,
+		},
+		{
+			desc:         "primary selection on termux when not strict",
+			opts:         ClipboardOptions{Primary: true, Tools: []string{"termux-clipboard-set"}},
+			expectedTool: "termux-clipboard-set",
 		},
 	}
 	for _, tc := range testCases {
-		m := new(mockCommand)
-		newCmd = func(cmdName string, cmdArgs ...string) command.Command {
-			return m
-		}
 		t.Run(tc.desc, func(t *testing.T) {
-			if tc.mockClosure != nil {
-				tc.mockClosure(m)
+			fakeTools(t)
+			var tool string
+			var args []string
+			opts := tc.opts
+			opts.Runner = mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+				tool, args = filepath.Base(cmdName), cmdArgs
+				return new(mockCommand)
+			})
+			c, err := New(opts)
+			if tc.expectedError != nil {
+				require.True(t, errors.Is(err, tc.expectedError))
+				return
 			}
-			err := copyText("some text")
-			if err != nil {
-				if tc.expectedError == nil {
-					t.Fatalf("expected no error, got %v", err)
-				}
-				require.Equal(t, tc.expectedError.Error(), err.Error())
-			} else {
-				if tc.expectedError != nil {
-					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
-				}
+			require.NoError(t, err)
+			require.NoError(t, c.CopyText("some text"))
+			require.Equal(t, tc.expectedTool, tool)
+			if tc.expectedArgs != nil {
+				require.Equal(t, tc.expectedArgs, args)
 			}
 		})
 	}
 }
 
-func Test_pasteText(t *testing.T) {
+func Test_clear(t *testing.T) {
+	testCases := []struct {
+		desc         string
+		opts         ClipboardOptions
+		expectedTool string
+		expectedArgs []string
+	}{
+		{
+			desc:         "xsel",
+			opts:         ClipboardOptions{Tools: []string{"xsel"}},
+			expectedTool: "xsel",
+			expectedArgs: []string{"--clear", "--clipboard"},
+		},
+		{
+			desc:         "xsel primary selection",
+			opts:         ClipboardOptions{Tools: []string{"xsel"}, Primary: true},
+			expectedTool: "xsel",
+			expectedArgs: []string{"--clear", "--primary"},
+		},
+		{
+			desc:         "xclip owns an empty selection",
+			opts:         ClipboardOptions{Tools: []string{"xclip"}},
+			expectedTool: "xclip",
+			expectedArgs: []string{"-in", "-selection", "clipboard"},
+		},
+		{
+			desc:         "wayland",
+			opts:         ClipboardOptions{Tools: []string{"wl-copy"}},
+			expectedTool: "wl-copy",
+			expectedArgs: []string{"--clear"},
+		},
+		{
+			desc:         "wayland primary selection",
+			opts:         ClipboardOptions{Tools: []string{"wl-copy"}, Primary: true},
+			expectedTool: "wl-copy",
+			expectedArgs: []string{"--primary", "--clear"},
+		},
+		{
+			desc:         "termux",
+			opts:         ClipboardOptions{Tools: []string{"termux-clipboard-set"}},
+			expectedTool: "termux-clipboard-set",
+			expectedArgs: []string{""},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeTools(t)
+			m := &mockCommand{Input: "not cleared"}
+			var tool string
+			var args []string
+			opts := tc.opts
+			opts.Runner = mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+				tool, args = filepath.Base(cmdName), cmdArgs
+				return m
+			})
+			c := newTestClipboard(t, opts)
+			require.NoError(t, c.Clear())
+			require.Equal(t, tc.expectedTool, tool)
+			require.Equal(t, tc.expectedArgs, args)
+			require.Equal(t, "", m.Input)
+		})
+	}
+}
+
+func TestClipboard_sensitiveVerify(t *testing.T) {
+	testCases := []struct {
+		desc             string
+		text             string
+		expectedCommands []string
+	}{
+		{
+			desc:             "sensitive copy is not pasted back",
+			text:             "key: " + awsKeyID,
+			expectedCommands: []string{"xclip -in -selection clipboard -loops 1"},
+		},
+		{
+			desc:             "other copies are pasted back",
+			text:             "some text",
+			expectedCommands: []string{"xclip -in -selection clipboard", "xclip -out -selection clipboard"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeTools(t)
+			var commands []string
+			c := newTestClipboard(t, ClipboardOptions{
+				Tools:    []string{"xclip"},
+				Policies: []Policy{Detect(Sensitive, AWSKeys)},
+				Runner: mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+					commands = append(commands, strings.Join(append([]string{filepath.Base(cmdName)}, cmdArgs...), " "))
+					return &mockCommand{Output: tc.text}
+				}),
+			}, WithMiddleware(Verify()))
+			require.NoError(t, c.CopyText(tc.text))
+			require.Equal(t, tc.expectedCommands, commands)
+		})
+	}
+}
+
+func TestClipboard_Capabilities(t *testing.T) {
 	testCases := []struct {
 		desc           string
-		mockClosure    func(m *mockCommand)
-		expectedOutput string
-		expectedError  error
+		tools          []string
+		expectedOutput Capabilities
 	}{
 		{
-			desc: "happy path",
-			mockClosure: func(m *mockCommand) {
-				m.Output = "some text"
+			desc:  "xsel",
+			tools: []string{"xsel"},
+			expectedOutput: Capabilities{
+				Selections: []Selection{SelectionClipboard, SelectionPrimary},
+				Clear:      true,
+				Streaming:  true,
+			},
+		},
+		{
+			desc:  "wayland",
+			tools: []string{"wl-copy"},
+			expectedOutput: Capabilities{
+				Selections: []Selection{SelectionClipboard, SelectionPrimary},
+				Types:      true,
+				Watch:      true,
+				Clear:      true,
+				OneShot:    true,
+				Streaming:  true,
 			},
-			expectedOutput: "some text",
 		},
 		{
-			desc: "error",
-			mockClosure: func(m *mockCommand) {
-				m.ErrOutput = errors.New("paste text error")
+			desc:  "termux",
+			tools: []string{"termux-clipboard-set"},
+			expectedOutput: Capabilities{
+				Selections: []Selection{SelectionClipboard},
+				Clear:      true,
+				Streaming:  true,
 			},
-			expectedError: errors.New("paste text error"),
+		},
+		{
+			desc:           "no tools",
+			tools:          []string{"copyq"},
+			expectedOutput: Capabilities{},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeTools(t)
+			c := newTestClipboard(t, ClipboardOptions{Tools: tc.tools})
+			require.Equal(t, tc.expectedOutput, c.Capabilities())
+		})
+	}
+}
+
+func TestClipboard_fakeTools(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		unavailable    string
+		expectedOutput string
+		expectedError  error
+	}{
+		{
+			desc:           "first tool",
+			expectedOutput: "héllo\r\nwörld",
+		},
+		{
+			desc:           "fallback to the next tool",
+			unavailable:    "xsel,xclip",
+			expectedOutput: "héllo\r\nwörld",
+		},
+		{
+			desc:          "no tool reaches the display",
+			unavailable:   "all",
+			expectedError: errors.New("all clipboard tools failed: xsel: waiting for command: exit status 1 (unavailable); xclip: waiting for command: exit status 1 (unavailable); wl-copy: waiting for command: exit status 1 (unavailable)"),
 		},
 	}
 	for _, tc := range testCases {
-		m := new(mockCommand)
-		newCmd = func(cmdName string, cmdArgs ...string) command.Command {
-			return m
-		}
 		t.Run(tc.desc, func(t *testing.T) {
-			tc.mockClosure(m)
-			output, err := pasteText()
+			dir := t.TempDir()
+			require.NoError(t, fakebin.Install(dir, "xsel", "xclip", "wl-copy", "wl-paste"))
+			t.Setenv("PATH", dir)
+			t.Setenv(fakebin.StateDirEnv, t.TempDir())
+			t.Setenv(fakebin.UnavailableEnv, tc.unavailable)
+
+			c := newTestClipboard(t)
+			var output string
+			err := c.CopyText("héllo\r\nwörld")
+			if err == nil {
+				output, err = c.PasteText()
+			}
 			if err != nil {
 				if tc.expectedError == nil {
 					t.Fatalf("expected no error, got %v", err)
@@ -100,16 +285,151 @@ func Test_pasteText(t *testing.T) {
 	}
 }
 
-type mockCommand struct {
-	ErrTextInput error
-	ErrOutput    error
-	Output       string
+func TestClipboard_forkingTools(t *testing.T) {
+	for _, tool := range []string{"xclip", "wl-copy"} {
+		t.Run(tool, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, fakebin.Install(dir, "xclip", "wl-copy", "wl-paste"))
+			t.Setenv("PATH", dir)
+			t.Setenv(fakebin.StateDirEnv, t.TempDir())
+
+			// The tool forks a process serving the copy, which
+			// keeps running until another copy replaces it.
+			c := newTestClipboard(t, ClipboardOptions{Tools: []string{tool}})
+			copied := make(chan error, 1)
+			go func() { copied <- c.CopyText("some text") }()
+			select {
+			case err := <-copied:
+				require.NoError(t, err)
+			case <-time.After(2 * time.Second):
+				t.Fatal("copying waited for the process serving the copy")
+			}
+			text, err := c.PasteText()
+			require.NoError(t, err)
+			require.Equal(t, "some text", text)
+		})
+	}
 }
 
-func (m *mockCommand) TextInput(text string) error {
-	return m.ErrTextInput
+func TestClipboard_Watch(t *testing.T) {
+	for _, primary := range []bool{false, true} {
+		primary := primary
+		t.Run(fmt.Sprintf("primary %v", primary), func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, fakebin.Install(dir, "wl-copy", "wl-paste"))
+			// wl-paste --watch runs echo.
+			t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+			t.Setenv(fakebin.StateDirEnv, t.TempDir())
+
+			c := newTestClipboard(t, ClipboardOptions{Primary: primary})
+			other := newTestClipboard(t, ClipboardOptions{Primary: !primary})
+			require.True(t, c.Capabilities().Watch)
+			require.NoError(t, c.CopyText("first"))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			texts, err := c.Watch(ctx)
+			require.NoError(t, err)
+			require.Equal(t, "first", <-texts)
+
+			require.NoError(t, other.CopyText("other selection"))
+			require.NoError(t, c.CopyText("second"))
+			require.Equal(t, "second", <-texts)
+			cancel()
+			for range texts {
+			}
+		})
+	}
 }
 
-func (m *mockCommand) TextOutput() (string, error) {
-	return m.Output, m.ErrOutput
+func TestClipboard_wsl(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		tools          []string
+		wayland        string
+		unavailable    string
+		expectedOutput string
+	}{
+		{
+			desc:           "windows clipboard",
+			tools:          []string{"xsel", "clip.exe", "powershell.exe"},
+			expectedOutput: "héllo\nwörld",
+		},
+		{
+			desc:           "WSLg",
+			tools:          []string{"wl-copy", "wl-paste", "clip.exe", "powershell.exe"},
+			wayland:        "wayland-0",
+			expectedOutput: "héllo\r\nwörld",
+		},
+		{
+			desc:           "WSLg not working",
+			tools:          []string{"wl-copy", "wl-paste", "clip.exe", "powershell.exe"},
+			wayland:        "wayland-0",
+			unavailable:    "wl-copy,wl-paste",
+			expectedOutput: "héllo\nwörld",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, fakebin.Install(dir, tc.tools...))
+			t.Setenv("PATH", dir)
+			t.Setenv("WSL_INTEROP", "/run/WSL/1_interop")
+			t.Setenv("WAYLAND_DISPLAY", tc.wayland)
+			t.Setenv(fakebin.StateDirEnv, t.TempDir())
+			t.Setenv(fakebin.UnavailableEnv, tc.unavailable)
+
+			c := newTestClipboard(t)
+			require.NoError(t, c.CopyText("héllo\r\nwörld"))
+			output, err := c.PasteText()
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedOutput, output)
+		})
+	}
+}
+
+func TestClipboard_wsl_clipInput(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, fakebin.Install(dir, "clip.exe", "powershell.exe"))
+	t.Setenv("PATH", dir)
+	t.Setenv("WSL_INTEROP", "/run/WSL/1_interop")
+	t.Setenv("WAYLAND_DISPLAY", "")
+	var cmd *mockCommand
+	c := newTestClipboard(t, ClipboardOptions{
+		Runner: mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+			cmd = &mockCommand{}
+			return cmd
+		}),
+	})
+
+	require.NoError(t, c.CopyText("hé"))
+	require.NotNil(t, cmd)
+	require.Equal(t, "\xff\xfeh\x00\xe9\x00", cmd.Input)
+}
+
+func TestClipboard_sensitive(t *testing.T) {
+	fakeTools(t)
+	var cmd []string
+	var logs bytes.Buffer
+	c := newTestClipboard(t, ClipboardOptions{
+		Tools:    []string{"xclip"},
+		Policies: []Policy{Detect(Sensitive)},
+		Runner: mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+			cmd = append([]string{filepath.Base(cmdName)}, cmdArgs...)
+			return &mockCommand{}
+		}),
+		Hooks: Hooks{
+			Logger:     slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+			LogContent: true,
+		},
+	})
+
+	require.NoError(t, c.CopyText("some text"))
+	require.Equal(t, []string{"xclip", "-in", "-selection", "clipboard"}, cmd)
+	require.Contains(t, logs.String(), "content=\"some text\"")
+
+	logs.Reset()
+	require.NoError(t, c.CopyText("key: "+awsKeyID))
+	require.Equal(t, []string{"xclip", "-in", "-selection", "clipboard", "-loops", "1"}, cmd)
+	require.Contains(t, logs.String(), "sensitive=true")
+	require.NotContains(t, logs.String(), awsKeyID)
 }
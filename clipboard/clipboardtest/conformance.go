@@ -0,0 +1,225 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboardtest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tiagomelo/go-clipboard/clipboard"
+)
+
+// Factory returns the clipboard under test, using the given selection.
+// Both a clipboard.Clipboard and a clipboard.Backend can be returned.
+// Every clipboard returned by a factory must share the same state, so
+// that what one copies, another pastes. The suite doesn't expect the
+// clipboard to be empty when it starts.
+type Factory func(t *testing.T, selection clipboard.Selection) clipboard.Clipboard
+
+// eventTimeout is how long a watched change may take to be reported.
+const eventTimeout = 5 * time.Second
+
+// concurrency is how many goroutines access the clipboard at once.
+const concurrency = 8
+
+// payloads are the texts that must survive a round trip.
+var payloads = []struct {
+	name string
+	text string
+}{
+	{name: "ASCII", text: "some text"},
+	{name: "UTF-8", text: "héllo wörld, こんにちは, 👋"},
+	{name: "empty", text: ""},
+	{name: "large", text: strings.Repeat("0123456789abcdéf", 64*1024)},
+	{name: "NUL", text: "before\x00after"},
+	{name: "CRLF", text: "first line\r\nsecond line\r\n"},
+}
+
+// RunConformance runs the conformance test suite against the clipboards
+// returned by factory. Tests of what the capabilities of the clipboard
+// say isn't supported are skipped. What they say is supported must work:
+// failing with an error wrapping errors.ErrUnsupported fails the test.
+func RunConformance(t *testing.T, factory Factory) {
+	t.Run("RoundTrip", func(t *testing.T) {
+		for _, p := range payloads {
+			p := p
+			t.Run(p.name, func(t *testing.T) {
+				c := factory(t, clipboard.SelectionClipboard)
+				copyText(t, c, p.text)
+				if got := pasteText(t, c); got != p.text {
+					t.Fatalf("pasted %s, want %s", abbreviate(got), abbreviate(p.text))
+				}
+			})
+		}
+	})
+	t.Run("Selections", func(t *testing.T) {
+		c := factory(t, clipboard.SelectionClipboard)
+		if !hasSelection(c.Capabilities(), clipboard.SelectionPrimary) {
+			t.Skip("the primary selection is not supported")
+		}
+		primary := factory(t, clipboard.SelectionPrimary)
+		copyText(t, c, "clipboard text")
+		copyText(t, primary, "primary text")
+		if got := pasteText(t, c); got != "clipboard text" {
+			t.Fatalf("pasted %q from the clipboard, want %q", got, "clipboard text")
+		}
+		if got := pasteText(t, primary); got != "primary text" {
+			t.Fatalf("pasted %q from the primary selection, want %q", got, "primary text")
+		}
+	})
+	t.Run("Types", func(t *testing.T) {
+		c := factory(t, clipboard.SelectionClipboard)
+		if !c.Capabilities().Types {
+			t.Skip("listing types is not supported")
+		}
+		lister, ok := c.(clipboard.TypeLister)
+		if !ok {
+			t.Fatal("the capabilities include types, but the clipboard is no clipboard.TypeLister")
+		}
+		copyText(t, c, "some text")
+		types, err := lister.Types()
+		if err != nil {
+			t.Fatalf("listing types: %v", err)
+		}
+		for _, typ := range types {
+			if isTextType(typ) {
+				return
+			}
+		}
+		t.Fatalf("types %q of copied text include no text type", types)
+	})
+	t.Run("Clear", func(t *testing.T) {
+		c := factory(t, clipboard.SelectionClipboard)
+		if !c.Capabilities().Clear {
+			t.Skip("clearing is not supported")
+		}
+		copyText(t, c, "some text")
+		if err := c.Clear(); err != nil {
+			t.Fatalf("clearing: %v", err)
+		}
+		if got, err := c.PasteText(); err == nil && got != "" {
+			t.Fatalf("pasted %q after clearing, want nothing", got)
+		}
+	})
+	t.Run("Watch", func(t *testing.T) {
+		c := factory(t, clipboard.SelectionClipboard)
+		if !c.Capabilities().Watch {
+			t.Skip("watching is not supported")
+		}
+		watcher, ok := c.(clipboard.Watcher)
+		if !ok {
+			t.Fatal("the capabilities include watching, but the clipboard is no clipboard.Watcher")
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		texts, err := watcher.Watch(ctx)
+		if err != nil {
+			t.Fatalf("watching: %v", err)
+		}
+		want := fmt.Sprintf("watched at %d", time.Now().UnixNano())
+		copyText(t, c, want)
+		timeout := time.After(eventTimeout)
+		for done := false; !done; {
+			select {
+			case got, ok := <-texts:
+				if !ok {
+					t.Fatal("watch stopped before reporting the change")
+				}
+				done = got == want
+			case <-timeout:
+				t.Fatalf("change to %q was not reported", want)
+			}
+		}
+		cancel()
+		for {
+			select {
+			case _, ok := <-texts:
+				if !ok {
+					return
+				}
+			case <-time.After(eventTimeout):
+				t.Fatal("watch did not stop when its context was done")
+			}
+		}
+	})
+	t.Run("Concurrent", func(t *testing.T) {
+		c := factory(t, clipboard.SelectionClipboard)
+		copied := make(map[string]bool)
+		var wg sync.WaitGroup
+		errs := make(chan error, 2*concurrency)
+		for i := 0; i < concurrency; i++ {
+			text := fmt.Sprintf("text %d", i)
+			copied[text] = true
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := c.CopyText(text); err != nil {
+					errs <- fmt.Errorf("copying: %w", err)
+					return
+				}
+				if _, err := c.PasteText(); err != nil {
+					errs <- fmt.Errorf("pasting: %w", err)
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Error(err)
+		}
+		if got := pasteText(t, c); !copied[got] {
+			t.Fatalf("pasted %q, which was never copied", got)
+		}
+	})
+}
+
+// copyText copies text, failing the test on error.
+func copyText(t *testing.T, c clipboard.Clipboard, text string) {
+	t.Helper()
+	if err := c.CopyText(text); err != nil {
+		t.Fatalf("copying %s: %v", abbreviate(text), err)
+	}
+}
+
+// pasteText pastes text, failing the test on error.
+func pasteText(t *testing.T, c clipboard.Clipboard) string {
+	t.Helper()
+	text, err := c.PasteText()
+	if err != nil {
+		t.Fatalf("pasting: %v", err)
+	}
+	return text
+}
+
+// hasSelection reports whether caps include the given selection.
+func hasSelection(caps clipboard.Capabilities, selection clipboard.Selection) bool {
+	for _, s := range caps.Selections {
+		if s == selection {
+			return true
+		}
+	}
+	return false
+}
+
+// isTextType reports whether typ is a MIME type or X11 target of text.
+func isTextType(typ string) bool {
+	switch typ {
+	case "UTF8_STRING", "STRING", "TEXT":
+		return true
+	}
+	return strings.HasPrefix(typ, "text/plain")
+}
+
+// abbreviate quotes text, shortening it if it is long.
+func abbreviate(text string) string {
+	if len(text) > 64 {
+		return fmt.Sprintf("%q... (%d bytes)", text[:64], len(text))
+	}
+	return fmt.Sprintf("%q", text)
+}
@@ -0,0 +1,5 @@
+// Package clipboardtest provides a conformance test suite that every
+// clipboard backend, built in or third-party, is expected to pass,
+// Preserve, which lets tests use the clipboard without losing its content,
+// and Memory, a clipboard kept in memory for tests that need no display.
+package clipboardtest
@@ -0,0 +1,31 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+// fakebin emulates the clipboard tools used on Linux and Termux, keeping
+// the clipboard in the directory named by GO_CLIPBOARD_FAKEBIN_DIR.
+//
+// Install it under the names of the tools, in a directory to put first
+// in the PATH of the program under test:
+//
+//	fakebin install <dir> [tool ...]
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/clipboardtest/fakebin"
+)
+
+func main() {
+	fakebin.Main()
+	if len(os.Args) < 3 || os.Args[1] != "install" {
+		fmt.Fprintln(os.Stderr, "usage: fakebin install <dir> [tool ...]")
+		os.Exit(2)
+	}
+	if err := fakebin.Install(os.Args[2], os.Args[3:]...); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
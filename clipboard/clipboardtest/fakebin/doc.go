@@ -0,0 +1,41 @@
+// Package fakebin emulates the clipboard tools used on Linux, Termux and
+// WSL, so that code running them for real can be tested without a display.
+//
+// A binary calling Main acts as xsel, xclip, wl-copy, wl-paste,
+// termux-clipboard-set, termux-clipboard-get, clip.exe or powershell.exe
+// when it runs under one of their names. Install copies the running binary into a directory under
+// those names. Every fake tool keeps the selections in the directory
+// named by the GO_CLIPBOARD_FAKEBIN_DIR environment variable, so what one
+// copies, the others paste. What xclip and wl-copy copy as a target
+// other than text, with -target or --type, is only offered as that
+// target, as is. Like the real tools, xclip and wl-copy fork a process
+// serving their copy until another one replaces it, which inherits their
+// standard error, or keep serving it themselves when run with -quiet or
+// --foreground. wl-paste --watch runs its command on every change.
+// clip.exe reads UTF-16LE text, and garbles any other, and
+// powershell.exe pastes with Get-Clipboard, ending lines with CRLF.
+// Tools listed in GO_CLIPBOARD_FAKEBIN_UNAVAILABLE fail as if there was
+// no display server.
+//
+// Tests usually call Main from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		fakebin.Main()
+//		os.Exit(m.Run())
+//	}
+//
+//	func TestCopy(t *testing.T) {
+//		dir := t.TempDir()
+//		if err := fakebin.Install(dir, "xclip"); err != nil {
+//			t.Fatal(err)
+//		}
+//		t.Setenv("PATH", dir)
+//		t.Setenv(fakebin.StateDirEnv, t.TempDir())
+//		// ...
+//	}
+//
+// The fakebin command does the same outside of tests:
+//
+//	go install github.com/tiagomelo/go-clipboard/clipboard/clipboardtest/fakebin/cmd/fakebin
+//	fakebin install ~/fake-tools
+package fakebin
@@ -0,0 +1,664 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package fakebin
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/charset"
+)
+
+const (
+	// StateDirEnv names the directory where the fake tools keep
+	// the selections. Without it, a directory in os.TempDir is used.
+	StateDirEnv = "GO_CLIPBOARD_FAKEBIN_DIR"
+	// UnavailableEnv holds a comma-separated list of tools that fail
+	// as if there was no display server, or "all".
+	UnavailableEnv = "GO_CLIPBOARD_FAKEBIN_UNAVAILABLE"
+
+	// serveEnv makes a fake tool serve the named selection, as the
+	// process forked by xclip and wl-copy to serve their copy does.
+	serveEnv = "GO_CLIPBOARD_FAKEBIN_SERVE"
+)
+
+// servePollInterval is how often a tool serving a selection
+// in the foreground checks whether it was replaced.
+const servePollInterval = 10 * time.Millisecond
+
+// Tools are the names of the clipboard tools that are emulated.
+var Tools = []string{
+	"xsel",
+	"xclip",
+	"wl-copy",
+	"wl-paste",
+	"termux-clipboard-set",
+	"termux-clipboard-get",
+	"clip.exe",
+	"powershell.exe",
+}
+
+// Main runs the fake tool named by os.Args[0] and exits, if there is one.
+// Otherwise it returns, so that the binary can go on as usual.
+func Main() {
+	name := toolName(os.Args[0])
+	if !isTool(name) {
+		return
+	}
+	os.Exit(Run(name, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// Install copies the running binary into dir under the names of the given
+// tools, or of all of them if none is given, so that, once dir is in the
+// PATH, running them runs the fakes. The binary must call Main.
+func Install(dir string, names ...string) error {
+	if len(names) == 0 {
+		names = Tools
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(exe)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if !isTool(name) {
+			return fmt.Errorf("fakebin: unknown tool %s", name)
+		}
+		if runtime.GOOS == "windows" && !strings.HasSuffix(name, ".exe") {
+			name += ".exe"
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run emulates the named tool, called with args, and returns its exit code.
+func Run(name string, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	t := &tool{
+		stdin:  stdin,
+		stdout: stdout,
+		stderr: stderr,
+		state:  state{dir: stateDir()},
+	}
+	var err error
+	switch selection := os.Getenv(serveEnv); {
+	case selection != "" && isTool(name):
+		err = t.state.serve(selection)
+	case !isTool(name):
+		err = fmt.Errorf("fakebin: unknown tool %s", name)
+	case unavailable(name):
+		err = errors.New(unavailableMessage(name))
+	case name == "xsel":
+		err = t.xsel(args)
+	case name == "xclip":
+		err = t.xclip(args)
+	case name == "wl-copy":
+		err = t.wlCopy(args)
+	case name == "wl-paste":
+		err = t.wlPaste(args)
+	case name == "termux-clipboard-set":
+		err = t.termuxSet(args)
+	case name == "termux-clipboard-get":
+		err = t.termuxGet(args)
+	case name == "clip.exe":
+		err = t.clip(args)
+	case name == "powershell.exe":
+		err = t.powershell(args)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// tool is a running fake tool.
+type tool struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	state  state
+}
+
+// xsel emulates xsel, which uses the primary selection
+// and writes it out unless told otherwise.
+func (t *tool) xsel(args []string) error {
+	selection, mode := "primary", "output"
+	for _, arg := range args {
+		switch arg {
+		case "-i", "--input":
+			mode = "input"
+		case "-o", "--output":
+			mode = "output"
+		case "-c", "--clear":
+			mode = "clear"
+		case "-p", "--primary":
+			selection = "primary"
+		case "-b", "--clipboard":
+			selection = "clipboard"
+		case "-n", "--nodetach":
+		default:
+			return fmt.Errorf("xsel: Invalid option: %s", arg)
+		}
+	}
+	switch mode {
+	case "input":
+		return t.copyInput(selection, "", nil)
+	case "clear":
+		return t.state.clear(selection)
+	}
+	text, _, _, err := t.state.read(selection)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(t.stdout, text)
+	return err
+}
+
+// xclip emulates xclip, which uses the primary selection and reads it in
+// unless told otherwise. Like xclip, options can be abbreviated.
+func (t *tool) xclip(args []string) error {
+	selection, mode, target, foreground := "primary", "input", "", false
+	var files []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		option := strings.TrimPrefix(arg, "-")
+		switch {
+		case option == arg:
+			files = append(files, arg)
+		case isOption(option, "in", 1):
+			mode = "input"
+		case isOption(option, "out", 1):
+			mode = "output"
+		case isOption(option, "quiet", 1):
+			foreground = true
+		case isOption(option, "silent", 2), isOption(option, "verbose", 1):
+		case isOption(option, "selection", 2), isOption(option, "target", 1), isOption(option, "loops", 1):
+			if i+1 == len(args) {
+				return fmt.Errorf("xclip: option %s requires an argument", arg)
+			}
+			i++
+			switch option[0] {
+			case 's':
+				switch value := args[i]; {
+				case isOption(value, "primary", 1):
+					selection = "primary"
+				case isOption(value, "clipboard", 1):
+					selection = "clipboard"
+				default:
+					return fmt.Errorf("xclip: unsupported selection %s", value)
+				}
+			case 't':
+				target = args[i]
+			}
+		default:
+			return fmt.Errorf("xclip: unknown option %s", arg)
+		}
+	}
+	if mode == "input" {
+		if err := t.copyInput(selection, target, files); err != nil {
+			return err
+		}
+		if !foreground {
+			return t.fork("xclip", selection)
+		}
+		return t.state.serve(selection)
+	}
+	text, typ, ok, err := t.state.read(selection)
+	if err != nil {
+		return err
+	}
+	if target == "" {
+		target = "UTF8_STRING"
+	}
+	if !ok {
+		return fmt.Errorf("Error: target %s not available", target)
+	}
+	if target == "TARGETS" {
+		targets := "TARGETS\nUTF8_STRING\nSTRING\nTEXT\ntext/plain;charset=utf-8\ntext/plain\n"
+		if typ != "" {
+			targets = "TARGETS\n" + typ + "\n"
+		}
+		_, err := io.WriteString(t.stdout, targets)
+		return err
+	}
+	out, ok := encode(text, typ, target)
+	if !ok {
+		return fmt.Errorf("Error: target %s not available", target)
+	}
+	_, err = io.WriteString(t.stdout, out)
+	return err
+}
+
+// wlCopy emulates wl-copy, which copies its arguments
+// or, without any, its standard input.
+func (t *tool) wlCopy(args []string) error {
+	selection, clear, foreground, typ := "clipboard", false, false, ""
+	var text []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-p", "--primary":
+			selection = "primary"
+		case "-c", "--clear":
+			clear = true
+		case "-f", "--foreground":
+			foreground = true
+		case "-o", "--paste-once", "-n", "--trim-newline", "--regular":
+		case "-t", "--type", "-s", "--seat":
+			if i+1 == len(args) {
+				return fmt.Errorf("wl-copy: option %s requires an argument", arg)
+			}
+			i++
+			if arg == "-t" || arg == "--type" {
+				typ = args[i]
+			}
+		default:
+			switch {
+			case !strings.HasPrefix(arg, "-"):
+				text = append(text, arg)
+			case strings.HasPrefix(arg, "--type="):
+				typ = strings.TrimPrefix(arg, "--type=")
+			case !strings.HasPrefix(arg, "--seat="):
+				return fmt.Errorf("wl-copy: unrecognized option '%s'", arg)
+			}
+		}
+	}
+	if clear {
+		return t.state.clear(selection)
+	}
+	var err error
+	if len(text) > 0 {
+		err = t.state.write(selection, strings.Join(text, " "), typ)
+	} else {
+		err = t.copyInput(selection, typ, nil)
+	}
+	if err != nil {
+		return err
+	}
+	if !foreground {
+		return t.fork("wl-copy", selection)
+	}
+	return t.state.serve(selection)
+}
+
+// wlPaste emulates wl-paste, which adds a newline
+// to the text it writes out unless told otherwise.
+func (t *tool) wlPaste(args []string) error {
+	selection, listTypes, newline, typ := "clipboard", false, true, ""
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "-w" || arg == "--watch":
+			if i+1 == len(args) {
+				return fmt.Errorf("wl-paste: option %s requires an argument", arg)
+			}
+			return t.watch(selection, args[i+1:])
+		case arg == "-p" || arg == "--primary":
+			selection = "primary"
+		case arg == "-n" || arg == "--no-newline":
+			newline = false
+		case arg == "-l" || arg == "--list-types":
+			listTypes = true
+		case arg == "-t" || arg == "--type" || arg == "-s" || arg == "--seat":
+			if i+1 == len(args) {
+				return fmt.Errorf("wl-paste: option %s requires an argument", arg)
+			}
+			i++
+			if arg == "-t" || arg == "--type" {
+				typ = args[i]
+			}
+		case strings.HasPrefix(arg, "--type="):
+			typ = strings.TrimPrefix(arg, "--type=")
+		case strings.HasPrefix(arg, "--seat="):
+		default:
+			return fmt.Errorf("wl-paste: unrecognized option '%s'", arg)
+		}
+	}
+	text, copiedTyp, ok, err := t.state.read(selection)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("Nothing is copied")
+	}
+	if listTypes {
+		types := "text/plain;charset=utf-8\ntext/plain\nUTF8_STRING\nSTRING\nTEXT\n"
+		if copiedTyp != "" {
+			types = copiedTyp + "\n"
+		}
+		_, err := io.WriteString(t.stdout, types)
+		return err
+	}
+	if typ == "" {
+		typ = copiedTyp
+	}
+	out, ok := encode(text, copiedTyp, typ)
+	if !ok {
+		return fmt.Errorf("Clipboard content is not available as requested type \"%s\"", typ)
+	}
+	if newline {
+		out += "\n"
+	}
+	_, err = io.WriteString(t.stdout, out)
+	return err
+}
+
+// watch runs the command every time the text of the selection changes,
+// starting with the current one, with the text as its standard input,
+// as "wl-paste --watch" does, until the tool is killed.
+func (t *tool) watch(selection string, command []string) error {
+	path := filepath.Join(t.state.dir, selection)
+	var last fs.FileInfo
+	for first := true; ; first = false {
+		info, err := os.Stat(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		replaced := info != nil && last != nil && !os.SameFile(info, last)
+		if first || replaced || (info == nil) != (last == nil) {
+			last = info
+			text, _, _, err := t.state.read(selection)
+			if err != nil {
+				return err
+			}
+			cmd := exec.Command(command[0], command[1:]...)
+			cmd.Stdin = strings.NewReader(text)
+			cmd.Stdout, cmd.Stderr = t.stdout, t.stderr
+			if err := cmd.Run(); err != nil {
+				return err
+			}
+		}
+		time.Sleep(servePollInterval)
+	}
+}
+
+// termuxSet emulates termux-clipboard-set, which copies its
+// arguments or, without any, its standard input.
+func (t *tool) termuxSet(args []string) error {
+	if len(args) > 0 {
+		return t.state.write("clipboard", strings.Join(args, " "), "")
+	}
+	return t.copyInput("clipboard", "", nil)
+}
+
+// termuxGet emulates termux-clipboard-get.
+func (t *tool) termuxGet(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("termux-clipboard-get: unexpected arguments %q", args)
+	}
+	text, _, _, err := t.state.read("clipboard")
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(t.stdout, text)
+	return err
+}
+
+// clip emulates clip.exe, as run through interop under WSL, which reads
+// UTF-16LE text when it starts with a byte order mark, and anything else
+// in the console code page, here iso-8859-1, so that UTF-8 text is garbled.
+func (t *tool) clip(args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("ERROR: Invalid argument/option - '%s'.", args[0])
+	}
+	data, err := io.ReadAll(t.stdin)
+	if err != nil {
+		return err
+	}
+	target := "iso-8859-1"
+	if bytes.HasPrefix(data, []byte{0xff, 0xfe}) {
+		target = "utf-16le"
+	}
+	text, err := charset.Decode(data, target, false)
+	if err != nil {
+		return err
+	}
+	return t.state.write("clipboard", text, "")
+}
+
+// powershell emulates PowerShell running Get-Clipboard, which writes
+// every line of the clipboard followed by CRLF, and nothing when it is
+// empty. Other statements before it, separated by semicolons, are
+// ignored, as are the options.
+func (t *tool) powershell(args []string) error {
+	var command []string
+	for i, arg := range args {
+		if strings.EqualFold(arg, "-Command") || strings.EqualFold(arg, "-c") {
+			command = args[i+1:]
+			break
+		}
+	}
+	statements := strings.Split(strings.Join(command, " "), ";")
+	if last := strings.TrimSpace(statements[len(statements)-1]); !strings.EqualFold(last, "Get-Clipboard") {
+		return fmt.Errorf("powershell.exe: unexpected command %q", strings.Join(command, " "))
+	}
+	text, _, _, err := t.state.read("clipboard")
+	if err != nil || text == "" {
+		return err
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if _, err := io.WriteString(t.stdout, strings.TrimSuffix(line, "\r")+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fork starts a process serving the selection in the background, without
+// waiting for it, as xclip and wl-copy do unless told to stay in the
+// foreground. Like theirs, it inherits the standard error of the tool.
+func (t *tool) fork(name, selection string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := &exec.Cmd{
+		Path: exe,
+		Args: []string{name},
+		Env:  append(os.Environ(), serveEnv+"="+selection, StateDirEnv+"="+t.state.dir),
+	}
+	if f, ok := t.stderr.(*os.File); ok {
+		cmd.Stderr = f
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// copyInput copies the content of the given files or, without
+// any, the standard input, as the given target or MIME type.
+func (t *tool) copyInput(selection, typ string, files []string) error {
+	var text strings.Builder
+	if len(files) == 0 {
+		if _, err := io.Copy(&text, t.stdin); err != nil {
+			return err
+		}
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		text.Write(data)
+	}
+	return t.state.write(selection, text.String(), typ)
+}
+
+// state holds the selections, one file each, in a directory. The
+// target or MIME type of a selection copied as something other than
+// text is kept next to it, in a file with the .type extension.
+type state struct {
+	dir string
+}
+
+// read returns the text of the selection, the type it was copied as,
+// empty if it is text, and whether anything was copied to it.
+func (s state) read(selection string) (string, string, bool, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, selection))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", "", false, nil
+	}
+	if err != nil {
+		return "", "", false, err
+	}
+	typ, err := os.ReadFile(filepath.Join(s.dir, selection+".type"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return string(data), "", true, nil
+	}
+	return string(data), string(typ), err == nil, err
+}
+
+// write replaces the text of the selection atomically,
+// copied as the given type, or as text if it is empty.
+func (s state) write(selection, text, typ string) error {
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	typePath := filepath.Join(s.dir, selection+".type")
+	if _, ok := encode("", "", typ); ok {
+		if err := os.Remove(typePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	} else if err := os.WriteFile(typePath, []byte(typ), 0o600); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, ".copy-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.WriteString(tmp, text); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.dir, selection))
+}
+
+// serve waits until the text of the selection is replaced or cleared,
+// as a tool serving the selection in the foreground does.
+func (s state) serve(selection string) error {
+	path := filepath.Join(s.dir, selection)
+	served, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	for {
+		time.Sleep(servePollInterval)
+		if info, err := os.Stat(path); err != nil || !os.SameFile(info, served) {
+			return nil
+		}
+	}
+}
+
+// clear empties the selection.
+func (s state) clear(selection string) error {
+	os.Remove(filepath.Join(s.dir, selection+".type"))
+	err := os.Remove(filepath.Join(s.dir, selection))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// encode returns text, copied as typ, as the given target or MIME type,
+// and whether it can be converted to it: text copied as another type
+// is only available as that type, as is, and text as any text target.
+// STRING is Latin-1, as in X11.
+func encode(text, typ, target string) (string, bool) {
+	if typ != "" {
+		return text, target == typ
+	}
+	switch target {
+	case "", "UTF8_STRING", "TEXT", "text", "text/plain", "text/plain;charset=utf-8":
+		return text, true
+	case "STRING":
+		latin1 := make([]byte, 0, len(text))
+		for _, r := range text {
+			if r > 0xff {
+				r = '?'
+			}
+			latin1 = append(latin1, byte(r))
+		}
+		return string(latin1), true
+	}
+	return "", false
+}
+
+// stateDir returns the directory holding the selections.
+func stateDir() string {
+	if dir := os.Getenv(StateDirEnv); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), "go-clipboard-fakebin")
+}
+
+// unavailable reports whether the named tool must
+// fail as if there was no display server.
+func unavailable(name string) bool {
+	for _, tool := range strings.Split(os.Getenv(UnavailableEnv), ",") {
+		if tool = strings.TrimSpace(tool); tool == name || tool == "all" {
+			return true
+		}
+	}
+	return false
+}
+
+// unavailableMessage returns what the named tool prints
+// when it cannot reach the display server.
+func unavailableMessage(name string) string {
+	switch name {
+	case "xsel":
+		return "xsel: Can't open display: (null)"
+	case "xclip":
+		return "Error: Can't open display: (null)"
+	case "wl-copy", "wl-paste":
+		return "Failed to connect to a Wayland server"
+	case "clip.exe", "powershell.exe":
+		return "<3>WSL (1) ERROR: UtilConnectToInteropServer:300: connect failed 2"
+	}
+	return "Termux:API is not available"
+}
+
+// isOption reports whether option is name, abbreviated
+// to at least n characters.
+func isOption(option, name string, n int) bool {
+	return len(option) >= n && strings.HasPrefix(name, option)
+}
+
+// utf16le reports whether data looks like UTF-16LE text,
+// toolName returns the name of the tool run by the executable at path.
+func toolName(path string) string {
+	if name := filepath.Base(path); isTool(name) {
+		return name
+	}
+	return strings.TrimSuffix(filepath.Base(path), ".exe")
+}
+
+// isTool reports whether name is one of the emulated tools.
+func isTool(name string) bool {
+	for _, tool := range Tools {
+		if name == tool {
+			return true
+		}
+	}
+	return false
+}
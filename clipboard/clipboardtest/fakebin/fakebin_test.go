@@ -0,0 +1,309 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package fakebin
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	Main()
+	os.Exit(m.Run())
+}
+
+func TestTools(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		copy           []string
+		input          string
+		paste          []string
+		unavailable    string
+		expectedOutput string
+		expectedError  error
+	}{
+		{
+			desc:           "xsel",
+			copy:           []string{"xsel", "--input", "--clipboard"},
+			input:          "héllo\r\n",
+			paste:          []string{"xsel", "--output", "--clipboard"},
+			expectedOutput: "héllo\r\n",
+		},
+		{
+			desc:  "xsel with nothing copied",
+			paste: []string{"xsel", "--output", "--clipboard"},
+		},
+		{
+			desc:           "xsel uses the primary selection by default",
+			copy:           []string{"xsel", "-i"},
+			input:          "some text",
+			paste:          []string{"xsel", "--output", "--primary"},
+			expectedOutput: "some text",
+		},
+		{
+			desc:           "xsel clear",
+			copy:           []string{"xsel", "--clear", "--clipboard"},
+			paste:          []string{"xsel", "--output", "--clipboard"},
+			expectedOutput: "",
+		},
+		{
+			desc:          "xsel with an unknown option",
+			paste:         []string{"xsel", "--paste"},
+			expectedError: errors.New("xsel: Invalid option: --paste"),
+		},
+		{
+			desc:           "xclip",
+			copy:           []string{"xclip", "-in", "-selection", "clipboard"},
+			input:          "some text",
+			paste:          []string{"xclip", "-out", "-selection", "clipboard"},
+			expectedOutput: "some text",
+		},
+		{
+			desc:           "xclip with abbreviated options",
+			copy:           []string{"xclip", "-i", "-sel", "c", "-loops", "1"},
+			input:          "some text",
+			paste:          []string{"xclip", "-o", "-sel", "clip"},
+			expectedOutput: "some text",
+		},
+		{
+			desc:           "xclip STRING target",
+			copy:           []string{"xclip", "-in", "-selection", "clipboard"},
+			input:          "héllo ☺",
+			paste:          []string{"xclip", "-out", "-selection", "clipboard", "-target", "STRING"},
+			expectedOutput: "h\xe9llo ?",
+		},
+		{
+			desc:           "xclip targets",
+			copy:           []string{"xclip", "-in", "-selection", "clipboard"},
+			input:          "some text",
+			paste:          []string{"xclip", "-out", "-selection", "clipboard", "-target", "TARGETS"},
+			expectedOutput: "TARGETS\nUTF8_STRING\nSTRING\nTEXT\ntext/plain;charset=utf-8\ntext/plain\n",
+		},
+		{
+			desc:          "xclip with nothing copied",
+			paste:         []string{"xclip", "-out", "-selection", "clipboard"},
+			expectedError: errors.New("Error: target UTF8_STRING not available"),
+		},
+		{
+			desc:          "xclip with a target that isn't available",
+			copy:          []string{"xclip", "-in", "-selection", "clipboard"},
+			paste:         []string{"xclip", "-out", "-selection", "clipboard", "-target", "image/png"},
+			expectedError: errors.New("Error: target image/png not available"),
+		},
+		{
+			desc:           "wl-copy and wl-paste",
+			copy:           []string{"wl-copy", "--primary"},
+			input:          "some text",
+			paste:          []string{"wl-paste", "--no-newline", "--primary"},
+			expectedOutput: "some text",
+		},
+		{
+			desc:           "wl-copy arguments",
+			copy:           []string{"wl-copy", "--paste-once", "some", "text"},
+			paste:          []string{"wl-paste"},
+			expectedOutput: "some text\n",
+		},
+		{
+			desc:          "wl-copy clear",
+			copy:          []string{"wl-copy", "--clear"},
+			paste:         []string{"wl-paste", "--no-newline"},
+			expectedError: errors.New("Nothing is copied"),
+		},
+		{
+			desc:           "wl-paste types",
+			copy:           []string{"wl-copy"},
+			paste:          []string{"wl-paste", "--list-types"},
+			expectedOutput: "text/plain;charset=utf-8\ntext/plain\nUTF8_STRING\nSTRING\nTEXT\n",
+		},
+		{
+			desc:          "wl-paste with a type that isn't available",
+			copy:          []string{"wl-copy"},
+			paste:         []string{"wl-paste", "--type", "image/png"},
+			expectedError: errors.New(`Clipboard content is not available as requested type "image/png"`),
+		},
+		{
+			desc:           "xclip with a copied target",
+			copy:           []string{"xclip", "-in", "-selection", "clipboard", "-target", "text/uri-list"},
+			input:          "file:///tmp/a\r\n",
+			paste:          []string{"xclip", "-out", "-selection", "clipboard", "-target", "TARGETS"},
+			expectedOutput: "TARGETS\ntext/uri-list\n",
+		},
+		{
+			desc:          "xclip with a copied target pasted as text",
+			copy:          []string{"xclip", "-in", "-selection", "clipboard", "-target", "text/uri-list"},
+			input:         "file:///tmp/a\r\n",
+			paste:         []string{"xclip", "-out", "-selection", "clipboard"},
+			expectedError: errors.New("Error: target UTF8_STRING not available"),
+		},
+		{
+			desc:           "wl-copy with a type",
+			copy:           []string{"wl-copy", "--type", "text/uri-list"},
+			input:          "file:///tmp/a\r\n",
+			paste:          []string{"wl-paste", "--no-newline", "--type", "text/uri-list"},
+			expectedOutput: "file:///tmp/a\r\n",
+		},
+		{
+			desc:           "wl-paste types of a copied type",
+			copy:           []string{"wl-copy", "--type=text/uri-list"},
+			paste:          []string{"wl-paste", "--list-types"},
+			expectedOutput: "text/uri-list\n",
+		},
+		{
+			desc:           "termux",
+			copy:           []string{"termux-clipboard-set"},
+			input:          "some text",
+			paste:          []string{"termux-clipboard-get"},
+			expectedOutput: "some text",
+		},
+		{
+			desc:           "termux clear",
+			copy:           []string{"termux-clipboard-set", ""},
+			input:          "some text",
+			paste:          []string{"termux-clipboard-get"},
+			expectedOutput: "",
+		},
+		{
+			desc:           "clip.exe with utf-16le text",
+			copy:           []string{"clip.exe"},
+			input:          "\xff\xfeh\x00\xe9\x00\n\x00w\x00\xf6\x00",
+			paste:          []string{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard"},
+			expectedOutput: "hé\r\nwö\r\n",
+		},
+		{
+			desc:           "clip.exe with utf-16le text without byte order mark",
+			copy:           []string{"clip.exe"},
+			input:          "h\x00\xe9\x00",
+			paste:          []string{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard"},
+			expectedOutput: "h\x00é\x00\r\n",
+		},
+		{
+			desc:           "clip.exe with utf-8 text",
+			copy:           []string{"clip.exe"},
+			input:          "hé",
+			paste:          []string{"powershell.exe", "-NoProfile", "-Command", "[Console]::OutputEncoding = [Text.Encoding]::UTF8; Get-Clipboard"},
+			expectedOutput: "hÃ©\r\n",
+		},
+		{
+			desc:  "powershell.exe with nothing copied",
+			paste: []string{"powershell.exe", "-Command", "Get-Clipboard"},
+		},
+		{
+			desc:          "powershell.exe with another command",
+			paste:         []string{"powershell.exe", "-Command", "Set-Clipboard"},
+			expectedError: errors.New(`powershell.exe: unexpected command "Set-Clipboard"`),
+		},
+		{
+			desc:          "unavailable tool",
+			paste:         []string{"xclip", "-out", "-selection", "clipboard"},
+			unavailable:   "wl-paste,xclip",
+			expectedError: errors.New("Error: Can't open display: (null)"),
+		},
+		{
+			desc:          "all tools unavailable",
+			paste:         []string{"wl-paste"},
+			unavailable:   "all",
+			expectedError: errors.New("Failed to connect to a Wayland server"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, Install(dir))
+			t.Setenv("PATH", dir)
+			t.Setenv(StateDirEnv, t.TempDir())
+			t.Setenv(UnavailableEnv, tc.unavailable)
+			if tc.copy != nil {
+				_, err := run(tc.copy, tc.input)
+				require.NoError(t, err)
+			}
+			output, err := run(tc.paste, "")
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+				require.Equal(t, tc.expectedOutput, output)
+			}
+		})
+	}
+}
+
+func TestTools_fork(t *testing.T) {
+	for _, copy := range [][]string{{"xclip", "-in", "-selection", "clipboard"}, {"wl-copy"}} {
+		t.Run(copy[0], func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, Install(dir))
+			t.Setenv("PATH", dir)
+			t.Setenv(StateDirEnv, t.TempDir())
+
+			// The forked process keeps the pipe open.
+			stderr, w, err := os.Pipe()
+			require.NoError(t, err)
+			defer stderr.Close()
+			cmd := exec.Command(copy[0], copy[1:]...)
+			cmd.Stdin = strings.NewReader("some text")
+			cmd.Stderr = w
+			err = cmd.Run()
+			w.Close()
+			require.NoError(t, err)
+			done := make(chan struct{})
+			go func() {
+				io.Copy(io.Discard, stderr)
+				close(done)
+			}()
+			select {
+			case <-done:
+				t.Fatal("the process serving the copy exited")
+			case <-time.After(5 * servePollInterval):
+			}
+			_, err = run([]string{"xsel", "--input", "--clipboard"}, "other text")
+			require.NoError(t, err)
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("the process serving the copy kept running once replaced")
+			}
+		})
+	}
+}
+
+func TestInstall_unknownTool(t *testing.T) {
+	err := Install(t.TempDir(), "pbcopy")
+	require.EqualError(t, err, "fakebin: unknown tool pbcopy")
+}
+
+// run runs the command found in the PATH with the given input, and returns
+// its output, or an error holding its standard error if it fails. The
+// standard error goes to a file, which the processes forked by xclip and
+// wl-copy to serve their copy can keep open.
+func run(args []string, input string) (string, error) {
+	stderr, err := os.CreateTemp("", "fakebin-stderr-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(stderr.Name())
+	defer stderr.Close()
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(input)
+	var stdout bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, stderr
+	if err := cmd.Run(); err != nil {
+		msg, _ := os.ReadFile(stderr.Name())
+		return "", errors.New(strings.TrimSpace(string(msg)))
+	}
+	return stdout.String(), nil
+}
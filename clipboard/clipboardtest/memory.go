@@ -0,0 +1,147 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboardtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/tiagomelo/go-clipboard/clipboard"
+)
+
+// Memory is a clipboard keeping its text in memory, to test code using a
+// clipboard, such as a sync engine, without clipboard tools. It implements
+// clipboard.Watcher and clipboard.TypeLister, and records the texts copied
+// with CopyText, unlike those set with Set, as another program would.
+type Memory struct {
+	defaultTypes []string
+
+	mu       sync.Mutex
+	text     string
+	types    []string
+	copied   []string
+	copyErr  error
+	watchers map[chan string]struct{}
+}
+
+// NewMemory returns an empty clipboard whose text is offered as the given
+// types, unless Set gives others. Without types, listing them fails with
+// an error wrapping errors.ErrUnsupported, as with some clipboard tools.
+func NewMemory(types ...string) *Memory {
+	return &Memory{defaultTypes: types, types: types, watchers: make(map[chan string]struct{})}
+}
+
+// Set sets the text, offered as the given types, if any, as another
+// program would copy it, and sends it to the watchers.
+func (m *Memory) Set(text string, types ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.set(text, types)
+}
+
+// set implements Set.
+func (m *Memory) set(text string, types []string) {
+	if len(types) == 0 {
+		types = m.defaultTypes
+	}
+	m.text, m.types = text, types
+	for w := range m.watchers {
+		w <- text
+	}
+}
+
+// Copies returns the texts copied with CopyText.
+func (m *Memory) Copies() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.copied...)
+}
+
+// FailCopies makes CopyText fail with err from now on,
+// or succeed again if err is nil.
+func (m *Memory) FailCopies(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.copyErr = err
+}
+
+// Watched reports whether the clipboard is watched.
+func (m *Memory) Watched() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.watchers) > 0
+}
+
+// StopWatchers closes the channels of the watchers,
+// as when the tool watching the clipboard stops.
+func (m *Memory) StopWatchers() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for w := range m.watchers {
+		close(w)
+		delete(m.watchers, w)
+	}
+}
+
+// CopyText implements the clipboard.Clipboard interface.
+func (m *Memory) CopyText(s string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.copyErr != nil {
+		return m.copyErr
+	}
+	m.set(s, nil)
+	m.copied = append(m.copied, s)
+	return nil
+}
+
+// PasteText implements the clipboard.Clipboard interface.
+func (m *Memory) PasteText() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.text, nil
+}
+
+// Clear implements the clipboard.Clipboard interface.
+func (m *Memory) Clear() error {
+	m.Set("")
+	return nil
+}
+
+// Capabilities implements the clipboard.Clipboard interface.
+func (m *Memory) Capabilities() clipboard.Capabilities {
+	return clipboard.Capabilities{Selections: []clipboard.Selection{clipboard.SelectionClipboard}, Watch: true, Clear: true}
+}
+
+// Types implements the clipboard.TypeLister interface.
+func (m *Memory) Types() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.types == nil {
+		return nil, fmt.Errorf("listing types: %w", errors.ErrUnsupported)
+	}
+	return m.types, nil
+}
+
+// Watch implements the clipboard.Watcher interface. The
+// channel is closed once ctx is done, or by StopWatchers.
+func (m *Memory) Watch(ctx context.Context) (<-chan string, error) {
+	w := make(chan string, 16)
+	m.mu.Lock()
+	m.watchers[w] = struct{}{}
+	m.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if _, ok := m.watchers[w]; ok {
+			delete(m.watchers, w)
+			close(w)
+		}
+	}()
+	return w, nil
+}
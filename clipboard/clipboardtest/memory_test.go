@@ -0,0 +1,18 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboardtest
+
+import (
+	"testing"
+
+	"github.com/tiagomelo/go-clipboard/clipboard"
+)
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	RunConformance(t, func(t *testing.T, selection clipboard.Selection) clipboard.Clipboard {
+		return m
+	})
+}
@@ -0,0 +1,33 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboardtest
+
+import (
+	"testing"
+
+	"github.com/tiagomelo/go-clipboard/clipboard"
+)
+
+// Preserve snapshots the selections of c, which must implement
+// clipboard.Snapshotter, and puts their content back when the test
+// and its subtests complete, so that tests can use the real clipboard
+// without losing what the user copied. The test fails right away if
+// the content couldn't be put back, e.g. an image copied with a tool.
+func Preserve(tb testing.TB, c clipboard.Clipboard) {
+	tb.Helper()
+	s, ok := c.(clipboard.Snapshotter)
+	if !ok {
+		tb.Fatalf("clipboard %T can't be snapshotted", c)
+	}
+	snapshot, err := s.Snapshot()
+	if err != nil {
+		tb.Fatalf("snapshot: %v", err)
+	}
+	tb.Cleanup(func() {
+		if err := s.Restore(snapshot); err != nil {
+			tb.Errorf("restoring the clipboard: %v", err)
+		}
+	})
+}
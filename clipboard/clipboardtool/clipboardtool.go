@@ -4,29 +4,304 @@
 
 package clipboardtool
 
+import (
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+)
+
 // CopyTool encapsulates the details of a clipboard copy command.
 type CopyTool struct {
-	Name    string   // Name of the copy command or executable
-	CmdArgs []string // Arguments required for the copy operation
+	Name        string   // Name of the copy command or executable
+	Path        string   // Absolute path of the executable, resolved on detection
+	CmdArgs     []string // Arguments required for the copy operation
+	OneShotArgs []string // Extra arguments to serve the copy for a single paste, if supported
+	ClearArgs   []string // Arguments to empty the clipboard; if nil, empty input is copied
+	TargetArg   string   // Flag used to copy as a specific target, if supported
+	Charset     string   // Charset the text is converted to before it is copied; empty means UTF-8
+	BOM         bool     // The converted text is preceded by the byte order mark of the charset
+
+	// ForegroundArgs are extra arguments making a tool that forks a
+	// process to serve the copy serve it in the foreground instead.
+	ForegroundArgs []string
+}
+
+// Executable returns the resolved path of the copy tool,
+// or its name when the path is unknown.
+func (t *CopyTool) Executable() string {
+	if t.Path != "" {
+		return t.Path
+	}
+	return t.Name
+}
+
+// ClearCmdArgs returns the arguments that make the copy tool empty the
+// clipboard. The tool must be run with empty input.
+func (t *CopyTool) ClearCmdArgs() []string {
+	if t.ClearArgs != nil {
+		return t.ClearArgs
+	}
+	return t.CmdArgs
+}
+
+// withPath returns a copy of the tool pointing at the given path.
+func (t *CopyTool) withPath(path string) *CopyTool {
+	c := *t
+	c.Path = path
+	return &c
 }
 
 // PasteTool encapsulates the details of a clipboard paste command.
 type PasteTool struct {
-	Name    string   // Name of the paste command or executable
-	CmdArgs []string // Arguments required for the paste operation
+	Name      string   // Name of the paste command or executable
+	Path      string   // Absolute path of the executable, resolved on detection
+	CmdArgs   []string // Arguments required for the paste operation
+	TargetArg string   // Flag used to request a specific target, if supported
+	ProbeArgs []string // Arguments for a side-effect-free check, if not CmdArgs
+	TypesArgs []string // Arguments listing the targets held by the clipboard, if supported
+	WatchArgs []string // Arguments running a command printing a line on every change, if supported
+
+	// CRLF reports that the paste tool ends lines with CRLF, including
+	// an extra one after the text, as PowerShell does.
+	CRLF bool
 }
 
-// clipboardTool combines CopyTool and PasteTool to provide a unified interface
+// Executable returns the resolved path of the paste tool,
+// or its name when the path is unknown.
+func (t *PasteTool) Executable() string {
+	if t.Path != "" {
+		return t.Path
+	}
+	return t.Name
+}
+
+// withPath returns a copy of the tool pointing at the given path.
+func (t *PasteTool) withPath(path string) *PasteTool {
+	c := *t
+	c.Path = path
+	return &c
+}
+
+// ClipboardTool combines CopyTool and PasteTool to provide a unified interface
 // for clipboard operations. It abstracts the underlying command-line tools used
 // to interact with the system clipboard.
-type clipboardTool struct {
+type ClipboardTool struct {
 	CopyTool  *CopyTool  // Tool to copy content to the clipboard
 	PasteTool *PasteTool // Tool to paste content from the clipboard
+
+	caps *Capabilities // Capabilities of tools that are not in toolCapabilities
 }
 
-// New initializes and returns a new instance of clipboardTool.
+// New initializes and returns a new instance of ClipboardTool.
 // It determines the appropriate tools to use based on the current system environment
 // and returns an error if no suitable tools are found.
-func New(primary bool) (*clipboardTool, error) {
-	return newClipboardTool(primary)
+func New(primary bool) (*ClipboardTool, error) {
+	return newClipboardTool(primary, command.ExecRunner{})
+}
+
+// Options configures how a Cache detects the clipboard tools.
+type Options struct {
+	// Primary selects the tools for the primary selection
+	// instead of the clipboard.
+	Primary bool
+
+	// Order lists the names of the tools to use, in the order they
+	// should be tried. Tools not listed are never used. When empty,
+	// every available tool is used in the default order.
+	Order []string
+
+	// Probe makes the cache check that the tools actually work, e.g. that
+	// the X server is reachable, instead of only looking for them in the
+	// PATH. Tools that fail the check are not used.
+	Probe bool
+
+	// ProbeTimeout is how long each check may take.
+	// Zero means DefaultProbeTimeout.
+	ProbeTimeout time.Duration
+
+	// Require lists the capabilities every tool must have. Tools
+	// lacking any capability set in Require are not used.
+	Require Capabilities
+
+	// CopyCmd, PasteCmd and ClearCmd are custom command lines used instead
+	// of the detected tools, e.g. "xclip -selection {selection}". They are
+	// split with shell-like quoting, and SelectionPlaceholder and
+	// MIMEPlaceholder are replaced in their arguments. When empty, they
+	// are read from CopyCmdEnv, PasteCmdEnv and ClearCmdEnv. If only one
+	// of CopyCmd and PasteCmd is set, the other is detected. ClearCmd
+	// must run the same program as CopyCmd.
+	CopyCmd  string
+	PasteCmd string
+	ClearCmd string
+
+	// Target replaces MIMEPlaceholder in custom commands.
+	Target string
+
+	// Runner finds the tools and runs their probes.
+	// Nil means command.ExecRunner.
+	Runner command.Runner
+}
+
+// envVars lists the environment variables that affect which
+// clipboard tools are detected. A change to any of them
+// invalidates the tools held by a Cache.
+var envVars = []string{"PATH", "DISPLAY", "WAYLAND_DISPLAY", "WSL_INTEROP", CopyCmdEnv, PasteCmdEnv, ClearCmdEnv}
+
+// getenv is a variable holding the os.Getenv function,
+// used to read the environment the tools were detected in.
+var getenv = os.Getenv
+
+// errNoOrderedUtilitiesFound is returned when tools are available,
+// but none of them is in the configured order.
+var errNoOrderedUtilitiesFound = errors.New("none of the requested clipboard utilities is available")
+
+// ErrUnsupported is returned when tools are available, but
+// none of them has the capabilities in Options.Require.
+var ErrUnsupported = errors.New("no clipboard utilities support the requested options")
+
+// errNoWorkingUtilitiesFound is returned when tools are available,
+// but none of them passes its probe.
+var errNoWorkingUtilitiesFound = errors.New("no working clipboard utilities available")
+
+// Cache resolves the clipboard tools once and reuses them, so that
+// repeated clipboard operations don't search the PATH every time.
+// The tools are detected again when the environment changes or after
+// Invalidate is called. It is safe for concurrent use.
+type Cache struct {
+	opts Options
+
+	mu  sync.Mutex
+	cts []*ClipboardTool
+	env string
+}
+
+// NewCache returns a Cache that detects tools according to opts.
+func NewCache(opts Options) *Cache {
+	return &Cache{opts: opts}
+}
+
+// Get returns the first of the Candidates.
+func (c *Cache) Get() (*ClipboardTool, error) {
+	cts, err := c.Candidates()
+	if err != nil {
+		return nil, err
+	}
+	return cts[0], nil
+}
+
+// Candidates returns every available pair of clipboard tools, in the
+// order they should be tried, detecting them first if the cache is empty
+// or the environment changed since they were detected. Custom commands
+// come before the detected tools. Detection errors are not cached.
+func (c *Cache) Candidates() ([]*ClipboardTool, error) {
+	env := environment()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cts == nil || c.env != env {
+		cts, err := c.detect()
+		if err != nil {
+			c.cts = nil
+			return nil, err
+		}
+		c.cts, c.env = cts, env
+	}
+	return append([]*ClipboardTool{}, c.cts...), nil
+}
+
+// detect returns the custom and detected tools that honor the options.
+// Detection errors are ignored when both custom copy and paste commands
+// are set, since the detected tools are then only fallbacks.
+func (c *Cache) detect() ([]*ClipboardTool, error) {
+	r := command.OrDefault(c.opts.Runner)
+	cts, err := newClipboardTools(c.opts.Primary, r)
+	if err == nil {
+		if cts = inOrder(cts, c.opts.Order); len(cts) == 0 {
+			err = errNoOrderedUtilitiesFound
+		}
+	}
+	copyCmd, pasteCmd, _ := customCommands(c.opts)
+	if err != nil && (copyCmd == "" || pasteCmd == "") {
+		return nil, err
+	}
+	var fallback *ClipboardTool
+	if len(cts) > 0 {
+		fallback = cts[0]
+	}
+	custom, err := newCustomTool(c.opts, fallback)
+	if err != nil {
+		return nil, err
+	}
+	if custom != nil {
+		cts = append([]*ClipboardTool{custom}, cts...)
+	}
+	if cts = capable(cts, c.opts.Require); len(cts) == 0 {
+		return nil, ErrUnsupported
+	}
+	if c.opts.Probe {
+		if cts = working(r, cts, c.opts.ProbeTimeout); len(cts) == 0 {
+			return nil, errNoWorkingUtilitiesFound
+		}
+	}
+	return cts, nil
+}
+
+// Invalidate drops the cached tools, e.g. because one of
+// them failed to execute, so that they are detected again.
+func (c *Cache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cts = nil
+}
+
+// inOrder returns the tools whose copy or paste tool
+// is named in order, sorted accordingly.
+func inOrder(cts []*ClipboardTool, order []string) []*ClipboardTool {
+	if len(order) == 0 {
+		return cts
+	}
+	var ordered []*ClipboardTool
+	for _, name := range order {
+		for _, ct := range cts {
+			if ct.CopyTool.Name == name || ct.PasteTool.Name == name {
+				ordered = append(ordered, ct)
+			}
+		}
+	}
+	return ordered
+}
+
+// capable returns the tools that have every capability set in require.
+func capable(cts []*ClipboardTool, require Capabilities) []*ClipboardTool {
+	var result []*ClipboardTool
+	for _, ct := range cts {
+		if ct.Capabilities().Has(require) {
+			result = append(result, ct)
+		}
+	}
+	return result
+}
+
+// working returns the tools that pass their probe.
+func working(r command.Runner, cts []*ClipboardTool, timeout time.Duration) []*ClipboardTool {
+	var result []*ClipboardTool
+	for _, ct := range cts {
+		if caps := ProbeWith(r, ct, timeout); caps.Copy && caps.Paste {
+			result = append(result, ct)
+		}
+	}
+	return result
+}
+
+// environment returns a fingerprint of the environment
+// variables that affect tool detection.
+func environment() string {
+	values := make([]string, len(envVars))
+	for i, name := range envVars {
+		values[i] = getenv(name)
+	}
+	return strings.Join(values, "\x00")
 }
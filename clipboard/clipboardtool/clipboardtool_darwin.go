@@ -8,7 +8,8 @@ package clipboardtool
 
 import (
 	"errors"
-	"os/exec"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
 )
 
 const (
@@ -27,34 +28,49 @@ var (
 	pasteTool = &PasteTool{
 		Name: pbpaste,
 	}
-	// lookPath is a variable holding the exec.LookPath function,
-	// used to check for the presence of a command in the system's PATH.
-	lookPath = exec.LookPath
+	// toolCapabilities describes what each pair of tools
+	// supports, keyed by the name of the copy tool.
+	toolCapabilities = map[string]Capabilities{
+		pbcopy: {Copy: true, Paste: true, Clear: true, Streaming: true},
+	}
 
 	errNoCopyUtilitiesFound  = errors.New("no clipboard copy utilities available")
 	errNoPasteUtilitiesFound = errors.New("no clipboard paste utilities available")
 )
 
-// newClipboardTool initializes a new clipboardTool instance by
+// newClipboardTool initializes a new ClipboardTool instance by
 // checking the availability of clipboard utilities.
-func newClipboardTool(primary bool) (*clipboardTool, error) {
-	if isAvailable := isToolAvailable(copyTool.Name); !isAvailable {
+func newClipboardTool(primary bool, r command.Runner) (*ClipboardTool, error) {
+	copyPath, isAvailable := isToolAvailable(r, copyTool.Name)
+	if !isAvailable {
 		return nil, errNoCopyUtilitiesFound
 	}
-	if isAvailable := isToolAvailable(pasteTool.Name); !isAvailable {
+	pastePath, isAvailable := isToolAvailable(r, pasteTool.Name)
+	if !isAvailable {
 		return nil, errNoPasteUtilitiesFound
 	}
-	return &clipboardTool{
-		CopyTool:  copyTool,
-		PasteTool: pasteTool,
+	return &ClipboardTool{
+		CopyTool:  copyTool.withPath(copyPath),
+		PasteTool: pasteTool.withPath(pastePath),
 	}, nil
 }
 
+// newClipboardTools returns the only pair of tools
+// available on this platform.
+func newClipboardTools(primary bool, r command.Runner) ([]*ClipboardTool, error) {
+	ct, err := newClipboardTool(primary, r)
+	if err != nil {
+		return nil, err
+	}
+	return []*ClipboardTool{ct}, nil
+}
+
 // isToolAvailable checks if a clipboard utility tool
-// is available in the system's PATH.
-func isToolAvailable(toolName string) bool {
-	if _, err := lookPath(toolName); err != nil {
-		return false
+// is available in the system's PATH, returning its path.
+func isToolAvailable(r command.Runner, toolName string) (string, bool) {
+	path, err := r.LookPath(toolName)
+	if err != nil {
+		return "", false
 	}
-	return true
+	return path, true
 }
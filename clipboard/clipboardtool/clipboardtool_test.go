@@ -0,0 +1,238 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboardtool
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+)
+
+func TestCache(t *testing.T) {
+	testCases := []struct {
+		desc               string
+		betweenGets        func(c *Cache, env map[string]string)
+		expectedDetections int
+	}{
+		{
+			desc:               "tools are resolved once",
+			betweenGets:        func(c *Cache, env map[string]string) {},
+			expectedDetections: 1,
+		},
+		{
+			desc: "PATH changes",
+			betweenGets: func(c *Cache, env map[string]string) {
+				env["PATH"] = "/other/bin"
+			},
+			expectedDetections: 2,
+		},
+		{
+			desc: "DISPLAY changes",
+			betweenGets: func(c *Cache, env map[string]string) {
+				env["DISPLAY"] = ":1"
+			},
+			expectedDetections: 2,
+		},
+		{
+			desc: "WAYLAND_DISPLAY changes",
+			betweenGets: func(c *Cache, env map[string]string) {
+				env["WAYLAND_DISPLAY"] = "wayland-1"
+			},
+			expectedDetections: 2,
+		},
+		{
+			desc: "unrelated variable changes",
+			betweenGets: func(c *Cache, env map[string]string) {
+				env["HOME"] = "/other/home"
+			},
+			expectedDetections: 1,
+		},
+		{
+			desc: "cache is invalidated",
+			betweenGets: func(c *Cache, env map[string]string) {
+				c.Invalidate()
+			},
+			expectedDetections: 2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			env := map[string]string{"PATH": "/usr/bin", "DISPLAY": ":0"}
+			getenv = func(key string) string { return env[key] }
+			var lookups int
+			r := &mockRunner{lookPath: func(file string) (string, error) {
+				lookups++
+				return usrBin(file)
+			}}
+			defer func() { getenv = os.Getenv }()
+
+			c := NewCache(Options{Runner: r})
+			first, err := c.Get()
+			require.NoError(t, err)
+			lookupsPerDetection := lookups
+			tc.betweenGets(c, env)
+			second, err := c.Get()
+			require.NoError(t, err)
+			require.Equal(t, first, second)
+			require.Equal(t, tc.expectedDetections*lookupsPerDetection, lookups)
+		})
+	}
+}
+
+func TestCache_errorsAreNotCached(t *testing.T) {
+	available := false
+	r := &mockRunner{lookPath: func(file string) (string, error) {
+		if !available {
+			return "", errors.New("not available")
+		}
+		return usrBin(file)
+	}}
+
+	c := NewCache(Options{Runner: r})
+	_, err := c.Get()
+	require.Error(t, err)
+	available = true
+	ct, err := c.Get()
+	require.NoError(t, err)
+	require.Equal(t, "/usr/bin/"+ct.CopyTool.Name, ct.CopyTool.Executable())
+}
+
+func TestCache_Candidates(t *testing.T) {
+	r := &mockRunner{lookPath: usrBin}
+	all, err := newClipboardTools(false, r)
+	require.NoError(t, err)
+	last := all[len(all)-1]
+
+	testCases := []struct {
+		desc           string
+		order          []string
+		require        Capabilities
+		expectedOutput []*ClipboardTool
+		expectedError  error
+	}{
+		{
+			desc:           "default order",
+			expectedOutput: all,
+		},
+		{
+			desc:           "configured order",
+			order:          []string{last.CopyTool.Name},
+			expectedOutput: []*ClipboardTool{last},
+		},
+		{
+			desc:          "configured order without available tools",
+			order:         []string{"copyq"},
+			expectedError: errors.New("none of the requested clipboard utilities is available"),
+		},
+		{
+			desc:           "required capabilities that every tool has",
+			require:        Capabilities{Copy: true, Paste: true},
+			expectedOutput: all,
+		},
+		{
+			desc:          "required capabilities that the tools lack",
+			order:         []string{all[0].CopyTool.Name},
+			require:       Capabilities{Watch: true},
+			expectedError: errors.New("no clipboard utilities support the requested options"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := NewCache(Options{Order: tc.order, Require: tc.require, Runner: r})
+			cts, err := c.Candidates()
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+				require.Equal(t, tc.expectedOutput, cts)
+			}
+		})
+	}
+}
+
+// mockRunner is a command.Runner finding tools with lookPath and
+// running commands with run, which returns the error of the command.
+type mockRunner struct {
+	lookPath func(file string) (string, error)
+	run      func(ctx context.Context, name string, args ...string) error
+}
+
+// Command implements the command.Runner interface.
+func (r *mockRunner) Command(ctx context.Context, name string, args ...string) command.Command {
+	return &mockCommand{run: func() error { return r.run(ctx, name, args...) }}
+}
+
+// LookPath implements the command.Runner interface.
+func (r *mockRunner) LookPath(file string) (string, error) {
+	return r.lookPath(file)
+}
+
+// mockCommand is a command.Command returning the error of run.
+type mockCommand struct {
+	run func() error
+}
+
+// TextInput implements the command.Command interface.
+func (c *mockCommand) TextInput(text string) error {
+	return c.run()
+}
+
+// TextOutput implements the command.Command interface.
+func (c *mockCommand) TextOutput() (string, error) {
+	return "", c.run()
+}
+
+// usrBin finds every tool in /usr/bin.
+func usrBin(file string) (string, error) {
+	return "/usr/bin/" + file, nil
+}
+
+// fakeToolsDir creates a directory holding an executable for every
+// tool name, placed at the end of a PATH full of unrelated directories,
+// so that benchmarks measure a realistic search.
+func fakeToolsDir(b *testing.B) string {
+	dir := b.TempDir()
+	var path []string
+	for i := 0; i < 10; i++ {
+		path = append(path, filepath.Join(dir, "empty", string(rune('a'+i))))
+	}
+	bin := filepath.Join(dir, "bin")
+	require.NoError(b, os.MkdirAll(bin, 0o755))
+	for _, name := range []string{"xsel", "xclip", "wl-copy", "wl-paste", "termux-clipboard-set",
+		"termux-clipboard-get", "pbcopy", "pbpaste", "clip.exe", "powershell", "powershell.exe"} {
+		require.NoError(b, os.WriteFile(filepath.Join(bin, name), nil, 0o755))
+	}
+	return strings.Join(append(path, bin), string(os.PathListSeparator))
+}
+
+func BenchmarkNew(b *testing.B) {
+	b.Setenv("PATH", fakeToolsDir(b))
+	for i := 0; i < b.N; i++ {
+		if _, err := New(false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCache_Get(b *testing.B) {
+	b.Setenv("PATH", fakeToolsDir(b))
+	c := NewCache(Options{})
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Get(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -8,6 +8,8 @@ package clipboardtool
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -17,7 +19,7 @@ func Test_newClipboardTool(t *testing.T) {
 	testCases := []struct {
 		desc           string
 		lookPathMock   func(file string) (string, error)
-		expectedOutput *clipboardTool
+		expectedOutput *ClipboardTool
 		expectedError  error
 	}{
 		{
@@ -28,13 +30,16 @@ func Test_newClipboardTool(t *testing.T) {
 				}
 				return "", errors.New("not available")
 			},
-			expectedOutput: &clipboardTool{
+			expectedOutput: &ClipboardTool{
 				CopyTool: &CopyTool{
-					Name:    xsel,
-					CmdArgs: []string{"--input", "--clipboard"},
+					Name:      xsel,
+					Path:      "/path/to/xsel",
+					CmdArgs:   []string{"--input", "--clipboard"},
+					ClearArgs: []string{"--clear", "--clipboard"},
 				},
 				PasteTool: &PasteTool{
 					Name:    xsel,
+					Path:    "/path/to/xsel",
 					CmdArgs: []string{"--output", "--clipboard"},
 				},
 			},
@@ -47,14 +52,22 @@ func Test_newClipboardTool(t *testing.T) {
 				}
 				return "", errors.New("not available")
 			},
-			expectedOutput: &clipboardTool{
+			expectedOutput: &ClipboardTool{
 				CopyTool: &CopyTool{
-					Name:    xclip,
-					CmdArgs: []string{"-in", "-selection", "clipboard"},
+					Name:           xclip,
+					Path:           "/path/to/xclip",
+					CmdArgs:        []string{"-in", "-selection", "clipboard"},
+					OneShotArgs:    []string{"-loops", "1"},
+					ForegroundArgs: []string{"-quiet"},
+					TargetArg:      "-target",
 				},
 				PasteTool: &PasteTool{
-					Name:    xclip,
-					CmdArgs: []string{"-out", "-selection", "clipboard"},
+					Name:      xclip,
+					Path:      "/path/to/xclip",
+					CmdArgs:   []string{"-out", "-selection", "clipboard"},
+					TargetArg: "-target",
+					ProbeArgs: []string{"-out", "-selection", "clipboard", "-target", "TARGETS"},
+					TypesArgs: []string{"-out", "-selection", "clipboard", "-target", "TARGETS"},
 				},
 			},
 		},
@@ -62,17 +75,27 @@ func Test_newClipboardTool(t *testing.T) {
 			desc: "wayland tools options are available",
 			lookPathMock: func(toolName string) (string, error) {
 				if toolName == wlcopy || toolName == wlpaste {
-					return "", nil
+					return "/path/to/" + toolName, nil
 				}
 				return "", errors.New("not available")
 			},
-			expectedOutput: &clipboardTool{
+			expectedOutput: &ClipboardTool{
 				CopyTool: &CopyTool{
-					Name: wlcopy,
+					Name:           wlcopy,
+					Path:           "/path/to/wl-copy",
+					OneShotArgs:    []string{"--paste-once"},
+					ClearArgs:      []string{"--clear"},
+					ForegroundArgs: []string{"--foreground"},
+					TargetArg:      "--type",
 				},
 				PasteTool: &PasteTool{
-					Name:    wlpaste,
-					CmdArgs: []string{"--no-newline"},
+					Name:      wlpaste,
+					Path:      "/path/to/wl-paste",
+					CmdArgs:   []string{"--no-newline"},
+					TargetArg: "--type",
+					ProbeArgs: []string{"--list-types"},
+					TypesArgs: []string{"--list-types"},
+					WatchArgs: []string{"--watch", "echo"},
 				},
 			},
 		},
@@ -80,16 +103,19 @@ func Test_newClipboardTool(t *testing.T) {
 			desc: "termux tools options are available",
 			lookPathMock: func(toolName string) (string, error) {
 				if toolName == termuxClipboardGet || toolName == termuxClipboardSet {
-					return "", nil
+					return "/data/data/com.termux/files/usr/bin/" + toolName, nil
 				}
 				return "", errors.New("not available")
 			},
-			expectedOutput: &clipboardTool{
+			expectedOutput: &ClipboardTool{
 				CopyTool: &CopyTool{
-					Name: termuxClipboardSet,
+					Name:      termuxClipboardSet,
+					Path:      "/data/data/com.termux/files/usr/bin/termux-clipboard-set",
+					ClearArgs: []string{""},
 				},
 				PasteTool: &PasteTool{
 					Name: termuxClipboardGet,
+					Path: "/data/data/com.termux/files/usr/bin/termux-clipboard-get",
 				},
 			},
 		},
@@ -103,8 +129,7 @@ func Test_newClipboardTool(t *testing.T) {
 	}
 	for _, tc := range testCases {
 		t.Run(tc.desc, func(t *testing.T) {
-			lookPath = tc.lookPathMock
-			ct, err := newClipboardTool(false)
+			ct, err := newClipboardTool(false, &mockRunner{lookPath: tc.lookPathMock})
 			if err != nil {
 				if tc.expectedError == nil {
 					t.Fatalf("expected no error, got %v", err)
@@ -120,3 +145,176 @@ func Test_newClipboardTool(t *testing.T) {
 		})
 	}
 }
+
+func Test_newClipboardTools(t *testing.T) {
+	r := &mockRunner{lookPath: func(toolName string) (string, error) {
+		if toolName == xsel {
+			return "", errors.New("not available")
+		}
+		return "/path/to/" + toolName, nil
+	}}
+	cts, err := newClipboardTools(true, r)
+	require.NoError(t, err)
+	require.Len(t, cts, 3)
+	require.Equal(t, xclip, cts[0].CopyTool.Name)
+	require.Equal(t, []string{"-in", "-selection", "primary"}, cts[0].CopyTool.CmdArgs)
+	require.Equal(t, wlcopy, cts[1].CopyTool.Name)
+	require.Equal(t, []string{"--primary"}, cts[1].CopyTool.CmdArgs)
+	require.Equal(t, termuxClipboardSet, cts[2].CopyTool.Name)
+}
+
+func Test_newClipboardTools_wsl(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		osRelease      string
+		env            map[string]string
+		primary        bool
+		windowsTools   bool
+		expectedOutput []string
+	}{
+		{
+			desc:           "not WSL",
+			osRelease:      "6.5.0-14-generic",
+			windowsTools:   true,
+			expectedOutput: []string{xclip, wlcopy},
+		},
+		{
+			desc:           "WSL 2",
+			osRelease:      "5.15.133.1-microsoft-standard-WSL2",
+			windowsTools:   true,
+			expectedOutput: []string{clipExe, xclip, wlcopy},
+		},
+		{
+			desc:           "WSL 1",
+			osRelease:      "4.4.0-19041-Microsoft",
+			windowsTools:   true,
+			expectedOutput: []string{clipExe, xclip, wlcopy},
+		},
+		{
+			desc:           "WSL with a custom kernel",
+			osRelease:      "6.6.0-custom",
+			env:            map[string]string{"WSL_INTEROP": "/run/WSL/1_interop"},
+			windowsTools:   true,
+			expectedOutput: []string{clipExe, xclip, wlcopy},
+		},
+		{
+			desc:           "WSLg",
+			osRelease:      "5.15.133.1-microsoft-standard-WSL2",
+			env:            map[string]string{"WAYLAND_DISPLAY": "wayland-0"},
+			windowsTools:   true,
+			expectedOutput: []string{wlcopy, clipExe, xclip},
+		},
+		{
+			desc:           "WSL without interop",
+			osRelease:      "5.15.133.1-microsoft-standard-WSL2",
+			expectedOutput: []string{xclip, wlcopy},
+		},
+		{
+			desc:           "WSL primary selection",
+			osRelease:      "5.15.133.1-microsoft-standard-WSL2",
+			primary:        true,
+			windowsTools:   true,
+			expectedOutput: []string{xclip, wlcopy},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			osRelease = filepath.Join(t.TempDir(), "osrelease")
+			require.NoError(t, os.WriteFile(osRelease, []byte(tc.osRelease+"\n"), 0o644))
+			getenv = func(key string) string { return tc.env[key] }
+			defer func() {
+				osRelease = "/proc/sys/kernel/osrelease"
+				getenv = os.Getenv
+			}()
+			r := &mockRunner{lookPath: func(toolName string) (string, error) {
+				switch toolName {
+				case xclip, wlcopy, wlpaste:
+				case clipExe, powershellExe:
+					if !tc.windowsTools {
+						return "", errors.New("not available")
+					}
+				default:
+					return "", errors.New("not available")
+				}
+				return "/path/to/" + toolName, nil
+			}}
+
+			cts, err := newClipboardTools(tc.primary, r)
+			require.NoError(t, err)
+			var names []string
+			for _, ct := range cts {
+				names = append(names, ct.CopyTool.Name)
+			}
+			require.Equal(t, tc.expectedOutput, names)
+		})
+	}
+}
+
+func Test_newClipboardTool_wsl(t *testing.T) {
+	osRelease = filepath.Join(t.TempDir(), "osrelease")
+	require.NoError(t, os.WriteFile(osRelease, []byte("5.15.133.1-microsoft-standard-WSL2\n"), 0o644))
+	defer func() { osRelease = "/proc/sys/kernel/osrelease" }()
+	r := &mockRunner{lookPath: func(toolName string) (string, error) {
+		if toolName == clipExe || toolName == powershellExe {
+			return "/mnt/c/Windows/system32/" + toolName, nil
+		}
+		return "", errors.New("not available")
+	}}
+
+	ct, err := newClipboardTool(false, r)
+	require.NoError(t, err)
+	require.Equal(t, &ClipboardTool{
+		CopyTool: &CopyTool{
+			Name:    clipExe,
+			Path:    "/mnt/c/Windows/system32/clip.exe",
+			Charset: "utf-16le",
+			BOM:     true,
+		},
+		PasteTool: &PasteTool{
+			Name:    powershellExe,
+			Path:    "/mnt/c/Windows/system32/powershell.exe",
+			CmdArgs: []string{"-NoProfile", "-NonInteractive", "-Command", "[Console]::OutputEncoding = [Text.Encoding]::UTF8; Get-Clipboard"},
+			CRLF:    true,
+		},
+	}, ct)
+}
+
+func TestClipboardTool_Capabilities(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		toolName       string
+		expectedOutput Capabilities
+	}{
+		{
+			desc:           "xsel",
+			toolName:       xsel,
+			expectedOutput: Capabilities{Copy: true, Paste: true, Primary: true, Clear: true, Streaming: true},
+		},
+		{
+			desc:           "xclip",
+			toolName:       xclip,
+			expectedOutput: Capabilities{Copy: true, Paste: true, Primary: true, Types: true, Clear: true, OneShot: true, Streaming: true},
+		},
+		{
+			desc:           "wayland",
+			toolName:       wlcopy,
+			expectedOutput: Capabilities{Copy: true, Paste: true, Primary: true, Types: true, Watch: true, Clear: true, OneShot: true, Streaming: true},
+		},
+		{
+			desc:           "termux",
+			toolName:       termuxClipboardSet,
+			expectedOutput: Capabilities{Copy: true, Paste: true, Clear: true, Streaming: true},
+		},
+		{
+			desc:           "wsl",
+			toolName:       clipExe,
+			expectedOutput: Capabilities{Copy: true, Paste: true, Clear: true, Streaming: true},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			ct := &ClipboardTool{CopyTool: &CopyTool{Name: tc.toolName}}
+			require.Equal(t, tc.expectedOutput, ct.Capabilities())
+		})
+	}
+}
@@ -8,7 +8,10 @@ package clipboardtool
 
 import (
 	"errors"
-	"os/exec"
+	"os"
+	"strings"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
 )
 
 const (
@@ -26,24 +29,40 @@ const (
 	termuxClipboardGet = "termux-clipboard-get"
 	// termuxClipboardSet is a clipboard utility for Termux, an Android terminal emulator.
 	termuxClipboardSet = "termux-clipboard-set"
+
+	// clipExe is the Windows tool copying to the clipboard, run through
+	// interop under the Windows Subsystem for Linux.
+	clipExe = "clip.exe"
+	// powershellExe is used to paste from the Windows clipboard
+	// under the Windows Subsystem for Linux.
+	powershellExe = "powershell.exe"
 )
 
 var (
 	// copyTools is a list of available CopyTool configurations for different environments.
 	copyTools = []*CopyTool{
 		{
-			Name:    xsel,
-			CmdArgs: []string{"--input", "--clipboard"},
+			Name:      xsel,
+			CmdArgs:   []string{"--input", "--clipboard"},
+			ClearArgs: []string{"--clear", "--clipboard"},
 		},
 		{
-			Name:    xclip,
-			CmdArgs: []string{"-in", "-selection", "clipboard"},
+			Name:           xclip,
+			CmdArgs:        []string{"-in", "-selection", "clipboard"},
+			OneShotArgs:    []string{"-loops", "1"},
+			ForegroundArgs: []string{"-quiet"},
+			TargetArg:      "-target",
 		},
 		{
-			Name: wlcopy,
+			Name:           wlcopy,
+			OneShotArgs:    []string{"--paste-once"},
+			ClearArgs:      []string{"--clear"},
+			ForegroundArgs: []string{"--foreground"},
+			TargetArg:      "--type",
 		},
 		{
-			Name: termuxClipboardSet,
+			Name:      termuxClipboardSet,
+			ClearArgs: []string{""},
 		},
 	}
 	// pasteTools is a list of available PasteTool configurations for different environments.
@@ -53,12 +72,19 @@ var (
 			CmdArgs: []string{"--output", "--clipboard"},
 		},
 		{
-			Name:    xclip,
-			CmdArgs: []string{"-out", "-selection", "clipboard"},
+			Name:      xclip,
+			CmdArgs:   []string{"-out", "-selection", "clipboard"},
+			TargetArg: "-target",
+			ProbeArgs: []string{"-out", "-selection", "clipboard", "-target", "TARGETS"},
+			TypesArgs: []string{"-out", "-selection", "clipboard", "-target", "TARGETS"},
 		},
 		{
-			Name:    wlpaste,
-			CmdArgs: []string{"--no-newline"},
+			Name:      wlpaste,
+			CmdArgs:   []string{"--no-newline"},
+			TargetArg: "--type",
+			ProbeArgs: []string{"--list-types"},
+			TypesArgs: []string{"--list-types"},
+			WatchArgs: []string{"--watch", "echo"},
 		},
 		{
 			Name: termuxClipboardGet,
@@ -68,19 +94,28 @@ var (
 	// same with primary selection
 	copyToolsPrimary = []*CopyTool{
 		{
-			Name:    xsel,
-			CmdArgs: []string{"--input", "--primary"},
+			Name:      xsel,
+			CmdArgs:   []string{"--input", "--primary"},
+			ClearArgs: []string{"--clear", "--primary"},
 		},
 		{
-			Name:    xclip,
-			CmdArgs: []string{"-in", "-selection", "primary"},
+			Name:           xclip,
+			CmdArgs:        []string{"-in", "-selection", "primary"},
+			OneShotArgs:    []string{"-loops", "1"},
+			ForegroundArgs: []string{"-quiet"},
+			TargetArg:      "-target",
 		},
 		{
-			Name:    wlcopy,
-			CmdArgs: []string{"--primary"},
+			Name:           wlcopy,
+			CmdArgs:        []string{"--primary"},
+			OneShotArgs:    []string{"--paste-once"},
+			ClearArgs:      []string{"--primary", "--clear"},
+			ForegroundArgs: []string{"--foreground"},
+			TargetArg:      "--type",
 		},
 		{
-			Name: termuxClipboardSet,
+			Name:      termuxClipboardSet,
+			ClearArgs: []string{""},
 		},
 	}
 
@@ -90,28 +125,75 @@ var (
 			CmdArgs: []string{"--output", "--primary"},
 		},
 		{
-			Name:    xclip,
-			CmdArgs: []string{"-out", "-selection", "primary"},
+			Name:      xclip,
+			CmdArgs:   []string{"-out", "-selection", "primary"},
+			TargetArg: "-target",
+			ProbeArgs: []string{"-out", "-selection", "primary", "-target", "TARGETS"},
+			TypesArgs: []string{"-out", "-selection", "primary", "-target", "TARGETS"},
 		},
 		{
-			Name:    wlpaste,
-			CmdArgs: []string{"--no-newline", "--primary"},
+			Name:      wlpaste,
+			CmdArgs:   []string{"--no-newline", "--primary"},
+			TargetArg: "--type",
+			ProbeArgs: []string{"--list-types", "--primary"},
+			TypesArgs: []string{"--list-types", "--primary"},
+			WatchArgs: []string{"--primary", "--watch", "echo"},
 		},
 		{
 			Name: termuxClipboardGet,
 		},
 	}
 
-	// lookPath is a variable holding the exec.LookPath function,
-	// used to check for the presence of a command in the system's PATH.
-	lookPath = exec.LookPath
+	// wslCopyTool copies to the Windows clipboard. clip.exe reads text
+	// in the console code page unless it starts with the byte order mark
+	// of UTF-16, so it is converted.
+	wslCopyTool = &CopyTool{
+		Name:    clipExe,
+		Charset: "utf-16le",
+		BOM:     true,
+	}
+	// wslPasteTool pastes from the Windows clipboard. The output is
+	// switched to UTF-8, since the console code page can't hold any text.
+	wslPasteTool = &PasteTool{
+		Name:    powershellExe,
+		CmdArgs: []string{"-NoProfile", "-NonInteractive", "-Command", "[Console]::OutputEncoding = [Text.Encoding]::UTF8; Get-Clipboard"},
+		CRLF:    true,
+	}
+
+	// osRelease is the file holding the release of the kernel,
+	// which mentions Microsoft under the Windows Subsystem for Linux.
+	osRelease = "/proc/sys/kernel/osrelease"
+
+	// toolCapabilities describes what each pair of tools
+	// supports, keyed by the name of the copy tool.
+	toolCapabilities = map[string]Capabilities{
+		xsel:               {Copy: true, Paste: true, Primary: true, Clear: true, Streaming: true},
+		xclip:              {Copy: true, Paste: true, Primary: true, Types: true, Clear: true, OneShot: true, Streaming: true},
+		wlcopy:             {Copy: true, Paste: true, Primary: true, Types: true, Watch: true, Clear: true, OneShot: true, Streaming: true},
+		termuxClipboardSet: {Copy: true, Paste: true, Clear: true, Streaming: true},
+		clipExe:            {Copy: true, Paste: true, Clear: true, Streaming: true},
+	}
 
 	errNoUtilitiesFound = errors.New("no clipboard utilities available")
 )
 
 // newClipboardTool selects the first available pair of
-// copy and paste tools from the predefined list.
-func newClipboardTool(primary bool) (*clipboardTool, error) {
+// copy and paste tools from the predefined list, along with
+// their resolved paths.
+func newClipboardTool(primary bool, r command.Runner) (*ClipboardTool, error) {
+	cts, err := newClipboardTools(primary, r)
+	if err != nil {
+		return nil, err
+	}
+	return cts[0], nil
+}
+
+// newClipboardTools returns every available pair of copy and
+// paste tools from the predefined list, in order, along with
+// their resolved paths. Under WSL, the Windows tools come first,
+// after the Wayland tools of WSLg.
+func newClipboardTools(primary bool, r command.Runner) ([]*ClipboardTool, error) {
+	var cts []*ClipboardTool
 	for i, ct := range copyTools {
 		var pt *PasteTool
 		if primary {
@@ -121,23 +203,67 @@ func newClipboardTool(primary bool) (*clipboardTool, error) {
 			pt = pasteTools[i]
 		}
 
-		if available := toolsAreAvailable(ct.Name, pt.Name); available {
-			return &clipboardTool{
-				CopyTool:  ct,
-				PasteTool: pt,
-			}, nil
+		if paths, available := toolsAreAvailable(r, ct.Name, pt.Name); available {
+			cts = append(cts, &ClipboardTool{
+				CopyTool:  ct.withPath(paths[0]),
+				PasteTool: pt.withPath(paths[1]),
+			})
+		}
+	}
+	if !primary && isWSL() {
+		cts = withWindowsTools(r, cts)
+	}
+	if len(cts) == 0 {
+		return nil, errNoUtilitiesFound
+	}
+	return cts, nil
+}
+
+// isWSL reports whether this is the Windows Subsystem for Linux, where
+// Windows programs, such as clip.exe, can be run through interop.
+func isWSL() bool {
+	if getenv("WSL_INTEROP") != "" {
+		return true
+	}
+	release, err := os.ReadFile(osRelease)
+	return err == nil && strings.Contains(strings.ToLower(string(release)), "microsoft")
+}
+
+// withWindowsTools puts the Windows tools, if they are available, before
+// the other tools, which only reach the Windows clipboard through WSLg,
+// if at all. The Wayland tools stay first when a display is set, since
+// WSLg shares their clipboard with Windows without running a process
+// through interop.
+func withWindowsTools(r command.Runner, cts []*ClipboardTool) []*ClipboardTool {
+	paths, available := toolsAreAvailable(r, clipExe, powershellExe)
+	if !available {
+		return cts
+	}
+	windows := &ClipboardTool{
+		CopyTool:  wslCopyTool.withPath(paths[0]),
+		PasteTool: wslPasteTool.withPath(paths[1]),
+	}
+	var first, rest []*ClipboardTool
+	for _, ct := range cts {
+		if ct.CopyTool.Name == wlcopy && getenv("WAYLAND_DISPLAY") != "" {
+			first = append(first, ct)
+		} else {
+			rest = append(rest, ct)
 		}
 	}
-	return nil, errNoUtilitiesFound
+	return append(append(first, windows), rest...)
 }
 
 // toolsAreAvailable checks for the existence of the specified
-// tools by name in the system's PATH.
-func toolsAreAvailable(toolNames ...string) bool {
-	for _, toolName := range toolNames {
-		if _, err := lookPath(toolName); err != nil {
-			return false
+// tools by name in the system's PATH, returning their paths.
+func toolsAreAvailable(r command.Runner, toolNames ...string) ([]string, bool) {
+	paths := make([]string, len(toolNames))
+	for i, toolName := range toolNames {
+		path, err := r.LookPath(toolName)
+		if err != nil {
+			return nil, false
 		}
+		paths[i] = path
 	}
-	return true
+	return paths, true
 }
@@ -8,7 +8,8 @@ package clipboardtool
 
 import (
 	"errors"
-	"os/exec"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
 )
 
 const (
@@ -29,33 +30,49 @@ var (
 		Name:    powershell,
 		CmdArgs: []string{"Get-Clipboard"},
 	}
-	// lookPath is a variable holding the exec.LookPath function,
-	// used to check for the presence of a command in the system's PATH.
-	lookPath = exec.LookPath
+	// toolCapabilities describes what each pair of tools
+	// supports, keyed by the name of the copy tool.
+	toolCapabilities = map[string]Capabilities{
+		clip: {Copy: true, Paste: true, Clear: true, Streaming: true},
+	}
 
 	errNoCopyUtilitiesFound  = errors.New("no clipboard copy utilities available")
 	errNoPasteUtilitiesFound = errors.New("no clipboard paste utilities available")
 )
 
 // newClipboardTool checks the availability of clipboard utilities
-// and initializes a new clipboardTool.
-func newClipboardTool(primary bool) (*clipboardTool, error) {
-	if isAvailable := toolIsAvailable(copyTool.Name); !isAvailable {
+// and initializes a new ClipboardTool.
+func newClipboardTool(primary bool, r command.Runner) (*ClipboardTool, error) {
+	copyPath, isAvailable := toolIsAvailable(r, copyTool.Name)
+	if !isAvailable {
 		return nil, errNoCopyUtilitiesFound
 	}
-	if isAvailable := toolIsAvailable(pasteTool.Name); !isAvailable {
+	pastePath, isAvailable := toolIsAvailable(r, pasteTool.Name)
+	if !isAvailable {
 		return nil, errNoPasteUtilitiesFound
 	}
-	return &clipboardTool{
-		CopyTool:  copyTool,
-		PasteTool: pasteTool,
+	return &ClipboardTool{
+		CopyTool:  copyTool.withPath(copyPath),
+		PasteTool: pasteTool.withPath(pastePath),
 	}, nil
 }
 
-// toolIsAvailable verifies the presence of a clipboard utility in the system's PATH.
-func toolIsAvailable(toolName string) bool {
-	if _, err := lookPath(toolName); err != nil {
-		return false
+// newClipboardTools returns the only pair of tools
+// available on this platform.
+func newClipboardTools(primary bool, r command.Runner) ([]*ClipboardTool, error) {
+	ct, err := newClipboardTool(primary, r)
+	if err != nil {
+		return nil, err
+	}
+	return []*ClipboardTool{ct}, nil
+}
+
+// toolIsAvailable verifies the presence of a clipboard utility in the system's PATH,
+// returning its path.
+func toolIsAvailable(r command.Runner, toolName string) (string, bool) {
+	path, err := r.LookPath(toolName)
+	if err != nil {
+		return "", false
 	}
-	return true
+	return path, true
 }
@@ -17,20 +17,22 @@ func Test_newClipboardTool(t *testing.T) {
 	testCases := []struct {
 		desc           string
 		lookPathMock   func(file string) (string, error)
-		expectedOutput *clipboardTool
+		expectedOutput *ClipboardTool
 		expectedError  error
 	}{
 		{
 			desc: "both tools are available",
 			lookPathMock: func(toolName string) (string, error) {
-				return "", nil
+				return `C:\Windows\System32\` + toolName, nil
 			},
-			expectedOutput: &clipboardTool{
+			expectedOutput: &ClipboardTool{
 				CopyTool: &CopyTool{
 					Name: clip,
+					Path: `C:\Windows\System32\clip.exe`,
 				},
 				PasteTool: &PasteTool{
 					Name:    powershell,
+					Path:    `C:\Windows\System32\powershell`,
 					CmdArgs: []string{"Get-Clipboard"},
 				},
 			},
@@ -58,8 +60,7 @@ func Test_newClipboardTool(t *testing.T) {
 	}
 	for _, tc := range testCases {
 		t.Run(tc.desc, func(t *testing.T) {
-			lookPath = tc.lookPathMock
-			ct, err := newClipboardTool(false)
+			ct, err := newClipboardTool(false, &mockRunner{lookPath: tc.lookPathMock})
 			if err != nil {
 				if tc.expectedError == nil {
 					t.Fatalf("expected no error, got %v", err)
@@ -0,0 +1,205 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboardtool
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+)
+
+// Environment variables holding custom clipboard commands. They are
+// used when the corresponding command is not set in Options.
+const (
+	CopyCmdEnv  = "GO_CLIPBOARD_COPY_CMD"
+	PasteCmdEnv = "GO_CLIPBOARD_PASTE_CMD"
+	ClearCmdEnv = "GO_CLIPBOARD_CLEAR_CMD"
+)
+
+// Placeholders that are replaced in the arguments of custom commands.
+const (
+	// SelectionPlaceholder is replaced with "clipboard" or "primary".
+	SelectionPlaceholder = "{selection}"
+	// MIMEPlaceholder is replaced with the requested target,
+	// or "text/plain;charset=utf-8" if none was requested.
+	MIMEPlaceholder = "{mime}"
+)
+
+// defaultMIME is what MIMEPlaceholder is replaced with
+// when no target was requested.
+const defaultMIME = "text/plain;charset=utf-8"
+
+var (
+	errUnterminatedQuote = errors.New("unterminated quote")
+	errTrailingBackslash = errors.New("trailing backslash")
+	errEmptyCommand      = errors.New("empty command")
+)
+
+// SplitCommand splits a command line into its arguments the way a POSIX
+// shell does, without expanding anything: arguments are separated by
+// spaces, single quotes preserve everything they enclose, double quotes
+// preserve everything but backslash escapes of \, ", $ and `, and a
+// backslash outside of quotes escapes the next character.
+func SplitCommand(cmdline string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	for i := 0; i < len(cmdline); i++ {
+		ch := cmdline[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		case ch == '\\':
+			if i+1 == len(cmdline) {
+				return nil, errTrailingBackslash
+			}
+			i++
+			arg.WriteByte(cmdline[i])
+			inArg = true
+		case ch == '\'':
+			end := strings.IndexByte(cmdline[i+1:], '\'')
+			if end < 0 {
+				return nil, errUnterminatedQuote
+			}
+			arg.WriteString(cmdline[i+1 : i+1+end])
+			i += end + 1
+			inArg = true
+		case ch == '"':
+			closed := false
+			for i++; i < len(cmdline); i++ {
+				if cmdline[i] == '"' {
+					closed = true
+					break
+				}
+				if cmdline[i] == '\\' && i+1 < len(cmdline) && strings.IndexByte("\\\"$`", cmdline[i+1]) >= 0 {
+					i++
+				}
+				arg.WriteByte(cmdline[i])
+			}
+			if !closed {
+				return nil, errUnterminatedQuote
+			}
+			inArg = true
+		default:
+			arg.WriteByte(ch)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// customCommand parses a custom command line and replaces
+// the placeholders in its arguments.
+func customCommand(cmdline string, replacer *strings.Replacer) (string, []string, error) {
+	args, err := SplitCommand(cmdline)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(args) == 0 || args[0] == "" {
+		return "", nil, errEmptyCommand
+	}
+	for i := range args {
+		args[i] = replacer.Replace(args[i])
+	}
+	return args[0], args[1:], nil
+}
+
+// customCommands returns the custom copy, paste and clear command
+// lines, taken from opts or else from the environment.
+func customCommands(opts Options) (copyCmd, pasteCmd, clearCmd string) {
+	pick := func(cmd, env string) string {
+		if cmd != "" {
+			return cmd
+		}
+		return getenv(env)
+	}
+	return pick(opts.CopyCmd, CopyCmdEnv), pick(opts.PasteCmd, PasteCmdEnv), pick(opts.ClearCmd, ClearCmdEnv)
+}
+
+// newCustomTool returns the pair of tools made of the custom commands
+// in opts or the environment, or nil if there are none. A command that
+// is not set is taken from fallback, which must then not be nil.
+func newCustomTool(opts Options, fallback *ClipboardTool) (*ClipboardTool, error) {
+	copyCmd, pasteCmd, clearCmd := customCommands(opts)
+	if copyCmd == "" && pasteCmd == "" {
+		return nil, nil
+	}
+	selection, mime := "clipboard", defaultMIME
+	if opts.Primary {
+		selection = "primary"
+	}
+	if opts.Target != "" {
+		mime = opts.Target
+	}
+	replacer := strings.NewReplacer(SelectionPlaceholder, selection, MIMEPlaceholder, mime)
+
+	ct := &ClipboardTool{}
+	caps := Capabilities{Copy: true, Paste: true, Clear: true, Streaming: true, Primary: true}
+	if copyCmd != "" {
+		name, args, err := customCommand(copyCmd, replacer)
+		if err != nil {
+			return nil, fmt.Errorf("parsing custom copy command: %w", err)
+		}
+		ct.CopyTool = &CopyTool{Name: name, Path: resolve(opts.Runner, name), CmdArgs: args}
+		caps.Primary = caps.Primary && strings.Contains(copyCmd, SelectionPlaceholder)
+		if clearCmd != "" {
+			ct.CopyTool.ClearArgs, err = customClearArgs(clearCmd, name, replacer)
+			if err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		ct.CopyTool = fallback.CopyTool
+		caps.Primary = caps.Primary && fallback.Capabilities().Primary
+		caps.OneShot = fallback.Capabilities().OneShot
+	}
+	if pasteCmd != "" {
+		name, args, err := customCommand(pasteCmd, replacer)
+		if err != nil {
+			return nil, fmt.Errorf("parsing custom paste command: %w", err)
+		}
+		ct.PasteTool = &PasteTool{Name: name, Path: resolve(opts.Runner, name), CmdArgs: args}
+		caps.Primary = caps.Primary && strings.Contains(pasteCmd, SelectionPlaceholder)
+		caps.Types = strings.Contains(pasteCmd, MIMEPlaceholder)
+	} else {
+		ct.PasteTool = fallback.PasteTool
+		caps.Primary = caps.Primary && fallback.Capabilities().Primary
+		caps.Types = fallback.Capabilities().Types
+	}
+	ct.caps = &caps
+	return ct, nil
+}
+
+// customClearArgs parses the custom clear command, which
+// must run the same program as the custom copy command.
+func customClearArgs(clearCmd, copyName string, replacer *strings.Replacer) ([]string, error) {
+	name, args, err := customCommand(clearCmd, replacer)
+	if err != nil {
+		return nil, fmt.Errorf("parsing custom clear command: %w", err)
+	}
+	if name != copyName {
+		return nil, fmt.Errorf("custom clear command must run %s, like the copy command", copyName)
+	}
+	return args, nil
+}
+
+// resolve returns the path of the named program, found
+// with r, or an empty string if it can't be found.
+func resolve(r command.Runner, name string) string {
+	path, err := command.OrDefault(r).LookPath(name)
+	if err != nil {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboardtool
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitCommand(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		input          string
+		expectedOutput []string
+		expectedError  error
+	}{
+		{
+			desc:           "plain arguments",
+			input:          "  xclip -selection\tclipboard ",
+			expectedOutput: []string{"xclip", "-selection", "clipboard"},
+		},
+		{
+			desc:           "single quotes",
+			input:          `tool 'a "b" \c' 'd'e`,
+			expectedOutput: []string{"tool", `a "b" \c`, "de"},
+		},
+		{
+			desc:           "double quotes",
+			input:          `tool "a 'b' \"c\" \\ \d"`,
+			expectedOutput: []string{"tool", `a 'b' "c" \ \d`},
+		},
+		{
+			desc:           "backslash outside of quotes",
+			input:          `tool a\ b \"c`,
+			expectedOutput: []string{"tool", "a b", `"c`},
+		},
+		{
+			desc:           "empty arguments",
+			input:          `tool '' ""`,
+			expectedOutput: []string{"tool", "", ""},
+		},
+		{
+			desc:  "empty command line",
+			input: " ",
+		},
+		{
+			desc:          "unterminated single quote",
+			input:         "tool 'a",
+			expectedError: errors.New("unterminated quote"),
+		},
+		{
+			desc:          "unterminated double quote",
+			input:         `tool "a\"`,
+			expectedError: errors.New("unterminated quote"),
+		},
+		{
+			desc:          "trailing backslash",
+			input:         `tool a\`,
+			expectedError: errors.New("trailing backslash"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			output, err := SplitCommand(tc.input)
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+				require.Equal(t, tc.expectedOutput, output)
+			}
+		})
+	}
+}
+
+func TestCache_custom(t *testing.T) {
+	detected, err := newClipboardTools(false, &mockRunner{lookPath: usrBin})
+	require.NoError(t, err)
+	fallback := detected[0]
+
+	testCases := []struct {
+		desc              string
+		opts              Options
+		env               map[string]string
+		detect            bool
+		expectedCopyTool  *CopyTool
+		expectedPasteTool *PasteTool
+		expectedCaps      Capabilities
+		expectedError     error
+	}{
+		{
+			desc: "custom copy and paste commands",
+			opts: Options{
+				CopyCmd:  "mycopy --selection {selection}",
+				PasteCmd: "mypaste --selection={selection} --type '{mime}'",
+				Primary:  true,
+				Target:   "text/html",
+			},
+			expectedCopyTool:  &CopyTool{Name: "mycopy", CmdArgs: []string{"--selection", "primary"}},
+			expectedPasteTool: &PasteTool{Name: "mypaste", CmdArgs: []string{"--selection=primary", "--type", "text/html"}},
+			expectedCaps:      Capabilities{Copy: true, Paste: true, Primary: true, Types: true, Clear: true, Streaming: true},
+		},
+		{
+			desc:              "commands from the environment",
+			env:               map[string]string{CopyCmdEnv: "mycopy", PasteCmdEnv: "mypaste --type {mime}"},
+			expectedCopyTool:  &CopyTool{Name: "mycopy", CmdArgs: []string{}},
+			expectedPasteTool: &PasteTool{Name: "mypaste", CmdArgs: []string{"--type", "text/plain;charset=utf-8"}},
+			expectedCaps:      Capabilities{Copy: true, Paste: true, Types: true, Clear: true, Streaming: true},
+		},
+		{
+			desc:              "options take priority over the environment",
+			opts:              Options{CopyCmd: "mycopy", PasteCmd: "mypaste"},
+			env:               map[string]string{CopyCmdEnv: "envcopy", PasteCmdEnv: "envpaste"},
+			expectedCopyTool:  &CopyTool{Name: "mycopy", CmdArgs: []string{}},
+			expectedPasteTool: &PasteTool{Name: "mypaste", CmdArgs: []string{}},
+			expectedCaps:      Capabilities{Copy: true, Paste: true, Clear: true, Streaming: true},
+		},
+		{
+			desc:              "custom clear command",
+			opts:              Options{CopyCmd: "mycopy", PasteCmd: "mypaste", ClearCmd: "mycopy --clear {selection}"},
+			expectedCopyTool:  &CopyTool{Name: "mycopy", CmdArgs: []string{}, ClearArgs: []string{"--clear", "clipboard"}},
+			expectedPasteTool: &PasteTool{Name: "mypaste", CmdArgs: []string{}},
+			expectedCaps:      Capabilities{Copy: true, Paste: true, Clear: true, Streaming: true},
+		},
+		{
+			desc:              "only a custom copy command",
+			opts:              Options{CopyCmd: "mycopy"},
+			detect:            true,
+			expectedCopyTool:  &CopyTool{Name: "mycopy", CmdArgs: []string{}},
+			expectedPasteTool: fallback.PasteTool,
+			expectedCaps: Capabilities{Copy: true, Paste: true, Clear: true, Streaming: true,
+				Types: fallback.Capabilities().Types},
+		},
+		{
+			desc:          "only a custom copy command without detected tools",
+			opts:          Options{CopyCmd: "mycopy"},
+			expectedError: errors.New("no clipboard utilities available"),
+		},
+		{
+			desc:          "clear command running another program",
+			opts:          Options{CopyCmd: "mycopy", PasteCmd: "mypaste", ClearCmd: "other"},
+			expectedError: errors.New("custom clear command must run mycopy, like the copy command"),
+		},
+		{
+			desc:          "invalid quoting",
+			opts:          Options{CopyCmd: "mycopy", PasteCmd: "mypaste 'a"},
+			expectedError: errors.New("parsing custom paste command: unterminated quote"),
+		},
+		{
+			desc:          "empty command",
+			env:           map[string]string{CopyCmdEnv: "''", PasteCmdEnv: "mypaste"},
+			expectedError: errors.New("parsing custom copy command: empty command"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			getenv = func(key string) string { return tc.env[key] }
+			opts := tc.opts
+			opts.Runner = &mockRunner{lookPath: func(file string) (string, error) {
+				if !tc.detect {
+					return "", errors.New("not available")
+				}
+				if file == "mycopy" || file == "mypaste" {
+					return "", errors.New("not available")
+				}
+				return usrBin(file)
+			}}
+			defer func() { getenv = os.Getenv }()
+
+			cts, err := NewCache(opts).Candidates()
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+				require.Equal(t, tc.expectedCopyTool, cts[0].CopyTool)
+				require.Equal(t, tc.expectedPasteTool.CmdArgs, cts[0].PasteTool.CmdArgs)
+				require.Equal(t, tc.expectedPasteTool.Name, cts[0].PasteTool.Name)
+				require.Equal(t, tc.expectedCaps, cts[0].Capabilities())
+			}
+		})
+	}
+}
+
+func TestCache_customEnvChanges(t *testing.T) {
+	env := map[string]string{}
+	getenv = func(key string) string { return env[key] }
+	defer func() { getenv = os.Getenv }()
+
+	c := NewCache(Options{Runner: &mockRunner{lookPath: usrBin}})
+	ct, err := c.Get()
+	require.NoError(t, err)
+	require.NotEqual(t, "mycopy", ct.CopyTool.Name)
+	env[CopyCmdEnv] = "mycopy"
+	ct, err = c.Get()
+	require.NoError(t, err)
+	require.Equal(t, "mycopy", ct.CopyTool.Name)
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboardtool
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+)
+
+// DefaultProbeTimeout is how long a probe may take when no other timeout is given.
+const DefaultProbeTimeout = 2 * time.Second
+
+// Capabilities describes what a pair of clipboard tools can do.
+type Capabilities struct {
+	Copy      bool // Text can be copied
+	Paste     bool // Text can be pasted
+	Primary   bool // The primary selection is supported
+	Types     bool // Specific targets or MIME types can be requested
+	Watch     bool // Clipboard changes can be watched
+	Clear     bool // The clipboard can be emptied
+	OneShot   bool // A copy can be served for a single paste
+	Streaming bool // Content is piped through the tools instead of passed as arguments
+}
+
+// Has reports whether c has every capability set in other.
+func (c Capabilities) Has(other Capabilities) bool {
+	return (c.Copy || !other.Copy) &&
+		(c.Paste || !other.Paste) &&
+		(c.Primary || !other.Primary) &&
+		(c.Types || !other.Types) &&
+		(c.Watch || !other.Watch) &&
+		(c.Clear || !other.Clear) &&
+		(c.OneShot || !other.OneShot) &&
+		(c.Streaming || !other.Streaming)
+}
+
+// unavailableMessages are fragments of what clipboard tools print to
+// standard error when they cannot reach the display server or service.
+var unavailableMessages = []string{
+	"can't open display",
+	"cannot open display",
+	"no protocol specified",
+	"authorization required",
+	"failed to connect to a wayland server",
+	"wayland_display",
+	"compositor doesn't seem to implement",
+	"termux:api",
+}
+
+// IsUnavailable reports whether the standard error of a clipboard tool
+// says that it could not reach the display server or clipboard service.
+func IsUnavailable(stderr []byte) bool {
+	msg := strings.ToLower(string(stderr))
+	for _, fragment := range unavailableMessages {
+		if strings.Contains(msg, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// probeResults caches the outcome of every probe run by
+// command.ExecRunner for the lifetime of the process, keyed
+// by the probe command and the environment it ran in.
+var probeResults sync.Map
+
+// Capabilities returns what the pair of tools supports, according to
+// their documentation, or to the placeholders used by custom commands.
+// It does not check that the tools actually work.
+func (ct *ClipboardTool) Capabilities() Capabilities {
+	if ct.caps != nil {
+		return *ct.caps
+	}
+	return toolCapabilities[ct.CopyTool.Name]
+}
+
+// Probe checks that the pair of tools actually works, by running a cheap,
+// side-effect-free paste command, such as listing the available targets,
+// and returns their capabilities. If the check fails or doesn't finish
+// within timeout, the tools can neither copy nor paste. A zero timeout
+// means DefaultProbeTimeout. Results are cached until the environment
+// changes, so each pair of tools is probed once.
+func Probe(ct *ClipboardTool, timeout time.Duration) Capabilities {
+	return ProbeWith(command.ExecRunner{}, ct, timeout)
+}
+
+// ProbeWith is like Probe, running the check with r. Only
+// the results of command.ExecRunner are cached.
+func ProbeWith(r command.Runner, ct *ClipboardTool, timeout time.Duration) Capabilities {
+	caps := ct.Capabilities()
+	if !probe(r, ct.PasteTool, timeout) {
+		caps.Copy, caps.Paste = false, false
+	}
+	return caps
+}
+
+// probe runs the probe command of the given paste tool with r,
+// or returns the cached result of an earlier run.
+func probe(r command.Runner, pt *PasteTool, timeout time.Duration) bool {
+	args := pt.ProbeArgs
+	if args == nil {
+		args = pt.CmdArgs
+	}
+	_, cached := r.(command.ExecRunner)
+	key := strings.Join(append([]string{environment(), pt.Executable()}, args...), "\x00")
+	if works, ok := probeResults.Load(key); ok && cached {
+		return works.(bool)
+	}
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err := r.Command(ctx, pt.Executable(), args...).TextOutput()
+	works := probeSucceeded(ctx, err)
+	if cached {
+		probeResults.Store(key, works)
+	}
+	return works
+}
+
+// probeSucceeded reports whether a probe that returned err shows that the
+// tool works. A tool that runs but fails for other reasons than reaching
+// the display server, e.g. because the clipboard is empty, works.
+func probeSucceeded(ctx context.Context, err error) bool {
+	if err == nil {
+		return true
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return !IsUnavailable(exitErr.Stderr)
+	}
+	return false
+}
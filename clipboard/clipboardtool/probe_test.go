@@ -0,0 +1,122 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboardtool
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbe(t *testing.T) {
+	// The errors are built beforehand, since building
+	// them runs a process, which can take longer than
+	// the timeout of the probe.
+	targetsErr := exitError(t, "Error: target TARGETS not available\n")
+	noDisplayErr := exitError(t, "Error: Can't open display: (null)\n")
+	testCases := []struct {
+		desc          string
+		runProbeMock  func(ctx context.Context, name string, args ...string) error
+		expectedWorks bool
+	}{
+		{
+			desc: "tool works",
+			runProbeMock: func(ctx context.Context, name string, args ...string) error {
+				return nil
+			},
+			expectedWorks: true,
+		},
+		{
+			desc: "tool runs but the clipboard is empty",
+			runProbeMock: func(ctx context.Context, name string, args ...string) error {
+				return targetsErr
+			},
+			expectedWorks: true,
+		},
+		{
+			desc: "no display",
+			runProbeMock: func(ctx context.Context, name string, args ...string) error {
+				return noDisplayErr
+			},
+		},
+		{
+			desc: "tool cannot be executed",
+			runProbeMock: func(ctx context.Context, name string, args ...string) error {
+				return exec.ErrNotFound
+			},
+		},
+		{
+			desc: "tool hangs",
+			runProbeMock: func(ctx context.Context, name string, args ...string) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := &mockRunner{lookPath: usrBin, run: tc.runProbeMock}
+			ct, err := newClipboardTool(false, r)
+			require.NoError(t, err)
+			expected := ct.Capabilities()
+			expected.Copy, expected.Paste = tc.expectedWorks, tc.expectedWorks
+			require.Equal(t, expected, ProbeWith(r, ct, 10*time.Millisecond))
+		})
+	}
+}
+
+func TestProbe_cached(t *testing.T) {
+	probeResults = sync.Map{}
+	dir := t.TempDir()
+	data, err := os.ReadFile(os.Args[0])
+	require.NoError(t, err)
+	path := filepath.Join(dir, "mypaste")
+	require.NoError(t, os.WriteFile(path, data, 0o755))
+	ct := &ClipboardTool{
+		CopyTool:  &CopyTool{Name: "mycopy"},
+		PasteTool: &PasteTool{Name: "mypaste", Path: path, ProbeArgs: []string{"-test.run=^$"}},
+		caps:      &Capabilities{Copy: true, Paste: true},
+	}
+	require.True(t, Probe(ct, 0).Copy)
+	require.NoError(t, os.Remove(path))
+	require.True(t, Probe(ct, 0).Copy)
+
+	var calls int
+	r := &mockRunner{run: func(ctx context.Context, name string, args ...string) error {
+		calls++
+		return exec.ErrNotFound
+	}}
+	require.False(t, ProbeWith(r, ct, 0).Copy)
+	require.False(t, ProbeWith(r, ct, 0).Copy)
+	require.Equal(t, 2, calls)
+}
+
+func TestCache_probe(t *testing.T) {
+	noDisplayErr := exitError(t, "Error: Can't open display: (null)\n")
+	r := &mockRunner{lookPath: usrBin, run: func(ctx context.Context, name string, args ...string) error {
+		return noDisplayErr
+	}}
+	_, err := NewCache(Options{Runner: r}).Candidates()
+	require.NoError(t, err)
+	_, err = NewCache(Options{Probe: true, Runner: r}).Candidates()
+	require.EqualError(t, err, "no working clipboard utilities available")
+}
+
+// exitError returns an *exec.ExitError with the given standard error,
+// obtained by running the test binary with an unknown flag.
+func exitError(t *testing.T, stderr string) *exec.ExitError {
+	var exitErr *exec.ExitError
+	err := exec.Command(os.Args[0], "-test.unknown-flag").Run()
+	require.True(t, errors.As(err, &exitErr))
+	exitErr.Stderr = []byte(stderr)
+	return exitErr
+}
@@ -5,10 +5,16 @@
 package command
 
 import (
+	"errors"
 	"io"
+	"os"
 	"os/exec"
 )
 
+// maxStderr is how much of the standard error of a
+// command is kept to be reported when it fails.
+const maxStderr = 4096
+
 // ioPipeWriter is an interface that abstracts the io.WriteCloser interface
 // to allow for mocking the standard input pipe of a system command.
 type ioPipeWriter interface {
@@ -37,17 +43,32 @@ type sysCommand interface {
 	Start() error
 	Output() ([]byte, error)
 	StdinPipe() (ioPipeWriter, error)
+	StdoutPipe() (io.ReadCloser, error)
 	Wait() error
+	Kill() error
+	Pid() int
 }
 
 // sysCommandWrapper wraps an exec.Cmd to conform to the sysCommand interface.
 type sysCommandWrapper struct {
-	cmd *exec.Cmd
+	cmd    *exec.Cmd
+	stdout *os.File // write end of the pipe returned by StdoutPipe
+	stderr *os.File
 }
 
 // Start starts the specified command but does not wait for it to complete.
 func (sc *sysCommandWrapper) Start() error {
-	return sc.cmd.Start()
+	err := sc.cmd.Start()
+	if sc.stdout != nil {
+		sc.stdout.Close()
+		sc.stdout = nil
+	}
+	if err != nil && sc.stderr != nil {
+		sc.stderr.Close()
+		os.Remove(sc.stderr.Name())
+		sc.stderr = nil
+	}
+	return err
 }
 
 // Output runs the command and returns its standard output.
@@ -56,20 +77,86 @@ func (sc *sysCommandWrapper) Output() ([]byte, error) {
 }
 
 // StdinPipe returns a pipe that will be connected to the command's standard input
-// when the command starts.
+// when the command starts. The command's standard error is captured, so that it
+// can be reported by Wait the same way Output does. It goes to a temporary file
+// rather than a pipe, since copy tools such as xclip and wl-copy fork a process
+// serving the selection, which inherits it: Wait would wait for that process to
+// close a pipe, i.e. until another program takes the selection.
 func (sc *sysCommandWrapper) StdinPipe() (ioPipeWriter, error) {
+	if sc.cmd.Stderr == nil {
+		if f, err := os.CreateTemp("", "go-clipboard-stderr-"); err == nil {
+			sc.stderr = f
+			sc.cmd.Stderr = f
+		}
+	}
 	p, err := sc.cmd.StdinPipe()
 	return &ioPipeWriterWrapper{p}, err
 }
 
+// StdoutPipe returns a pipe connected to the command's standard output
+// when the command starts. Unlike exec.Cmd.StdoutPipe, it is not closed by
+// Wait, so the output can be read while the command is waited for: it
+// reaches EOF once the command, and the processes it started, exited.
+func (sc *sysCommandWrapper) StdoutPipe() (io.ReadCloser, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	sc.cmd.Stdout = w
+	sc.stdout = w
+	return r, nil
+}
+
 // Wait waits for the command to exit and waits for any copying to stdin or
-// copying from stdout or stderr to complete.
+// copying from stdout or stderr to complete. If the command exits unsuccessfully,
+// the returned *exec.ExitError carries the captured standard error.
 func (sc *sysCommandWrapper) Wait() error {
-	return sc.cmd.Wait()
+	err := sc.cmd.Wait()
+	if sc.stderr == nil {
+		return err
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(exitErr.Stderr) == 0 {
+		// ReadAt leaves the offset alone, which is
+		// shared with the processes the command forked.
+		stderr := make([]byte, maxStderr)
+		n, _ := sc.stderr.ReadAt(stderr, 0)
+		exitErr.Stderr = stderr[:n]
+	}
+	sc.stderr.Close()
+	os.Remove(sc.stderr.Name())
+	sc.stderr = nil
+	return err
+}
+
+// abort kills a started command whose input couldn't be sent, and
+// waits for it, so that it is reaped and its standard error file is
+// removed. It returns err.
+func abort(c sysCommand, err error) error {
+	c.Kill()
+	c.Wait()
+	return err
+}
+
+// Kill kills the started command.
+func (sc *sysCommandWrapper) Kill() error {
+	if sc.cmd.Process == nil {
+		return errors.New("command not started")
+	}
+	return sc.cmd.Process.Kill()
+}
+
+// Pid returns the process id of the started command, or -1.
+func (sc *sysCommandWrapper) Pid() int {
+	if sc.cmd.Process == nil {
+		return -1
+	}
+	return sc.cmd.Process.Pid
 }
 
 // Command is an interface that provides methods for sending text input to a command
-// and receiving text output from a command.
+// and receiving text output from a command. Commands returned by New also implement
+// Starter.
 type Command interface {
 	TextInput(text string) error
 	TextOutput() (string, error)
@@ -84,7 +171,7 @@ type command struct {
 // New creates a new command instance with the specified exec.Cmd.
 func New(cmd *exec.Cmd) Command {
 	return &command{
-		sc: &sysCommandWrapper{cmd},
+		sc: &sysCommandWrapper{cmd: cmd},
 	}
 }
 
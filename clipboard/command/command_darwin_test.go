@@ -7,8 +7,12 @@
 package command
 
 import (
+	"bytes"
 	"errors"
+	"io"
+	"os/exec"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -158,6 +162,60 @@ func (m *mockSysCmd) StdinPipe() (ioPipeWriter, error) {
 	return m.IoPipeWriterMock, m.ErrStdinPipe
 }
 
+func (m *mockSysCmd) StdoutPipe() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(m.CmdOutput)), m.ErrOutput
+}
+
 func (m *mockSysCmd) Wait() error {
 	return m.ErrWait
 }
+
+func (m *mockSysCmd) Kill() error {
+	return nil
+}
+
+func (m *mockSysCmd) Pid() int {
+	return 0
+}
+
+func Test_sysCommandWrapper_stderr(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		run            func(c *command) error
+		expectedStderr string
+	}{
+		{
+			desc: "text input",
+			run: func(c *command) error {
+				return c.TextInput("some text")
+			},
+			expectedStderr: "Can't open display\n",
+		},
+		{
+			desc: "text output",
+			run: func(c *command) error {
+				_, err := c.TextOutput()
+				return err
+			},
+			expectedStderr: "Can't open display\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := New(exec.Command("sh", "-c", "cat >/dev/null; echo \"Can't open display\" >&2; exit 1")).(*command)
+			err := tc.run(c)
+			var exitErr *exec.ExitError
+			require.True(t, errors.As(err, &exitErr))
+			require.Equal(t, tc.expectedStderr, string(exitErr.Stderr))
+		})
+	}
+}
+
+func Test_command_TextInput_forkedProcess(t *testing.T) {
+	// Like xclip and wl-copy, the command forks a process
+	// that keeps its standard error open.
+	c := New(exec.Command("sh", "-c", "cat >/dev/null; sleep 5 &"))
+	start := time.Now()
+	require.NoError(t, c.TextInput("some text"))
+	require.Less(t, time.Since(start), 2*time.Second)
+}
@@ -20,10 +20,10 @@ func textInput(c sysCommand, text string) error {
 		return errors.Wrap(err, "starting command")
 	}
 	if _, err := in.Write([]byte(text)); err != nil {
-		return errors.Wrap(err, "writing input for command")
+		return abort(c, errors.Wrap(err, "writing input for command"))
 	}
 	if err := in.Close(); err != nil {
-		return errors.Wrap(err, "closing input")
+		return abort(c, errors.Wrap(err, "closing input"))
 	}
 	if err := c.Wait(); err != nil {
 		return errors.Wrap(err, "waiting for command")
@@ -7,7 +7,9 @@
 package command
 
 import (
+	"bytes"
 	"errors"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -158,6 +160,18 @@ func (m *mockSysCmd) StdinPipe() (ioPipeWriter, error) {
 	return m.IoPipeWriterMock, m.ErrStdinPipe
 }
 
+func (m *mockSysCmd) StdoutPipe() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(m.CmdOutput)), m.ErrOutput
+}
+
 func (m *mockSysCmd) Wait() error {
 	return m.ErrWait
 }
+
+func (m *mockSysCmd) Kill() error {
+	return nil
+}
+
+func (m *mockSysCmd) Pid() int {
+	return 0
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// ErrUntrusted is returned when a tool is not in a trusted
+// location, or could have been modified by another user.
+var ErrUntrusted = errors.New("untrusted executable")
+
+// SafeEnv lists the environment variables that HardenedRunner
+// passes to the tools by default.
+var SafeEnv = []string{"DISPLAY", "WAYLAND_DISPLAY", "XAUTHORITY", "XDG_RUNTIME_DIR"}
+
+// HardenedRunner is a Runner that doesn't trust the PATH. Tools are
+// pinned to absolute paths, or found in trusted directories only, and
+// refused if they are in the current directory, or if they or their
+// directory could be modified by a user other than root or the current
+// user. They run with a minimal environment.
+type HardenedRunner struct {
+	// Paths pins tools to absolute paths, keyed by
+	// name, e.g. {"xclip": "/usr/bin/xclip"}.
+	Paths map[string]string
+
+	// Dirs lists the directories other tools are found in,
+	// in order. Nil means DefaultTrustedDirs.
+	Dirs []string
+
+	// Env lists the names of the environment variables
+	// passed to the tools. Nil means SafeEnv.
+	Env []string
+}
+
+// Command implements the Runner interface. A name that is not an
+// absolute path is looked up first. If the tool is not trusted, the
+// returned command fails with an error wrapping ErrUntrusted.
+func (r HardenedRunner) Command(ctx context.Context, name string, args ...string) Command {
+	cmd, err := r.Cmd(ctx, name, args...)
+	if err != nil {
+		return failedCommand{err}
+	}
+	return New(cmd)
+}
+
+// Cmd implements the Execer interface, with the
+// same checks and environment as Command.
+func (r HardenedRunner) Cmd(ctx context.Context, name string, args ...string) (*exec.Cmd, error) {
+	path := name
+	if !filepath.IsAbs(name) {
+		var err error
+		if path, err = r.LookPath(name); err != nil {
+			return nil, err
+		}
+	} else if err := r.check(path); err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Env = r.environment()
+	return cmd, nil
+}
+
+// LookPath implements the Runner interface. The named tool
+// is looked for in Paths, then in Dirs; the PATH is ignored.
+func (r HardenedRunner) LookPath(file string) (string, error) {
+	if path, ok := r.Paths[file]; ok {
+		if err := r.check(path); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+	if filepath.Base(file) != file {
+		return "", untrusted(file, "not a plain tool name")
+	}
+	for _, dir := range r.dirs() {
+		if !filepath.IsAbs(dir) {
+			continue
+		}
+		path, err := exec.LookPath(filepath.Join(dir, file))
+		if err != nil {
+			continue
+		}
+		if err := r.check(path); err != nil {
+			return "", err
+		}
+		return path, nil
+	}
+	return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+}
+
+// check returns an error if the tool at path is not trusted.
+func (r HardenedRunner) check(path string) error {
+	if !filepath.IsAbs(path) {
+		return untrusted(path, "not an absolute path")
+	}
+	path = filepath.Clean(path)
+	dir := filepath.Dir(path)
+	if cwd, err := os.Getwd(); err == nil && dir == cwd {
+		return untrusted(path, "in the current directory")
+	}
+	if !r.pinned(path) && !r.inDirs(dir) {
+		return untrusted(path, "not in a trusted directory")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return untrusted(path, "not a regular file")
+	}
+	if err := checkPermissions(path, info); err != nil {
+		return err
+	}
+	for _, d := range directories(path) {
+		info, err := os.Stat(d)
+		if err != nil {
+			return err
+		}
+		if err := checkPermissions(d, info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pinned reports whether path is one of the pinned paths.
+func (r HardenedRunner) pinned(path string) bool {
+	for _, p := range r.Paths {
+		if filepath.Clean(p) == path {
+			return true
+		}
+	}
+	return false
+}
+
+// inDirs reports whether dir is one of the trusted directories.
+func (r HardenedRunner) inDirs(dir string) bool {
+	for _, d := range r.dirs() {
+		if filepath.IsAbs(d) && filepath.Clean(d) == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// dirs returns the trusted directories.
+func (r HardenedRunner) dirs() []string {
+	if r.Dirs == nil {
+		return DefaultTrustedDirs
+	}
+	return r.Dirs
+}
+
+// environment returns the environment of the tools.
+func (r HardenedRunner) environment() []string {
+	names := r.Env
+	if names == nil {
+		names = append(append([]string{}, SafeEnv...), platformEnv...)
+	}
+	env := []string{}
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// directories returns the directory holding path and, if path is
+// a symbolic link, the directory holding the file it points to.
+func directories(path string) []string {
+	dirs := []string{filepath.Dir(path)}
+	if target, err := filepath.EvalSymlinks(path); err == nil && filepath.Dir(target) != dirs[0] {
+		dirs = append(dirs, filepath.Dir(target))
+	}
+	return dirs
+}
+
+// untrusted returns an error wrapping ErrUntrusted.
+func untrusted(path, reason string) error {
+	return fmt.Errorf("%w: %s: %s", ErrUntrusted, path, reason)
+}
+
+// failedCommand is a Command that fails without running.
+type failedCommand struct {
+	err error
+}
+
+// TextInput implements the Command interface.
+func (c failedCommand) TextInput(text string) error {
+	return c.err
+}
+
+// TextOutput implements the Command interface.
+func (c failedCommand) TextOutput() (string, error) {
+	return "", c.err
+}
@@ -0,0 +1,40 @@
+//go:build !windows
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package command
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// DefaultTrustedDirs are the directories HardenedRunner finds tools in
+// when HardenedRunner.Dirs is nil.
+var DefaultTrustedDirs = []string{
+	"/usr/bin",
+	"/bin",
+	"/usr/local/bin",
+	"/opt/homebrew/bin",
+	"/data/data/com.termux/files/usr/bin",
+}
+
+// platformEnv lists the environment variables passed
+// to the tools by default on top of SafeEnv.
+var platformEnv []string
+
+// checkPermissions returns an error if the file or directory at path
+// is writable by its group or by others, or is owned by a user other
+// than root or the current user.
+func checkPermissions(path string, info fs.FileInfo) error {
+	if info.Mode().Perm()&0o022 != 0 {
+		return untrusted(path, "writable by other users")
+	}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok && st.Uid != 0 && int(st.Uid) != os.Geteuid() {
+		return untrusted(path, "owned by another user")
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+//go:build !windows
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package command
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHardenedRunner_LookPath(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		setup         func(t *testing.T, dir, path string) HardenedRunner
+		file          string
+		expectedError error
+	}{
+		{
+			desc: "tool in a trusted directory",
+			setup: func(t *testing.T, dir, path string) HardenedRunner {
+				return HardenedRunner{Dirs: []string{t.TempDir(), dir}}
+			},
+		},
+		{
+			desc: "tool that is only in the PATH",
+			setup: func(t *testing.T, dir, path string) HardenedRunner {
+				t.Setenv("PATH", dir)
+				return HardenedRunner{Dirs: []string{t.TempDir()}}
+			},
+			expectedError: exec.ErrNotFound,
+		},
+		{
+			desc: "relative trusted directory",
+			setup: func(t *testing.T, dir, path string) HardenedRunner {
+				chdir(t, filepath.Dir(dir))
+				return HardenedRunner{Dirs: []string{filepath.Base(dir)}}
+			},
+			expectedError: exec.ErrNotFound,
+		},
+		{
+			desc: "pinned tool",
+			setup: func(t *testing.T, dir, path string) HardenedRunner {
+				return HardenedRunner{Paths: map[string]string{"xclip": path}, Dirs: []string{}}
+			},
+		},
+		{
+			desc: "tool pinned to a relative path",
+			setup: func(t *testing.T, dir, path string) HardenedRunner {
+				return HardenedRunner{Paths: map[string]string{"xclip": "xclip"}}
+			},
+			expectedError: ErrUntrusted,
+		},
+		{
+			desc: "tool writable by other users",
+			setup: func(t *testing.T, dir, path string) HardenedRunner {
+				require.NoError(t, os.Chmod(path, 0o775))
+				return HardenedRunner{Dirs: []string{dir}}
+			},
+			expectedError: ErrUntrusted,
+		},
+		{
+			desc: "directory writable by other users",
+			setup: func(t *testing.T, dir, path string) HardenedRunner {
+				require.NoError(t, os.Chmod(dir, 0o777))
+				return HardenedRunner{Dirs: []string{dir}}
+			},
+			expectedError: ErrUntrusted,
+		},
+		{
+			desc: "link to a tool writable by other users",
+			setup: func(t *testing.T, dir, path string) HardenedRunner {
+				other := t.TempDir()
+				require.NoError(t, os.Chmod(other, 0o777))
+				require.NoError(t, os.Rename(path, filepath.Join(other, "xclip")))
+				require.NoError(t, os.Symlink(filepath.Join(other, "xclip"), path))
+				return HardenedRunner{Dirs: []string{dir}}
+			},
+			expectedError: ErrUntrusted,
+		},
+		{
+			desc: "tool in the current directory",
+			setup: func(t *testing.T, dir, path string) HardenedRunner {
+				chdir(t, dir)
+				return HardenedRunner{Dirs: []string{dir}}
+			},
+			expectedError: ErrUntrusted,
+		},
+		{
+			desc: "path instead of a name",
+			setup: func(t *testing.T, dir, path string) HardenedRunner {
+				return HardenedRunner{Dirs: []string{dir}}
+			},
+			file:          "./xclip",
+			expectedError: ErrUntrusted,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "xclip")
+			require.NoError(t, os.WriteFile(path, nil, 0o755))
+			require.NoError(t, os.Chmod(path, 0o755))
+			r := tc.setup(t, dir, path)
+			file := tc.file
+			if file == "" {
+				file = "xclip"
+			}
+			output, err := r.LookPath(file)
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.ErrorIs(t, err, tc.expectedError)
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+				require.Equal(t, path, output)
+			}
+		})
+	}
+}
+
+func TestHardenedRunner_Command(t *testing.T) {
+	dir := t.TempDir()
+	data, err := os.ReadFile(os.Args[0])
+	require.NoError(t, err)
+	path := filepath.Join(dir, "tool")
+	require.NoError(t, os.WriteFile(path, data, 0o755))
+	r := HardenedRunner{Dirs: []string{dir}}
+
+	_, err = r.Command(context.Background(), "tool", "-test.run=^$").TextOutput()
+	require.NoError(t, err)
+	_, err = r.Command(context.Background(), path, "-test.run=^$").TextOutput()
+	require.NoError(t, err)
+	err = r.Command(context.Background(), os.Args[0], "-test.run=^$").TextInput("")
+	require.True(t, errors.Is(err, ErrUntrusted), "%v", err)
+
+	cmd, err := r.Cmd(context.Background(), "tool")
+	require.NoError(t, err)
+	require.Equal(t, path, cmd.Path)
+	require.NotNil(t, cmd.Env)
+	_, err = r.Cmd(context.Background(), os.Args[0])
+	require.True(t, errors.Is(err, ErrUntrusted), "%v", err)
+}
+
+func TestHardenedRunner_environment(t *testing.T) {
+	t.Setenv("DISPLAY", ":0")
+	t.Setenv("WAYLAND_DISPLAY", "")
+	t.Setenv("XAUTHORITY", "/home/user/.Xauthority")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("LD_PRELOAD", "/tmp/evil.so")
+
+	c := HardenedRunner{}.Command(context.Background(), "/usr/bin/true")
+	if fc, ok := c.(failedCommand); ok {
+		t.Skipf("needs a trusted /usr/bin/true: %v", fc.err)
+	}
+	cmd := c.(*command).sc.(*sysCommandWrapper).cmd
+	expected := []string{"DISPLAY=:0", "WAYLAND_DISPLAY=", "XAUTHORITY=/home/user/.Xauthority"}
+	if value, ok := os.LookupEnv("XDG_RUNTIME_DIR"); ok {
+		expected = append(expected, "XDG_RUNTIME_DIR="+value)
+	}
+	require.Equal(t, expected, cmd.Env)
+
+	c = HardenedRunner{Env: []string{"DISPLAY"}}.Command(context.Background(), "/usr/bin/true")
+	require.Equal(t, []string{"DISPLAY=:0"}, c.(*command).sc.(*sysCommandWrapper).cmd.Env)
+}
+
+// chdir changes the current directory for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	t.Cleanup(func() { os.Chdir(cwd) })
+}
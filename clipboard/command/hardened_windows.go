@@ -0,0 +1,38 @@
+//go:build windows
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package command
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DefaultTrustedDirs are the directories HardenedRunner finds tools in
+// when HardenedRunner.Dirs is nil.
+var DefaultTrustedDirs = []string{
+	filepath.Join(systemRoot(), "System32"),
+	filepath.Join(systemRoot(), "System32", "WindowsPowerShell", "v1.0"),
+}
+
+// platformEnv lists the environment variables passed to the tools by
+// default on top of SafeEnv. PowerShell doesn't start without them.
+var platformEnv = []string{"SystemRoot", "SystemDrive"}
+
+// checkPermissions does nothing on Windows, where files are
+// protected by access control lists rather than mode bits.
+func checkPermissions(path string, info fs.FileInfo) error {
+	return nil
+}
+
+// systemRoot returns the directory Windows is installed in.
+func systemRoot() string {
+	if dir := os.Getenv("SystemRoot"); dir != "" {
+		return dir
+	}
+	return `C:\Windows`
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package command
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// StartTimeout is how long a command started with StartInput may take
+// to be ready before it is considered running anyway, unless it failed,
+// e.g. because there is no display.
+const StartTimeout = time.Second
+
+// readyPollInterval is how often StartInput checks whether a command is ready.
+const readyPollInterval = 5 * time.Millisecond
+
+// exitGrace is how long a command whose input couldn't be
+// sent is given to exit on its own before it is stopped.
+const exitGrace = 100 * time.Millisecond
+
+// Process is a command left running in the background.
+type Process interface {
+	// Pid returns the process id.
+	Pid() int
+
+	// Done returns a channel closed once the process
+	// exited and was reaped.
+	Done() <-chan struct{}
+
+	// Err returns the error the process exited with,
+	// once Done is closed.
+	Err() error
+
+	// Stop kills the process, if it is still running,
+	// and waits for it to be reaped.
+	Stop() error
+}
+
+// Starter is implemented by commands that can be left running once their
+// input is sent, such as a clipboard tool serving a selection in the
+// foreground.
+type Starter interface {
+	// StartInput sends the provided text as input to the command, and
+	// returns it running once ready reports true, e.g. once the command
+	// serves the selection, which is checked until the command exits or
+	// StartTimeout elapses. It fails if the command fails before. A nil
+	// ready means the command is ready once its input is sent. The
+	// process is reaped as soon as it exits and, on Linux, gets SIGTERM
+	// once the program exits.
+	StartInput(text string, ready func() bool) (Process, error)
+}
+
+// OutputStarter is implemented by commands whose output can be read
+// while they run, such as a clipboard tool reporting changes.
+type OutputStarter interface {
+	// StartOutput starts the command and returns it running, with its
+	// standard output, which reaches EOF once it exits. The process is
+	// reaped as soon as it exits and, on Linux, gets SIGTERM once the
+	// program exits.
+	StartOutput() (io.ReadCloser, Process, error)
+}
+
+// StartOutput implements the OutputStarter interface.
+func (c *command) StartOutput() (io.ReadCloser, Process, error) {
+	return startOutput(c.sc)
+}
+
+// StartOutput implements the OutputStarter interface.
+func (c failedCommand) StartOutput() (io.ReadCloser, Process, error) {
+	return nil, nil, c.err
+}
+
+// StartInput implements the Starter interface.
+func (c *command) StartInput(text string, ready func() bool) (Process, error) {
+	return startInput(c.sc, text, ready)
+}
+
+// StartInput implements the Starter interface.
+func (c failedCommand) StartInput(text string, ready func() bool) (Process, error) {
+	return nil, c.err
+}
+
+// startInput starts the system command, sends it the provided text as
+// input, waits for it in the background, and for it to be ready.
+func startInput(c sysCommand, text string, ready func() bool) (Process, error) {
+	stopWithParent(c)
+	in, err := c.StdinPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "getting pipe for command")
+	}
+	if err := c.Start(); err != nil {
+		return nil, errors.Wrap(err, "starting command")
+	}
+	p := &process{sc: c, done: make(chan struct{})}
+	go func() {
+		p.err = c.Wait()
+		close(p.done)
+	}()
+	if _, err := in.Write([]byte(text)); err != nil {
+		return nil, p.fail(errors.Wrap(err, "writing input for command"))
+	}
+	if err := in.Close(); err != nil {
+		return nil, p.fail(errors.Wrap(err, "closing input"))
+	}
+	timeout := time.NewTimer(StartTimeout)
+	defer timeout.Stop()
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			if p.err != nil {
+				return nil, errors.Wrap(p.err, "waiting for command")
+			}
+			return p, nil
+		default:
+		}
+		if ready == nil || ready() {
+			return p, nil
+		}
+		select {
+		case <-p.done:
+		case <-timeout.C:
+			return p, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// startOutput starts the system command, with its standard
+// output piped, and waits for it in the background.
+func startOutput(c sysCommand) (io.ReadCloser, Process, error) {
+	stopWithParent(c)
+	out, err := c.StdoutPipe()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "getting pipe for command")
+	}
+	if err := c.Start(); err != nil {
+		out.Close()
+		return nil, nil, errors.Wrap(err, "starting command")
+	}
+	p := &process{sc: c, done: make(chan struct{})}
+	go func() {
+		p.err = c.Wait()
+		close(p.done)
+	}()
+	return out, p, nil
+}
+
+// stopWithParent makes a system command left running stop once the
+// program exits, where that is supported: on Linux.
+func stopWithParent(c sysCommand) {
+	if sc, ok := c.(*sysCommandWrapper); ok {
+		sc.stopWithParent()
+	}
+}
+
+// process is a system command waited for in the background.
+type process struct {
+	sc   sysCommand
+	done chan struct{}
+	err  error
+}
+
+// fail stops a process whose input couldn't be sent, and returns why:
+// the error the process exited with if it exited on its own, e.g.
+// because it failed before reading its input, or err.
+func (p *process) fail(err error) error {
+	timer := time.NewTimer(exitGrace)
+	defer timer.Stop()
+	select {
+	case <-p.done:
+		if p.err != nil {
+			return errors.Wrap(p.err, "waiting for command")
+		}
+	case <-timer.C:
+		p.Stop()
+	}
+	return err
+}
+
+// Pid implements the Process interface.
+func (p *process) Pid() int {
+	return p.sc.Pid()
+}
+
+// Done implements the Process interface.
+func (p *process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Err implements the Process interface.
+func (p *process) Err() error {
+	select {
+	case <-p.done:
+		return p.err
+	default:
+		return nil
+	}
+}
+
+// Stop implements the Process interface.
+func (p *process) Stop() error {
+	select {
+	case <-p.done:
+		return nil
+	default:
+	}
+	if err := p.sc.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return errors.Wrap(err, "killing command")
+	}
+	<-p.done
+	return nil
+}
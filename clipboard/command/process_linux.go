@@ -0,0 +1,19 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package command
+
+import "syscall"
+
+// stopWithParent makes the command get SIGTERM once the program exits,
+// even if it didn't stop it, so that it isn't left running. The signal
+// is tied to the thread starting the command, which the Go runtime only
+// ends for goroutines that locked their thread and returned without
+// unlocking it.
+func (sc *sysCommandWrapper) stopWithParent() {
+	if sc.cmd.SysProcAttr == nil {
+		sc.cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	sc.cmd.SysProcAttr.Pdeathsig = syscall.SIGTERM
+}
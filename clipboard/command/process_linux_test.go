@@ -0,0 +1,53 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package command
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// parentEnv makes the test binary start a command
+// with StartInput, print its pid and exit.
+const parentEnv = "GO_COMMAND_TEST_PARENT"
+
+func TestStartInput_parentExits(t *testing.T) {
+	if os.Getenv(parentEnv) != "" {
+		p, err := New(exec.Command("sh", "-c", "cat >/dev/null; sleep 60")).(Starter).StartInput("some text", nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println(p.Pid())
+		os.Exit(0)
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestStartInput_parentExits$")
+	cmd.Env = append(os.Environ(), parentEnv+"=1")
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return !running(pid)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// running reports whether the process with the given pid is running:
+// it exists, and isn't a zombie waiting to be reaped by init.
+func running(pid int) bool {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
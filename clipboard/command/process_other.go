@@ -0,0 +1,11 @@
+//go:build !linux
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package command
+
+// stopWithParent does nothing: outside Linux, a command can't be told
+// to stop when the program exits, and keeps running unless stopped.
+func (sc *sysCommandWrapper) stopWithParent() {}
@@ -0,0 +1,137 @@
+//go:build !windows
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package command
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartInput(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		script          string
+		ready           func(file string) func() bool
+		expectedRunning bool
+		expectedError   error
+	}{
+		{
+			desc:            "keeps running",
+			script:          `cat > "$0"; exec sleep 60`,
+			expectedRunning: true,
+		},
+		{
+			desc:            "ready once it wrote its input",
+			script:          `sleep 0.2; cat > "$0"; exec sleep 60`,
+			ready:           wrote,
+			expectedRunning: true,
+		},
+		{
+			desc:   "exits right away",
+			script: `cat > "$0"`,
+			ready:  wrote,
+		},
+		{
+			desc:          "fails right away",
+			script:        `echo "Can't open display" >&2; exit 3`,
+			ready:         wrote,
+			expectedError: errors.New("waiting for command: exit status 3"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "input")
+			var ready func() bool
+			if tc.ready != nil {
+				ready = tc.ready(file)
+			}
+			p, err := New(exec.Command("sh", "-c", tc.script, file)).(Starter).StartInput("some text", ready)
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+				var exitErr *exec.ExitError
+				require.True(t, errors.As(err, &exitErr))
+				require.Equal(t, "Can't open display\n", string(exitErr.Stderr))
+				return
+			}
+			if tc.expectedError != nil {
+				t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+			}
+			if tc.ready != nil {
+				require.True(t, tc.ready(file)())
+			}
+
+			select {
+			case <-p.Done():
+				require.False(t, tc.expectedRunning, "process exited")
+				require.NoError(t, p.Err())
+			default:
+				require.True(t, tc.expectedRunning, "process still running")
+			}
+			require.NoError(t, p.Stop())
+			<-p.Done()
+			// The process was reaped, so it doesn't even exist as a zombie.
+			require.ErrorIs(t, syscall.Kill(p.Pid(), 0), syscall.ESRCH)
+		})
+	}
+}
+
+func TestStartInput_failedCommand(t *testing.T) {
+	r := HardenedRunner{Dirs: []string{t.TempDir()}}
+	_, err := r.Command(context.Background(), "xclip").(Starter).StartInput("some text", nil)
+	require.ErrorIs(t, err, exec.ErrNotFound)
+}
+
+// wrote returns a function reporting whether the
+// file holds the input of the command.
+func wrote(file string) func() bool {
+	return func() bool {
+		data, err := os.ReadFile(file)
+		return err == nil && string(data) == "some text"
+	}
+}
+
+func TestStartOutput(t *testing.T) {
+	out, p, err := New(exec.Command("sh", "-c", `echo first; sleep 0.1; echo second`)).(OutputStarter).StartOutput()
+	require.NoError(t, err)
+	defer out.Close()
+	// The output can be read after the process was reaped.
+	<-p.Done()
+	require.NoError(t, p.Err())
+	data, err := io.ReadAll(out)
+	require.NoError(t, err)
+	require.Equal(t, "first\nsecond\n", string(data))
+}
+
+func TestStartOutput_stop(t *testing.T) {
+	out, p, err := New(exec.Command("sh", "-c", `echo started; exec sleep 60`)).(OutputStarter).StartOutput()
+	require.NoError(t, err)
+	defer out.Close()
+	line, err := bufio.NewReader(out).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "started\n", line)
+	require.NoError(t, p.Stop())
+	_, err = io.ReadAll(out)
+	require.NoError(t, err)
+}
+
+func TestStartOutput_failedCommand(t *testing.T) {
+	r := HardenedRunner{Dirs: []string{t.TempDir()}}
+	_, _, err := r.Command(context.Background(), "wl-paste").(OutputStarter).StartOutput()
+	require.ErrorIs(t, err, exec.ErrNotFound)
+}
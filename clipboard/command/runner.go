@@ -0,0 +1,58 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package command
+
+import (
+	"context"
+	"os/exec"
+)
+
+// Runner finds and runs the clipboard tools. It can be wrapped to add
+// sandboxing, logging, dry runs or remote execution.
+type Runner interface {
+	// Command returns the command running name with args. The
+	// command is killed if ctx is done before it finishes.
+	Command(ctx context.Context, name string, args ...string) Command
+
+	// LookPath searches for the named executable, like exec.LookPath.
+	LookPath(file string) (string, error)
+}
+
+// Execer is implemented by runners that can return the exec.Cmd of a
+// command, once they checked it, so that the caller runs it with its own
+// pipes, e.g. to talk to a backend plugin. Runners that don't implement
+// it can't run plugins.
+type Execer interface {
+	// Cmd returns the exec.Cmd running name with args, or an
+	// error if the runner refuses to run it.
+	Cmd(ctx context.Context, name string, args ...string) (*exec.Cmd, error)
+}
+
+// ExecRunner is the default Runner. It runs commands on the local
+// system with os/exec.
+type ExecRunner struct{}
+
+// Command implements the Runner interface.
+func (ExecRunner) Command(ctx context.Context, name string, args ...string) Command {
+	return New(exec.CommandContext(ctx, name, args...))
+}
+
+// Cmd implements the Execer interface.
+func (ExecRunner) Cmd(ctx context.Context, name string, args ...string) (*exec.Cmd, error) {
+	return exec.CommandContext(ctx, name, args...), nil
+}
+
+// LookPath implements the Runner interface.
+func (ExecRunner) LookPath(file string) (string, error) {
+	return exec.LookPath(file)
+}
+
+// OrDefault returns r, or ExecRunner if r is nil.
+func OrDefault(r Runner) Runner {
+	if r == nil {
+		return ExecRunner{}
+	}
+	return r
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package command
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecRunner(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	testCases := []struct {
+		desc          string
+		ctx           context.Context
+		expectedError error
+	}{
+		{
+			desc: "command runs",
+			ctx:  context.Background(),
+		},
+		{
+			desc:          "context is done",
+			ctx:           ctx,
+			expectedError: context.Canceled,
+		},
+	}
+	cancel()
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			r := OrDefault(nil)
+			path, err := r.LookPath(os.Args[0])
+			require.NoError(t, err)
+			_, err = r.Command(tc.ctx, path, "-test.run=^$").TextOutput()
+			if tc.expectedError != nil {
+				require.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestOrDefault(t *testing.T) {
+	require.Equal(t, ExecRunner{}, OrDefault(nil))
+	var r Runner = ExecRunner{}
+	require.Equal(t, r, OrDefault(r))
+	_, err := OrDefault(nil).LookPath("go-clipboard-missing-tool")
+	require.ErrorIs(t, err, exec.ErrNotFound)
+}
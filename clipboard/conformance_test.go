@@ -0,0 +1,53 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard"
+	"github.com/tiagomelo/go-clipboard/clipboard/clipboardtest"
+	"github.com/tiagomelo/go-clipboard/clipboard/plugin"
+)
+
+func TestConformance_plugin(t *testing.T) {
+	dir := t.TempDir()
+	installTestBinary(t, dir, plugin.Prefix+"file")
+	t.Setenv("PATH", dir)
+	t.Setenv("GO_CLIPBOARD_TEST_PLUGIN_DIR", t.TempDir())
+
+	t.Run("Backend", func(t *testing.T) {
+		clipboardtest.RunConformance(t, func(t *testing.T, selection clipboard.Selection) clipboard.Clipboard {
+			b, err := clipboard.NewPluginBackend("file", clipboard.ClipboardOptions{Primary: selection == clipboard.SelectionPrimary})
+			require.NoError(t, err)
+			return b
+		})
+	})
+	t.Run("Clipboard", func(t *testing.T) {
+		clipboardtest.RunConformance(t, func(t *testing.T, selection clipboard.Selection) clipboard.Clipboard {
+			c, err := clipboard.New(clipboard.ClipboardOptions{
+				Primary: selection == clipboard.SelectionPrimary,
+				Tools:   []string{"file"},
+			})
+			require.NoError(t, err)
+			return c
+		})
+	})
+}
+
+// installTestBinary copies the test binary into dir under the
+// given name, so that it runs as a plugin.
+func installTestBinary(t *testing.T, dir, name string) {
+	data, err := os.ReadFile(os.Args[0])
+	require.NoError(t, err)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o755))
+}
@@ -0,0 +1,44 @@
+//go:build freebsd || linux || netbsd || openbsd || solaris || dragonfly
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard"
+	"github.com/tiagomelo/go-clipboard/clipboard/clipboardtest"
+	"github.com/tiagomelo/go-clipboard/clipboard/clipboardtest/fakebin"
+)
+
+func TestConformance_tools(t *testing.T) {
+	for _, tools := range [][]string{
+		{"xsel"},
+		{"xclip"},
+		{"wl-copy", "wl-paste"},
+		{"termux-clipboard-set", "termux-clipboard-get"},
+	} {
+		tools := tools
+		t.Run(tools[0], func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, fakebin.Install(dir, tools...))
+			// wl-paste --watch runs echo.
+			t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+			t.Setenv(fakebin.StateDirEnv, t.TempDir())
+
+			clipboardtest.RunConformance(t, func(t *testing.T, selection clipboard.Selection) clipboard.Clipboard {
+				c, err := clipboard.New(clipboard.ClipboardOptions{
+					Primary: selection == clipboard.SelectionPrimary,
+					Tools:   tools[:1],
+				})
+				require.NoError(t, err)
+				return c
+			})
+		})
+	}
+}
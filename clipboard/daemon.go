@@ -0,0 +1,243 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/internal/ownership"
+	"github.com/tiagomelo/go-clipboard/clipboard/plugin"
+)
+
+// DaemonName is the name of the clipboard daemon, and of its backend.
+const DaemonName = "gclipd"
+
+// DaemonSocketEnv names the environment variable
+// holding the path of the socket of the daemon.
+const DaemonSocketEnv = "GO_CLIPBOARD_DAEMON_SOCKET"
+
+// ErrDaemonUnavailable is returned when the daemon can't be reached,
+// e.g. because it stopped and left its socket behind.
+var ErrDaemonUnavailable = errors.New("clipboard daemon unavailable")
+
+// DaemonSocket returns the path of the socket of the daemon: the value of
+// GO_CLIPBOARD_DAEMON_SOCKET if set, or gclipd.sock in XDG_RUNTIME_DIR,
+// or in a directory of the user in the temporary directory.
+func DaemonSocket() string {
+	if path := os.Getenv(DaemonSocketEnv); path != "" {
+		return path
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, DaemonName+".sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", DaemonName, os.Getuid()), DaemonName+".sock")
+}
+
+// NewDaemonBackend returns the backend talking to the daemon listening on
+// the socket at path, e.g. DaemonSocket(). It uses the selection and
+// target set in opts, and implements TypeLister, Watcher, DataPaster and
+// DataCopier. The daemon is trusted whoever runs it, e.g. one shared by
+// another user with gclipd -allow-uid.
+func NewDaemonBackend(path string, opts ClipboardOptions) Backend {
+	return &daemonBackend{opts: &opts, path: path}
+}
+
+// daemonBackend is a Backend talking to the daemon. It
+// connects to the daemon for each operation.
+type daemonBackend struct {
+	opts    *ClipboardOptions
+	path    string
+	private bool // Only talk to a daemon run by the user
+	oneShot bool // Copy for a single paste, out of the history
+
+	capsOnce sync.Once
+	caps     Capabilities
+}
+
+// Name implements the Backend interface.
+func (b *daemonBackend) Name() string {
+	return DaemonName
+}
+
+// Capabilities implements the Backend interface. They are asked
+// once; a daemon that can't be reached has no capabilities.
+func (b *daemonBackend) Capabilities() Capabilities {
+	b.capsOnce.Do(func() {
+		b.run(func(c *plugin.Client) error {
+			caps, err := c.Capabilities()
+			if err == nil {
+				b.caps = pluginCapabilities(caps)
+			}
+			return err
+		})
+	})
+	return b.caps
+}
+
+// CopyText implements the Backend interface.
+func (b *daemonBackend) CopyText(s string) error {
+	return b.run(func(c *plugin.Client) error {
+		return copyPlugin(c, b.selection(), "", s, b.oneShot || b.opts.OneShot)
+	})
+}
+
+// PasteText implements the Backend interface.
+// The configured target is requested as the MIME type.
+func (b *daemonBackend) PasteText() (string, error) {
+	var text string
+	err := b.run(func(c *plugin.Client) error {
+		var err error
+		text, err = c.Paste(b.selection(), b.opts.Target)
+		return err
+	})
+	return text, err
+}
+
+// PasteData implements the DataPaster interface.
+// The target is requested as the MIME type.
+func (b *daemonBackend) PasteData(target string) ([]byte, error) {
+	var data []byte
+	err := b.run(func(c *plugin.Client) error {
+		text, err := c.Paste(b.selection(), target)
+		data = []byte(text)
+		return err
+	})
+	return data, err
+}
+
+// CopyData implements the DataCopier interface.
+// The target is sent as the MIME type.
+func (b *daemonBackend) CopyData(target string, data []byte) error {
+	return b.run(func(c *plugin.Client) error {
+		return copyPlugin(c, b.selection(), target, string(data), b.oneShot || b.opts.OneShot)
+	})
+}
+
+// Clear implements the Backend interface.
+func (b *daemonBackend) Clear() error {
+	return b.run(func(c *plugin.Client) error {
+		return c.Clear(b.selection())
+	})
+}
+
+// Types implements the TypeLister interface.
+func (b *daemonBackend) Types() ([]string, error) {
+	var types []string
+	err := b.run(func(c *plugin.Client) error {
+		var err error
+		types, err = c.Types(b.selection())
+		return err
+	})
+	return types, err
+}
+
+// Watch implements the Watcher interface. The connection
+// is kept until ctx is done or the daemon stops.
+func (b *daemonBackend) Watch(ctx context.Context) (<-chan string, error) {
+	c, err := b.dial()
+	if err != nil {
+		return nil, err
+	}
+	events, err := c.Watch(ctx, b.selection())
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	texts := make(chan string)
+	go func() {
+		defer close(texts)
+		defer c.Close()
+		for event := range events {
+			select {
+			case texts <- event.Text:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return texts, nil
+}
+
+// run connects to the daemon, calls f with it, and disconnects.
+func (b *daemonBackend) run(f func(c *plugin.Client) error) error {
+	c, err := b.dial()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	return f(c)
+}
+
+// dial connects to the daemon and performs the handshake, once the
+// daemon is known to be run by the user if it must be.
+func (b *daemonBackend) dial() (*plugin.Client, error) {
+	conn, err := net.Dial("unix", b.path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w: %v", DaemonName, ErrDaemonUnavailable, err)
+	}
+	if b.private {
+		if err := checkPeer(conn.(*net.UnixConn)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s: %w: %w", DaemonName, ErrDaemonUnavailable, err)
+		}
+	}
+	return plugin.NewClient(conn, conn, plugin.Options{Timeout: b.opts.PluginTimeout})
+}
+
+// checkPeer returns an error wrapping ErrInsecure if the
+// process at the other end of conn isn't run by the user.
+func checkPeer(conn *net.UnixConn) error {
+	uid, err := ownership.PeerUID(conn)
+	if err != nil {
+		return fmt.Errorf("%w: reading peer credentials: %v", ErrInsecure, err)
+	}
+	if uid != os.Getuid() {
+		return fmt.Errorf("%w: run by user %d", ErrInsecure, uid)
+	}
+	return nil
+}
+
+// selection returns the selection to use.
+func (b *daemonBackend) selection() string {
+	if b.opts.Primary {
+		return string(SelectionPrimary)
+	}
+	return string(SelectionClipboard)
+}
+
+// daemonBackend returns the backend of the daemon if its socket exists and
+// it isn't disabled, and ClipboardOptions.Tools is empty or names it. The
+// socket, and its directory, must belong to the user and be inaccessible
+// to other users, who could otherwise have created them to receive what
+// is copied; the daemon must be run by the user too.
+func (c *clipboard) daemonBackend() *daemonBackend {
+	if c.opts.NoDaemon {
+		return nil
+	}
+	named := len(c.opts.Tools) == 0
+	for _, name := range c.opts.Tools {
+		named = named || name == DaemonName
+	}
+	path := DaemonSocket()
+	if info, err := os.Lstat(path); !named || err != nil || info.Mode().Type() != fs.ModeSocket {
+		return nil
+	}
+	if ownership.Check(filepath.Dir(path)) != nil || ownership.Check(path) != nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.daemon == nil || c.daemon.path != path {
+		c.daemon = &daemonBackend{opts: &c.opts, path: path, private: true}
+	}
+	return c.daemon
+}
@@ -0,0 +1,29 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package daemon
+
+import (
+	"net"
+
+	"github.com/tiagomelo/go-clipboard/clipboard"
+	"github.com/tiagomelo/go-clipboard/clipboard/plugin"
+)
+
+// FetchHistory asks the daemon listening on the socket at path for the
+// texts held by the given selection, the current one last.
+func FetchHistory(path string, s clipboard.Selection) ([]Entry, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := plugin.NewClient(conn, conn, plugin.Options{})
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	var result HistoryResult
+	err = c.Call(MethodHistory, plugin.Params{Selection: string(s)}, &result)
+	return result.Entries, err
+}
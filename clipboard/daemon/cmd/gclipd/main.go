@@ -0,0 +1,123 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+// gclipd is the clipboard daemon. It owns the selections and serves
+// them on a Unix socket, until it receives SIGINT or SIGTERM. On SIGHUP,
+// it restarts without losing the selections or refusing connections,
+// e.g. after being upgraded.
+//
+//	gclipd [-socket path] [-history n] [-allow-uid uid,...] [-v]
+//	gclipd history [-socket path] [-primary]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/tiagomelo/go-clipboard/clipboard"
+	"github.com/tiagomelo/go-clipboard/clipboard/daemon"
+)
+
+// shutdownTimeout is how long the requests being
+// served may take to finish when the daemon stops.
+const shutdownTimeout = 5 * time.Second
+
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "history" {
+		os.Exit(history(os.Args[2:]))
+	}
+	os.Exit(serve(os.Args[1:]))
+}
+
+// serve runs the daemon.
+func serve(args []string) int {
+	flags := flag.NewFlagSet("gclipd", flag.ExitOnError)
+	socket := flags.String("socket", clipboard.DaemonSocket(), "path of the socket")
+	size := flags.Int("history", daemon.DefaultHistory, "number of texts kept for each selection")
+	allow := flags.String("allow-uid", "", "comma-separated ids of other users allowed to connect")
+	verbose := flags.Bool("v", false, "log every connection")
+	flags.Parse(args)
+
+	level := slog.LevelInfo
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	opts := daemon.Options{History: *size, Logger: logger}
+	if *allow != "" {
+		for _, s := range strings.Split(*allow, ",") {
+			uid, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				logger.Error("invalid user id", "uid", s)
+				return 2
+			}
+			opts.AllowUIDs = append(opts.AllowUIDs, uid)
+		}
+	}
+	if state := daemon.InheritedState(); state != nil {
+		defer state.Close()
+		opts.State = state
+	}
+	d, err := daemon.New(opts)
+	if err != nil {
+		logger.Error("starting daemon", "error", err)
+		return 1
+	}
+	l, err := daemon.Listen(*socket)
+	if err != nil {
+		logger.Error("listening", "error", err)
+		return 1
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		sig := <-signals
+		if sig == syscall.SIGHUP {
+			if err := d.Restart(l); err != nil {
+				logger.Error("restarting", "error", err)
+			}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		d.Shutdown(ctx)
+	}()
+	logger.Info("serving", "socket", l.Addr().String())
+	if err := d.Serve(l); !errors.Is(err, daemon.ErrClosed) {
+		logger.Error("serving", "error", err)
+		return 1
+	}
+	return 0
+}
+
+// history prints the texts held by a selection, the current one last.
+func history(args []string) int {
+	flags := flag.NewFlagSet("gclipd history", flag.ExitOnError)
+	socket := flags.String("socket", clipboard.DaemonSocket(), "path of the socket")
+	primary := flags.Bool("primary", false, "print the history of the primary selection")
+	flags.Parse(args)
+
+	selection := clipboard.SelectionClipboard
+	if *primary {
+		selection = clipboard.SelectionPrimary
+	}
+	entries, err := daemon.FetchHistory(*socket, selection)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, e := range entries {
+		fmt.Printf("%s\t%q\n", e.Time.Format(time.RFC3339), e.Text)
+	}
+	return 0
+}
@@ -0,0 +1,676 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tiagomelo/go-clipboard/clipboard"
+	"github.com/tiagomelo/go-clipboard/clipboard/internal/ownership"
+	"github.com/tiagomelo/go-clipboard/clipboard/plugin"
+)
+
+// DefaultHistory is how many texts are kept for each
+// selection when no other size is given.
+const DefaultHistory = 50
+
+// MethodHistory is the method of the requests for the history of a
+// selection. Its params are plugin.Params and its result HistoryResult.
+const MethodHistory = "history"
+
+// ErrClosed is returned by Serve once the daemon is shut down.
+var ErrClosed = errors.New("daemon closed")
+
+// Entry is a text held by a selection.
+type Entry struct {
+	Time time.Time `json:"time"` // When the text was copied
+	Text string    `json:"text"`
+}
+
+// HistoryResult is the result of a history request.
+type HistoryResult struct {
+	Entries []Entry `json:"entries"` // Texts held by the selection, the current one last
+}
+
+// Options configures a Daemon.
+type Options struct {
+	// Clipboard configures the clipboards owning the selections. Primary
+	// and Target are set by the daemon for each selection and target, and
+	// NoDaemon, Supervise and NoLock are always set.
+	Clipboard clipboard.ClipboardOptions
+
+	// History is how many texts are kept for each selection.
+	// Zero means DefaultHistory.
+	History int
+
+	// AllowUIDs lists the users, other than the one running
+	// the daemon, who are allowed to connect.
+	AllowUIDs []int
+
+	// State is the state written by SaveState, e.g. InheritedState()
+	// after Restart. The history is restored, and the current text of each
+	// selection is copied again, so that this daemon serves it.
+	State io.Reader
+
+	// Logger logs refused connections and failures.
+	// Nil means they are not logged.
+	Logger *slog.Logger
+}
+
+// Daemon owns the selections and serves them. It implements the
+// plugin.Handler and plugin.Caller interfaces, so that it can also be
+// served by plugin.Serve over other transports.
+type Daemon struct {
+	opts       Options
+	ctx        context.Context
+	cancel     context.CancelFunc
+	selections map[clipboard.Selection]*selection
+
+	mu        sync.Mutex
+	closed    bool
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup
+}
+
+// New returns a daemon owning the clipboard and the primary selection,
+// restoring opts.State if set. The selections are watched, when the
+// clipboard tools can, so that texts copied by other programs are kept
+// in the history.
+func New(opts Options) (*Daemon, error) {
+	if opts.History <= 0 {
+		opts.History = DefaultHistory
+	}
+	opts.Clipboard.NoDaemon = true
+	opts.Clipboard.Supervise = true
+	opts.Clipboard.NoLock = true
+	d := &Daemon{
+		opts:       opts,
+		selections: make(map[clipboard.Selection]*selection),
+		listeners:  make(map[net.Listener]struct{}),
+		conns:      make(map[net.Conn]struct{}),
+	}
+	for _, name := range []clipboard.Selection{clipboard.SelectionClipboard, clipboard.SelectionPrimary} {
+		s := &selection{
+			name:       name,
+			size:       opts.History,
+			clipboards: make(map[string]clipboard.Clipboard),
+			watchers:   make(map[chan plugin.Event]struct{}),
+		}
+		if _, err := s.clipboard(opts.Clipboard, ""); err != nil {
+			return nil, err
+		}
+		d.selections[name] = s
+	}
+	if opts.State != nil {
+		if err := d.restore(opts.State); err != nil {
+			return nil, fmt.Errorf("restoring state: %w", err)
+		}
+	}
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	for _, s := range d.selections {
+		d.watch(s)
+	}
+	return d, nil
+}
+
+// Serve accepts connections on l and serves them, until the daemon is
+// shut down, when it returns ErrClosed. Connections from users other
+// than the one running the daemon and the allowed ones are refused.
+// Serve always closes l.
+func (d *Daemon) Serve(l net.Listener) error {
+	defer l.Close()
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return ErrClosed
+	}
+	d.listeners[l] = struct{}{}
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		delete(d.listeners, l)
+		d.mu.Unlock()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			d.mu.Lock()
+			closed := d.closed
+			d.mu.Unlock()
+			if closed {
+				return ErrClosed
+			}
+			return err
+		}
+		if err := d.authorize(conn); err != nil {
+			d.log(slog.LevelWarn, "connection refused", "error", err)
+			conn.Close()
+			continue
+		}
+		if !d.track(conn) {
+			conn.Close()
+			return ErrClosed
+		}
+		go func() {
+			defer d.untrack(conn)
+			if err := plugin.Serve(conn, conn, d); err != nil {
+				d.log(slog.LevelDebug, "connection failed", "error", err)
+			}
+		}()
+	}
+}
+
+// Shutdown stops accepting connections, stops the watches, and waits for
+// the requests being served, until ctx is done, when the connections are
+// closed. The clipboard tools serving the selections are stopped last.
+func (d *Daemon) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	d.closed = true
+	for l := range d.listeners {
+		l.Close()
+	}
+	for conn := range d.conns {
+		if cr, ok := conn.(interface{ CloseRead() error }); ok {
+			cr.CloseRead()
+		} else {
+			conn.Close()
+		}
+	}
+	d.mu.Unlock()
+	d.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		d.mu.Lock()
+		for conn := range d.conns {
+			conn.Close()
+		}
+		d.mu.Unlock()
+		<-done
+		err = ctx.Err()
+	}
+	for _, s := range d.selections {
+		s.close()
+	}
+	return err
+}
+
+// History returns the texts held by the given selection, the current
+// one last, or nil if the selection is unknown.
+func (d *Daemon) History(name clipboard.Selection) []Entry {
+	s, ok := d.selections[name]
+	if !ok {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Entry{}, s.history...)
+}
+
+// state is what SaveState writes.
+type state struct {
+	Selections map[clipboard.Selection]savedSelection `json:"selections"`
+}
+
+// savedSelection is the state of a selection.
+type savedSelection struct {
+	Current string  `json:"current"`
+	History []Entry `json:"history"`
+}
+
+// SaveState writes the current text and the history of the
+// selections to w, so that a new daemon can be created with it.
+func (d *Daemon) SaveState(w io.Writer) error {
+	st := state{Selections: make(map[clipboard.Selection]savedSelection)}
+	for name, s := range d.selections {
+		s.mu.Lock()
+		st.Selections[name] = savedSelection{Current: s.current, History: s.history}
+		s.mu.Unlock()
+	}
+	return json.NewEncoder(w).Encode(st)
+}
+
+// restore restores the state read from r, copying the
+// current text of each selection again.
+func (d *Daemon) restore(r io.Reader) error {
+	var st state
+	if err := json.NewDecoder(r).Decode(&st); err != nil {
+		return err
+	}
+	for name, saved := range st.Selections {
+		s, ok := d.selections[name]
+		if !ok {
+			continue
+		}
+		if len(saved.History) > s.size {
+			saved.History = saved.History[len(saved.History)-s.size:]
+		}
+		s.history, s.current = saved.History, saved.Current
+		if s.current == "" {
+			continue
+		}
+		cb, _ := s.clipboard(d.opts.Clipboard, "")
+		if err := cb.CopyText(s.current); err != nil {
+			d.log(slog.LevelWarn, "restoring selection failed", "selection", name, "error", err)
+		}
+	}
+	return nil
+}
+
+// watch keeps the texts copied to s by other programs in the
+// history, if the clipboard can be watched, until the daemon is
+// shut down.
+func (d *Daemon) watch(s *selection) {
+	cb, _ := s.clipboard(d.opts.Clipboard, "")
+	w, ok := cb.(clipboard.Watcher)
+	if !ok {
+		return
+	}
+	texts, err := w.Watch(d.ctx)
+	if err != nil {
+		d.log(slog.LevelDebug, "selection not watched", "selection", s.name, "error", err)
+		return
+	}
+	go func() {
+		for text := range texts {
+			s.watched(text)
+		}
+	}()
+}
+
+// authorize checks that the peer of conn is allowed to connect. Peers
+// whose credentials can't be read, e.g. on Windows, are refused.
+func (d *Daemon) authorize(conn net.Conn) error {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return errors.New("peer credentials unavailable")
+	}
+	uid, err := ownership.PeerUID(uc)
+	if err != nil {
+		return fmt.Errorf("reading peer credentials: %w", err)
+	}
+	if uid == os.Getuid() {
+		return nil
+	}
+	for _, allowed := range d.opts.AllowUIDs {
+		if uid == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("user %d is not allowed", uid)
+}
+
+// track registers a connection being served,
+// unless the daemon is shut down.
+func (d *Daemon) track(conn net.Conn) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return false
+	}
+	d.conns[conn] = struct{}{}
+	d.wg.Add(1)
+	return true
+}
+
+// untrack closes a connection that was served.
+func (d *Daemon) untrack(conn net.Conn) {
+	conn.Close()
+	d.mu.Lock()
+	delete(d.conns, conn)
+	d.mu.Unlock()
+	d.wg.Done()
+}
+
+// Name implements the plugin.Handler interface.
+func (d *Daemon) Name() string {
+	return clipboard.DaemonName
+}
+
+// Capabilities implements the plugin.Handler interface. The selections
+// can always be watched, at least for the texts copied through the daemon.
+func (d *Daemon) Capabilities() plugin.Capabilities {
+	cb, _ := d.selections[clipboard.SelectionClipboard].clipboard(d.opts.Clipboard, "")
+	caps := cb.Capabilities()
+	selections := make([]string, len(caps.Selections))
+	for i, s := range caps.Selections {
+		selections[i] = string(s)
+	}
+	return plugin.Capabilities{
+		Selections: selections,
+		Types:      caps.Types,
+		Watch:      true,
+		Clear:      caps.Clear,
+		OneShot:    caps.OneShot,
+	}
+}
+
+// Copy implements the plugin.Handler interface. Content of other types
+// than text, e.g. a list of files, is copied as that type, when the
+// clipboard tools can, and leaves the selection without text. One-shot
+// copies are served for a single paste, and kept out of the history,
+// the state and the events sent to the watchers.
+func (d *Daemon) Copy(p plugin.Params) error {
+	s, err := d.selection(p.Selection)
+	if err != nil {
+		return err
+	}
+	if len(p.Items) > 0 {
+		return &plugin.Error{Code: plugin.CodeUnsupported, Message: "items can't be copied together"}
+	}
+	if p.OneShot {
+		return d.copyOneShot(s, p)
+	}
+	cb, err := s.clipboard(d.opts.Clipboard, "")
+	if err != nil {
+		return pluginError(err)
+	}
+	if p.Type != "" && p.Type != plugin.TextType && p.Type != "text/plain" {
+		if err := cb.(clipboard.DataCopier).CopyData(p.Type, []byte(p.Text)); err != nil {
+			return pluginError(err)
+		}
+		s.set("")
+		return nil
+	}
+	if err := cb.CopyText(p.Text); err != nil {
+		return pluginError(err)
+	}
+	s.set(p.Text)
+	return nil
+}
+
+// copyOneShot copies p for a single paste.
+func (d *Daemon) copyOneShot(s *selection, p plugin.Params) error {
+	cb, err := s.oneShotClipboard(d.opts.Clipboard)
+	if err != nil {
+		return pluginError(err)
+	}
+	if !cb.Capabilities().OneShot {
+		return &plugin.Error{Code: plugin.CodeUnsupported, Message: "one-shot copies are not supported"}
+	}
+	if p.Type != "" && p.Type != plugin.TextType && p.Type != "text/plain" {
+		err = cb.(clipboard.DataCopier).CopyData(p.Type, []byte(p.Text))
+	} else {
+		err = cb.CopyText(p.Text)
+	}
+	if err != nil {
+		return pluginError(err)
+	}
+	s.setOneShot(p.Text)
+	return nil
+}
+
+// Paste implements the plugin.Handler interface.
+// The type is requested as the target.
+func (d *Daemon) Paste(p plugin.Params) (string, error) {
+	s, err := d.selection(p.Selection)
+	if err != nil {
+		return "", err
+	}
+	cb, err := s.clipboard(d.opts.Clipboard, p.Type)
+	if err != nil {
+		return "", pluginError(err)
+	}
+	text, err := cb.PasteText()
+	return text, pluginError(err)
+}
+
+// Types implements the plugin.Handler interface.
+func (d *Daemon) Types(p plugin.Params) ([]string, error) {
+	s, err := d.selection(p.Selection)
+	if err != nil {
+		return nil, err
+	}
+	cb, err := s.clipboard(d.opts.Clipboard, "")
+	if err != nil {
+		return nil, pluginError(err)
+	}
+	types, err := cb.(clipboard.TypeLister).Types()
+	return types, pluginError(err)
+}
+
+// Clear implements the plugin.Handler interface.
+func (d *Daemon) Clear(p plugin.Params) error {
+	s, err := d.selection(p.Selection)
+	if err != nil {
+		return err
+	}
+	cb, err := s.clipboard(d.opts.Clipboard, "")
+	if err == nil {
+		err = cb.Clear()
+	}
+	if err != nil {
+		return pluginError(err)
+	}
+	s.set("")
+	return nil
+}
+
+// Watch implements the plugin.Handler interface. Events are sent for the
+// texts copied through the daemon and, if the clipboard can be watched,
+// by other programs. The channel is closed once ctx is done or the daemon
+// is shut down.
+func (d *Daemon) Watch(ctx context.Context, p plugin.Params) (<-chan plugin.Event, error) {
+	s, err := d.selection(p.Selection)
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan plugin.Event, 16)
+	s.mu.Lock()
+	s.watchers[events] = struct{}{}
+	s.mu.Unlock()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-d.ctx.Done():
+		}
+		s.mu.Lock()
+		delete(s.watchers, events)
+		close(events)
+		s.mu.Unlock()
+	}()
+	return events, nil
+}
+
+// Call implements the plugin.Caller interface, serving MethodHistory.
+func (d *Daemon) Call(method string, params json.RawMessage) (any, error) {
+	if method != MethodHistory {
+		return nil, &plugin.Error{Code: plugin.CodeUnsupported, Message: "unknown method " + method}
+	}
+	var p plugin.Params
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if _, err := d.selection(p.Selection); err != nil {
+		return nil, err
+	}
+	return HistoryResult{Entries: d.History(clipboard.Selection(p.Selection))}, nil
+}
+
+// selection returns the selection with the given name.
+func (d *Daemon) selection(name string) (*selection, error) {
+	s, ok := d.selections[clipboard.Selection(name)]
+	if !ok {
+		return nil, &plugin.Error{Code: plugin.CodeUnsupported, Message: "unknown selection " + name}
+	}
+	return s, nil
+}
+
+// pluginError converts an error of a clipboard to the
+// error sent to the clients, keeping its class.
+func pluginError(err error) error {
+	if err == nil {
+		return nil
+	}
+	code := plugin.CodeFailed
+	switch {
+	case errors.Is(err, errors.ErrUnsupported), errors.Is(err, clipboard.ErrUnsupported):
+		code = plugin.CodeUnsupported
+	case clipboard.ClassifyError(err) == clipboard.ClassUnavailable:
+		code = plugin.CodeUnavailable
+	}
+	return &plugin.Error{Code: code, Message: err.Error()}
+}
+
+// maxTargets is the number of targets, besides the default one,
+// whose clipboards a selection keeps for later pastes.
+const maxTargets = 16
+
+// selection holds a selection owned by the daemon.
+type selection struct {
+	name clipboard.Selection
+	size int
+
+	mu         sync.Mutex
+	clipboards map[string]clipboard.Clipboard // By target
+	targets    []string                       // Requested targets, the most recently used last
+	once       clipboard.Clipboard            // Copying for a single paste
+	history    []Entry
+	current    string
+	oneShot    string // Text copied for a single paste, not to record when watched
+	watchers   map[chan plugin.Event]struct{}
+}
+
+// clipboard returns the clipboard of the selection pasting the given
+// target, creating it on first use. Only the clipboards of the
+// maxTargets most recently requested targets are kept, so that clients
+// asking for many types don't make the daemon grow.
+func (s *selection) clipboard(opts clipboard.ClipboardOptions, target string) (clipboard.Clipboard, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if target != "" {
+		s.use(target)
+	}
+	if cb, ok := s.clipboards[target]; ok {
+		return cb, nil
+	}
+	opts.Primary = s.name == clipboard.SelectionPrimary
+	opts.Target = target
+	cb, err := clipboard.New(opts)
+	if err != nil {
+		return nil, err
+	}
+	s.clipboards[target] = cb
+	return cb, nil
+}
+
+// use records that target was requested, dropping the clipboard of
+// the least recently used target when there are more than maxTargets.
+func (s *selection) use(target string) {
+	for i, t := range s.targets {
+		if t == target {
+			s.targets = append(s.targets[:i], s.targets[i+1:]...)
+			break
+		}
+	}
+	s.targets = append(s.targets, target)
+	if len(s.targets) <= maxTargets {
+		return
+	}
+	oldest := s.targets[0]
+	s.targets = s.targets[1:]
+	if cb, ok := s.clipboards[oldest]; ok {
+		cb.(io.Closer).Close()
+		delete(s.clipboards, oldest)
+	}
+}
+
+// oneShotClipboard returns the clipboard of the selection
+// copying for a single paste, creating it on first use.
+func (s *selection) oneShotClipboard(opts clipboard.ClipboardOptions) (clipboard.Clipboard, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.once != nil {
+		return s.once, nil
+	}
+	opts.Primary = s.name == clipboard.SelectionPrimary
+	opts.OneShot = true
+	cb, err := clipboard.New(opts)
+	if err != nil {
+		return nil, err
+	}
+	s.once = cb
+	return cb, nil
+}
+
+// close stops the clipboard tools serving the selection.
+func (s *selection) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cb := range s.clipboards {
+		cb.(io.Closer).Close()
+	}
+	if s.once != nil {
+		s.once.(io.Closer).Close()
+	}
+}
+
+// set records that the selection now holds text, keeping it in the
+// history unless it is empty, and notifies the watchers if it changed.
+func (s *selection) set(text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.oneShot = ""
+	if text == s.current {
+		return
+	}
+	s.current = text
+	if text != "" {
+		s.history = append(s.history, Entry{Time: time.Now(), Text: text})
+		if len(s.history) > s.size {
+			s.history = s.history[len(s.history)-s.size:]
+		}
+	}
+	for events := range s.watchers {
+		select {
+		case events <- plugin.Event{Text: text}:
+		default:
+		}
+	}
+}
+
+// setOneShot records that the selection holds text served for a
+// single paste: the watchers are not told, and the text is neither
+// kept in the history nor saved with the state.
+func (s *selection) setOneShot(text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current, s.oneShot = "", text
+}
+
+// watched records that the selection now holds text, seen by
+// watching it, unless it is the text copied for a single paste.
+func (s *selection) watched(text string) {
+	s.mu.Lock()
+	oneShot := text != "" && text == s.oneShot
+	s.mu.Unlock()
+	if !oneShot {
+		s.set(text)
+	}
+}
+
+// log logs a record with the logger, if any.
+func (d *Daemon) log(level slog.Level, msg string, args ...any) {
+	if d.opts.Logger != nil {
+		d.opts.Logger.Log(context.Background(), level, msg, args...)
+	}
+}
@@ -0,0 +1,345 @@
+//go:build freebsd || linux || netbsd || openbsd || solaris || dragonfly
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard"
+	"github.com/tiagomelo/go-clipboard/clipboard/clipboardtest/fakebin"
+	"github.com/tiagomelo/go-clipboard/clipboard/plugin"
+)
+
+func TestMain(m *testing.M) {
+	fakebin.Main()
+	os.Exit(m.Run())
+}
+
+func TestDaemon(t *testing.T) {
+	fakeXsel(t)
+	d, path := startDaemon(t, Options{History: 2})
+	t.Setenv(clipboard.DaemonSocketEnv, path)
+
+	c, err := clipboard.New()
+	require.NoError(t, err)
+	require.True(t, c.Capabilities().Watch)
+	require.NoError(t, c.CopyText("one"))
+	text, err := c.PasteText()
+	require.NoError(t, err)
+	require.Equal(t, "one", text)
+
+	// The daemon owns the selection with xsel.
+	direct, err := clipboard.New(clipboard.ClipboardOptions{NoDaemon: true})
+	require.NoError(t, err)
+	text, err = direct.PasteText()
+	require.NoError(t, err)
+	require.Equal(t, "one", text)
+
+	for _, text := range []string{"two", "three", "three"} {
+		require.NoError(t, c.CopyText(text))
+	}
+	primary, err := clipboard.New(clipboard.ClipboardOptions{Primary: true})
+	require.NoError(t, err)
+	require.NoError(t, primary.CopyText("selected"))
+
+	require.Equal(t, []string{"two", "three"}, texts(d.History(clipboard.SelectionClipboard)))
+	entries, err := FetchHistory(path, clipboard.SelectionClipboard)
+	require.NoError(t, err)
+	require.Equal(t, []string{"two", "three"}, texts(entries))
+	entries, err = FetchHistory(path, clipboard.SelectionPrimary)
+	require.NoError(t, err)
+	require.Equal(t, []string{"selected"}, texts(entries))
+	_, err = FetchHistory(path, "secondary")
+	require.EqualError(t, err, "plugin gclipd: history: unsupported: unknown selection secondary")
+}
+
+func TestDaemon_Watch(t *testing.T) {
+	fakeXsel(t)
+	_, path := startDaemon(t, Options{})
+	t.Setenv(clipboard.DaemonSocketEnv, path)
+
+	c, err := clipboard.New()
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := c.(clipboard.Watcher).Watch(ctx)
+	require.NoError(t, err)
+
+	other, err := clipboard.New()
+	require.NoError(t, err)
+	require.NoError(t, other.CopyText("copied elsewhere"))
+	select {
+	case text := <-changes:
+		require.Equal(t, "copied elsewhere", text)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change received")
+	}
+	require.NoError(t, other.Clear())
+	select {
+	case text := <-changes:
+		require.Empty(t, text)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change received")
+	}
+}
+
+func TestDaemon_files(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, fakebin.Install(dir, "xclip"))
+	t.Setenv("PATH", dir)
+	t.Setenv(fakebin.StateDirEnv, t.TempDir())
+	_, path := startDaemon(t, Options{})
+	t.Setenv(clipboard.DaemonSocketEnv, path)
+
+	c, err := clipboard.New()
+	require.NoError(t, err)
+	require.NoError(t, c.CopyText("some text"))
+	paths := []string{filepath.Join(dir, "a b.txt")}
+	require.NoError(t, c.(clipboard.FileCopier).CopyFiles(paths, clipboard.OperationCut))
+	pasted, op, err := c.(clipboard.FileCopier).PasteFiles()
+	require.NoError(t, err)
+	require.Equal(t, paths, pasted)
+	require.Equal(t, clipboard.OperationCut, op)
+	entries, err := FetchHistory(path, clipboard.SelectionClipboard)
+	require.NoError(t, err)
+	require.Equal(t, []string{"some text"}, texts(entries))
+}
+
+func TestDaemon_sensitive(t *testing.T) {
+	sensitive := clipboard.ClipboardOptions{Policies: []clipboard.Policy{func(text string) clipboard.Decision {
+		return clipboard.Decision{Action: clipboard.Sensitive}
+	}}}
+	testCases := []struct {
+		desc string
+		tool string
+	}{
+		{
+			desc: "daemon copying for a single paste",
+			tool: "xclip",
+		},
+		{
+			desc: "daemon that can't copy for a single paste, bypassed",
+			tool: "xsel",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, fakebin.Install(dir, tc.tool))
+			t.Setenv("PATH", dir)
+			t.Setenv(fakebin.StateDirEnv, t.TempDir())
+			d, path := startDaemon(t, Options{})
+			t.Setenv(clipboard.DaemonSocketEnv, path)
+
+			c, err := clipboard.New()
+			require.NoError(t, err)
+			require.NoError(t, c.CopyText("one"))
+			s, err := clipboard.New(sensitive)
+			require.NoError(t, err)
+			require.NoError(t, s.CopyText("secret"))
+			text, err := c.PasteText()
+			require.NoError(t, err)
+			require.Equal(t, "secret", text)
+
+			require.Equal(t, []string{"one"}, texts(d.History(clipboard.SelectionClipboard)))
+			var state bytes.Buffer
+			require.NoError(t, d.SaveState(&state))
+			require.NotContains(t, state.String(), "secret")
+		})
+	}
+}
+
+func TestDaemon_Copy_oneShot(t *testing.T) {
+	fakeXsel(t)
+	d, err := New(Options{})
+	require.NoError(t, err)
+	defer d.Shutdown(context.Background())
+	err = d.Copy(plugin.Params{Selection: "clipboard", Text: "secret", OneShot: true})
+	require.EqualError(t, err, "unsupported: one-shot copies are not supported")
+	require.Empty(t, d.History(clipboard.SelectionClipboard))
+}
+
+func TestDaemon_Paste_targets(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, fakebin.Install(dir, "xclip"))
+	t.Setenv("PATH", dir)
+	t.Setenv(fakebin.StateDirEnv, t.TempDir())
+	d, err := New(Options{})
+	require.NoError(t, err)
+	defer d.Shutdown(context.Background())
+	require.NoError(t, d.Copy(plugin.Params{Selection: "clipboard", Text: "some text"}))
+
+	for i := 0; i < 2*maxTargets; i++ {
+		d.Paste(plugin.Params{Selection: "clipboard", Type: fmt.Sprintf("text/x-type-%d", i)})
+	}
+	text, err := d.Paste(plugin.Params{Selection: "clipboard", Type: "UTF8_STRING"})
+	require.NoError(t, err)
+	require.Equal(t, "some text", text)
+
+	s := d.selections[clipboard.SelectionClipboard]
+	require.Len(t, s.clipboards, maxTargets+1)
+	require.Contains(t, s.clipboards, "")
+	require.Contains(t, s.clipboards, "UTF8_STRING")
+	require.Contains(t, s.clipboards, fmt.Sprintf("text/x-type-%d", 2*maxTargets-1))
+	require.NotContains(t, s.clipboards, "text/x-type-0")
+}
+
+func TestDaemon_staleSocket(t *testing.T) {
+	fakeXsel(t)
+	path := socketPath(t)
+	l, err := net.Listen("unix", path)
+	require.NoError(t, err)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, l.Close())
+	t.Setenv(clipboard.DaemonSocketEnv, path)
+
+	// The clipboard falls back to the tools.
+	c, err := clipboard.New()
+	require.NoError(t, err)
+	require.NoError(t, c.CopyText("some text"))
+	text, err := c.PasteText()
+	require.NoError(t, err)
+	require.Equal(t, "some text", text)
+
+	// And a new daemon replaces the socket.
+	l, err = Listen(path)
+	require.NoError(t, err)
+	defer l.Close()
+	_, err = Listen(path)
+	require.ErrorIs(t, err, ErrRunning)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestDaemon_insecureSocket(t *testing.T) {
+	fakeXsel(t)
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0o755))
+	_, err := Listen(filepath.Join(dir, "gclipd.sock"))
+	require.ErrorIs(t, err, clipboard.ErrInsecure)
+
+	// A socket other users could have created is not used.
+	d, path := startDaemon(t, Options{})
+	require.NoError(t, os.Chmod(filepath.Dir(path), 0o755))
+	t.Setenv(clipboard.DaemonSocketEnv, path)
+	c, err := clipboard.New()
+	require.NoError(t, err)
+	require.NoError(t, c.CopyText("some text"))
+	require.Empty(t, d.History(clipboard.SelectionClipboard))
+
+	require.NoError(t, os.Chmod(filepath.Dir(path), 0o700))
+	c, err = clipboard.New()
+	require.NoError(t, err)
+	require.NoError(t, c.CopyText("some text"))
+	require.Equal(t, []string{"some text"}, texts(d.History(clipboard.SelectionClipboard)))
+}
+
+func TestDaemon_Shutdown(t *testing.T) {
+	fakeXsel(t)
+	d, path := startDaemon(t, Options{})
+	conn, err := net.Dial("unix", path)
+	require.NoError(t, err)
+	c, err := plugin.NewClient(conn, conn, plugin.Options{})
+	require.NoError(t, err)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, d.Shutdown(ctx))
+	_, err = c.Paste("clipboard", "")
+	require.Error(t, err)
+	_, err = net.Dial("unix", path)
+	require.Error(t, err)
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "other.sock"))
+	require.NoError(t, err)
+	require.ErrorIs(t, d.Serve(l), ErrClosed)
+	_, err = l.Accept()
+	require.ErrorIs(t, err, net.ErrClosed)
+}
+
+func TestDaemon_SaveState(t *testing.T) {
+	fakeXsel(t)
+	d, err := New(Options{})
+	require.NoError(t, err)
+	require.NoError(t, d.Copy(plugin.Params{Selection: "clipboard", Text: "one"}))
+	require.NoError(t, d.Copy(plugin.Params{Selection: "clipboard", Text: "two"}))
+	require.NoError(t, d.Copy(plugin.Params{Selection: "primary", Text: "selected"}))
+	require.NoError(t, d.Clear(plugin.Params{Selection: "primary"}))
+	var state bytes.Buffer
+	require.NoError(t, d.SaveState(&state))
+	require.NoError(t, d.Shutdown(context.Background()))
+
+	// The selections are lost, e.g. because the tools were stopped too.
+	t.Setenv(fakebin.StateDirEnv, t.TempDir())
+	d, err = New(Options{State: &state})
+	require.NoError(t, err)
+	defer d.Shutdown(context.Background())
+	require.Equal(t, []string{"one", "two"}, texts(d.History(clipboard.SelectionClipboard)))
+	require.Equal(t, []string{"selected"}, texts(d.History(clipboard.SelectionPrimary)))
+
+	direct, err := clipboard.New(clipboard.ClipboardOptions{NoDaemon: true})
+	require.NoError(t, err)
+	text, err := direct.PasteText()
+	require.NoError(t, err)
+	require.Equal(t, "two", text)
+	direct, err = clipboard.New(clipboard.ClipboardOptions{NoDaemon: true, Primary: true})
+	require.NoError(t, err)
+	text, _ = direct.PasteText()
+	require.Empty(t, text)
+}
+
+// fakeXsel puts a fake xsel in the PATH.
+func fakeXsel(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, fakebin.Install(dir, "xsel"))
+	t.Setenv("PATH", dir)
+	t.Setenv(fakebin.StateDirEnv, t.TempDir())
+	t.Setenv(clipboard.DaemonSocketEnv, filepath.Join(dir, "none.sock"))
+}
+
+// startDaemon serves a daemon created with opts on a new
+// socket, until the test ends, and returns the socket path.
+func startDaemon(t *testing.T, opts Options) (*Daemon, string) {
+	d, err := New(opts)
+	require.NoError(t, err)
+	path := socketPath(t)
+	l, err := Listen(path)
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() { served <- d.Serve(l) }()
+	t.Cleanup(func() {
+		require.NoError(t, d.Shutdown(context.Background()))
+		require.ErrorIs(t, <-served, ErrClosed)
+	})
+	return d, path
+}
+
+// socketPath returns the path of a socket in a new
+// directory that only the user can access.
+func socketPath(t *testing.T) string {
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0o700))
+	return filepath.Join(dir, "gclipd.sock")
+}
+
+// texts returns the texts of entries.
+func texts(entries []Entry) []string {
+	result := make([]string, len(entries))
+	for i, e := range entries {
+		result[i] = e.Text
+	}
+	return result
+}
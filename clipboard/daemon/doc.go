@@ -0,0 +1,17 @@
+// Package daemon implements gclipd, a long-running process owning the
+// selections, so that programs using the clipboard don't each run the
+// clipboard tools and leave their processes behind.
+//
+// The daemon keeps the history of each selection and the watchers of the
+// clipboard in one place, and serves them on a Unix socket, found with
+// clipboard.DaemonSocket, with the protocol of the plugin package. Only
+// the user running the daemon, and the users it allows, can connect, as
+// told by the credentials of the peer; where they can't be read, e.g. on
+// Windows, connections are refused. Clipboards created with clipboard.New
+// use the daemon automatically when its socket exists, is in a directory
+// private to the user, and the daemon is run by the user.
+//
+// Listen accepts a socket passed by systemd socket activation, and
+// Restart hands the socket and the selections over to a new process,
+// so that the daemon can be upgraded without losing them.
+package daemon
@@ -0,0 +1,150 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/internal/ownership"
+)
+
+// Environment variables through which a listener and a state
+// are inherited from systemd or from a daemon that restarted.
+const (
+	listenFDsEnv = "LISTEN_FDS"
+	listenPIDEnv = "LISTEN_PID"
+	listenFDEnv  = "GCLIPD_LISTEN_FD"
+	stateFDEnv   = "GCLIPD_STATE_FD"
+)
+
+// firstFD is the first file descriptor passed to a process
+// after its standard input, output and error.
+const firstFD = 3
+
+// ErrRunning is returned by Listen when a daemon
+// is already listening on the socket.
+var ErrRunning = errors.New("daemon already running")
+
+// Listen returns the listener of the daemon: the one handed over by
+// Restart, or the first socket passed by systemd socket activation, or a
+// new Unix socket at path, e.g. clipboard.DaemonSocket(). The directory
+// of a new socket is created if needed, and must only be accessible by
+// the user, as the socket is: otherwise it fails with an error wrapping
+// clipboard.ErrInsecure, since another user could have created it. A
+// socket left behind by a daemon that stopped is replaced.
+func Listen(path string) (net.Listener, error) {
+	if fd := os.Getenv(listenFDEnv); fd != "" {
+		os.Unsetenv(listenFDEnv)
+		return fileListener(fd)
+	}
+	if os.Getenv(listenPIDEnv) == strconv.Itoa(os.Getpid()) {
+		n, _ := strconv.Atoi(os.Getenv(listenFDsEnv))
+		os.Unsetenv(listenPIDEnv)
+		os.Unsetenv(listenFDsEnv)
+		os.Unsetenv("LISTEN_FDNAMES")
+		if n > 0 {
+			return fileListener(strconv.Itoa(firstFD))
+		}
+	}
+	if err := ownership.MkdirPrivate(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("%s: %w", path, ErrRunning)
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// fileListener returns the listener of the inherited file descriptor fd.
+func fileListener(fd string) (net.Listener, error) {
+	n, err := strconv.Atoi(fd)
+	if err != nil {
+		return nil, fmt.Errorf("inherited listener: %w", err)
+	}
+	f := os.NewFile(uintptr(n), "listener")
+	defer f.Close()
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("inherited listener: %w", err)
+	}
+	return l, nil
+}
+
+// InheritedState returns the state handed over by Restart, to be
+// set in Options.State, or nil if the daemon didn't restart.
+func InheritedState() io.ReadCloser {
+	fd := os.Getenv(stateFDEnv)
+	if fd == "" {
+		return nil
+	}
+	os.Unsetenv(stateFDEnv)
+	n, err := strconv.Atoi(fd)
+	if err != nil {
+		return nil
+	}
+	return os.NewFile(uintptr(n), "state")
+}
+
+// Restart runs the executable of the process again, with the same
+// arguments, handing it l and the state of the daemon, so that no
+// connection is refused and no selection is lost. The new process gets
+// them with Listen and InheritedState. The daemon should then be shut
+// down; the socket is left for the new process.
+func (d *Daemon) Restart(l net.Listener) error {
+	ul, ok := l.(*net.UnixListener)
+	if !ok {
+		return errors.New("only Unix listeners can be handed over")
+	}
+	f, err := ul.File()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.ExtraFiles = []*os.File{f, r}
+	cmd.Env = append(os.Environ(), listenFDEnv+"="+strconv.Itoa(firstFD), stateFDEnv+"="+strconv.Itoa(firstFD+1))
+	err = cmd.Start()
+	r.Close()
+	if err != nil {
+		w.Close()
+		return err
+	}
+	ul.SetUnlinkOnClose(false)
+	err = d.SaveState(w)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	cmd.Process.Release()
+	return err
+}
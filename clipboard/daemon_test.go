@@ -0,0 +1,117 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"net"
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+	"github.com/tiagomelo/go-clipboard/clipboard/plugin"
+)
+
+func TestClipboard_daemon(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		opts           ClipboardOptions
+		socketMode     os.FileMode
+		expectedDaemon bool
+	}{
+		{
+			desc:           "socket exists",
+			expectedDaemon: true,
+		},
+		{
+			desc:       "socket other users can connect to",
+			socketMode: 0o666,
+		},
+		{
+			desc:           "named in tools",
+			opts:           ClipboardOptions{Tools: []string{"xsel", DaemonName}},
+			expectedDaemon: true,
+		},
+		{
+			desc: "not named in tools",
+			opts: ClipboardOptions{Tools: []string{"xsel"}},
+		},
+		{
+			desc: "disabled",
+			opts: ClipboardOptions{NoDaemon: true},
+		},
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("the daemon is only used where its credentials can be checked")
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeTools(t)
+			serveDaemon(t, &plugin.FileHandler{Dir: t.TempDir()})
+			if tc.socketMode != 0 {
+				require.NoError(t, os.Chmod(DaemonSocket(), tc.socketMode))
+			}
+			ran := false
+			tc.opts.Runner = mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+				ran = true
+				return &mockCommand{Output: "from a tool"}
+			})
+			c := newTestClipboard(t, tc.opts)
+			require.NoError(t, c.CopyText("some text"))
+			text, err := c.PasteText()
+			require.NoError(t, err)
+			require.Equal(t, !tc.expectedDaemon, ran)
+			if tc.expectedDaemon {
+				require.Equal(t, "some text", text)
+			} else {
+				require.Equal(t, "from a tool", text)
+			}
+		})
+	}
+}
+
+func TestClipboard_daemonUnavailable(t *testing.T) {
+	fakeTools(t)
+	l, err := net.Listen("unix", DaemonSocket())
+	require.NoError(t, err)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, l.Close())
+
+	c := newTestClipboard(t, ClipboardOptions{
+		Tools: []string{DaemonName, "xsel"},
+		Runner: mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+			return &mockCommand{Output: "from xsel"}
+		}),
+	})
+	text, err := c.PasteText()
+	require.NoError(t, err)
+	require.Equal(t, "from xsel", text)
+
+	err = NewDaemonBackend(DaemonSocket(), ClipboardOptions{}).CopyText("some text")
+	require.ErrorIs(t, err, ErrDaemonUnavailable)
+	require.Equal(t, ClassUnavailable, ClassifyError(err))
+}
+
+// serveDaemon serves h as the daemon, on the socket
+// set by fakeTools, until the test ends.
+func serveDaemon(t *testing.T, h plugin.Handler) {
+	l, err := net.Listen("unix", DaemonSocket())
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	require.NoError(t, os.Chmod(DaemonSocket(), 0o600))
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				plugin.Serve(conn, conn, h)
+			}()
+		}
+	}()
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"strings"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/clipboardtool"
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+	"github.com/tiagomelo/go-clipboard/clipboard/plugin"
+)
+
+// ErrorClass classifies the errors returned by clipboard tools.
+type ErrorClass int
+
+const (
+	// ClassFailed is a tool failure that another tool
+	// would not fix, such as an empty clipboard.
+	ClassFailed ErrorClass = iota
+	// ClassNotFound means the tool could not be executed at all.
+	ClassNotFound
+	// ClassPermission means the tool was not allowed to run,
+	// e.g. because command.HardenedRunner doesn't trust it, or
+	// the operation was denied by a policy or ReadOnly.
+	ClassPermission
+	// ClassUnavailable means the tool ran, but could not reach the
+	// display server or clipboard service, e.g. there's no X display.
+	ClassUnavailable
+	// ClassTimeout means the tool did not finish in time,
+	// or the clipboard stayed locked by another process.
+	ClassTimeout
+)
+
+// String returns the name of the class.
+func (c ErrorClass) String() string {
+	switch c {
+	case ClassNotFound:
+		return "not found"
+	case ClassPermission:
+		return "permission denied"
+	case ClassUnavailable:
+		return "unavailable"
+	case ClassTimeout:
+		return "timeout"
+	}
+	return "failed"
+}
+
+// Retryable reports whether another tool should be
+// tried after an error of this class.
+func (c ErrorClass) Retryable() bool {
+	return c != ClassFailed
+}
+
+// ClassifyError returns the class of an error returned by a clipboard tool.
+func ClassifyError(err error) ErrorClass {
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return ClassFailed
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return ClassNotFound
+	case errors.Is(err, fs.ErrPermission), errors.Is(err, command.ErrUntrusted),
+		errors.Is(err, ErrDenied), errors.Is(err, ErrReadOnly), errors.Is(err, ErrWriteOnly),
+		errors.Is(err, ErrInsecure):
+		return ClassPermission
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, ErrLocked):
+		return ClassTimeout
+	case errors.As(err, &exitErr) && clipboardtool.IsUnavailable(exitErr.Stderr),
+		errors.Is(err, plugin.ErrUnavailable), errors.Is(err, ErrDaemonUnavailable):
+		return ClassUnavailable
+	}
+	return ClassFailed
+}
+
+// Attempt records a clipboard tool that failed.
+type Attempt struct {
+	Tool  string     // Name of the tool
+	Class ErrorClass // Class of the error
+	Err   error      // Error returned by the tool
+}
+
+// FallbackError is returned when more than one clipboard tool was
+// tried and none of them succeeded. It holds every attempt, in order.
+type FallbackError struct {
+	Attempts []Attempt
+}
+
+// Error implements the error interface.
+func (e *FallbackError) Error() string {
+	msgs := make([]string, len(e.Attempts))
+	for i, a := range e.Attempts {
+		msgs[i] = fmt.Sprintf("%s: %v (%s)", a.Tool, a.Err, a.Class)
+	}
+	return "all clipboard tools failed: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the errors of every attempt,
+// so that they can be inspected with errors.Is and errors.As.
+func (e *FallbackError) Unwrap() []error {
+	errs := make([]error, len(e.Attempts))
+	for i, a := range e.Attempts {
+		errs[i] = a.Err
+	}
+	return errs
+}
+
+// fallbackError returns the error for the given failed attempts: the
+// only error when a single tool was tried, or a *FallbackError otherwise.
+func fallbackError(attempts []Attempt) error {
+	if len(attempts) == 1 {
+		return attempts[0].Err
+	}
+	return &FallbackError{Attempts: attempts}
+}
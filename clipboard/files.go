@@ -0,0 +1,249 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// URIListTarget is the target of lists of files, as
+	// file URIs (RFC 2483), understood by most programs.
+	URIListTarget = "text/uri-list"
+
+	// GnomeCopiedFilesTarget is the target of lists of files used by the
+	// GNOME file manager and others: "copy" or "cut", then file URIs, one
+	// per line. It is the only one that tells that files were cut.
+	GnomeCopiedFilesTarget = "x-special/gnome-copied-files"
+
+	// kdeCutTarget holds "1" when the files of a
+	// text/uri-list were cut in the KDE file manager.
+	kdeCutTarget = "application/x-kde-cutselection"
+)
+
+// ErrNoFiles is returned by PasteFiles when the clipboard holds no files.
+var ErrNoFiles = errors.New("no files copied")
+
+// Operation is what a file manager does with the files pasted.
+type Operation int
+
+const (
+	// OperationCopy copies the files.
+	OperationCopy Operation = iota
+	// OperationCut moves the files.
+	OperationCut
+)
+
+// String returns "copy" or "cut".
+func (o Operation) String() string {
+	if o == OperationCut {
+		return "cut"
+	}
+	return "copy"
+}
+
+// FileCopier is implemented by clipboards that can hold files, so that
+// they can be pasted in a file manager, and read the files copied in one.
+type FileCopier interface {
+	// CopyFiles copies the files at the given paths, to be copied or
+	// moved where they are pasted, according to op.
+	CopyFiles(paths []string, op Operation) error
+
+	// PasteFiles returns the absolute paths of the files
+	// held by the clipboard, and whether they were cut.
+	PasteFiles() ([]string, Operation, error)
+}
+
+// CopyFiles implements the FileCopier interface. Backends implementing
+// ItemCopier offer the files as text/uri-list, as GNOME and KDE copied
+// files, and as text; the clipboard tools can only offer one target at
+// a time, so they offer text/uri-list, or GNOME copied files if the files
+// are cut. Relative paths are made absolute.
+func (c *clipboard) CopyFiles(paths []string, op Operation) error {
+	if len(paths) == 0 {
+		return errors.New("no files to copy")
+	}
+	abs := make([]string, len(paths))
+	uris := make([]string, len(paths))
+	for i, path := range paths {
+		var err error
+		if abs[i], err = filepath.Abs(path); err != nil {
+			return err
+		}
+		uris[i] = fileURI(abs[i])
+	}
+	items := []Item{
+		{Target: GnomeCopiedFilesTarget, Data: []byte(op.String() + "\n" + strings.Join(uris, "\n"))},
+		{Target: URIListTarget, Data: []byte(strings.Join(uris, "\r\n") + "\r\n")},
+		{Target: "text/plain;charset=utf-8", Data: []byte(strings.Join(abs, "\n"))},
+	}
+	if op == OperationCut {
+		items = append(items, Item{Target: kdeCutTarget, Data: []byte("1")})
+	}
+	unlock, err := c.lock("copy")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	items, sensitive, err := c.checkItems(items)
+	if err != nil {
+		c.record("copy", nil, "", err)
+		return err
+	}
+	return c.withFallback("copy", sensitive, func(b Backend) (string, error) {
+		err := Wrapper{b}.CopyItems(items)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return string(items[1].Data), err
+		}
+		item := items[1]
+		if op == OperationCut {
+			item = items[0]
+		}
+		return string(item.Data), Wrapper{b}.CopyData(item.Target, item.Data)
+	})
+}
+
+// PasteFiles implements the FileCopier interface. The GNOME copied
+// files are preferred, as they tell whether the files were cut, then
+// text/uri-list. It fails with ErrNoFiles if the clipboard holds
+// neither, or an error wrapping errors.ErrUnsupported if the targets
+// of the clipboard can't be listed.
+func (c *clipboard) PasteFiles() ([]string, Operation, error) {
+	types, err := c.Types()
+	if err != nil {
+		return nil, OperationCopy, err
+	}
+	has := make(map[string]bool)
+	for _, typ := range types {
+		has[typ] = true
+	}
+	var uris []string
+	op := OperationCopy
+	switch {
+	case has[GnomeCopiedFilesTarget]:
+		data, err := c.pasteData(GnomeCopiedFilesTarget)
+		if err != nil {
+			return nil, op, err
+		}
+		if uris, op, err = parseGnomeCopiedFiles(data); err != nil {
+			return nil, op, err
+		}
+	case has[URIListTarget]:
+		data, err := c.pasteData(URIListTarget)
+		if err != nil {
+			return nil, op, err
+		}
+		uris = parseURIList(data)
+		if has[kdeCutTarget] {
+			if cut, err := c.pasteData(kdeCutTarget); err == nil && strings.TrimSpace(string(cut)) == "1" {
+				op = OperationCut
+			}
+		}
+	default:
+		return nil, op, ErrNoFiles
+	}
+	if len(uris) == 0 {
+		return nil, op, ErrNoFiles
+	}
+	paths := make([]string, len(uris))
+	for i, uri := range uris {
+		if paths[i], err = filePath(uri); err != nil {
+			return nil, op, err
+		}
+	}
+	return paths, op, nil
+}
+
+// parseGnomeCopiedFiles returns the URIs and the operation
+// of a list of files in the GNOME format.
+func parseGnomeCopiedFiles(data []byte) ([]string, Operation, error) {
+	lines := lines(data)
+	if len(lines) == 0 {
+		return nil, OperationCopy, ErrNoFiles
+	}
+	switch lines[0] {
+	case "copy":
+		return lines[1:], OperationCopy, nil
+	case "cut":
+		return lines[1:], OperationCut, nil
+	}
+	return nil, OperationCopy, fmt.Errorf("%s: unknown operation %q", GnomeCopiedFilesTarget, lines[0])
+}
+
+// parseURIList returns the URIs of a text/uri-list, without its comments.
+func parseURIList(data []byte) []string {
+	var uris []string
+	for _, line := range lines(data) {
+		if !strings.HasPrefix(line, "#") {
+			uris = append(uris, line)
+		}
+	}
+	return uris
+}
+
+// lines returns the lines of data that are not
+// blank, ending with a newline or CRLF or not.
+func lines(data []byte) []string {
+	var lines []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// fileURI returns the file URI of an absolute path. Like GLib, every
+// byte of the path is percent-encoded but those allowed in a URI path,
+// so that spaces, "#", "?", "%" and non-ASCII characters are.
+func fileURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// A Windows path, such as C:/Users.
+		path = "/" + path
+	}
+	var b strings.Builder
+	b.WriteString("file://")
+	for i := 0; i < len(path); i++ {
+		if c := path[i]; allowedInPath(c) {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// allowedInPath reports whether c can appear as is in
+// a URI path: unreserved characters, sub-delimiters, ":",
+// "@" and "/" (RFC 3986).
+func allowedInPath(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	}
+	return strings.IndexByte("-._~!$&'()*+,;=:@/", c) >= 0
+}
+
+// filePath returns the path of a file URI, decoding it.
+func filePath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" || u.Opaque != "" {
+		return "", fmt.Errorf("%q is not a file URI", uri)
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return "", fmt.Errorf("%s is not a local file", uri)
+	}
+	path := u.Path
+	if len(path) > 1 && filepath.VolumeName(path[1:]) != "" {
+		// A Windows path, such as /C:/Users.
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), nil
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_fileURI(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("paths are Unix paths")
+	}
+	testCases := []struct {
+		desc           string
+		path           string
+		expectedOutput string
+	}{
+		{
+			desc:           "plain path",
+			path:           "/home/user/notes.txt",
+			expectedOutput: "file:///home/user/notes.txt",
+		},
+		{
+			desc:           "space, hash, question mark and percent",
+			path:           "/tmp/a b#1?%.txt",
+			expectedOutput: "file:///tmp/a%20b%231%3F%25.txt",
+		},
+		{
+			desc:           "non-ASCII characters",
+			path:           "/tmp/héllo wörld",
+			expectedOutput: "file:///tmp/h%C3%A9llo%20w%C3%B6rld",
+		},
+		{
+			desc:           "characters allowed in a path",
+			path:           "/tmp/it's (1)+[2]:@~",
+			expectedOutput: "file:///tmp/it's%20(1)+%5B2%5D:@~",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			uri := fileURI(tc.path)
+			require.Equal(t, tc.expectedOutput, uri)
+			path, err := filePath(uri)
+			require.NoError(t, err)
+			require.Equal(t, tc.path, path)
+		})
+	}
+}
+
+func Test_filePath(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		uri            string
+		expectedOutput string
+		expectedError  error
+	}{
+		{
+			desc:           "localhost",
+			uri:            "file://localhost/tmp/a%20b",
+			expectedOutput: filepath.FromSlash("/tmp/a b"),
+		},
+		{
+			desc:           "without authority",
+			uri:            "file:/tmp/a",
+			expectedOutput: filepath.FromSlash("/tmp/a"),
+		},
+		{
+			desc:           "lower case escapes",
+			uri:            "file:///tmp/h%c3%a9llo",
+			expectedOutput: filepath.FromSlash("/tmp/héllo"),
+		},
+		{
+			desc:          "another host",
+			uri:           "file://server/tmp/a",
+			expectedError: errors.New("file://server/tmp/a is not a local file"),
+		},
+		{
+			desc:          "another scheme",
+			uri:           "https://example.com/a",
+			expectedError: errors.New(`"https://example.com/a" is not a file URI`),
+		},
+		{
+			desc:          "invalid escape",
+			uri:           "file:///tmp/100%",
+			expectedError: errors.New(`"file:///tmp/100%" is not a file URI`),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			output, err := filePath(tc.uri)
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+				require.Equal(t, tc.expectedOutput, output)
+			}
+		})
+	}
+}
+
+func Test_parseGnomeCopiedFiles(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		data          string
+		expectedURIs  []string
+		expectedOp    Operation
+		expectedError error
+	}{
+		{
+			desc:         "copy",
+			data:         "copy\nfile:///tmp/a\nfile:///tmp/b",
+			expectedURIs: []string{"file:///tmp/a", "file:///tmp/b"},
+			expectedOp:   OperationCopy,
+		},
+		{
+			desc:         "cut, with a trailing newline",
+			data:         "cut\r\nfile:///tmp/a\r\n",
+			expectedURIs: []string{"file:///tmp/a"},
+			expectedOp:   OperationCut,
+		},
+		{
+			desc:          "empty",
+			expectedError: ErrNoFiles,
+		},
+		{
+			desc:          "unknown operation",
+			data:          "link\nfile:///tmp/a",
+			expectedError: errors.New(`x-special/gnome-copied-files: unknown operation "link"`),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			uris, op, err := parseGnomeCopiedFiles([]byte(tc.data))
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+				require.Equal(t, tc.expectedURIs, uris)
+				require.Equal(t, tc.expectedOp, op)
+			}
+		})
+	}
+}
+
+func Test_parseURIList(t *testing.T) {
+	uris := parseURIList([]byte("# dropped by a browser\r\nfile:///tmp/a\r\n\r\nfile:///tmp/b\r\n"))
+	require.Equal(t, []string{"file:///tmp/a", "file:///tmp/b"}, uris)
+}
@@ -0,0 +1,85 @@
+//go:build freebsd || linux || netbsd || openbsd || solaris || dragonfly
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard_test
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard"
+)
+
+func TestClipboard_CopyFiles(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		tools         []string
+		op            clipboard.Operation
+		expectedTypes []string
+		expectedError error
+	}{
+		{
+			desc:          "xclip copy",
+			tools:         []string{"xclip"},
+			op:            clipboard.OperationCopy,
+			expectedTypes: []string{"TARGETS", clipboard.URIListTarget},
+		},
+		{
+			desc:          "xclip cut",
+			tools:         []string{"xclip"},
+			op:            clipboard.OperationCut,
+			expectedTypes: []string{"TARGETS", clipboard.GnomeCopiedFilesTarget},
+		},
+		{
+			desc:          "wayland copy",
+			tools:         []string{"wl-copy", "wl-paste"},
+			op:            clipboard.OperationCopy,
+			expectedTypes: []string{clipboard.URIListTarget},
+		},
+		{
+			desc:          "wayland cut",
+			tools:         []string{"wl-copy", "wl-paste"},
+			op:            clipboard.OperationCut,
+			expectedTypes: []string{clipboard.GnomeCopiedFilesTarget},
+		},
+		{
+			desc:          "xsel",
+			tools:         []string{"xsel"},
+			op:            clipboard.OperationCopy,
+			expectedError: errors.ErrUnsupported,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			c, _ := fakeSelections(t, tc.tools...)
+			dir := t.TempDir()
+			paths := []string{filepath.Join(dir, "notes.txt"), filepath.Join(dir, "a b#1", "héllo.png")}
+
+			err := c.(clipboard.FileCopier).CopyFiles(paths, tc.op)
+			if tc.expectedError != nil {
+				require.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			types, err := c.(clipboard.TypeLister).Types()
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedTypes, types)
+			pasted, op, err := c.(clipboard.FileCopier).PasteFiles()
+			require.NoError(t, err)
+			require.Equal(t, paths, pasted)
+			require.Equal(t, tc.op, op)
+		})
+	}
+}
+
+func TestClipboard_PasteFiles_noFiles(t *testing.T) {
+	c, _ := fakeSelections(t, "xclip")
+	require.NoError(t, c.CopyText("some text"))
+	_, _, err := c.(clipboard.FileCopier).PasteFiles()
+	require.ErrorIs(t, err, clipboard.ErrNoFiles)
+}
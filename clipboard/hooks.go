@@ -0,0 +1,175 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Names of the metrics recorded through Hooks.Metrics.
+const (
+	// MetricOperations counts the attempts to run an operation,
+	// such as copy or paste, with a backend, labeled by result.
+	MetricOperations = "clipboard_operations_total"
+	// MetricDuration is a histogram of how long each attempt took.
+	MetricDuration = "clipboard_operation_duration_seconds"
+	// MetricBytes is a histogram of how many bytes were copied or pasted.
+	MetricBytes = "clipboard_operation_bytes"
+)
+
+// Hooks observes the clipboard operations. Each attempt with a backend
+// is logged, counted and traced by whichever of Logger, Metrics and
+// Tracer are set. The text is never recorded unless LogContent is set.
+// The zero Hooks does nothing and costs nothing.
+type Hooks struct {
+	// Logger receives a record for each attempt: at debug level
+	// when it succeeds, at warn level when it fails.
+	Logger *slog.Logger
+
+	// LogContent adds the copied or pasted text to the log records,
+	// except text that a Policy marked as Sensitive.
+	LogContent bool
+
+	// Metrics records MetricOperations, MetricDuration and MetricBytes.
+	Metrics Metrics
+
+	// Tracer starts a span for each attempt.
+	Tracer Tracer
+}
+
+// Label is a name and value pair attached to a metric.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Metrics records counters and histograms. metrics.Prometheus implements
+// it; other metric libraries can be adapted in a few lines.
+type Metrics interface {
+	// Add adds value to the named counter.
+	Add(name string, value float64, labels ...Label)
+
+	// Observe adds value to the named histogram.
+	Observe(name string, value float64, labels ...Label)
+}
+
+// Tracer starts spans, e.g. on top of an OpenTelemetry tracer.
+type Tracer interface {
+	// Start starts a span with the given name and attributes.
+	Start(ctx context.Context, name string, attrs ...slog.Attr) Span
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// End ends the span, recording its error, if any,
+	// and the attributes known once it finished.
+	End(err error, attrs ...slog.Attr)
+}
+
+// enabled reports whether any hook is set.
+func (h *Hooks) enabled() bool {
+	return h.Logger != nil || h.Metrics != nil || h.Tracer != nil
+}
+
+// observation is an attempt being observed.
+type observation struct {
+	hooks     *Hooks
+	op        string
+	sensitive bool
+	attrs     []slog.Attr
+	start     time.Time
+	span      Span
+}
+
+// observe starts observing an attempt to run op with backend b, on
+// sensitive text or not. It returns nil, which is safe to end, if no
+// hook is set.
+func (c *clipboard) observe(op string, b Backend, sensitive bool) *observation {
+	h := &c.opts.Hooks
+	if !h.enabled() {
+		return nil
+	}
+	o := &observation{
+		hooks:     h,
+		op:        op,
+		sensitive: sensitive,
+		attrs: []slog.Attr{
+			slog.String("backend", b.Name()),
+			slog.String("selection", c.selection()),
+			slog.String("mime", c.mime()),
+		},
+		start: time.Now(),
+	}
+	if sensitive {
+		o.attrs = append(o.attrs, slog.Bool("sensitive", true))
+	}
+	if h.Tracer != nil {
+		o.span = h.Tracer.Start(context.Background(), "clipboard."+op, o.attrs...)
+	}
+	return o
+}
+
+// end records the outcome of the attempt, which ran tool
+// and copied or pasted text.
+func (o *observation) end(tool, text string, err error) {
+	if o == nil {
+		return
+	}
+	duration := time.Since(o.start)
+	result := "ok"
+	if err != nil {
+		result = ClassifyError(err).String()
+	}
+	attrs := []slog.Attr{
+		slog.String("tool", tool),
+		slog.Int("bytes", len(text)),
+		slog.Duration("duration", duration),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("class", result))
+	}
+	if o.span != nil {
+		o.span.End(err, attrs...)
+	}
+	if m := o.hooks.Metrics; m != nil {
+		backend := o.attrs[0].Value.String()
+		labels := []Label{{"op", o.op}, {"backend", backend}}
+		m.Add(MetricOperations, 1, append(labels, Label{"tool", tool}, Label{"result", result})...)
+		m.Observe(MetricDuration, duration.Seconds(), labels...)
+		if err == nil && (o.op == "copy" || o.op == "paste") {
+			m.Observe(MetricBytes, float64(len(text)), labels...)
+		}
+	}
+	if l := o.hooks.Logger; l != nil {
+		all := append(o.attrs[:len(o.attrs):len(o.attrs)], attrs...)
+		if o.hooks.LogContent && !o.sensitive {
+			all = append(all, slog.String("content", text))
+		}
+		level := slog.LevelDebug
+		if err != nil {
+			level = slog.LevelWarn
+			all = append(all, slog.Any("error", err))
+		}
+		l.LogAttrs(context.Background(), level, "clipboard "+o.op, all...)
+	}
+}
+
+// selection returns the name of the selection the clipboard uses.
+func (c *clipboard) selection() string {
+	if c.opts.Primary {
+		return "primary"
+	}
+	return "clipboard"
+}
+
+// mime returns the MIME type or target the clipboard requests.
+func (c *clipboard) mime() string {
+	if c.opts.Target != "" {
+		return c.opts.Target
+	}
+	return "text/plain;charset=utf-8"
+}
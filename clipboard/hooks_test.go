@@ -0,0 +1,197 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+)
+
+func TestHooks(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		logContent      bool
+		op              func(c *clipboard) error
+		errOutput       error
+		expectedLog     map[string]any
+		expectedSpan    string
+		expectedMetrics []string
+	}{
+		{
+			desc: "copy",
+			op: func(c *clipboard) error {
+				return c.copyText("secret")
+			},
+			expectedLog: map[string]any{
+				"level": "DEBUG", "msg": "clipboard copy", "backend": "mycopy", "tool": "mycopy",
+				"selection": "clipboard", "mime": "text/plain;charset=utf-8", "bytes": float64(6),
+			},
+			expectedSpan: "clipboard.copy backend=mycopy selection=clipboard mime=text/plain;charset=utf-8 tool=mycopy bytes=6 <nil>",
+			expectedMetrics: []string{
+				`clipboard_operations_total{backend="mycopy",op="copy",result="ok",tool="mycopy"} 1`,
+				`clipboard_operation_bytes_sum{backend="mycopy",op="copy"} 6`,
+				`clipboard_operation_duration_seconds_count{backend="mycopy",op="copy"} 1`,
+			},
+		},
+		{
+			desc:       "paste with content",
+			logContent: true,
+			op: func(c *clipboard) error {
+				_, err := c.pasteText()
+				return err
+			},
+			expectedLog: map[string]any{
+				"level": "DEBUG", "msg": "clipboard paste", "backend": "mycopy", "tool": "mypaste",
+				"selection": "clipboard", "mime": "text/plain;charset=utf-8", "bytes": float64(6), "content": "pasted",
+			},
+			expectedSpan: "clipboard.paste backend=mycopy selection=clipboard mime=text/plain;charset=utf-8 tool=mypaste bytes=6 <nil>",
+			expectedMetrics: []string{
+				`clipboard_operations_total{backend="mycopy",op="paste",result="ok",tool="mypaste"} 1`,
+				`clipboard_operation_bytes_sum{backend="mycopy",op="paste"} 6`,
+			},
+		},
+		{
+			desc: "failed clear",
+			op: func(c *clipboard) error {
+				return c.clear()
+			},
+			errOutput: command.ErrUntrusted,
+			expectedLog: map[string]any{
+				"level": "WARN", "msg": "clipboard clear", "backend": "mycopy", "tool": "mycopy",
+				"selection": "clipboard", "mime": "text/plain;charset=utf-8", "bytes": float64(0),
+				"class": "permission denied", "error": "untrusted executable",
+			},
+			expectedSpan: "clipboard.clear backend=mycopy selection=clipboard mime=text/plain;charset=utf-8 tool=mycopy bytes=0 class=permission denied untrusted executable",
+			expectedMetrics: []string{
+				`clipboard_operations_total{backend="mycopy",op="clear",result="permission denied",tool="mycopy"} 1`,
+				`clipboard_operation_duration_seconds_count{backend="mycopy",op="clear"} 1`,
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeTools(t)
+			var logs bytes.Buffer
+			metrics := &mockMetrics{}
+			tracer := &mockTracer{}
+			c := newTestClipboard(t, ClipboardOptions{
+				CopyCmd:  "mycopy",
+				PasteCmd: "mypaste",
+				Runner: mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+					return &mockCommand{Output: "pasted", ErrTextInput: tc.errOutput}
+				}),
+				Hooks: Hooks{
+					Logger:     slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+					LogContent: tc.logContent,
+					Metrics:    metrics,
+					Tracer:     tracer,
+				},
+			})
+			err := tc.op(c)
+			require.True(t, errors.Is(err, tc.errOutput))
+
+			var record map[string]any
+			line, _, _ := bytes.Cut(logs.Bytes(), []byte("\n"))
+			require.NoError(t, json.Unmarshal(line, &record))
+			require.Contains(t, record, "duration")
+			delete(record, "duration")
+			delete(record, "time")
+			require.Equal(t, tc.expectedLog, record)
+			require.NotContains(t, logs.String(), "secret")
+
+			require.NotEmpty(t, tracer.spans)
+			require.Equal(t, tc.expectedSpan, tracer.spans[0])
+
+			for _, line := range tc.expectedMetrics {
+				require.Contains(t, metrics.lines(), line)
+			}
+		})
+	}
+}
+
+func TestHooks_disabled(t *testing.T) {
+	c := &clipboard{}
+	require.Nil(t, c.observe("copy", &toolBackend{}, false))
+	c.observe("copy", &toolBackend{}, false).end("xclip", "text", nil)
+}
+
+// mockTracer records the spans it starts as strings.
+type mockTracer struct {
+	spans []string
+}
+
+// Start implements the Tracer interface.
+func (m *mockTracer) Start(ctx context.Context, name string, attrs ...slog.Attr) Span {
+	m.spans = append(m.spans, name)
+	return &mockSpan{tracer: m, index: len(m.spans) - 1, attrs: attrs}
+}
+
+// mockSpan is a span started by mockTracer.
+type mockSpan struct {
+	tracer *mockTracer
+	index  int
+	attrs  []slog.Attr
+}
+
+// End implements the Span interface.
+func (m *mockSpan) End(err error, attrs ...slog.Attr) {
+	s := m.tracer.spans[m.index]
+	for _, a := range append(m.attrs, attrs...) {
+		if a.Key != "duration" {
+			s += " " + a.String()
+		}
+	}
+	m.tracer.spans[m.index] = fmt.Sprintf("%s %v", s, err)
+}
+
+// mockMetrics sums the counters, and the values and number of
+// observations of the histograms, by name and labels.
+type mockMetrics struct {
+	values map[string]float64
+}
+
+// Add implements the Metrics interface.
+func (m *mockMetrics) Add(name string, value float64, labels ...Label) {
+	m.add(name, value, labels)
+}
+
+// Observe implements the Metrics interface.
+func (m *mockMetrics) Observe(name string, value float64, labels ...Label) {
+	m.add(name+"_sum", value, labels)
+	m.add(name+"_count", 1, labels)
+}
+
+// add adds value to the named series.
+func (m *mockMetrics) add(name string, value float64, labels []Label) {
+	sorted := append([]Label{}, labels...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	parts := make([]string, len(sorted))
+	for i, l := range sorted {
+		parts[i] = fmt.Sprintf("%s=%q", l.Name, l.Value)
+	}
+	if m.values == nil {
+		m.values = make(map[string]float64)
+	}
+	m.values[name+"{"+strings.Join(parts, ",")+"}"] += value
+}
+
+// lines returns the series as "name{labels} value" lines.
+func (m *mockMetrics) lines() []string {
+	var lines []string
+	for key, value := range m.values {
+		lines = append(lines, fmt.Sprintf("%s %v", key, value))
+	}
+	return lines
+}
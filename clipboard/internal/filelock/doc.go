@@ -0,0 +1,4 @@
+// Package filelock takes advisory locks on files, shared by the
+// processes of a user: the clipboard locks a selection with them, and
+// the reference plugin serializes the copies to its directory.
+package filelock
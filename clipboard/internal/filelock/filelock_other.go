@@ -0,0 +1,18 @@
+//go:build !(darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || windows)
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package filelock
+
+import (
+	"errors"
+	"os"
+)
+
+// TryLock fails with errors.ErrUnsupported: files can't be locked
+// on this platform.
+func TryLock(f *os.File) (bool, error) {
+	return false, errors.ErrUnsupported
+}
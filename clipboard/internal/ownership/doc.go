@@ -0,0 +1,5 @@
+// Package ownership checks that the files shared by the processes of a
+// user, such as the lock files and the socket of the daemon, belong to
+// that user and can't be reached by others, so that another user can't
+// create them first to block or intercept the clipboard.
+package ownership
@@ -0,0 +1,15 @@
+//go:build !(aix || darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd || solaris)
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package ownership
+
+import "io/fs"
+
+// fileOwner reports false: files have no owning
+// user id on this platform.
+func fileOwner(info fs.FileInfo) (int, bool) {
+	return -1, false
+}
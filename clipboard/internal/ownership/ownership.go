@@ -0,0 +1,47 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package ownership
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrInsecure is returned when a file could have been
+// created, or can be reached, by another user.
+var ErrInsecure = errors.New("not private to the user")
+
+// Check returns an error wrapping ErrInsecure unless path, which is not
+// followed if it is a symbolic link, belongs to the user and grants no
+// permission to the group or to other users. Ownership is not checked on
+// platforms without user ids, such as Windows, where the temporary
+// directory belongs to the user anyway.
+func Check(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	uid, ok := fileOwner(info)
+	if !ok {
+		return nil
+	}
+	if uid != os.Getuid() {
+		return fmt.Errorf("%s: %w: owned by user %d", path, ErrInsecure, uid)
+	}
+	if perm := info.Mode().Perm(); perm&0o077 != 0 || info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("%s: %w: mode %v", path, ErrInsecure, info.Mode())
+	}
+	return nil
+}
+
+// MkdirPrivate creates the directory dir, and its parents, if needed,
+// and checks that dir is private to the user.
+func MkdirPrivate(dir string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	return Check(dir)
+}
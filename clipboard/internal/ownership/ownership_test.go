@@ -0,0 +1,105 @@
+//go:build freebsd || linux || netbsd || openbsd || solaris || dragonfly
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package ownership
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		path          func(t *testing.T) string
+		expectedError error
+	}{
+		{
+			desc: "private directory",
+			path: func(t *testing.T) string {
+				dir := t.TempDir()
+				require.NoError(t, os.Chmod(dir, 0o700))
+				return dir
+			},
+		},
+		{
+			desc: "private file",
+			path: func(t *testing.T) string {
+				path := filepath.Join(t.TempDir(), "file")
+				require.NoError(t, os.WriteFile(path, nil, 0o600))
+				return path
+			},
+		},
+		{
+			desc: "group can read",
+			path: func(t *testing.T) string {
+				path := filepath.Join(t.TempDir(), "file")
+				require.NoError(t, os.WriteFile(path, nil, 0o640))
+				return path
+			},
+			expectedError: ErrInsecure,
+		},
+		{
+			desc: "symbolic link",
+			path: func(t *testing.T) string {
+				dir := t.TempDir()
+				path := filepath.Join(dir, "link")
+				require.NoError(t, os.Symlink(t.TempDir(), path))
+				return path
+			},
+			expectedError: ErrInsecure,
+		},
+		{
+			desc: "owned by another user",
+			path: func(t *testing.T) string {
+				if os.Getuid() != 0 {
+					t.Skip("only root can give a file away")
+				}
+				dir := t.TempDir()
+				require.NoError(t, os.Chown(dir, 12345, 12345))
+				return dir
+			},
+			expectedError: ErrInsecure,
+		},
+		{
+			desc: "missing",
+			path: func(t *testing.T) string {
+				return filepath.Join(t.TempDir(), "missing")
+			},
+			expectedError: os.ErrNotExist,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := Check(tc.path(t))
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.True(t, errors.Is(err, tc.expectedError))
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+			}
+		})
+	}
+}
+
+func TestMkdirPrivate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b")
+	require.NoError(t, MkdirPrivate(dir))
+	info, err := os.Stat(dir)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o700), info.Mode().Perm())
+
+	require.NoError(t, os.Chmod(dir, 0o755))
+	require.ErrorIs(t, MkdirPrivate(dir), ErrInsecure)
+}
@@ -0,0 +1,53 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package ownership
+
+import (
+	"encoding/binary"
+	"net"
+	"runtime"
+	"syscall"
+)
+
+// PeerUID returns the user id of the process at the other end of conn,
+// as getpeereid does. The syscall package can't read the structures
+// holding the credentials, so they are read into an IPv6Mreq, which is
+// larger than the part holding the user id.
+func PeerUID(conn *net.UnixConn) (int, error) {
+	level, option, offset := peerCredOption()
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return -1, err
+	}
+	var buf *syscall.IPv6Mreq
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		buf, credErr = syscall.GetsockoptIPv6Mreq(int(fd), level, option)
+	})
+	if err != nil {
+		return -1, err
+	}
+	if credErr != nil {
+		return -1, credErr
+	}
+	return int(binary.NativeEndian.Uint32(buf.Multiaddr[offset:])), nil
+}
+
+// peerCredOption returns the level and name of the socket option
+// holding the credentials of the peer, and the offset of its user id.
+func peerCredOption() (level, option, offset int) {
+	switch runtime.GOOS {
+	case "openbsd":
+		// SO_PEERCRED: struct sockpeercred {uid, gid, pid}.
+		return syscall.SOL_SOCKET, 0x1022, 0
+	case "netbsd":
+		// LOCAL_PEEREID: struct unpcbid {pid, euid, egid}.
+		return 0, 3, 4
+	}
+	// LOCAL_PEERCRED: struct xucred {version, uid, ...}.
+	return 0, 1, 4
+}
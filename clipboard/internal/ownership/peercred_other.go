@@ -0,0 +1,18 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package ownership
+
+import (
+	"errors"
+	"net"
+)
+
+// PeerUID fails with errors.ErrUnsupported: the credentials
+// of the peer can't be read on this platform.
+func PeerUID(conn *net.UnixConn) (int, error) {
+	return -1, errors.ErrUnsupported
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/internal/filelock"
+	"github.com/tiagomelo/go-clipboard/clipboard/internal/ownership"
+)
+
+// DefaultLockTimeout is how long a copy waits for
+// another process to release the clipboard by default.
+const DefaultLockTimeout = 10 * time.Second
+
+// lockPollInterval is how often a locked clipboard is checked.
+const lockPollInterval = 10 * time.Millisecond
+
+// ErrLocked is returned when another process kept the
+// clipboard locked for longer than the lock timeout.
+var ErrLocked = errors.New("clipboard locked by another process")
+
+// ErrInsecure is returned when the lock file, or the socket of the
+// daemon, is in a directory that another user could have created.
+var ErrInsecure = ownership.ErrInsecure
+
+// ErrChanged is returned by CopyIfUnchanged
+// when the clipboard no longer holds the expected text.
+var ErrChanged = errors.New("clipboard changed")
+
+// Fingerprint identifies a text held by the clipboard
+// without keeping it: it is the SHA-256 hash of the text.
+type Fingerprint [sha256.Size]byte
+
+// FingerprintOf returns the fingerprint of text.
+func FingerprintOf(text string) Fingerprint {
+	return sha256.Sum256([]byte(text))
+}
+
+// String returns the fingerprint in hexadecimal.
+func (f Fingerprint) String() string {
+	return hex.EncodeToString(f[:])
+}
+
+// Swapper is implemented by clipboards that can copy text only if
+// the clipboard still holds what was pasted, e.g. to append to it or
+// transform it in place without clobbering what was copied meanwhile.
+type Swapper interface {
+	// CopyIfUnchanged copies s to the clipboard if it still holds
+	// the text with the expected fingerprint, or returns ErrChanged.
+	CopyIfUnchanged(expected Fingerprint, s string) error
+}
+
+// CopyIfUnchanged implements the Swapper interface. The clipboard is
+// pasted and copied to while it is locked, so that the processes using
+// this package can't write it in between.
+func (c *clipboard) CopyIfUnchanged(expected Fingerprint, s string) error {
+	unlock, err := c.lock("copy")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	text, err := c.pasteText()
+	if err != nil {
+		return err
+	}
+	if FingerprintOf(text) != expected {
+		err := fmt.Errorf("copy: %w", ErrChanged)
+		c.record("copy", nil, "", err)
+		return err
+	}
+	return c.copyUnlocked(s)
+}
+
+// lock locks the selection of the clipboard against writes by other
+// processes, until the returned function is called, unless
+// ClipboardOptions.NoLock is set or op is blocked anyway. The lock is
+// advisory: it is only honored by the processes using this package. It
+// fails if the lock file can't be opened, e.g. on a read-only file
+// system, or if its directory isn't private to the user, in which case
+// another user could hold the lock; nothing is locked on platforms that
+// can't lock files.
+func (c *clipboard) lock(op string) (func(), error) {
+	if c.opts.NoLock || c.blocked(op) != nil {
+		return func() {}, nil
+	}
+	path := lockFile(c.selection())
+	f, err := openLockFile(path)
+	if err != nil {
+		err := fmt.Errorf("%s: locking the clipboard: %w", op, err)
+		c.record(op, nil, "", err)
+		return nil, err
+	}
+	timeout := c.opts.LockTimeout
+	if timeout <= 0 {
+		timeout = DefaultLockTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := filelock.TryLock(f)
+		if errors.Is(err, errors.ErrUnsupported) {
+			f.Close()
+			return func() {}, nil
+		}
+		if err != nil {
+			f.Close()
+			err := fmt.Errorf("%s: locking the clipboard: %w", op, err)
+			c.record(op, nil, "", err)
+			return nil, err
+		}
+		if locked {
+			return func() { f.Close() }, nil
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			err := fmt.Errorf("%s: %w: %s", op, ErrLocked, path)
+			c.record(op, nil, "", err)
+			return nil, err
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// openLockFile opens the lock file at path, creating it and its
+// directory if needed, once the directory is known to be private.
+func openLockFile(path string) (*os.File, error) {
+	if err := ownership.MkdirPrivate(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+}
+
+// lockFile returns the path of the file locking the given selection, in
+// XDG_RUNTIME_DIR, or in a directory of the user in the temporary directory.
+func lockFile(selection string) string {
+	name := "go-clipboard-" + selection + ".lock"
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, name)
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("go-clipboard-%d", os.Getuid()), name)
+}
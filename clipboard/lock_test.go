@@ -0,0 +1,120 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+)
+
+func TestClipboard_CopyIfUnchanged(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		expected       Fingerprint
+		errPaste       error
+		expectedOutput string
+		expectedError  error
+	}{
+		{
+			desc:           "unchanged",
+			expected:       FingerprintOf("pasted"),
+			expectedOutput: "pasted and appended",
+		},
+		{
+			desc:           "changed",
+			expected:       FingerprintOf("pasted earlier"),
+			expectedOutput: "pasted",
+			expectedError:  errors.New("copy: clipboard changed"),
+		},
+		{
+			desc:           "paste fails",
+			expected:       FingerprintOf("pasted"),
+			errPaste:       errors.New("paste failed"),
+			expectedOutput: "pasted",
+			expectedError:  errors.New("paste failed"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeTools(t)
+			current := "pasted"
+			c := newTestClipboard(t, ClipboardOptions{
+				Tools: []string{"xsel"},
+				Runner: mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+					return &stateCommand{text: &current, errOutput: tc.errPaste}
+				}),
+			})
+			err := c.CopyIfUnchanged(tc.expected, "pasted and appended")
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+			} else if tc.expectedError != nil {
+				t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+			}
+			require.Equal(t, tc.expectedOutput, current)
+		})
+	}
+}
+
+func TestClipboard_lock(t *testing.T) {
+	fakeTools(t)
+	runner := mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+		return &mockCommand{}
+	})
+	holder := newTestClipboard(t, ClipboardOptions{Tools: []string{"xsel"}, Runner: runner})
+	unlock, err := holder.lock("copy")
+	require.NoError(t, err)
+
+	c := newTestClipboard(t, ClipboardOptions{Tools: []string{"xsel"}, Runner: runner, LockTimeout: 50 * time.Millisecond})
+	err = c.CopyText("some text")
+	require.ErrorIs(t, err, ErrLocked)
+	require.Equal(t, ClassTimeout, ClassifyError(err))
+	require.ErrorIs(t, c.Clear(), ErrLocked)
+
+	// The other selection is not locked, nor are clipboards not locking.
+	primary := newTestClipboard(t, ClipboardOptions{Tools: []string{"xsel"}, Runner: runner, Primary: true, LockTimeout: 50 * time.Millisecond})
+	require.NoError(t, primary.CopyText("some text"))
+	unlocked := newTestClipboard(t, ClipboardOptions{Tools: []string{"xsel"}, Runner: runner, NoLock: true})
+	require.NoError(t, unlocked.CopyText("some text"))
+
+	// A copy waits for the lock to be released.
+	c.opts.LockTimeout = 0
+	copied := make(chan error, 1)
+	go func() { copied <- c.CopyText("some text") }()
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case err := <-copied:
+		t.Fatalf("copied while locked: %v", err)
+	default:
+	}
+	unlock()
+	select {
+	case err := <-copied:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("copy still waiting for the lock")
+	}
+}
+
+// stateCommand is a command copying to and pasting from text.
+type stateCommand struct {
+	text      *string
+	errOutput error
+}
+
+func (c *stateCommand) TextInput(text string) error {
+	*c.text = text
+	return nil
+}
+
+func (c *stateCommand) TextOutput() (string, error) {
+	return *c.text, c.errOutput
+}
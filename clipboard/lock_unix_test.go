@@ -0,0 +1,71 @@
+//go:build freebsd || linux || netbsd || openbsd || solaris || dragonfly
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+)
+
+func TestClipboard_lock_insecure(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		runtimeDir    func(t *testing.T) string
+		expectedError error
+	}{
+		{
+			desc:       "private directory",
+			runtimeDir: privateDir,
+		},
+		{
+			desc: "directory other users can write to",
+			runtimeDir: func(t *testing.T) string {
+				dir := t.TempDir()
+				require.NoError(t, os.Chmod(dir, 0o777))
+				return dir
+			},
+			expectedError: ErrInsecure,
+		},
+		{
+			desc: "directory that can't be created",
+			runtimeDir: func(t *testing.T) string {
+				file := filepath.Join(t.TempDir(), "file")
+				require.NoError(t, os.WriteFile(file, nil, 0o600))
+				return filepath.Join(file, "dir")
+			},
+			expectedError: syscall.ENOTDIR,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			fakeTools(t)
+			t.Setenv("XDG_RUNTIME_DIR", tc.runtimeDir(t))
+			c := newTestClipboard(t, ClipboardOptions{
+				Tools: []string{"xsel"},
+				Runner: mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+					return &mockCommand{}
+				}),
+			})
+			err := c.CopyText("some text")
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.ErrorIs(t, err, tc.expectedError)
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+// Package metrics exports the metrics recorded through clipboard.Hooks.
+//
+// Prometheus keeps them in memory and serves them over HTTP in the
+// Prometheus text format. It lives apart from the clipboard package so
+// that programs which don't serve metrics don't pull in net/http.
+package metrics
@@ -0,0 +1,183 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/tiagomelo/go-clipboard/clipboard"
+)
+
+// DefaultDurationBuckets are the upper bounds, in seconds,
+// of the histograms whose names end with "_seconds".
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets are the upper bounds of the other histograms.
+var DefaultSizeBuckets = []float64{16, 256, 4096, 65536, 1 << 20, 16 << 20}
+
+// Prometheus is a clipboard.Metrics that keeps the counters and histograms
+// in memory and serves them over HTTP in the Prometheus text format, so
+// that Prometheus can scrape them without a client library. It is safe
+// for concurrent use. The zero Prometheus is ready to use.
+type Prometheus struct {
+	// Buckets holds the upper bounds of the histograms, keyed by name.
+	// Histograms that are not in it use DefaultDurationBuckets or
+	// DefaultSizeBuckets.
+	Buckets map[string][]float64
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+// series is a counter or a histogram with a set of labels.
+type series struct {
+	name   string
+	labels string
+	bounds []float64 // nil for counters
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Add implements the clipboard.Metrics interface.
+func (m *Prometheus) Add(name string, value float64, labels ...clipboard.Label) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.get(name, labels, false).sum += value
+}
+
+// Observe implements the clipboard.Metrics interface.
+func (m *Prometheus) Observe(name string, value float64, labels ...clipboard.Label) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.get(name, labels, true)
+	for i, bound := range s.bounds {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// get returns the series with the given name and labels, creating it if needed.
+func (m *Prometheus) get(name string, labels []clipboard.Label, histogram bool) *series {
+	key := name + formatLabels(labels)
+	if s, ok := m.series[key]; ok {
+		return s
+	}
+	if m.series == nil {
+		m.series = make(map[string]*series)
+	}
+	s := &series{name: name, labels: formatLabels(labels)}
+	if histogram {
+		s.bounds = m.buckets(name)
+		s.counts = make([]uint64, len(s.bounds))
+	}
+	m.series[key] = s
+	return s
+}
+
+// buckets returns the upper bounds of the named histogram.
+func (m *Prometheus) buckets(name string) []float64 {
+	if b, ok := m.Buckets[name]; ok {
+		return b
+	}
+	if strings.HasSuffix(name, "_seconds") {
+		return DefaultDurationBuckets
+	}
+	return DefaultSizeBuckets
+}
+
+// WriteTo writes the metrics to w in the Prometheus text format.
+func (m *Prometheus) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	m.mu.Lock()
+	all := make([]*series, 0, len(m.series))
+	for _, s := range m.series {
+		all = append(all, s)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].name != all[j].name {
+			return all[i].name < all[j].name
+		}
+		return all[i].labels < all[j].labels
+	})
+	previous := ""
+	for _, s := range all {
+		if s.name != previous {
+			kind := "counter"
+			if s.bounds != nil {
+				kind = "histogram"
+			}
+			fmt.Fprintf(&buf, "# TYPE %s %s\n", s.name, kind)
+			previous = s.name
+		}
+		if s.bounds == nil {
+			fmt.Fprintf(&buf, "%s%s %s\n", s.name, s.labels, formatFloat(s.sum))
+			continue
+		}
+		for i, bound := range s.bounds {
+			fmt.Fprintf(&buf, "%s_bucket%s %d\n", s.name, withLabel(s.labels, "le", formatFloat(bound)), s.counts[i])
+		}
+		fmt.Fprintf(&buf, "%s_bucket%s %d\n", s.name, withLabel(s.labels, "le", "+Inf"), s.count)
+		fmt.Fprintf(&buf, "%s_sum%s %s\n", s.name, s.labels, formatFloat(s.sum))
+		fmt.Fprintf(&buf, "%s_count%s %d\n", s.name, s.labels, s.count)
+	}
+	m.mu.Unlock()
+	return buf.WriteTo(w)
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (m *Prometheus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// formatLabels formats labels as "{name="value",...}", sorted by name.
+func formatLabels(labels []clipboard.Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	sorted := append([]clipboard.Label{}, labels...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	parts := make([]string, len(sorted))
+	for i, l := range sorted {
+		parts[i] = l.Name + "=" + quote(l.Value)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+// withLabel adds a label at the end of formatted labels.
+func withLabel(labels, name, value string) string {
+	l := name + "=" + quote(value)
+	if labels == "" {
+		return "{" + l + "}"
+	}
+	return labels[:len(labels)-1] + "," + l + "}"
+}
+
+// quote quotes a label value, escaping backslashes,
+// double quotes and newlines.
+func quote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
+
+// formatFloat formats a sample value.
+func formatFloat(f float64) string {
+	if math.IsInf(f, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
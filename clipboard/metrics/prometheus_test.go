@@ -0,0 +1,53 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package metrics
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard"
+)
+
+func TestPrometheus(t *testing.T) {
+	m := &Prometheus{Buckets: map[string][]float64{"size": {10, 100}}}
+	m.Add("calls_total", 1, clipboard.Label{Name: "tool", Value: "xclip"}, clipboard.Label{Name: "op", Value: "copy"})
+	m.Add("calls_total", 2, clipboard.Label{Name: "op", Value: "copy"}, clipboard.Label{Name: "tool", Value: "xclip"})
+	m.Add("calls_total", 1, clipboard.Label{Name: "op", Value: "paste"}, clipboard.Label{Name: "tool", Value: "say \"hi\"\n"})
+	m.Observe("size", 5)
+	m.Observe("size", 50)
+	m.Observe("size", 500)
+	m.Observe("wait_seconds", 0.02, clipboard.Label{Name: "op", Value: "copy"})
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Equal(t, `# TYPE calls_total counter
+calls_total{op="copy",tool="xclip"} 3
+calls_total{op="paste",tool="say \"hi\"\n"} 1
+# TYPE size histogram
+size_bucket{le="10"} 1
+size_bucket{le="100"} 2
+size_bucket{le="+Inf"} 3
+size_sum 555
+size_count 3
+# TYPE wait_seconds histogram
+wait_seconds_bucket{op="copy",le="0.005"} 0
+wait_seconds_bucket{op="copy",le="0.01"} 0
+wait_seconds_bucket{op="copy",le="0.025"} 1
+wait_seconds_bucket{op="copy",le="0.05"} 1
+wait_seconds_bucket{op="copy",le="0.1"} 1
+wait_seconds_bucket{op="copy",le="0.25"} 1
+wait_seconds_bucket{op="copy",le="0.5"} 1
+wait_seconds_bucket{op="copy",le="1"} 1
+wait_seconds_bucket{op="copy",le="2.5"} 1
+wait_seconds_bucket{op="copy",le="5"} 1
+wait_seconds_bucket{op="copy",le="10"} 1
+wait_seconds_bucket{op="copy",le="+Inf"} 1
+wait_seconds_sum{op="copy"} 0.02
+wait_seconds_count{op="copy"} 1
+`, rec.Body.String())
+}
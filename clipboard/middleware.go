@@ -0,0 +1,399 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Middleware wraps a Backend to change what its operations do, e.g. to
+// retry them or to check the text that is copied. Middlewares usually
+// embed Wrapper, so that the operations they don't change, including
+// Types and Watch, reach the wrapped Backend.
+type Middleware func(Backend) Backend
+
+// Option configures the Clipboard returned by New.
+// ClipboardOptions is an Option, and so is WithMiddleware.
+type Option interface {
+	apply(opts *ClipboardOptions)
+}
+
+// apply replaces the options with o, keeping
+// the middlewares set by earlier options.
+func (o ClipboardOptions) apply(opts *ClipboardOptions) {
+	middleware := opts.Middleware
+	*opts = o
+	opts.Middleware = append(middleware, o.Middleware...)
+}
+
+// optionFunc is an Option changing the options with a function.
+type optionFunc func(opts *ClipboardOptions)
+
+// apply implements the Option interface.
+func (f optionFunc) apply(opts *ClipboardOptions) {
+	f(opts)
+}
+
+// WithMiddleware adds middlewares to every backend of the clipboard. The
+// first middleware is the outermost: it sees the operations first.
+func WithMiddleware(m ...Middleware) Option {
+	return optionFunc(func(opts *ClipboardOptions) {
+		opts.Middleware = append(opts.Middleware, m...)
+	})
+}
+
+// wrap returns b wrapped in the middlewares of the clipboard.
+func (c *clipboard) wrap(b Backend) Backend {
+	for i := len(c.opts.Middleware) - 1; i >= 0; i-- {
+		b = c.opts.Middleware[i](b)
+	}
+	return b
+}
+
+// Wrapper is a Backend forwarding every operation to the Backend it
+// holds, including Types, Watch, PasteData, CopyData and CopyItems, which fail with
+// an error wrapping errors.ErrUnsupported if the Backend doesn't
+// implement them.
+type Wrapper struct {
+	Backend
+}
+
+// Types implements the TypeLister interface.
+func (w Wrapper) Types() ([]string, error) {
+	if l, ok := w.Backend.(TypeLister); ok {
+		return l.Types()
+	}
+	return nil, unsupported(w.Backend, "listing types")
+}
+
+// Watch implements the Watcher interface.
+func (w Wrapper) Watch(ctx context.Context) (<-chan string, error) {
+	if wt, ok := w.Backend.(Watcher); ok {
+		return wt.Watch(ctx)
+	}
+	return nil, unsupported(w.Backend, "watching")
+}
+
+// PasteData implements the DataPaster interface.
+func (w Wrapper) PasteData(target string) ([]byte, error) {
+	if p, ok := w.Backend.(DataPaster); ok {
+		return p.PasteData(target)
+	}
+	return nil, unsupported(w.Backend, "pasting "+target)
+}
+
+// CopyData implements the DataCopier interface.
+func (w Wrapper) CopyData(target string, data []byte) error {
+	if c, ok := w.Backend.(DataCopier); ok {
+		return c.CopyData(target, data)
+	}
+	return unsupported(w.Backend, "copying "+target)
+}
+
+// CopyItems implements the ItemCopier interface.
+func (w Wrapper) CopyItems(items []Item) error {
+	if c, ok := w.Backend.(ItemCopier); ok {
+		return c.CopyItems(items)
+	}
+	return unsupported(w.Backend, "copying items")
+}
+
+// Unwrap returns the wrapped Backend.
+func (w Wrapper) Unwrap() Backend {
+	return w.Backend
+}
+
+// unsupported returns the error of a backend that can't do what.
+func unsupported(b Backend, what string) error {
+	return fmt.Errorf("%s: %s: %w", b.Name(), what, errors.ErrUnsupported)
+}
+
+// RetryOptions configures the Retry middleware.
+type RetryOptions struct {
+	// Attempts is how many times each operation is tried
+	// in total. Zero means 3.
+	Attempts int
+
+	// Delay is how long to wait before the first retry. It is doubled
+	// after every retry, up to MaxDelay. Zero means 100ms.
+	Delay time.Duration
+
+	// MaxDelay is the longest wait between two tries. Zero means 2s.
+	MaxDelay time.Duration
+
+	// Retryable reports whether an operation that failed with err should
+	// be tried again. Nil retries errors of class ClassUnavailable and
+	// ClassTimeout, e.g. a display server that is still starting.
+	Retryable func(err error) bool
+}
+
+// Retry returns a middleware trying the operations of a backend again,
+// with exponential backoff, when they fail with a retryable error. Each
+// operation is tried with the same backend before falling back to the
+// next one.
+func Retry(opts RetryOptions) Middleware {
+	if opts.Attempts <= 0 {
+		opts.Attempts = 3
+	}
+	if opts.Delay <= 0 {
+		opts.Delay = 100 * time.Millisecond
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 2 * time.Second
+	}
+	if opts.Retryable == nil {
+		opts.Retryable = func(err error) bool {
+			class := ClassifyError(err)
+			return class == ClassUnavailable || class == ClassTimeout
+		}
+	}
+	return func(b Backend) Backend {
+		return &retryBackend{Wrapper{b}, opts}
+	}
+}
+
+// retryBackend is the backend returned by Retry.
+type retryBackend struct {
+	Wrapper
+	opts RetryOptions
+}
+
+// CopyText implements the Backend interface.
+func (b *retryBackend) CopyText(s string) error {
+	return b.retry(func() error {
+		return b.Backend.CopyText(s)
+	})
+}
+
+// PasteText implements the Backend interface.
+func (b *retryBackend) PasteText() (string, error) {
+	var text string
+	err := b.retry(func() error {
+		var err error
+		text, err = b.Backend.PasteText()
+		return err
+	})
+	return text, err
+}
+
+// Clear implements the Backend interface.
+func (b *retryBackend) Clear() error {
+	return b.retry(b.Backend.Clear)
+}
+
+// Types implements the TypeLister interface.
+func (b *retryBackend) Types() ([]string, error) {
+	var types []string
+	err := b.retry(func() error {
+		var err error
+		types, err = b.Wrapper.Types()
+		return err
+	})
+	return types, err
+}
+
+// Watch implements the Watcher interface. Only starting
+// to watch is retried.
+func (b *retryBackend) Watch(ctx context.Context) (<-chan string, error) {
+	var texts <-chan string
+	err := b.retry(func() error {
+		var err error
+		texts, err = b.Wrapper.Watch(ctx)
+		return err
+	})
+	return texts, err
+}
+
+// PasteData implements the DataPaster interface.
+func (b *retryBackend) PasteData(target string) ([]byte, error) {
+	var data []byte
+	err := b.retry(func() error {
+		var err error
+		data, err = b.Wrapper.PasteData(target)
+		return err
+	})
+	return data, err
+}
+
+// CopyData implements the DataCopier interface.
+func (b *retryBackend) CopyData(target string, data []byte) error {
+	return b.retry(func() error {
+		return b.Wrapper.CopyData(target, data)
+	})
+}
+
+// CopyItems implements the ItemCopier interface.
+func (b *retryBackend) CopyItems(items []Item) error {
+	return b.retry(func() error {
+		return b.Wrapper.CopyItems(items)
+	})
+}
+
+// retry calls op until it succeeds, fails with an error that is not
+// retryable, or has been called opts.Attempts times.
+func (b *retryBackend) retry(op func() error) error {
+	delay := b.opts.Delay
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt == b.opts.Attempts || !b.opts.Retryable(err) {
+			return err
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > b.opts.MaxDelay {
+			delay = b.opts.MaxDelay
+		}
+	}
+}
+
+// RateLimit returns a middleware letting each kind of operation (copy,
+// paste, clear, listing types and watching) run at most burst times in
+// a row, and then once every interval. Copying and pasting data or
+// items count as copies and pastes. Operations over the limit wait
+// for their turn. The limits are shared by every backend the middleware
+// wraps.
+func RateLimit(interval time.Duration, burst int) Middleware {
+	if burst < 1 {
+		burst = 1
+	}
+	l := &limiter{interval: interval, burst: burst, next: make(map[string]time.Time)}
+	return func(b Backend) Backend {
+		return &rateLimitBackend{Wrapper{b}, l}
+	}
+}
+
+// limiter is a set of leaky buckets, one per operation.
+type limiter struct {
+	interval time.Duration
+	burst    int
+
+	mu   sync.Mutex
+	next map[string]time.Time // when each operation's bucket is empty
+}
+
+// wait blocks until op can run.
+func (l *limiter) wait(op string) {
+	l.mu.Lock()
+	now := time.Now()
+	next := l.next[op]
+	if next.Before(now) {
+		next = now
+	}
+	// The bucket holds up to burst operations: wait until
+	// there is room for one more, and take it.
+	start := next.Add(-time.Duration(l.burst-1) * l.interval)
+	l.next[op] = next.Add(l.interval)
+	l.mu.Unlock()
+	if d := start.Sub(now); d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// rateLimitBackend is the backend returned by RateLimit.
+type rateLimitBackend struct {
+	Wrapper
+	limiter *limiter
+}
+
+// CopyText implements the Backend interface.
+func (b *rateLimitBackend) CopyText(s string) error {
+	b.limiter.wait("copy")
+	return b.Backend.CopyText(s)
+}
+
+// PasteText implements the Backend interface.
+func (b *rateLimitBackend) PasteText() (string, error) {
+	b.limiter.wait("paste")
+	return b.Backend.PasteText()
+}
+
+// Clear implements the Backend interface.
+func (b *rateLimitBackend) Clear() error {
+	b.limiter.wait("clear")
+	return b.Backend.Clear()
+}
+
+// Types implements the TypeLister interface.
+func (b *rateLimitBackend) Types() ([]string, error) {
+	b.limiter.wait("types")
+	return b.Wrapper.Types()
+}
+
+// Watch implements the Watcher interface.
+func (b *rateLimitBackend) Watch(ctx context.Context) (<-chan string, error) {
+	b.limiter.wait("watch")
+	return b.Wrapper.Watch(ctx)
+}
+
+// PasteData implements the DataPaster interface.
+func (b *rateLimitBackend) PasteData(target string) ([]byte, error) {
+	b.limiter.wait("paste")
+	return b.Wrapper.PasteData(target)
+}
+
+// CopyData implements the DataCopier interface.
+func (b *rateLimitBackend) CopyData(target string, data []byte) error {
+	b.limiter.wait("copy")
+	return b.Wrapper.CopyData(target, data)
+}
+
+// CopyItems implements the ItemCopier interface.
+func (b *rateLimitBackend) CopyItems(items []Item) error {
+	b.limiter.wait("copy")
+	return b.Wrapper.CopyItems(items)
+}
+
+// ErrVerify is returned by the Verify middleware
+// when copied text could not be read back.
+var ErrVerify = errors.New("copied text could not be read back")
+
+// Verify returns a middleware pasting the text after every copy and
+// returning an error wrapping ErrVerify if it differs from what was
+// copied. Copies served for a single paste, with ClipboardOptions.OneShot
+// or because a policy found the text Sensitive, are not verified, since
+// the check would consume them.
+func Verify() Middleware {
+	return func(b Backend) Backend {
+		return &verifyBackend{Wrapper{b}}
+	}
+}
+
+// verifyBackend is the backend returned by Verify.
+type verifyBackend struct {
+	Wrapper
+}
+
+// CopyText implements the Backend interface.
+func (b *verifyBackend) CopyText(s string) error {
+	if err := b.Backend.CopyText(s); err != nil || servesOnce(b.Backend) {
+		return err
+	}
+	got, err := b.Backend.PasteText()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrVerify, err)
+	}
+	if got != s {
+		return fmt.Errorf("%w: pasted %d bytes instead of %d", ErrVerify, len(got), len(s))
+	}
+	return nil
+}
+
+// servesOnce reports whether b, or the backend it wraps,
+// serves what it copies for a single paste.
+func servesOnce(b Backend) bool {
+	for {
+		switch w := b.(type) {
+		case *toolBackend:
+			return w.opts.OneShot && w.ct.CopyTool.OneShotArgs != nil
+		case interface{ Unwrap() Backend }:
+			b = w.Unwrap()
+		default:
+			return false
+		}
+	}
+}
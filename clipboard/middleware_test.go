@@ -0,0 +1,319 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+)
+
+func TestWithMiddleware(t *testing.T) {
+	fakeTools(t)
+	var calls []string
+	record := func(name string) Middleware {
+		return func(b Backend) Backend {
+			return &recordBackend{Wrapper{b}, name, &calls}
+		}
+	}
+	m := &mockCommand{Output: "some text"}
+	c := newTestClipboard(t,
+		WithMiddleware(record("outer")),
+		ClipboardOptions{
+			CopyCmd:    "mycopy",
+			PasteCmd:   "mypaste",
+			Middleware: []Middleware{record("middle")},
+			Runner: mockRunner(func(cmdName string, cmdArgs ...string) command.Command {
+				return m
+			}),
+		},
+		WithMiddleware(record("inner")),
+	)
+	require.NoError(t, c.CopyText("copied"))
+	require.Equal(t, "copied", m.Input)
+	text, err := c.PasteText()
+	require.NoError(t, err)
+	require.Equal(t, "some text", text)
+	_, err = c.Types()
+	require.ErrorIs(t, err, errors.ErrUnsupported)
+	require.Equal(t, []string{
+		"outer copy", "middle copy", "inner copy",
+		"outer paste", "middle paste", "inner paste",
+		"outer types", "middle types", "inner types",
+	}, calls)
+}
+
+func TestRetry(t *testing.T) {
+	unavailable := exitError(t, "Error: Can't open display: (null)\n")
+	testCases := []struct {
+		desc          string
+		opts          RetryOptions
+		errs          []error
+		expectedCalls int
+		expectedError error
+	}{
+		{
+			desc:          "success",
+			errs:          []error{nil},
+			expectedCalls: 1,
+		},
+		{
+			desc:          "success after retries",
+			errs:          []error{unavailable, unavailable, nil},
+			expectedCalls: 3,
+		},
+		{
+			desc:          "too many failures",
+			errs:          []error{unavailable, unavailable, unavailable, nil},
+			expectedCalls: 3,
+			expectedError: unavailable,
+		},
+		{
+			desc:          "error that is not retryable",
+			errs:          []error{errors.New("empty clipboard"), nil},
+			expectedCalls: 1,
+			expectedError: errors.New("empty clipboard"),
+		},
+		{
+			desc:          "custom options",
+			opts:          RetryOptions{Attempts: 2, Retryable: func(err error) bool { return true }},
+			errs:          []error{errors.New("empty clipboard"), errors.New("still empty"), nil},
+			expectedCalls: 2,
+			expectedError: errors.New("still empty"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			opts := tc.opts
+			opts.Delay = time.Millisecond
+			b := &mockBackend{errs: tc.errs}
+			output, err := Retry(opts)(b).PasteText()
+			require.Equal(t, tc.expectedCalls, b.calls)
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+				require.Equal(t, "some text", output)
+			}
+		})
+	}
+}
+
+func TestRetry_data(t *testing.T) {
+	unavailable := exitError(t, "Error: Can't open display: (null)\n")
+	testCases := []struct {
+		desc string
+		op   func(b Backend) error
+	}{
+		{
+			desc: "paste data",
+			op: func(b Backend) error {
+				data, err := b.(DataPaster).PasteData("image/png")
+				if err == nil {
+					require.Equal(t, []byte("image/png data"), data)
+				}
+				return err
+			},
+		},
+		{
+			desc: "copy data",
+			op: func(b Backend) error {
+				return b.(DataCopier).CopyData("image/png", []byte("png"))
+			},
+		},
+		{
+			desc: "copy items",
+			op: func(b Backend) error {
+				return b.(ItemCopier).CopyItems([]Item{{Target: "image/png", Data: []byte("png")}})
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			b := &dataBackend{&mockBackend{errs: []error{unavailable, unavailable}}}
+			require.NoError(t, tc.op(Retry(RetryOptions{Delay: time.Millisecond})(b)))
+			require.Equal(t, 3, b.calls)
+		})
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	b := RateLimit(50*time.Millisecond, 2)(&mockBackend{})
+	start := time.Now()
+	require.NoError(t, b.CopyText("1"))
+	require.NoError(t, b.CopyText("2"))
+	_, err := b.PasteText()
+	require.NoError(t, err)
+	require.Less(t, time.Since(start), 50*time.Millisecond)
+	require.NoError(t, b.CopyText("3"))
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestRateLimit_data(t *testing.T) {
+	b := RateLimit(50*time.Millisecond, 2)(&dataBackend{&mockBackend{}})
+	start := time.Now()
+	require.NoError(t, b.(DataCopier).CopyData("image/png", []byte("png")))
+	require.NoError(t, b.CopyText("1"))
+	_, err := b.(DataPaster).PasteData("image/png")
+	require.NoError(t, err)
+	require.Less(t, time.Since(start), 50*time.Millisecond)
+	require.NoError(t, b.(ItemCopier).CopyItems([]Item{{Target: "image/png", Data: []byte("png")}}))
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
+
+func TestVerify(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		backend       *mockBackend
+		expectedError error
+	}{
+		{
+			desc:    "text read back",
+			backend: &mockBackend{},
+		},
+		{
+			desc:          "different text read back",
+			backend:       &mockBackend{text: "other text"},
+			expectedError: errors.New("copied text could not be read back: pasted 10 bytes instead of 9"),
+		},
+		{
+			desc:          "paste fails",
+			backend:       &mockBackend{errs: []error{nil, errors.New("paste error")}},
+			expectedError: errors.New("copied text could not be read back: paste error"),
+		},
+		{
+			desc:          "copy fails",
+			backend:       &mockBackend{errs: []error{errors.New("copy error")}},
+			expectedError: errors.New("copy error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := Verify()(tc.backend).CopyText("some text")
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+			}
+		})
+	}
+}
+
+func TestWrapper(t *testing.T) {
+	_, err := Wrapper{&mockBackend{}}.Types()
+	require.EqualError(t, err, "mock: listing types: unsupported operation")
+	_, err = Wrapper{&mockBackend{}}.Watch(context.Background())
+	require.EqualError(t, err, "mock: watching: unsupported operation")
+
+	b := Retry(RetryOptions{})(&pluginBackend{})
+	require.Equal(t, &pluginBackend{}, b.(*retryBackend).Unwrap())
+}
+
+// recordBackend is a Backend recording the operations it forwards.
+type recordBackend struct {
+	Wrapper
+	name  string
+	calls *[]string
+}
+
+func (b *recordBackend) CopyText(s string) error {
+	*b.calls = append(*b.calls, b.name+" copy")
+	return b.Backend.CopyText(s)
+}
+
+func (b *recordBackend) PasteText() (string, error) {
+	*b.calls = append(*b.calls, b.name+" paste")
+	return b.Backend.PasteText()
+}
+
+func (b *recordBackend) Types() ([]string, error) {
+	*b.calls = append(*b.calls, b.name+" types")
+	return b.Wrapper.Types()
+}
+
+// mockBackend is a Backend whose successive operations fail with errs,
+// then succeed. It pastes text, or the last copied text if text is empty.
+type mockBackend struct {
+	errs   []error
+	calls  int
+	text   string
+	copied string
+}
+
+func (b *mockBackend) Name() string {
+	return "mock"
+}
+
+func (b *mockBackend) Capabilities() Capabilities {
+	return Capabilities{Selections: []Selection{SelectionClipboard}}
+}
+
+func (b *mockBackend) CopyText(s string) error {
+	if err := b.err(); err != nil {
+		return err
+	}
+	b.copied = s
+	return nil
+}
+
+func (b *mockBackend) PasteText() (string, error) {
+	if err := b.err(); err != nil {
+		return "", err
+	}
+	if b.text != "" {
+		return b.text, nil
+	}
+	if b.copied != "" {
+		return b.copied, nil
+	}
+	return "some text", nil
+}
+
+func (b *mockBackend) Clear() error {
+	return b.err()
+}
+
+// err returns the error of the next operation.
+func (b *mockBackend) err() error {
+	b.calls++
+	if b.calls <= len(b.errs) {
+		return b.errs[b.calls-1]
+	}
+	return nil
+}
+
+// dataBackend is a mockBackend that also copies and pastes data.
+type dataBackend struct {
+	*mockBackend
+}
+
+func (b *dataBackend) PasteData(target string) ([]byte, error) {
+	if err := b.err(); err != nil {
+		return nil, err
+	}
+	return []byte(target + " data"), nil
+}
+
+func (b *dataBackend) CopyData(target string, data []byte) error {
+	return b.err()
+}
+
+func (b *dataBackend) CopyItems(items []Item) error {
+	return b.err()
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"sync"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+)
+
+// owners supervises the clipboard tools left running in the foreground
+// to serve the selection, when ClipboardOptions.Supervise is set. At most
+// one of them is kept running: the one that served the last copy.
+type owners struct {
+	mu      sync.Mutex
+	current command.Process
+}
+
+// replace makes p the current owner, stopping the previous one. p is
+// forgotten as soon as it exits, e.g. because another program took
+// over the selection.
+func (o *owners) replace(p command.Process) error {
+	o.mu.Lock()
+	previous := o.current
+	o.current = p
+	o.mu.Unlock()
+	go func() {
+		<-p.Done()
+		o.mu.Lock()
+		if o.current == p {
+			o.current = nil
+		}
+		o.mu.Unlock()
+	}()
+	if previous == nil {
+		return nil
+	}
+	return previous.Stop()
+}
+
+// release stops the current owner, if any.
+func (o *owners) release() error {
+	o.mu.Lock()
+	p := o.current
+	o.current = nil
+	o.mu.Unlock()
+	if p == nil {
+		return nil
+	}
+	return p.Stop()
+}
+
+// owner returns the current owner, or nil.
+func (o *owners) owner() command.Process {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.current
+}
@@ -0,0 +1,124 @@
+//go:build freebsd || linux || netbsd || openbsd || solaris || dragonfly
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard/clipboardtest/fakebin"
+	"github.com/tiagomelo/go-clipboard/clipboard/command"
+)
+
+func TestClipboard_Supervise(t *testing.T) {
+	testCases := []struct {
+		desc                 string
+		tools                []string
+		expectedClearedOwner bool
+	}{
+		{
+			desc:                 "xclip",
+			tools:                []string{"xclip"},
+			expectedClearedOwner: true,
+		},
+		{
+			desc:  "wl-copy",
+			tools: []string{"wl-copy", "wl-paste"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, fakebin.Install(dir, tc.tools...))
+			t.Setenv("PATH", dir)
+			t.Setenv(fakebin.StateDirEnv, t.TempDir())
+			fds := openFiles(t)
+
+			cb, err := New(ClipboardOptions{Tools: tc.tools[:1], Supervise: true, NoDaemon: true})
+			require.NoError(t, err)
+			c := cb.(*clipboard)
+			require.NoError(t, c.CopyText("one"))
+			first := c.owners.owner()
+			require.NotNil(t, first)
+			requireRunning(t, first)
+
+			// The next copy replaces the owner.
+			require.NoError(t, c.CopyText("two"))
+			second := c.owners.owner()
+			require.NotNil(t, second)
+			requireStopped(t, first)
+			requireRunning(t, second)
+			text, err := c.PasteText()
+			require.NoError(t, err)
+			require.Equal(t, "two", text)
+
+			require.NoError(t, c.Clear())
+			requireStopped(t, second)
+			cleared := c.owners.owner()
+			require.Equal(t, tc.expectedClearedOwner, cleared != nil)
+
+			require.NoError(t, c.Close())
+			require.Nil(t, c.owners.owner())
+			if cleared != nil {
+				requireStopped(t, cleared)
+			}
+			require.NoError(t, c.Close())
+			if fds != nil {
+				require.Equal(t, fds, openFiles(t))
+			}
+		})
+	}
+}
+
+func TestClipboard_Supervise_oneShot(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, fakebin.Install(dir, "xclip"))
+	t.Setenv("PATH", dir)
+	t.Setenv(fakebin.StateDirEnv, t.TempDir())
+
+	cb, err := New(ClipboardOptions{Tools: []string{"xclip"}, Supervise: true, OneShot: true, NoDaemon: true})
+	require.NoError(t, err)
+	c := cb.(*clipboard)
+	require.NoError(t, c.CopyText("one"))
+	require.Nil(t, c.owners.owner())
+	text, err := c.PasteText()
+	require.NoError(t, err)
+	require.Equal(t, "one", text)
+}
+
+// requireRunning checks that p is still running.
+func requireRunning(t *testing.T, p command.Process) {
+	select {
+	case <-p.Done():
+		t.Fatalf("process exited: %v", p.Err())
+	default:
+	}
+	require.NoError(t, syscall.Kill(p.Pid(), 0))
+}
+
+// requireStopped checks that p exited and was reaped,
+// so that it doesn't even exist as a zombie.
+func requireStopped(t *testing.T, p command.Process) {
+	<-p.Done()
+	require.ErrorIs(t, syscall.Kill(p.Pid(), 0), syscall.ESRCH)
+}
+
+// openFiles returns the file descriptors open in the
+// process, or nil if they can't be listed.
+func openFiles(t *testing.T) []string {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return nil
+	}
+	var fds []string
+	for _, e := range entries {
+		fds = append(fds, e.Name())
+	}
+	return fds
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+// clipboard-peer shares the clipboard with paired devices on the local
+// network. Devices are paired once: one of them waits for the other and
+// prints a one-time code, which is typed on the other one. Then each of
+// them runs clipboard-peer until it receives SIGINT or SIGTERM.
+//
+//	clipboard-peer id [-dir path]
+//	clipboard-peer pair [-dir path] [-listen addr]
+//	clipboard-peer pair [-dir path] -connect addr -code code
+//	clipboard-peer run [-dir path] [-listen addr] [-types t,...] [-max-size n] [-v]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/peersync"
+)
+
+const usage = `usage:
+	clipboard-peer id [-dir path]
+	clipboard-peer pair [-dir path] [-listen addr]
+	clipboard-peer pair [-dir path] -connect addr -code code
+	clipboard-peer run [-dir path] [-listen addr] [-types t,...] [-max-size n] [-v]`
+
+// defaultListen is the address listened on by default.
+var defaultListen = ":" + strconv.Itoa(peersync.DefaultPort)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	switch os.Args[1] {
+	case "id":
+		os.Exit(id(os.Args[2:]))
+	case "pair":
+		os.Exit(pair(os.Args[2:]))
+	case "run":
+		os.Exit(run(os.Args[2:]))
+	}
+	fmt.Fprintln(os.Stderr, usage)
+	os.Exit(2)
+}
+
+// dirFlag adds the flag of the directory holding
+// the identity of the device and its peers.
+func dirFlag(flags *flag.FlagSet) *string {
+	dir, err := peersync.DefaultDir()
+	if err != nil {
+		dir = ".clipboard-peer"
+	}
+	return flags.String("dir", dir, "directory holding the identity of the device and its peers")
+}
+
+// id prints the public key of the device.
+func id(args []string) int {
+	flags := flag.NewFlagSet("clipboard-peer id", flag.ExitOnError)
+	dir := dirFlag(flags)
+	flags.Parse(args)
+
+	identity, err := peersync.LoadIdentity(filepath.Join(*dir, "identity"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Println(identity.PublicKey())
+	return 0
+}
+
+// pair pairs the device with another one, waiting for it
+// and printing the code, or connecting to it with the code.
+func pair(args []string) int {
+	flags := flag.NewFlagSet("clipboard-peer pair", flag.ExitOnError)
+	dir := dirFlag(flags)
+	listen := flags.String("listen", defaultListen, "address to wait for the other device on")
+	connect := flags.String("connect", "", "address of the other device, waiting with the code")
+	code := flags.String("code", "", "code printed by the other device")
+	flags.Parse(args)
+
+	identity, err := peersync.LoadIdentity(filepath.Join(*dir, "identity"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	var conn net.Conn
+	if *connect != "" {
+		if *code == "" {
+			fmt.Fprintln(os.Stderr, "-code is required with -connect")
+			return 2
+		}
+		conn, err = net.Dial("tcp", *connect)
+	} else {
+		if *code, err = peersync.NewPairingCode(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		var l net.Listener
+		if l, err = net.Listen("tcp", *listen); err == nil {
+			fmt.Printf("waiting on %s; on the other device, run:\n\n", l.Addr())
+			fmt.Printf("\tclipboard-peer pair -connect <address of this device> -code %s\n\n", *code)
+			conn, err = l.Accept()
+			l.Close()
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer conn.Close()
+	key, err := peersync.Pair(conn, identity, *code)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := peersync.SavePeer(filepath.Join(*dir, "peers"), peersync.Peer{Key: key, Addr: *connect}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("paired with %s\n", key)
+	return 0
+}
+
+// run syncs the clipboard with the peers.
+func run(args []string) int {
+	flags := flag.NewFlagSet("clipboard-peer run", flag.ExitOnError)
+	dir := dirFlag(flags)
+	listen := flags.String("listen", defaultListen, "address to accept peers on, or empty to only connect to them")
+	types := flags.String("types", "", "comma-separated types a text must be offered as to be synced")
+	maxSize := flags.Int("max-size", peersync.DefaultMaxSize, "size in bytes of the longest text synced")
+	verbose := flags.Bool("v", false, "log what is not synced, and why")
+	flags.Parse(args)
+
+	level := slog.LevelInfo
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	identity, err := peersync.LoadIdentity(filepath.Join(*dir, "identity"))
+	if err != nil {
+		logger.Error("loading identity", "error", err)
+		return 1
+	}
+	peers, err := peersync.LoadPeers(filepath.Join(*dir, "peers"))
+	if err != nil {
+		logger.Error("loading peers", "error", err)
+		return 1
+	}
+	opts := peersync.Options{Identity: identity, Peers: peers, MaxSize: *maxSize, Logger: logger}
+	for _, t := range strings.Split(*types, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			opts.Types = append(opts.Types, t)
+		}
+	}
+	n, err := peersync.New(opts)
+	if err != nil {
+		logger.Error("starting", "error", err)
+		return 1
+	}
+	var l net.Listener
+	if *listen != "" {
+		if l, err = net.Listen("tcp", *listen); err != nil {
+			logger.Error("listening", "error", err)
+			return 1
+		}
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	logger.Info("syncing", "id", identity.PublicKey(), "peers", len(peers))
+	if err := n.Run(ctx, l); err != nil {
+		logger.Error("syncing", "error", err)
+		return 1
+	}
+	return 0
+}
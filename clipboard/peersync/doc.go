@@ -0,0 +1,19 @@
+// Package peersync shares the clipboard between devices on a local
+// network, without going through a third party.
+//
+// Devices are paired once, with Pair and a one-time code shown on one of
+// them and typed on the other, which exchanges their public keys. Paired
+// devices then connect to each other directly, authenticating with those
+// keys in a handshake deriving session keys from X25519 exchanges with
+// their long-term and ephemeral keys, so that everything they send is
+// encrypted end to end with AES-GCM, and past sessions stay secret even
+// if a key is later stolen.
+//
+// A Node watches the clipboard and sends what is copied to the peers it
+// is connected to, which copy it in turn. Each change is stamped with its
+// time and the key of the device it was copied on, so that every device
+// keeps the newest one when changes cross, and recognizes what it copied
+// itself when it comes back. Peers are listed statically, with the
+// address they listen on, and a device relays the changes it receives
+// to its other peers, so that they don't all need to reach each other.
+package peersync
@@ -0,0 +1,175 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package peersync
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// keyEncoding is how keys are written in files and on the command line.
+var keyEncoding = base64.RawURLEncoding
+
+// PublicKey is the public key of a device, which identifies it.
+type PublicKey [32]byte
+
+// ParsePublicKey parses a key written by PublicKey.String.
+func ParsePublicKey(s string) (PublicKey, error) {
+	var k PublicKey
+	err := k.UnmarshalText([]byte(s))
+	return k, err
+}
+
+// String returns the key in unpadded URL-safe base64.
+func (k PublicKey) String() string {
+	return keyEncoding.EncodeToString(k[:])
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (k PublicKey) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (k *PublicKey) UnmarshalText(text []byte) error {
+	data, err := keyEncoding.DecodeString(string(text))
+	if err != nil || len(data) != len(k) {
+		return fmt.Errorf("invalid public key %q", text)
+	}
+	copy(k[:], data)
+	return nil
+}
+
+// ecdh returns the key as an X25519 public key.
+func (k PublicKey) ecdh() (*ecdh.PublicKey, error) {
+	return ecdh.X25519().NewPublicKey(k[:])
+}
+
+// Identity is the long-term key pair of a device.
+type Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// NewIdentity returns a new random identity.
+func NewIdentity() (*Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{key: key}, nil
+}
+
+// LoadIdentity returns the identity saved in the file at path,
+// creating the file, readable only by its owner, if it doesn't exist.
+func LoadIdentity(path string) (*Identity, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		id, err := NewIdentity()
+		if err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, err
+		}
+		data := keyEncoding.EncodeToString(id.key.Bytes()) + "\n"
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			return nil, err
+		}
+		return id, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	raw, err := keyEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err == nil {
+		var key *ecdh.PrivateKey
+		if key, err = ecdh.X25519().NewPrivateKey(raw); err == nil {
+			return &Identity{key: key}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: invalid identity", path)
+}
+
+// PublicKey returns the public key of the identity.
+func (id *Identity) PublicKey() PublicKey {
+	var k PublicKey
+	copy(k[:], id.key.PublicKey().Bytes())
+	return k
+}
+
+// Peer is a paired device.
+type Peer struct {
+	Key  PublicKey
+	Addr string // Address it listens on, e.g. "192.168.1.20:7219", if known
+}
+
+// LoadPeers returns the peers saved in the file at path by SavePeer,
+// or none if it doesn't exist.
+func LoadPeers(path string) ([]Peer, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var peers []Peer
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		key, err := ParsePublicKey(fields[0])
+		if err != nil || len(fields) > 2 {
+			return nil, fmt.Errorf("%s:%d: invalid peer", path, line)
+		}
+		p := Peer{Key: key}
+		if len(fields) == 2 {
+			p.Addr = fields[1]
+		}
+		peers = append(peers, p)
+	}
+	return peers, nil
+}
+
+// SavePeer saves p in the file at path, one peer per line, replacing
+// the peer with the same key, if any.
+func SavePeer(path string, p Peer) error {
+	peers, err := LoadPeers(path)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	for _, other := range peers {
+		if other.Key != p.Key {
+			fmt.Fprintln(&b, strings.TrimSpace(other.Key.String()+" "+other.Addr))
+		}
+	}
+	fmt.Fprintln(&b, strings.TrimSpace(p.Key.String()+" "+p.Addr))
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o600)
+}
+
+// DefaultDir returns the directory where the identity of the
+// device and its peers are kept, in the user's config directory.
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "go-clipboard", "peersync"), nil
+}
@@ -0,0 +1,412 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package peersync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/tiagomelo/go-clipboard/clipboard"
+)
+
+const (
+	// DefaultPort is the port nodes listen on, by default.
+	DefaultPort = 7219
+
+	// DefaultMaxSize is the size, in bytes, of the
+	// longest text synced, by default.
+	DefaultMaxSize = 1 << 20
+
+	// DefaultPollInterval is how often a clipboard that
+	// can't be watched is checked, by default.
+	DefaultPollInterval = 500 * time.Millisecond
+
+	// DefaultRedialInterval is how long a node waits before
+	// connecting to a peer again, by default.
+	DefaultRedialInterval = 5 * time.Second
+)
+
+// Options configures a Node.
+type Options struct {
+	// Identity is the identity of the device. It is required.
+	Identity *Identity
+
+	// Peers are the paired devices. Only they may connect, and
+	// the node connects to those whose address is known.
+	Peers []Peer
+
+	// Clipboard is the clipboard synced. Nil means
+	// the clipboard returned by clipboard.New.
+	Clipboard clipboard.Clipboard
+
+	// Types are the MIME types or targets, e.g. "text/*", a text must
+	// be offered as to be synced. Empty means any type.
+	Types []string
+
+	// MaxSize is the size, in bytes, of the longest text
+	// synced. Zero means DefaultMaxSize.
+	MaxSize int
+
+	// PollInterval is how often the clipboard is checked if it
+	// can't be watched. Zero means DefaultPollInterval.
+	PollInterval time.Duration
+
+	// RedialInterval is how long the node waits before connecting to a
+	// peer again, after failing to. Zero means DefaultRedialInterval.
+	RedialInterval time.Duration
+
+	// Logger logs connections and what is synced.
+	// Nil means nothing is logged.
+	Logger *slog.Logger
+}
+
+// Node syncs the clipboard of a device with its peers.
+type Node struct {
+	opts    Options
+	self    PublicKey
+	trusted map[PublicKey]bool
+
+	mu    sync.Mutex
+	last  update                // Newest change, copied here or received
+	held  clipboard.Fingerprint // What the clipboard holds
+	conns map[*secureConn]bool  // Connected peers
+	wg    sync.WaitGroup        // Connections being served
+
+	copyMu sync.Mutex // Serializes the copies of received changes
+}
+
+// update is a change of the clipboard of a device, sent to its peers.
+type update struct {
+	Time   int64     `json:"time"`   // When it was copied, in Unix nanoseconds
+	Origin PublicKey `json:"origin"` // Device it was copied on
+	Text   string    `json:"text"`
+	Types  []string  `json:"types,omitempty"` // Types it was offered as
+}
+
+// newer reports whether u is newer than other. Changes copied at the
+// same time on different devices are ordered by their origin, so that
+// every device keeps the same one.
+func (u update) newer(other update) bool {
+	if u.Time != other.Time {
+		return u.Time > other.Time
+	}
+	return bytes.Compare(u.Origin[:], other.Origin[:]) > 0
+}
+
+// New returns a node configured with opts.
+func New(opts Options) (*Node, error) {
+	if opts.Identity == nil {
+		return nil, errors.New("no identity")
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	if opts.RedialInterval <= 0 {
+		opts.RedialInterval = DefaultRedialInterval
+	}
+	if opts.Clipboard == nil {
+		c, err := clipboard.New(clipboard.ClipboardOptions{})
+		if err != nil {
+			return nil, err
+		}
+		opts.Clipboard = c
+	}
+	n := &Node{
+		opts:    opts,
+		self:    opts.Identity.PublicKey(),
+		trusted: make(map[PublicKey]bool),
+		conns:   make(map[*secureConn]bool),
+	}
+	for _, p := range opts.Peers {
+		n.trusted[p.Key] = true
+	}
+	return n, nil
+}
+
+// Run syncs the clipboard until ctx is done. It accepts the connections
+// of peers on l, if not nil, and connects to the peers whose address is
+// known, again and again until ctx is done. Run always closes l.
+func (n *Node) Run(ctx context.Context, l net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		cancel()
+		if l != nil {
+			l.Close()
+		}
+		n.mu.Lock()
+		for c := range n.conns {
+			c.Close()
+		}
+		n.mu.Unlock()
+		n.wg.Wait()
+	}()
+	if text, err := n.opts.Clipboard.PasteText(); err == nil {
+		n.held = clipboard.FingerprintOf(text)
+	}
+	texts, err := clipboard.WatchOrPoll(ctx, n.opts.Clipboard, n.opts.PollInterval)
+	if err != nil {
+		return err
+	}
+	if l != nil {
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			n.accept(ctx, l)
+		}()
+	}
+	for _, p := range n.opts.Peers {
+		if p.Addr == "" {
+			continue
+		}
+		p := p
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			n.dial(ctx, p)
+		}()
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case text, ok := <-texts:
+			if !ok {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return errors.New("watching the clipboard stopped")
+			}
+			n.copied(text)
+		}
+	}
+}
+
+// accept serves the peers connecting on l.
+func (n *Node) accept(ctx context.Context, l net.Listener) {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				n.log(slog.LevelError, "accepting connections", "error", err)
+			}
+			return
+		}
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			c, err := handshake(conn, n.opts.Identity, func(k PublicKey) bool { return n.trusted[k] })
+			if err != nil {
+				n.log(slog.LevelWarn, "refused connection", "addr", conn.RemoteAddr(), "error", err)
+				conn.Close()
+				return
+			}
+			n.serve(c)
+		}()
+	}
+}
+
+// dial connects to p, and serves it, until ctx is done.
+func (n *Node) dial(ctx context.Context, p Peer) {
+	var dialer net.Dialer
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", p.Addr)
+		if err == nil {
+			var c *secureConn
+			c, err = handshake(conn, n.opts.Identity, func(k PublicKey) bool { return k == p.Key })
+			if err == nil {
+				stop := context.AfterFunc(ctx, func() { c.Close() })
+				n.serve(c)
+				stop()
+			} else {
+				conn.Close()
+			}
+		}
+		if err != nil && ctx.Err() == nil {
+			n.log(slog.LevelDebug, "connecting to peer", "peer", p.Key, "addr", p.Addr, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(n.opts.RedialInterval):
+		}
+	}
+}
+
+// serve receives the changes sent by a peer until it disconnects.
+// It first sends the newest change known, so that the peer catches up.
+func (n *Node) serve(c *secureConn) {
+	defer c.Close()
+	n.mu.Lock()
+	n.conns[c] = true
+	last := n.last
+	n.mu.Unlock()
+	defer func() {
+		n.mu.Lock()
+		delete(n.conns, c)
+		n.mu.Unlock()
+	}()
+	n.log(slog.LevelInfo, "peer connected", "peer", c.peer, "addr", c.conn.RemoteAddr())
+	if last.Time != 0 {
+		n.send(c, last)
+	}
+	for {
+		msg, err := c.read()
+		if err != nil {
+			n.log(slog.LevelInfo, "peer disconnected", "peer", c.peer, "error", err)
+			return
+		}
+		var u update
+		if err := json.Unmarshal(msg, &u); err != nil {
+			n.log(slog.LevelWarn, "invalid change", "peer", c.peer, "error", err)
+			return
+		}
+		n.received(c, u)
+	}
+}
+
+// copied sends a text copied on this device to the peers, unless
+// it is what the node copied itself, or it is filtered out.
+func (n *Node) copied(text string) {
+	types := clipboard.ContentTypes(n.opts.Clipboard)
+	fp := clipboard.FingerprintOf(text)
+	n.mu.Lock()
+	if fp == n.held {
+		n.mu.Unlock()
+		return
+	}
+	n.held = fp
+	if reason := n.filter(text, types); reason != "" {
+		n.mu.Unlock()
+		n.log(slog.LevelDebug, "not synced", "size", len(text), "reason", reason)
+		return
+	}
+	u := update{Time: time.Now().UnixNano(), Origin: n.self, Text: text, Types: types}
+	if u.Time <= n.last.Time {
+		u.Time = n.last.Time + 1
+	}
+	n.last = u
+	n.mu.Unlock()
+	n.log(slog.LevelDebug, "sending change", "size", len(text))
+	n.broadcast(u, nil)
+}
+
+// received copies a change sent by a peer, unless a newer
+// one is known or it is filtered out, and relays it to the
+// other peers. The clipboard is copied to without holding
+// n.mu, as it can take a while, e.g. waiting for its lock.
+func (n *Node) received(from *secureConn, u update) {
+	n.mu.Lock()
+	if u.Origin == n.self || !u.newer(n.last) {
+		n.mu.Unlock()
+		return
+	}
+	if reason := n.filter(u.Text, u.Types); reason != "" {
+		n.mu.Unlock()
+		n.log(slog.LevelDebug, "not synced", "peer", from.peer, "size", len(u.Text), "reason", reason)
+		return
+	}
+	last := n.last
+	n.last = u
+	n.mu.Unlock()
+
+	n.copyMu.Lock()
+	n.mu.Lock()
+	if !n.current(u) {
+		// A newer change arrived, and is copied instead.
+		n.mu.Unlock()
+		n.copyMu.Unlock()
+		return
+	}
+	held, fp := n.held, clipboard.FingerprintOf(u.Text)
+	n.held = fp
+	n.mu.Unlock()
+	err := n.opts.Clipboard.CopyText(u.Text)
+	n.copyMu.Unlock()
+	if err != nil {
+		n.mu.Lock()
+		// The clipboard still holds what it did, and
+		// the change can be received again.
+		if n.held == fp {
+			n.held = held
+		}
+		if n.current(u) {
+			n.last = last
+		}
+		n.mu.Unlock()
+		n.log(slog.LevelWarn, "copying change", "peer", from.peer, "error", err)
+		return
+	}
+	n.log(slog.LevelInfo, "synced", "peer", from.peer, "origin", u.Origin, "size", len(u.Text))
+	n.broadcast(u, from)
+}
+
+// current reports whether u is still the newest change known.
+// n.mu must be held.
+func (n *Node) current(u update) bool {
+	return n.last.Time == u.Time && n.last.Origin == u.Origin
+}
+
+// filter returns why a text is not synced, or "" if it is.
+func (n *Node) filter(text string, types []string) string {
+	if len(types) == 0 {
+		types = []string{"text/plain;charset=utf-8"}
+	}
+	switch {
+	case text == "":
+		return "empty"
+	case len(text) > n.opts.MaxSize:
+		return "too long"
+	case len(n.opts.Types) > 0 && !clipboard.MatchTypes(n.opts.Types, types):
+		return "type not synced"
+	}
+	return ""
+}
+
+// broadcast sends u to every peer but except.
+func (n *Node) broadcast(u update, except *secureConn) {
+	n.mu.Lock()
+	var conns []*secureConn
+	for c := range n.conns {
+		if c != except {
+			conns = append(conns, c)
+		}
+	}
+	n.mu.Unlock()
+	for _, c := range conns {
+		n.send(c, u)
+	}
+}
+
+// send sends u to the peer of c, closing c if it fails.
+func (n *Node) send(c *secureConn, u update) {
+	msg, err := json.Marshal(u)
+	if err == nil {
+		err = c.write(msg)
+	}
+	if err != nil {
+		n.log(slog.LevelWarn, "sending change", "peer", c.peer, "error", err)
+		c.Close()
+	}
+}
+
+// log logs a message, if a logger is set.
+func (n *Node) log(level slog.Level, msg string, args ...any) {
+	if n.opts.Logger != nil {
+		n.opts.Logger.Log(context.Background(), level, msg, args...)
+	}
+}
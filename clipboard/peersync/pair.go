@@ -0,0 +1,104 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package peersync
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+// codeAlphabet is Crockford's base32 alphabet, which leaves out
+// the letters that are easily mistaken for digits.
+const codeAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ErrPairing is returned by Pair when the devices
+// were not given the same pairing code.
+var ErrPairing = errors.New("pairing codes don't match")
+
+// NewPairingCode returns a random one-time code for Pair, such as
+// "7KQ2-M9XD-4T1B-ZP0C". It holds 80 random bits, so that the keys
+// exchanged with it can't be decrypted by trying every code.
+func NewPairingCode() (string, error) {
+	data := make([]byte, 16)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for i, c := range data {
+		if i > 0 && i%4 == 0 {
+			b.WriteByte('-')
+		}
+		b.WriteByte(codeAlphabet[c%32])
+	}
+	return b.String(), nil
+}
+
+// normalizeCode returns code as generated by NewPairingCode, from
+// what was typed: ignoring case, spaces and dashes, and reading
+// O as zero and I and L as one.
+func normalizeCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.NewReplacer("-", "", " ", "", "O", "0", "I", "1", "L", "1").Replace(code)
+	return code
+}
+
+// Pair exchanges the public keys of the devices at both ends of conn,
+// which must both call Pair with the same code, and returns the key of
+// the other device. The code must only be used once.
+//
+// The devices exchange ephemeral keys, and send their public keys
+// encrypted with keys derived from the exchange and the code: a device
+// which wasn't given the code, including one in the middle of the
+// connection, can neither read nor send them.
+func Pair(conn net.Conn, id *Identity, code string) (PublicKey, error) {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return PublicKey{}, err
+	}
+	hello := eph.PublicKey().Bytes()
+	peerHello, err := exchange(conn, hello)
+	if err != nil {
+		return PublicKey{}, err
+	}
+	peerEph, err := ecdh.X25519().NewPublicKey(peerHello)
+	if err != nil {
+		return PublicKey{}, err
+	}
+	secret, err := eph.ECDH(peerEph)
+	if err != nil {
+		return PublicKey{}, err
+	}
+	isA := bytes.Compare(hello, peerHello) < 0
+	info := append([]byte(protocol+" pairing"), hello...)
+	info = append(info, peerHello...)
+	if !isA {
+		info = append(append([]byte(protocol+" pairing"), peerHello...), hello...)
+	}
+	c, err := keyedConn(conn, isA, secret, []byte(normalizeCode(code)), info)
+	if err != nil {
+		return PublicKey{}, err
+	}
+	self := id.PublicKey()
+	received, err := exchangeSecure(c, self[:])
+	if errors.Is(err, errDecrypt) {
+		return PublicKey{}, ErrPairing
+	}
+	if err != nil {
+		return PublicKey{}, err
+	}
+	var peer PublicKey
+	if len(received) != len(peer) {
+		return PublicKey{}, ErrPairing
+	}
+	copy(peer[:], received)
+	return peer, nil
+}
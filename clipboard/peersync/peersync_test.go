@@ -0,0 +1,385 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package peersync
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"crypto/rand"
+	"errors"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard"
+	"github.com/tiagomelo/go-clipboard/clipboard/clipboardtest"
+)
+
+// testPollInterval is the interval the clipboards are polled at in tests.
+const testPollInterval = 5 * time.Millisecond
+
+func TestPair(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		typed         func(code string) string
+		expectedError error
+	}{
+		{
+			desc:  "same code",
+			typed: func(code string) string { return code },
+		},
+		{
+			desc:  "code typed loosely",
+			typed: func(code string) string { return toLowerSpaced(code) },
+		},
+		{
+			desc:          "other code",
+			typed:         func(code string) string { return "0000-0000-0000-0000" },
+			expectedError: ErrPairing,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			code, err := NewPairingCode()
+			require.NoError(t, err)
+			require.Regexp(t, `^[0-9A-Z]{4}(-[0-9A-Z]{4}){3}$`, code)
+			a, b := newIdentity(t), newIdentity(t)
+			connA, connB := connPair(t)
+
+			var keyB PublicKey
+			var errA error
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				keyB, errA = Pair(connA, a, code)
+			}()
+			keyA, err := Pair(connB, b, tc.typed(code))
+			wg.Wait()
+			if tc.expectedError != nil {
+				require.ErrorIs(t, err, tc.expectedError)
+				require.ErrorIs(t, errA, tc.expectedError)
+			} else {
+				require.NoError(t, err)
+				require.NoError(t, errA)
+				require.Equal(t, a.PublicKey(), keyA)
+				require.Equal(t, b.PublicKey(), keyB)
+			}
+		})
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	a, b := newIdentity(t), newIdentity(t)
+	connA, connB := connPair(t)
+	errc := make(chan error, 1)
+	go func() {
+		c, err := handshake(connA, a, func(k PublicKey) bool { return k == b.PublicKey() })
+		if err == nil {
+			err = c.write([]byte("héllo"))
+		}
+		errc <- err
+	}()
+	c, err := handshake(connB, b, func(k PublicKey) bool { return k == a.PublicKey() })
+	require.NoError(t, err)
+	require.Equal(t, a.PublicKey(), c.peer)
+	msg, err := c.read()
+	require.NoError(t, err)
+	require.Equal(t, "héllo", string(msg))
+	require.NoError(t, <-errc)
+
+	t.Run("untrusted", func(t *testing.T) {
+		connA, connB := connPair(t)
+		go handshake(connA, a, func(PublicKey) bool { return true })
+		_, err := handshake(connB, b, func(PublicKey) bool { return false })
+		require.ErrorIs(t, err, ErrUntrusted)
+	})
+
+	t.Run("impersonated", func(t *testing.T) {
+		// The impostor claims the key of a without holding it.
+		impostor := &Identity{key: newIdentity(t).key}
+		connA, connB := connPair(t)
+		errc := make(chan error, 1)
+		go func() {
+			_, err := handshakeAs(connA, impostor, a.PublicKey())
+			errc <- err
+		}()
+		_, err := handshake(connB, b, func(k PublicKey) bool { return k == a.PublicKey() })
+		require.ErrorIs(t, err, errDecrypt)
+		require.Error(t, <-errc)
+	})
+}
+
+func TestUpdate_newer(t *testing.T) {
+	a, b := PublicKey{1}, PublicKey{2}
+	testCases := []struct {
+		desc           string
+		u, other       update
+		expectedOutput bool
+	}{
+		{
+			desc:           "later",
+			u:              update{Time: 2, Origin: a},
+			other:          update{Time: 1, Origin: b},
+			expectedOutput: true,
+		},
+		{
+			desc:  "earlier",
+			u:     update{Time: 1, Origin: b},
+			other: update{Time: 2, Origin: a},
+		},
+		{
+			desc:           "same time, greater origin",
+			u:              update{Time: 1, Origin: b},
+			other:          update{Time: 1, Origin: a},
+			expectedOutput: true,
+		},
+		{
+			desc:  "same time, same origin",
+			u:     update{Time: 1, Origin: a},
+			other: update{Time: 1, Origin: a},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			require.Equal(t, tc.expectedOutput, tc.u.newer(tc.other))
+		})
+	}
+}
+
+func TestNode(t *testing.T) {
+	// b listens; a and c connect to it, and learn of each
+	// other's changes through it.
+	a, b, c := newIdentity(t), newIdentity(t), newIdentity(t)
+	cbA, cbB, cbC := clipboardtest.NewMemory(), clipboardtest.NewMemory(), clipboardtest.NewMemory()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addrB := l.Addr().String()
+	runNode(t, Options{Identity: b, Clipboard: cbB, Peers: []Peer{{Key: a.PublicKey()}, {Key: c.PublicKey()}}}, l)
+	runNode(t, Options{Identity: a, Clipboard: cbA, Peers: []Peer{{Key: b.PublicKey(), Addr: addrB}}}, nil)
+	runNode(t, Options{Identity: c, Clipboard: cbC, Peers: []Peer{{Key: b.PublicKey(), Addr: addrB}}, MaxSize: 10}, nil)
+
+	cbA.Set("copied on a")
+	requireText(t, "copied on a", cbB)
+	requireText(t, "", cbC) // too long for c
+	cbC.Set("copied")
+	requireText(t, "copied", cbA, cbB)
+	time.Sleep(20 * testPollInterval)
+	require.Equal(t, []string{"copied"}, cbA.Copies())
+	require.Equal(t, []string{"copied on a", "copied"}, cbB.Copies())
+	require.Empty(t, cbC.Copies())
+}
+
+func TestNode_types(t *testing.T) {
+	a, b := newIdentity(t), newIdentity(t)
+	cbA, cbB := clipboardtest.NewMemory(), clipboardtest.NewMemory()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	runNode(t, Options{Identity: b, Clipboard: cbB, Peers: []Peer{{Key: a.PublicKey()}}, Types: []string{"text/html"}}, l)
+	runNode(t, Options{Identity: a, Clipboard: cbA, Peers: []Peer{{Key: b.PublicKey(), Addr: l.Addr().String()}}}, nil)
+
+	cbA.Set("plain text")
+	cbB.Set("plain text copied on b")
+	time.Sleep(20 * testPollInterval)
+	cbA.Set("<b>html</b>", "text/html", "text/plain;charset=utf-8")
+	requireText(t, "<b>html</b>", cbB)
+	require.Empty(t, cbA.Copies())
+	require.Equal(t, []string{"<b>html</b>"}, cbB.Copies())
+}
+
+func TestNode_catchUp(t *testing.T) {
+	a, b := newIdentity(t), newIdentity(t)
+	cbA, cbB := clipboardtest.NewMemory(), clipboardtest.NewMemory()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	runNode(t, Options{Identity: a, Clipboard: cbA, Peers: []Peer{{Key: b.PublicKey()}}}, l)
+	cbA.Set("copied before b started")
+	time.Sleep(20 * testPollInterval)
+
+	runNode(t, Options{Identity: b, Clipboard: cbB, Peers: []Peer{{Key: a.PublicKey(), Addr: l.Addr().String()}}}, nil)
+	requireText(t, "copied before b started", cbB)
+}
+
+func TestNode_received(t *testing.T) {
+	cb := clipboardtest.NewMemory()
+	n, err := New(Options{Identity: newIdentity(t), Clipboard: cb})
+	require.NoError(t, err)
+	n.opts.Clipboard = lockCheckingClipboard{cb, t, n}
+	held := clipboard.FingerprintOf("copied here")
+	n.held = held
+	from := &secureConn{peer: newIdentity(t).PublicKey()}
+	u := update{Time: 1, Origin: from.peer, Text: "copied on a peer"}
+
+	cb.FailCopies(errors.New("clipboard locked"))
+	n.received(from, u)
+	require.Equal(t, held, n.held)
+	require.Equal(t, update{}, n.last)
+
+	cb.FailCopies(nil)
+	n.received(from, u)
+	require.Equal(t, []string{"copied on a peer"}, cb.Copies())
+	require.Equal(t, clipboard.FingerprintOf("copied on a peer"), n.held)
+	require.Equal(t, u, n.last)
+}
+
+func TestLoadIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peersync", "identity")
+	id, err := LoadIdentity(path)
+	require.NoError(t, err)
+	loaded, err := LoadIdentity(path)
+	require.NoError(t, err)
+	require.Equal(t, id.PublicKey(), loaded.PublicKey())
+
+	key, err := ParsePublicKey(id.PublicKey().String())
+	require.NoError(t, err)
+	require.Equal(t, id.PublicKey(), key)
+	_, err = ParsePublicKey("not a key")
+	require.EqualError(t, err, `invalid public key "not a key"`)
+}
+
+func TestSavePeer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "peers")
+	peers, err := LoadPeers(path)
+	require.NoError(t, err)
+	require.Empty(t, peers)
+
+	a, b := PublicKey{1}, PublicKey{2}
+	require.NoError(t, SavePeer(path, Peer{Key: a, Addr: "192.168.1.20:7219"}))
+	require.NoError(t, SavePeer(path, Peer{Key: b}))
+	require.NoError(t, SavePeer(path, Peer{Key: a, Addr: "192.168.1.21:7219"}))
+	peers, err = LoadPeers(path)
+	require.NoError(t, err)
+	require.Equal(t, []Peer{{Key: b}, {Key: a, Addr: "192.168.1.21:7219"}}, peers)
+}
+
+// newIdentity returns a new identity.
+func newIdentity(t *testing.T) *Identity {
+	id, err := NewIdentity()
+	require.NoError(t, err)
+	return id
+}
+
+// connPair returns both ends of a TCP connection on localhost.
+func connPair(t *testing.T) (net.Conn, net.Conn) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := l.Accept()
+		accepted <- conn
+	}()
+	a, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	b := <-accepted
+	require.NotNil(t, b)
+	t.Cleanup(func() {
+		a.Close()
+		b.Close()
+	})
+	return a, b
+}
+
+// handshakeAs runs the handshake as the holder of key would, but
+// with the private key of id, as an impostor claiming key would.
+func handshakeAs(conn net.Conn, id *Identity, key PublicKey) (*secureConn, error) {
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	hello := append(append([]byte{}, key[:]...), eph.PublicKey().Bytes()...)
+	peerHello, err := exchange(conn, hello)
+	if err != nil {
+		return nil, err
+	}
+	peerStatic, err := ecdh.X25519().NewPublicKey(peerHello[:32])
+	if err != nil {
+		return nil, err
+	}
+	peerEph, err := ecdh.X25519().NewPublicKey(peerHello[32:])
+	if err != nil {
+		return nil, err
+	}
+	ee, _ := eph.ECDH(peerEph)
+	se, _ := id.key.ECDH(peerEph)
+	es, _ := eph.ECDH(peerStatic)
+	isA := bytes.Compare(key[:], peerHello[:32]) < 0
+	transcript := append(append([]byte(protocol), hello...), peerHello...)
+	if !isA {
+		se, es = es, se
+		transcript = append(append([]byte(protocol), peerHello...), hello...)
+	}
+	c, err := keyedConn(conn, isA, append(append(ee, se...), es...), nil, transcript)
+	if err != nil {
+		return nil, err
+	}
+	_, err = exchangeSecure(c, []byte(protocol))
+	return c, err
+}
+
+// runNode runs a node until the test ends.
+func runNode(t *testing.T, opts Options, l net.Listener) {
+	opts.PollInterval = testPollInterval
+	opts.RedialInterval = testPollInterval
+	n, err := New(opts)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- n.Run(ctx, l) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+	time.Sleep(4 * testPollInterval)
+}
+
+// requireText requires the clipboards to hold text, eventually.
+func requireText(t *testing.T, text string, clipboards ...*clipboardtest.Memory) {
+	for _, c := range clipboards {
+		require.Eventually(t, func() bool {
+			got, _ := c.PasteText()
+			return got == text
+		}, 5*time.Second, testPollInterval)
+	}
+}
+
+// toLowerSpaced returns code as someone may type it.
+func toLowerSpaced(code string) string {
+	var b []byte
+	for i := 0; i < len(code); i++ {
+		switch c := code[i]; {
+		case c == '-':
+			b = append(b, ' ')
+		case c == '0':
+			b = append(b, 'o')
+		case c >= 'A' && c <= 'Z':
+			b = append(b, c+'a'-'A')
+		default:
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
+
+// lockCheckingClipboard fails the test if the mutex of its node
+// is held while copying, which can take a while.
+type lockCheckingClipboard struct {
+	*clipboardtest.Memory
+	t *testing.T
+	n *Node
+}
+
+func (c lockCheckingClipboard) CopyText(s string) error {
+	if !c.n.mu.TryLock() {
+		c.t.Error("copying while holding the mutex of the node")
+	} else {
+		c.n.mu.Unlock()
+	}
+	return c.Memory.CopyText(s)
+}
@@ -0,0 +1,245 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package peersync
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// handshakeTimeout is how long a handshake may take.
+	handshakeTimeout = 10 * time.Second
+
+	// writeTimeout is how long a peer may take to receive a message.
+	writeTimeout = 10 * time.Second
+
+	// maxFrame is the size of the largest message accepted.
+	maxFrame = 64 << 20
+
+	// protocol is mixed in every key derived, so that
+	// keys of other protocols are never reused.
+	protocol = "go-clipboard peersync v1"
+)
+
+// ErrUntrusted is returned when a device that isn't a paired peer connects.
+var ErrUntrusted = errors.New("device is not a paired peer")
+
+// errDecrypt is returned when a message was not encrypted
+// with the key expected, or was tampered with.
+var errDecrypt = errors.New("message could not be decrypted")
+
+// secureConn sends and receives messages encrypted
+// with the keys derived by a handshake.
+type secureConn struct {
+	conn net.Conn
+	peer PublicKey
+
+	wmu     sync.Mutex
+	send    cipher.AEAD
+	sendSeq uint64
+
+	recv    cipher.AEAD
+	recvSeq uint64
+}
+
+// newSecureConn returns a connection encrypting
+// with sendKey and decrypting with recvKey.
+func newSecureConn(conn net.Conn, sendKey, recvKey []byte) (*secureConn, error) {
+	send, err := newAEAD(sendKey)
+	if err != nil {
+		return nil, err
+	}
+	recv, err := newAEAD(recvKey)
+	if err != nil {
+		return nil, err
+	}
+	return &secureConn{conn: conn, send: send, recv: recv}, nil
+}
+
+// newAEAD returns AES-256-GCM with the given key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// nonce returns the nonce of the message with the given sequence number.
+// Every key is used by a single connection in a single direction, so
+// counting messages is enough for nonces never to repeat.
+func nonce(seq uint64) []byte {
+	n := make([]byte, 12)
+	binary.BigEndian.PutUint64(n[4:], seq)
+	return n
+}
+
+// write sends msg.
+func (c *secureConn) write(msg []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	sealed := c.send.Seal(nil, nonce(c.sendSeq), msg, nil)
+	c.sendSeq++
+	frame := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(sealed)), uint32(len(sealed)))
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err := c.conn.Write(append(frame, sealed...))
+	return err
+}
+
+// read receives a message. It must not be called concurrently.
+func (c *secureConn) read() ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(c.conn, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxFrame {
+		return nil, fmt.Errorf("message of %d bytes is too large", n)
+	}
+	sealed := make([]byte, n)
+	if _, err := io.ReadFull(c.conn, sealed); err != nil {
+		return nil, err
+	}
+	msg, err := c.recv.Open(nil, nonce(c.recvSeq), sealed, nil)
+	if err != nil {
+		return nil, errDecrypt
+	}
+	c.recvSeq++
+	return msg, nil
+}
+
+// Close closes the connection.
+func (c *secureConn) Close() error {
+	return c.conn.Close()
+}
+
+// handshake authenticates the devices at both ends of conn, refusing
+// peers for which trusted returns false, and returns the connection
+// encrypted with the keys derived from their exchanges.
+//
+// Each device sends its long-term key and an ephemeral key. The device
+// with the lowest long-term key is A, the other B, and the keys are
+// derived from the exchanges of the ephemeral keys, of A's long-term key
+// and B's ephemeral key, and of A's ephemeral key and B's long-term key:
+// only the holders of both long-term private keys can derive them, and
+// once the ephemeral keys are gone, no one can.
+func handshake(conn net.Conn, id *Identity, trusted func(PublicKey) bool) (*secureConn, error) {
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer conn.SetDeadline(time.Time{})
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	self := id.PublicKey()
+	hello := append(append([]byte{}, self[:]...), eph.PublicKey().Bytes()...)
+	peerHello, err := exchange(conn, hello)
+	if err != nil {
+		return nil, err
+	}
+	var peer PublicKey
+	copy(peer[:], peerHello)
+	switch {
+	case peer == self:
+		return nil, errors.New("connected to itself")
+	case !trusted(peer):
+		return nil, fmt.Errorf("%s: %w", peer, ErrUntrusted)
+	}
+	peerStatic, err := peer.ecdh()
+	if err != nil {
+		return nil, err
+	}
+	peerEph, err := ecdh.X25519().NewPublicKey(peerHello[32:])
+	if err != nil {
+		return nil, err
+	}
+
+	isA := bytes.Compare(self[:], peer[:]) < 0
+	ee, err1 := eph.ECDH(peerEph)
+	se, err2 := id.key.ECDH(peerEph)
+	es, err3 := eph.ECDH(peerStatic)
+	if err := errors.Join(err1, err2, err3); err != nil {
+		return nil, err
+	}
+	transcript := append([]byte(protocol), hello...)
+	transcript = append(transcript, peerHello...)
+	if !isA {
+		se, es = es, se
+		transcript = append(append([]byte(protocol), peerHello...), hello...)
+	}
+	c, err := keyedConn(conn, isA, append(append(ee, se...), es...), nil, transcript)
+	if err != nil {
+		return nil, err
+	}
+	c.peer = peer
+
+	// Each device proves it derived the same keys.
+	if _, err := exchangeSecure(c, []byte(protocol)); err != nil {
+		return nil, fmt.Errorf("%s: handshake failed: %w", peer, err)
+	}
+	return c, nil
+}
+
+// keyedConn returns conn encrypted with the keys derived from secret,
+// salt and info: the first one is used by A, the second one by B.
+func keyedConn(conn net.Conn, isA bool, secret, salt, info []byte) (*secureConn, error) {
+	keys := hkdf(secret, salt, info, 64)
+	if isA {
+		return newSecureConn(conn, keys[:32], keys[32:])
+	}
+	return newSecureConn(conn, keys[32:], keys[:32])
+}
+
+// exchange sends msg, and receives a message of the same size.
+func exchange(conn net.Conn, msg []byte) ([]byte, error) {
+	errc := make(chan error, 1)
+	go func() {
+		_, err := conn.Write(msg)
+		errc <- err
+	}()
+	received := make([]byte, len(msg))
+	_, err := io.ReadFull(conn, received)
+	return received, errors.Join(err, <-errc)
+}
+
+// exchangeSecure sends msg, and receives a message, over c.
+func exchangeSecure(c *secureConn, msg []byte) ([]byte, error) {
+	errc := make(chan error, 1)
+	go func() { errc <- c.write(msg) }()
+	received, err := c.read()
+	return received, errors.Join(err, <-errc)
+}
+
+// hkdf derives n bytes from secret with HKDF-SHA256 (RFC 5869).
+func hkdf(secret, salt, info []byte, n int) []byte {
+	if salt == nil {
+		salt = make([]byte, sha256.Size)
+	}
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+	var out, block []byte
+	for i := byte(1); len(out) < n; i++ {
+		expand := hmac.New(sha256.New, prk)
+		expand.Write(block)
+		expand.Write(info)
+		expand.Write([]byte{i})
+		block = expand.Sum(nil)
+		out = append(out, block...)
+	}
+	return out[:n]
+}
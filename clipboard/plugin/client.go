@@ -0,0 +1,465 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is how long a plugin may take to answer
+// a request when no other timeout is given.
+const DefaultTimeout = 5 * time.Second
+
+// DefaultCloseTimeout is how long a plugin may take to exit once its
+// standard input is closed, before it is killed, when no other timeout is
+// given. Binaries built with the race detector take a second to exit.
+const DefaultCloseTimeout = 3 * time.Second
+
+// errStopped is the error of requests sent to a plugin that stopped.
+var errStopped = errors.New("plugin stopped")
+
+// maxStderr is how much of the standard error of a
+// plugin is kept to explain why it stopped.
+const maxStderr = 4096
+
+// Options configures a Client.
+type Options struct {
+	// Timeout is how long the plugin may take to answer each request,
+	// including the handshake. A plugin that doesn't answer in time is
+	// stopped. Zero means DefaultTimeout.
+	Timeout time.Duration
+
+	// CloseTimeout is how long the plugin may take to exit once Close
+	// closed its standard input, before it is killed. Zero means
+	// DefaultCloseTimeout.
+	CloseTimeout time.Duration
+
+	// Command returns the command running the plugin at path, e.g. once
+	// a command.Runner checked it can be trusted. Nil means exec.Command.
+	Command func(path string) (*exec.Cmd, error)
+}
+
+// Client talks to a plugin. It is safe for concurrent use.
+type Client struct {
+	name         string
+	version      int
+	timeout      time.Duration
+	closeTimeout time.Duration
+	r            io.ReadCloser
+	w            io.WriteCloser
+	cmd          *exec.Cmd
+	stderr       *limitedBuffer
+
+	writeMu sync.Mutex
+	enc     *json.Encoder
+
+	mu      sync.Mutex
+	nextID  int64
+	pending map[int64]chan Response
+	watches map[int64]chan Event
+	err     error
+	done    chan struct{}
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Start runs the plugin executable at path and performs the handshake.
+func Start(path string, opts Options) (*Client, error) {
+	cmd := exec.Command(path)
+	if opts.Command != nil {
+		var err error
+		if cmd, err = opts.Command(path); err != nil {
+			return nil, err
+		}
+	}
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	r, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr := &limitedBuffer{max: maxStderr}
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c := newClient(r, w, opts)
+	c.name = strings.TrimPrefix(trimExecutableExt(filepath.Base(path)), Prefix)
+	c.cmd, c.stderr = cmd, stderr
+	return c, c.handshake()
+}
+
+// NewClient performs the handshake with a plugin that reads requests
+// from w and writes responses to r, e.g. one served in-process by Serve.
+func NewClient(r io.ReadCloser, w io.WriteCloser, opts Options) (*Client, error) {
+	c := newClient(r, w, opts)
+	return c, c.handshake()
+}
+
+// newClient returns a client that reads responses from r.
+func newClient(r io.ReadCloser, w io.WriteCloser, opts Options) *Client {
+	c := &Client{
+		timeout:      opts.Timeout,
+		closeTimeout: opts.CloseTimeout,
+		r:            r,
+		w:            w,
+		enc:          json.NewEncoder(w),
+		pending:      make(map[int64]chan Response),
+		watches:      make(map[int64]chan Event),
+		done:         make(chan struct{}),
+	}
+	if c.timeout <= 0 {
+		c.timeout = DefaultTimeout
+	}
+	if c.closeTimeout <= 0 {
+		c.closeTimeout = DefaultCloseTimeout
+	}
+	go c.readResponses()
+	return c
+}
+
+// Name returns the name the plugin gave in the handshake.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Version returns the protocol version picked by the plugin.
+func (c *Client) Version() int {
+	return c.version
+}
+
+// handshake negotiates the protocol version, stopping
+// the plugin if it doesn't speak a supported one.
+func (c *Client) handshake() error {
+	var result HandshakeResult
+	err := c.call(MethodHandshake, HandshakeParams{Versions: []int{Version}}, &result)
+	if err == nil && result.Version != Version {
+		err = fmt.Errorf("plugin picked version %d: %w", result.Version, ErrVersion)
+	}
+	if err != nil {
+		c.Close()
+		return err
+	}
+	c.name, c.version = result.Name, result.Version
+	return nil
+}
+
+// Capabilities asks the plugin what it can do.
+func (c *Client) Capabilities() (Capabilities, error) {
+	var caps Capabilities
+	err := c.call(MethodCapabilities, nil, &caps)
+	return caps, err
+}
+
+// Copy copies text of the given MIME type, or plain text
+// if typ is empty, to the given selection.
+func (c *Client) Copy(selection, typ, text string) error {
+	return c.call(MethodCopy, Params{Selection: selection, Type: typ, Text: text}, nil)
+}
+
+// CopyOneShot is like Copy, but the plugin serves the text for a single
+// paste and keeps it out of any history, if it reports the OneShot
+// capability.
+func (c *Client) CopyOneShot(selection, typ, text string) error {
+	return c.call(MethodCopy, Params{Selection: selection, Type: typ, Text: text, OneShot: true}, nil)
+}
+
+// CopyItems copies the given items together to the given selection,
+// if the plugin reports the Items capability.
+func (c *Client) CopyItems(selection string, items []Item) error {
+	return c.call(MethodCopy, Params{Selection: selection, Items: items}, nil)
+}
+
+// CopyItemsOneShot is like CopyItems, but the plugin serves the items
+// for a single paste and keeps them out of any history, if it reports
+// the OneShot capability.
+func (c *Client) CopyItemsOneShot(selection string, items []Item) error {
+	return c.call(MethodCopy, Params{Selection: selection, Items: items, OneShot: true}, nil)
+}
+
+// Paste returns the content of the given selection as the given
+// MIME type, or as plain text if typ is empty. Content that is not
+// valid UTF-8 is returned as is.
+func (c *Client) Paste(selection, typ string) (string, error) {
+	var result PasteResult
+	err := c.call(MethodPaste, Params{Selection: selection, Type: typ}, &result)
+	if result.Data != nil {
+		return string(result.Data), err
+	}
+	return result.Text, err
+}
+
+// Types returns the MIME types held by the given selection.
+func (c *Client) Types(selection string) ([]string, error) {
+	var result TypesResult
+	err := c.call(MethodTypes, Params{Selection: selection}, &result)
+	return result.Types, err
+}
+
+// Clear empties the given selection.
+func (c *Client) Clear(selection string) error {
+	return c.call(MethodClear, Params{Selection: selection}, nil)
+}
+
+// Watch returns a channel receiving an event every time the given
+// selection changes, until ctx is done or the plugin stops, when the
+// channel is closed. Events are dropped while the channel is full.
+func (c *Client) Watch(ctx context.Context, selection string) (<-chan Event, error) {
+	events := make(chan Event, 16)
+	id, resp, err := c.send(MethodWatch, Params{Selection: selection}, events)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.wait(MethodWatch, id, resp, nil); err != nil {
+		c.unwatch(id)
+		return nil, err
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.unwatch(id)
+		case <-c.done:
+		}
+	}()
+	return events, nil
+}
+
+// Close stops the plugin, closing its standard input and killing it if
+// it doesn't exit within the close timeout. A plugin killed once its
+// standard input was closed, which asks it to stop, is not an error.
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() {
+		c.stop(errStopped)
+		asked := c.w.Close() == nil
+		if c.cmd == nil {
+			c.closeErr = c.r.Close()
+			return
+		}
+		exited := make(chan error, 1)
+		go func() { exited <- c.cmd.Wait() }()
+		select {
+		case c.closeErr = <-exited:
+		case <-time.After(c.closeTimeout):
+			c.cmd.Process.Kill()
+			if c.closeErr = <-exited; asked {
+				c.closeErr = nil
+			}
+		}
+	})
+	return c.closeErr
+}
+
+// Call sends a request for a method other than the ones of the protocol,
+// served by a Caller, and decodes its result into result, if not nil.
+func (c *Client) Call(method string, params, result any) error {
+	return c.call(method, params, result)
+}
+
+// call sends a request and decodes its result into result, if not nil.
+func (c *Client) call(method string, params, result any) error {
+	id, resp, err := c.send(method, params, nil)
+	if err != nil {
+		return err
+	}
+	return c.wait(method, id, resp, result)
+}
+
+// send writes a request, registering where its response
+// and, for watch requests, its events are delivered.
+func (c *Client) send(method string, params any, events chan Event) (int64, chan Response, error) {
+	req := Request{Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return 0, nil, err
+		}
+		req.Params = data
+	}
+	resp := make(chan Response, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return 0, nil, c.errorf(method, c.failure())
+	}
+	c.nextID++
+	req.ID = c.nextID
+	c.pending[req.ID] = resp
+	if events != nil {
+		c.watches[req.ID] = events
+	}
+	c.mu.Unlock()
+
+	c.writeMu.Lock()
+	err := c.enc.Encode(req)
+	c.writeMu.Unlock()
+	if err != nil {
+		c.mu.Lock()
+		delete(c.pending, req.ID)
+		delete(c.watches, req.ID)
+		c.mu.Unlock()
+		if c.cmd != nil {
+			// The plugin stopped reading, most likely because it
+			// exited: wait for it, to tell why.
+			c.Close()
+			return 0, nil, c.errorf(method, c.failure())
+		}
+		return 0, nil, c.errorf(method, err)
+	}
+	return req.ID, resp, nil
+}
+
+// wait waits for the response to a request. A plugin that
+// doesn't answer in time is stopped.
+func (c *Client) wait(method string, id int64, resp chan Response, result any) error {
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case r, ok := <-resp:
+		if !ok {
+			// Wait for the plugin to exit, so that all it wrote
+			// to its standard error can explain why it stopped.
+			c.Close()
+			return c.errorf(method, c.failure())
+		}
+		if r.Error != nil {
+			return c.errorf(method, r.Error)
+		}
+		if result != nil && r.Result != nil {
+			if err := json.Unmarshal(r.Result, result); err != nil {
+				return c.errorf(method, err)
+			}
+		}
+		return nil
+	case <-timer.C:
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		c.Close()
+		return c.errorf(method, context.DeadlineExceeded)
+	}
+}
+
+// errorf returns err as the error of a request.
+func (c *Client) errorf(method string, err error) error {
+	if c.name == "" {
+		return fmt.Errorf("plugin: %s: %w", method, err)
+	}
+	return fmt.Errorf("plugin %s: %s: %w", c.name, method, err)
+}
+
+// unwatch stops delivering the events of a watch request.
+func (c *Client) unwatch(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if events, ok := c.watches[id]; ok {
+		delete(c.watches, id)
+		close(events)
+	}
+}
+
+// readResponses delivers every response to the request it answers,
+// until the plugin stops writing, and then fails the pending requests.
+func (c *Client) readResponses() {
+	dec := json.NewDecoder(c.r)
+	for {
+		var r Response
+		if err := dec.Decode(&r); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe) || errors.Is(err, os.ErrClosed) {
+				err = errStopped
+			}
+			c.stop(err)
+			return
+		}
+		c.mu.Lock()
+		if r.Event != nil {
+			if events, ok := c.watches[r.ID]; ok {
+				select {
+				case events <- *r.Event:
+				default:
+				}
+			}
+			c.mu.Unlock()
+			continue
+		}
+		resp, ok := c.pending[r.ID]
+		delete(c.pending, r.ID)
+		c.mu.Unlock()
+		if ok {
+			resp <- r
+		}
+	}
+}
+
+// stop records why the plugin can no longer be used
+// and fails the pending and watch requests.
+func (c *Client) stop(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	for id, resp := range c.pending {
+		delete(c.pending, id)
+		close(resp)
+	}
+	for id, events := range c.watches {
+		delete(c.watches, id)
+		close(events)
+	}
+	close(c.done)
+}
+
+// failure returns why the plugin can no longer be used,
+// including what it wrote to its standard error.
+func (c *Client) failure() error {
+	c.mu.Lock()
+	err := c.err
+	c.mu.Unlock()
+	if c.stderr != nil {
+		if msg := bytes.TrimSpace(c.stderr.Bytes()); len(msg) > 0 {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+	}
+	return err
+}
+
+// limitedBuffer keeps the first max bytes written to it.
+type limitedBuffer struct {
+	mu  sync.Mutex
+	max int
+	buf bytes.Buffer
+}
+
+// Write implements the io.Writer interface.
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if room := b.max - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+// Bytes returns what was kept.
+func (b *limitedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte{}, b.buf.Bytes()...)
+}
@@ -0,0 +1,366 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// pluginDirEnv makes the test binary serve a FileHandler keeping its
+// selections in the named directory, so that it can run as a plugin.
+// Set to "crash", it makes the plugin fail right away instead.
+const pluginDirEnv = "GO_CLIPBOARD_TEST_PLUGIN_DIR"
+
+// pluginLingerEnv makes the plugin keep running once its input is closed.
+const pluginLingerEnv = "GO_CLIPBOARD_TEST_PLUGIN_LINGER"
+
+func TestMain(m *testing.M) {
+	if dir := os.Getenv(pluginDirEnv); dir == "crash" {
+		os.Stderr.WriteString("cannot open display\n")
+		os.Exit(1)
+	} else if dir != "" {
+		if err := Serve(os.Stdin, os.Stdout, &FileHandler{Dir: dir, PollInterval: 10 * time.Millisecond}); err != nil {
+			os.Exit(1)
+		}
+		if os.Getenv(pluginLingerEnv) != "" {
+			time.Sleep(time.Minute)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestClient(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		run            func(c *Client) (any, error)
+		expectedOutput any
+		expectedError  error
+	}{
+		{
+			desc: "capabilities",
+			run: func(c *Client) (any, error) {
+				return c.Capabilities()
+			},
+			expectedOutput: Capabilities{Selections: []string{"clipboard", "primary"}, Types: true, Watch: true, Clear: true, Items: true},
+		},
+		{
+			desc: "round trip",
+			run: func(c *Client) (any, error) {
+				if err := c.Copy("clipboard", "", "héllo\x00\r\n"); err != nil {
+					return nil, err
+				}
+				return c.Paste("clipboard", "")
+			},
+			expectedOutput: "héllo\x00\r\n",
+		},
+		{
+			desc: "items copied together",
+			run: func(c *Client) (any, error) {
+				items := []Item{
+					{Type: "image/png", Data: []byte("\x89PNG\x00\xff")},
+					{Type: TextType, Data: []byte("a picture")},
+				}
+				if err := c.CopyItems("clipboard", items); err != nil {
+					return nil, err
+				}
+				return c.Types("clipboard")
+			},
+			expectedOutput: []string{"image/png", TextType},
+		},
+		{
+			desc: "content that is not valid UTF-8",
+			run: func(c *Client) (any, error) {
+				if err := c.CopyItems("clipboard", []Item{{Type: "image/png", Data: []byte("\x89PNG\x00\xff")}}); err != nil {
+					return nil, err
+				}
+				return c.Paste("clipboard", "image/png")
+			},
+			expectedOutput: "\x89PNG\x00\xff",
+		},
+		{
+			desc: "selections are independent",
+			run: func(c *Client) (any, error) {
+				if err := c.Copy("clipboard", "", "clipboard text"); err != nil {
+					return nil, err
+				}
+				if err := c.Copy("primary", "", "primary text"); err != nil {
+					return nil, err
+				}
+				return c.Paste("clipboard", "")
+			},
+			expectedOutput: "clipboard text",
+		},
+		{
+			desc: "types",
+			run: func(c *Client) (any, error) {
+				if err := c.Copy("clipboard", "text/html", "<b>hi</b>"); err != nil {
+					return nil, err
+				}
+				return c.Types("clipboard")
+			},
+			expectedOutput: []string{"text/html"},
+		},
+		{
+			desc: "clear",
+			run: func(c *Client) (any, error) {
+				if err := c.Copy("clipboard", "", "some text"); err != nil {
+					return nil, err
+				}
+				if err := c.Clear("clipboard"); err != nil {
+					return nil, err
+				}
+				return c.Types("clipboard")
+			},
+			expectedOutput: []string{},
+		},
+		{
+			desc: "paste of an empty selection",
+			run: func(c *Client) (any, error) {
+				return c.Paste("clipboard", "")
+			},
+			expectedError: errors.New("plugin file: paste: failed: nothing was copied as text/plain;charset=utf-8"),
+		},
+		{
+			desc: "unknown selection",
+			run: func(c *Client) (any, error) {
+				return nil, c.Copy("secondary", "", "some text")
+			},
+			expectedError: errors.New("plugin file: copy: unsupported: unknown selection secondary"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			c := serve(t, &FileHandler{Dir: t.TempDir()})
+			output, err := tc.run(c)
+			if err != nil {
+				if tc.expectedError == nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				require.Equal(t, tc.expectedError.Error(), err.Error())
+			} else {
+				if tc.expectedError != nil {
+					t.Fatalf("expected error to be %v, got nil", tc.expectedError)
+				}
+				require.Equal(t, tc.expectedOutput, output)
+			}
+		})
+	}
+}
+
+func TestClient_errorCodes(t *testing.T) {
+	c := serve(t, &FileHandler{Dir: t.TempDir()})
+	err := c.Copy("secondary", "", "some text")
+	require.ErrorIs(t, err, ErrUnsupported)
+	require.NotErrorIs(t, err, ErrUnavailable)
+}
+
+func TestClient_Watch(t *testing.T) {
+	c := serve(t, &FileHandler{Dir: t.TempDir(), PollInterval: 10 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := c.Watch(ctx, "clipboard")
+	require.NoError(t, err)
+	require.NoError(t, c.Copy("clipboard", "", "first"))
+	require.Equal(t, Event{Text: "first"}, receive(t, events))
+	require.NoError(t, c.Copy("clipboard", "", "second"))
+	require.Equal(t, Event{Text: "second"}, receive(t, events))
+	cancel()
+	for range events {
+	}
+}
+
+func TestClient_Call(t *testing.T) {
+	c := serve(t, &callerHandler{})
+	var result map[string]string
+	require.NoError(t, c.Call("echo", map[string]string{"text": "some text"}, &result))
+	require.Equal(t, map[string]string{"text": "some text"}, result)
+	require.ErrorIs(t, c.Call("unknown", nil, nil), ErrUnsupported)
+
+	c = serve(t, &FileHandler{Dir: t.TempDir()})
+	require.EqualError(t, c.Call("echo", nil, nil), "plugin file: echo: unsupported: unknown method echo")
+}
+
+func TestClient_handshake(t *testing.T) {
+	testCases := []struct {
+		desc          string
+		reply         string
+		expectedError error
+	}{
+		{
+			desc:  "supported version",
+			reply: `{"id":1,"result":{"version":1,"name":"fake"}}`,
+		},
+		{
+			desc:          "unsupported version",
+			reply:         `{"id":1,"error":{"code":"version","message":"only version 2 is supported"}}`,
+			expectedError: ErrVersion,
+		},
+		{
+			desc:          "version the client doesn't speak",
+			reply:         `{"id":1,"result":{"version":2,"name":"fake"}}`,
+			expectedError: ErrVersion,
+		},
+		{
+			desc:          "no answer",
+			expectedError: context.DeadlineExceeded,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			r, w := fakePlugin(t, func(req Request) string {
+				return tc.reply
+			})
+			c, err := NewClient(r, w, Options{Timeout: 50 * time.Millisecond})
+			if tc.expectedError != nil {
+				require.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "fake", c.Name())
+			require.Equal(t, 1, c.Version())
+		})
+	}
+}
+
+func TestClient_timeout(t *testing.T) {
+	r, w := fakePlugin(t, func(req Request) string {
+		if req.Method == MethodHandshake {
+			return `{"id":1,"result":{"version":1,"name":"slow"}}`
+		}
+		return ""
+	})
+	c, err := NewClient(r, w, Options{Timeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+	_, err = c.Paste("clipboard", "")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.EqualError(t, err, "plugin slow: paste: context deadline exceeded")
+	_, err = c.Paste("clipboard", "")
+	require.EqualError(t, err, "plugin slow: paste: plugin stopped")
+}
+
+func TestStart(t *testing.T) {
+	dir := t.TempDir()
+	path := pluginExecutable(t, t.TempDir(), "file")
+	t.Setenv(pluginDirEnv, dir)
+
+	c, err := Start(path, Options{})
+	require.NoError(t, err)
+	require.Equal(t, "file", c.Name())
+	require.NoError(t, c.Copy("clipboard", "", "some text"))
+	require.NoError(t, c.Close())
+
+	c, err = Start(path, Options{})
+	require.NoError(t, err)
+	defer c.Close()
+	text, err := c.Paste("clipboard", "")
+	require.NoError(t, err)
+	require.Equal(t, "some text", text)
+}
+
+func TestClient_Close_killed(t *testing.T) {
+	path := pluginExecutable(t, t.TempDir(), "file")
+	t.Setenv(pluginDirEnv, t.TempDir())
+	t.Setenv(pluginLingerEnv, "1")
+
+	c, err := Start(path, Options{CloseTimeout: 50 * time.Millisecond})
+	require.NoError(t, err)
+	start := time.Now()
+	require.NoError(t, c.Close())
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestStart_crash(t *testing.T) {
+	path := pluginExecutable(t, t.TempDir(), "file")
+	t.Setenv(pluginDirEnv, "crash")
+	_, err := Start(path, Options{})
+	require.EqualError(t, err, "plugin file: handshake: plugin stopped: cannot open display")
+}
+
+// callerHandler is a FileHandler also serving an "echo" method.
+type callerHandler struct {
+	FileHandler
+}
+
+// Call implements the Caller interface.
+func (h *callerHandler) Call(method string, params json.RawMessage) (any, error) {
+	if method != "echo" {
+		return nil, &Error{Code: CodeUnsupported, Message: "unknown method " + method}
+	}
+	return params, nil
+}
+
+// serve returns a client of h, served in-process.
+func serve(t *testing.T, h Handler) *Client {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	go func() {
+		Serve(reqR, respW, h)
+		respW.Close()
+	}()
+	c, err := NewClient(respR, reqW, Options{})
+	require.NoError(t, err)
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// fakePlugin returns the ends of a plugin that answers every request with
+// the line returned by reply, or doesn't answer if reply returns nothing.
+func fakePlugin(t *testing.T, reply func(req Request) string) (io.ReadCloser, io.WriteCloser) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+	go func() {
+		defer respW.Close()
+		scanner := bufio.NewScanner(reqR)
+		for scanner.Scan() {
+			var req Request
+			if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+				return
+			}
+			if line := reply(req); line != "" {
+				if _, err := io.WriteString(respW, line+"\n"); err != nil {
+					return
+				}
+			}
+		}
+	}()
+	t.Cleanup(func() { reqW.Close(); respR.Close() })
+	return respR, reqW
+}
+
+// pluginExecutable copies the test binary into dir as the plugin
+// with the given name, and returns its path.
+func pluginExecutable(t *testing.T, dir, name string) string {
+	data, err := os.ReadFile(os.Args[0])
+	require.NoError(t, err)
+	path := filepath.Join(dir, Prefix+name)
+	if runtime.GOOS == "windows" {
+		path += ".exe"
+	}
+	require.NoError(t, os.WriteFile(path, data, 0o755))
+	return path
+}
+
+// receive returns the next event, failing the test if none comes.
+func receive(t *testing.T, events <-chan Event) Event {
+	select {
+	case event, ok := <-events:
+		require.True(t, ok, "events were closed")
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+	return Event{}
+}
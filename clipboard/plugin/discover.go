@@ -0,0 +1,103 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package plugin
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Info describes a plugin executable.
+type Info struct {
+	Name string // Name of the plugin, e.g. "file" for go-clipboard-backend-file
+	Path string // Absolute path of the executable
+}
+
+// Discover returns the plugins found in the directories of the PATH,
+// sorted by name. Like a shell, the first executable found for a name
+// wins, so a plugin can be overridden by putting another one earlier
+// in the PATH.
+func Discover() []Info {
+	seen := make(map[string]bool)
+	var plugins []Info
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := pluginName(entry.Name())
+			if !ok || seen[name] || entry.IsDir() {
+				continue
+			}
+			path, err := exec.LookPath(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				continue
+			}
+			if abs, err := filepath.Abs(path); err == nil {
+				path = abs
+			}
+			seen[name] = true
+			plugins = append(plugins, Info{Name: name, Path: path})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].Name < plugins[j].Name
+	})
+	return plugins
+}
+
+// pluginName returns the name of the plugin in the
+// given file, if it is named like a plugin executable.
+func pluginName(file string) (string, bool) {
+	name, ok := strings.CutPrefix(trimExecutableExt(file), Prefix)
+	return name, ok && name != ""
+}
+
+// Registry holds the discovered plugins, looking them up again when the
+// PATH changes, so that plugins are registered just by being installed.
+// It is safe for concurrent use.
+type Registry struct {
+	mu      sync.Mutex
+	path    string
+	plugins []Info
+	found   bool
+}
+
+// Plugins returns the plugins found in the PATH, sorted by name.
+func (r *Registry) Plugins() []Info {
+	path := os.Getenv("PATH")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.found || r.path != path {
+		r.plugins, r.path, r.found = Discover(), path, true
+	}
+	return append([]Info{}, r.plugins...)
+}
+
+// Lookup returns the plugin with the given name, if it was found.
+func (r *Registry) Lookup(name string) (Info, bool) {
+	for _, info := range r.Plugins() {
+		if info.Name == name {
+			return info, true
+		}
+	}
+	return Info{}, false
+}
+
+// Invalidate drops the plugins that were found, e.g. because
+// one of them was removed, so that they are looked up again.
+func (r *Registry) Invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.found = false
+}
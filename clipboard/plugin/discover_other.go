@@ -0,0 +1,13 @@
+//go:build !windows
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package plugin
+
+// trimExecutableExt returns the file name as is,
+// since executables have no extension here.
+func trimExecutableExt(file string) string {
+	return file
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscover(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	fileA := pluginExecutable(t, first, "file")
+	pluginExecutable(t, second, "file")
+	memory := pluginExecutable(t, second, "memory")
+	require.NoError(t, os.WriteFile(filepath.Join(second, "xsel"), nil, 0o755))
+	require.NoError(t, os.Mkdir(filepath.Join(second, Prefix+"dir"), 0o755))
+	if runtime.GOOS != "windows" {
+		require.NoError(t, os.WriteFile(filepath.Join(second, Prefix+"notexec"), nil, 0o644))
+	}
+	t.Setenv("PATH", strings.Join([]string{first, filepath.Join(first, "missing"), second}, string(os.PathListSeparator)))
+
+	expected := []Info{
+		{Name: "file", Path: fileA},
+		{Name: "memory", Path: memory},
+	}
+	require.Equal(t, expected, Discover())
+
+	var r Registry
+	require.Equal(t, expected, r.Plugins())
+	info, ok := r.Lookup("memory")
+	require.True(t, ok)
+	require.Equal(t, memory, info.Path)
+	_, ok = r.Lookup("xsel")
+	require.False(t, ok)
+
+	t.Setenv("PATH", second)
+	info, ok = r.Lookup("file")
+	require.True(t, ok)
+	require.Equal(t, filepath.Join(second, filepath.Base(fileA)), info.Path)
+}
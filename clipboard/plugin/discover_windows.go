@@ -0,0 +1,18 @@
+//go:build windows
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package plugin
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// trimExecutableExt removes the extension of an executable file,
+// such as .exe, so that plugins are named the same on every platform.
+func trimExecutableExt(file string) string {
+	return strings.TrimSuffix(file, filepath.Ext(file))
+}
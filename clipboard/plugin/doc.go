@@ -0,0 +1,36 @@
+// Package plugin implements the protocol spoken by external clipboard
+// backends: executables named go-clipboard-backend-<name>, found in the
+// PATH, that can be written in any language.
+//
+// A plugin reads requests from its standard input and writes responses to
+// its standard output, one JSON object per line. Every request has an id,
+// a method and optional params:
+//
+//	{"id":1,"method":"handshake","params":{"versions":[1]}}
+//	{"id":1,"result":{"version":1,"name":"file"}}
+//
+// The first request is always a handshake listing the protocol versions the
+// client speaks; the plugin answers with the version it picked, or with an
+// error whose code is "version". The other methods are "capabilities",
+// "copy", "paste", "types", "clear" and "watch", whose params and results
+// are described by Params, Capabilities, PasteResult and TypesResult.
+// Plugins reporting the "items" capability accept copy requests holding
+// several items, copied together, and pasted content that is not valid
+// UTF-8 is sent base64-encoded as "data" instead of "text". Plugins
+// reporting the "one_shot" capability serve copy requests marked "one_shot"
+// for a single paste, and keep them out of any history they keep.
+// A failed request is answered with an error instead of a result:
+//
+//	{"id":2,"error":{"code":"unavailable","message":"no display"}}
+//
+// A "watch" request is answered with an empty result, followed by an event
+// holding the new text every time the selection changes:
+//
+//	{"id":3,"event":{"text":"copied elsewhere"}}
+//
+// Handlers implementing Caller can serve other methods, which clients
+// send with Client.Call.
+//
+// Requests may be answered in any order. A plugin exits when its standard
+// input is closed.
+package plugin
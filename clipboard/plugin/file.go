@@ -0,0 +1,333 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/internal/filelock"
+)
+
+// TextType is the MIME type of text copied or pasted without a type.
+const TextType = "text/plain;charset=utf-8"
+
+// defaultPollInterval is how often FileHandler checks for changes.
+const defaultPollInterval = 100 * time.Millisecond
+
+// lockTimeout is how long FileHandler waits for another
+// process copying to the same selection.
+const lockTimeout = 10 * time.Second
+
+// FileHandler is the reference plugin. It keeps the content of each
+// selection in a directory, one file per MIME type, so that every
+// process using the same directory shares the same clipboard.
+//
+// The directory of a selection is named in a file after the selection,
+// which a copy replaces at once, so that other processes never see a
+// selection half copied, or empty while it is being replaced. Copies to
+// the same selection are serialized with a lock file, where files can
+// be locked.
+type FileHandler struct {
+	Dir          string        // Directory holding the selections
+	PollInterval time.Duration // How often Watch checks for changes; zero means 100ms
+}
+
+// Name implements the Handler interface.
+func (h *FileHandler) Name() string {
+	return "file"
+}
+
+// Capabilities implements the Handler interface.
+func (h *FileHandler) Capabilities() Capabilities {
+	return Capabilities{
+		Selections: []string{"clipboard", "primary"},
+		Types:      true,
+		Watch:      true,
+		Clear:      true,
+		Items:      true,
+	}
+}
+
+// Copy implements the Handler interface.
+func (h *FileHandler) Copy(p Params) error {
+	name, err := h.selectionFile(p.Selection)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(h.Dir, 0o700); err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp(h.Dir, "."+filepath.Base(name)+"-")
+	if err != nil {
+		return err
+	}
+	items := p.Items
+	if len(items) == 0 {
+		items = []Item{{Type: p.Type, Data: []byte(p.Text)}}
+	}
+	for _, item := range items {
+		if err := os.WriteFile(filepath.Join(dir, typeFile(item.Type)), item.Data, 0o600); err != nil {
+			os.RemoveAll(dir)
+			return err
+		}
+	}
+	unlock, err := lock(name)
+	if err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	defer unlock()
+	old, _ := h.current(name)
+	if err := replaceFile(name, filepath.Base(dir)); err != nil {
+		os.RemoveAll(dir)
+		return err
+	}
+	if old != "" {
+		os.RemoveAll(old)
+	}
+	return nil
+}
+
+// lock locks the selection named in the file at name against copies by
+// other processes, until the returned function is called. Nothing is
+// locked on platforms that can't lock files.
+func lock(name string) (func(), error) {
+	f, err := os.OpenFile(filepath.Join(filepath.Dir(name), "."+filepath.Base(name)+".lock"), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		locked, err := filelock.TryLock(f)
+		if errors.Is(err, errors.ErrUnsupported) {
+			f.Close()
+			return func() {}, nil
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if locked {
+			return func() { f.Close() }, nil
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, &Error{Code: CodeFailed, Message: "the selection is locked by another process"}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// replaceFile replaces the file at path with one holding content. The
+// file may be open in other processes, which prevents replacing it on
+// some systems, so this is retried a few times.
+func replaceFile(path, content string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteString(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		for i := 0; i < 10; i++ {
+			if err = os.Rename(f.Name(), path); err == nil {
+				return nil
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	os.Remove(f.Name())
+	return err
+}
+
+// current returns the directory holding the selection named in the
+// file at name, or an error wrapping fs.ErrNotExist if there is none.
+func (h *FileHandler) current(name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(h.Dir, filepath.Base(string(data))), nil
+}
+
+// read calls f with the directory holding the given selection. If f
+// fails because another copy replaced the directory in the meantime,
+// it is called again with the new one.
+func (h *FileHandler) read(selection string, f func(dir string) error) error {
+	name, err := h.selectionFile(selection)
+	if err != nil {
+		return err
+	}
+	for i := 0; ; i++ {
+		dir, err := h.current(name)
+		if err != nil {
+			return err
+		}
+		err = f(dir)
+		if !errors.Is(err, fs.ErrNotExist) || i == 9 {
+			return err
+		}
+		if again, _ := h.current(name); again == dir {
+			return err
+		}
+	}
+}
+
+// Paste implements the Handler interface. Without a type, plain
+// text is pasted, in whatever charset it was copied.
+func (h *FileHandler) Paste(p Params) (string, error) {
+	var data []byte
+	err := h.read(p.Selection, func(dir string) error {
+		file := typeFile(p.Type)
+		if p.Type == "" {
+			types, err := readTypes(dir)
+			if err != nil {
+				return err
+			}
+			for _, typ := range types {
+				if typ == "text/plain" || strings.HasPrefix(typ, "text/plain;") {
+					file = typeFile(typ)
+					break
+				}
+			}
+		}
+		var err error
+		data, err = os.ReadFile(filepath.Join(dir, file))
+		return err
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", &Error{Code: CodeFailed, Message: "nothing was copied as " + typeName(p.Type)}
+	}
+	return string(data), err
+}
+
+// Types implements the Handler interface.
+func (h *FileHandler) Types(p Params) ([]string, error) {
+	var types []string
+	err := h.read(p.Selection, func(dir string) error {
+		var err error
+		types, err = readTypes(dir)
+		return err
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return []string{}, nil
+	}
+	return types, err
+}
+
+// readTypes returns the types of the content in dir.
+func readTypes(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	types := []string{}
+	for _, entry := range entries {
+		if typ, err := url.QueryUnescape(entry.Name()); err == nil {
+			types = append(types, typ)
+		}
+	}
+	sort.Strings(types)
+	return types, nil
+}
+
+// Clear implements the Handler interface.
+func (h *FileHandler) Clear(p Params) error {
+	name, err := h.selectionFile(p.Selection)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(h.Dir, 0o700); err != nil {
+		return err
+	}
+	unlock, err := lock(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	dir, err := h.current(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
+// Watch implements the Handler interface, polling the
+// selection for changes to its plain text.
+func (h *FileHandler) Watch(ctx context.Context, p Params) (<-chan Event, error) {
+	if _, err := h.selectionFile(p.Selection); err != nil {
+		return nil, err
+	}
+	interval := h.PollInterval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	last, _ := h.Paste(Params{Selection: p.Selection})
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			text, _ := h.Paste(Params{Selection: p.Selection})
+			if text == last {
+				continue
+			}
+			last = text
+			select {
+			case events <- Event{Text: text}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, nil
+}
+
+// selectionFile returns the file naming the directory
+// holding the given selection.
+func (h *FileHandler) selectionFile(selection string) (string, error) {
+	switch selection {
+	case "", "clipboard":
+		return filepath.Join(h.Dir, "clipboard"), nil
+	case "primary":
+		return filepath.Join(h.Dir, "primary"), nil
+	}
+	return "", &Error{Code: CodeUnsupported, Message: "unknown selection " + selection}
+}
+
+// typeFile returns the name of the file holding content of the given type.
+func typeFile(typ string) string {
+	return url.QueryEscape(typeName(typ))
+}
+
+// typeName returns the given MIME type, or TextType if it is empty.
+func typeName(typ string) string {
+	if typ == "" {
+		return TextType
+	}
+	return typ
+}
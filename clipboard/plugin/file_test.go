@@ -0,0 +1,51 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileHandler_concurrent(t *testing.T) {
+	dir := t.TempDir()
+	writer, reader := &FileHandler{Dir: dir}, &FileHandler{Dir: dir}
+	require.NoError(t, writer.Copy(Params{Text: "text 0"}))
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 1; i <= 100; i++ {
+				if err := writer.Copy(Params{Text: fmt.Sprintf("text %d", i)}); err != nil {
+					t.Errorf("copying: %v", err)
+				}
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		text, err := reader.Paste(Params{})
+		require.NoError(t, err)
+		require.Regexp(t, `^text \d+$`, text)
+		types, err := reader.Types(Params{})
+		require.NoError(t, err)
+		require.Equal(t, []string{TextType}, types)
+	}
+	wg.Wait()
+
+	require.NoError(t, reader.Clear(Params{}))
+	require.NoError(t, reader.Clear(Params{}))
+	_, err := reader.Paste(Params{})
+	require.EqualError(t, err, "failed: nothing was copied as text/plain;charset=utf-8")
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, ".clipboard.lock", entries[0].Name())
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Prefix is the prefix of the names of plugin executables.
+const Prefix = "go-clipboard-backend-"
+
+// Version is the version of the protocol implemented by this package.
+const Version = 1
+
+// Methods of the protocol.
+const (
+	MethodHandshake    = "handshake"
+	MethodCapabilities = "capabilities"
+	MethodCopy         = "copy"
+	MethodPaste        = "paste"
+	MethodTypes        = "types"
+	MethodClear        = "clear"
+	MethodWatch        = "watch"
+)
+
+// Error codes of the protocol.
+const (
+	CodeFailed      = "failed"      // The request failed
+	CodeUnsupported = "unsupported" // The method or its params are not supported
+	CodeUnavailable = "unavailable" // The clipboard can't be reached, e.g. there's no display
+	CodeVersion     = "version"     // None of the protocol versions is supported
+)
+
+var (
+	// ErrUnsupported matches plugin errors with code CodeUnsupported.
+	ErrUnsupported = errors.New("unsupported by plugin")
+	// ErrUnavailable matches plugin errors with code CodeUnavailable.
+	ErrUnavailable = errors.New("clipboard unavailable to plugin")
+	// ErrVersion matches plugin errors with code CodeVersion, and is
+	// returned when a plugin picks a version the client doesn't speak.
+	ErrVersion = errors.New("unsupported plugin protocol version")
+)
+
+// Request is sent by the client to the plugin.
+type Request struct {
+	ID     int64           `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+// Response is sent by the plugin to answer a request. It holds
+// either a result, an error, or, for watch requests, an event.
+type Response struct {
+	ID     int64           `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Event  *Event          `json:"event,omitempty"`
+	Error  *Error          `json:"error,omitempty"`
+}
+
+// Error is a failed request.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// Is makes errors.Is match ErrUnsupported,
+// ErrUnavailable and ErrVersion by code.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrUnsupported:
+		return e.Code == CodeUnsupported
+	case ErrUnavailable:
+		return e.Code == CodeUnavailable
+	case ErrVersion:
+		return e.Code == CodeVersion
+	}
+	return false
+}
+
+// HandshakeParams are the params of a handshake request.
+type HandshakeParams struct {
+	Versions []int `json:"versions"` // Protocol versions spoken by the client
+}
+
+// HandshakeResult is the result of a handshake request.
+type HandshakeResult struct {
+	Version int    `json:"version"` // Protocol version picked by the plugin
+	Name    string `json:"name"`    // Name of the plugin
+}
+
+// Capabilities is the result of a capabilities request.
+type Capabilities struct {
+	Selections []string `json:"selections"` // Selections that can be used, e.g. "clipboard"
+	Types      bool     `json:"types"`      // Specific MIME types can be copied and pasted
+	Watch      bool     `json:"watch"`      // Clipboard changes can be watched
+	Clear      bool     `json:"clear"`      // The clipboard can be emptied
+	Items      bool     `json:"items"`      // Several types can be copied together
+	OneShot    bool     `json:"one_shot"`   // A copy can be served for a single paste
+}
+
+// Params are the params of copy, paste, types, clear and watch requests.
+type Params struct {
+	Selection string `json:"selection"`          // "clipboard" or "primary"
+	Type      string `json:"type,omitempty"`     // MIME type, for copy and paste; empty means text
+	Text      string `json:"text,omitempty"`     // Text to copy
+	Items     []Item `json:"items,omitempty"`    // Content to copy together, instead of Text
+	OneShot   bool   `json:"one_shot,omitempty"` // Serve the copy for a single paste, and keep it out of any history
+}
+
+// Item is content of one type, copied together with others
+// when the plugin reports the Items capability.
+type Item struct {
+	Type string `json:"type"` // MIME type
+	Data []byte `json:"data"` // Content, encoded in base64
+}
+
+// PasteResult is the result of a paste request.
+type PasteResult struct {
+	Text string `json:"text"`
+	Data []byte `json:"data,omitempty"` // Content that is not valid UTF-8, instead of Text
+}
+
+// TypesResult is the result of a types request.
+type TypesResult struct {
+	Types []string `json:"types"` // MIME types held by the selection
+}
+
+// Event is sent for a watch request when the selection changes.
+type Event struct {
+	Text string `json:"text"`
+}
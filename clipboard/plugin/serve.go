@@ -0,0 +1,175 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"unicode/utf8"
+)
+
+// Handler implements the methods of a plugin. Errors of type *Error
+// are sent as is; other errors are sent with code CodeFailed.
+type Handler interface {
+	// Name returns the name of the plugin.
+	Name() string
+	// Capabilities returns what the plugin can do.
+	Capabilities() Capabilities
+	// Copy copies p.Text, of type p.Type, to p.Selection, or
+	// p.Items together if the plugin reports the Items capability.
+	Copy(p Params) error
+	// Paste returns the content of p.Selection as p.Type. Content
+	// that is not valid UTF-8 is sent as data.
+	Paste(p Params) (string, error)
+	// Types returns the MIME types held by p.Selection.
+	Types(p Params) ([]string, error)
+	// Clear empties p.Selection.
+	Clear(p Params) error
+	// Watch returns a channel receiving the text of p.Selection every
+	// time it changes after Watch returns. The channel must be closed
+	// once ctx is done.
+	Watch(ctx context.Context, p Params) (<-chan Event, error)
+}
+
+// Caller is implemented by handlers serving methods
+// other than the ones of the protocol.
+type Caller interface {
+	// Call answers a request for another method with its result, or
+	// with an *Error with code CodeUnsupported if it doesn't know it.
+	Call(method string, params json.RawMessage) (any, error)
+}
+
+// Serve reads requests from r and answers them with h, writing the
+// responses to w, until r is exhausted. Requests are handled one at a
+// time, except watch requests, which are handled until Serve returns.
+func Serve(r io.Reader, w io.Writer, h Handler) error {
+	s := &server{enc: json.NewEncoder(w), h: h}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		s.watches.Wait()
+	}()
+	dec := json.NewDecoder(r)
+	for {
+		var req Request
+		if err := dec.Decode(&req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := s.handle(ctx, req); err != nil {
+			return err
+		}
+	}
+}
+
+// server holds the state of Serve.
+type server struct {
+	h         Handler
+	mu        sync.Mutex
+	enc       *json.Encoder
+	handshook bool
+	watches   sync.WaitGroup
+}
+
+// handle answers a single request.
+func (s *server) handle(ctx context.Context, req Request) error {
+	if req.Method != MethodHandshake && !s.handshook {
+		return s.reply(req.ID, nil, &Error{Code: CodeFailed, Message: "handshake required"})
+	}
+	var p Params
+	if req.Method != MethodHandshake && len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return s.reply(req.ID, nil, &Error{Code: CodeFailed, Message: err.Error()})
+		}
+	}
+	switch req.Method {
+	case MethodHandshake:
+		var hp HandshakeParams
+		if err := json.Unmarshal(req.Params, &hp); err != nil {
+			return s.reply(req.ID, nil, &Error{Code: CodeFailed, Message: err.Error()})
+		}
+		for _, v := range hp.Versions {
+			if v == Version {
+				s.handshook = true
+				return s.reply(req.ID, HandshakeResult{Version: Version, Name: s.h.Name()}, nil)
+			}
+		}
+		return s.reply(req.ID, nil, &Error{Code: CodeVersion, Message: "only version 1 is supported"})
+	case MethodCapabilities:
+		return s.reply(req.ID, s.h.Capabilities(), nil)
+	case MethodCopy:
+		return s.reply(req.ID, struct{}{}, s.h.Copy(p))
+	case MethodPaste:
+		text, err := s.h.Paste(p)
+		if !utf8.ValidString(text) {
+			return s.reply(req.ID, PasteResult{Data: []byte(text)}, err)
+		}
+		return s.reply(req.ID, PasteResult{Text: text}, err)
+	case MethodTypes:
+		types, err := s.h.Types(p)
+		return s.reply(req.ID, TypesResult{Types: types}, err)
+	case MethodClear:
+		return s.reply(req.ID, struct{}{}, s.h.Clear(p))
+	case MethodWatch:
+		return s.watch(ctx, req.ID, p)
+	}
+	if caller, ok := s.h.(Caller); ok {
+		result, err := caller.Call(req.Method, req.Params)
+		return s.reply(req.ID, result, err)
+	}
+	return s.reply(req.ID, nil, &Error{Code: CodeUnsupported, Message: "unknown method " + req.Method})
+}
+
+// watch starts watching with the handler, acknowledges the
+// request and then sends the events until ctx is done.
+func (s *server) watch(ctx context.Context, id int64, p Params) error {
+	events, err := s.h.Watch(ctx, p)
+	if err != nil {
+		return s.reply(id, nil, err)
+	}
+	if err := s.reply(id, struct{}{}, nil); err != nil {
+		return err
+	}
+	s.watches.Add(1)
+	go func() {
+		defer s.watches.Done()
+		for event := range events {
+			event := event
+			s.send(Response{ID: id, Event: &event})
+		}
+	}()
+	return nil
+}
+
+// reply sends the response to a request.
+func (s *server) reply(id int64, result any, err error) error {
+	resp := Response{ID: id}
+	if err != nil {
+		var pluginErr *Error
+		if !errors.As(err, &pluginErr) {
+			pluginErr = &Error{Code: CodeFailed, Message: err.Error()}
+		}
+		resp.Error = pluginErr
+	} else if result != nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		resp.Result = data
+	}
+	return s.send(resp)
+}
+
+// send writes a response.
+func (s *server) send(resp Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.enc.Encode(resp)
+}
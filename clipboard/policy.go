@@ -0,0 +1,335 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/charset"
+)
+
+// Action is what a Policy decides to do with text about to be copied.
+type Action int
+
+const (
+	// Allow copies the text as is.
+	Allow Action = iota
+	// Deny refuses to copy the text, with a *PolicyError.
+	Deny
+	// Redact copies the text with its findings replaced by Redacted.
+	Redact
+	// Sensitive copies the text as sensitive: it is never logged and,
+	// on tools that support it, served for a single paste.
+	Sensitive
+)
+
+// String returns the name of the action.
+func (a Action) String() string {
+	switch a {
+	case Deny:
+		return "deny"
+	case Redact:
+		return "redact"
+	case Sensitive:
+		return "sensitive"
+	}
+	return "allow"
+}
+
+// Redacted replaces the findings of a Redact decision.
+const Redacted = "[REDACTED]"
+
+// Finding is a secret found in text.
+type Finding struct {
+	Kind  string // Kind of secret, e.g. "aws-key"
+	Start int    // Offset of the first byte of the secret
+	End   int    // Offset of the byte after the secret
+}
+
+// Decision is the outcome of a Policy.
+type Decision struct {
+	Action   Action
+	Findings []Finding // What the decision is based on, if anything
+	Reason   string    // Why the text is not allowed, if it isn't
+}
+
+// Policy inspects text before it is copied. Policies are set with
+// ClipboardOptions.Policies and run in order: a Deny decision stops
+// the copy, and text redacted by a policy is what the next one sees.
+type Policy func(text string) Decision
+
+// Detector finds secrets in text.
+type Detector func(text string) []Finding
+
+// ErrDenied is wrapped by the errors of copies denied by a policy.
+var ErrDenied = errors.New("denied by policy")
+
+// PolicyError is returned by CopyText when a policy denies the copy.
+type PolicyError struct {
+	Reason   string
+	Findings []Finding
+}
+
+// Error implements the error interface. It never includes the secrets.
+func (e *PolicyError) Error() string {
+	return "copy " + ErrDenied.Error() + ": " + e.Reason
+}
+
+// Unwrap returns ErrDenied.
+func (e *PolicyError) Unwrap() error {
+	return ErrDenied
+}
+
+// Detect returns a policy taking the given action when any of the
+// detectors finds a secret, and allowing the text otherwise. Without
+// detectors, DefaultDetectors are used.
+func Detect(action Action, detectors ...Detector) Policy {
+	if len(detectors) == 0 {
+		detectors = DefaultDetectors
+	}
+	return func(text string) Decision {
+		var findings []Finding
+		for _, detect := range detectors {
+			findings = append(findings, detect(text)...)
+		}
+		if len(findings) == 0 {
+			return Decision{Action: Allow}
+		}
+		sort.SliceStable(findings, func(i, j int) bool { return findings[i].Start < findings[j].Start })
+		return Decision{Action: action, Findings: findings, Reason: "found " + kinds(findings)}
+	}
+}
+
+// DefaultDetectors are the detectors used by Detect when none is given.
+var DefaultDetectors = []Detector{AWSKeys, PrivateKeys, JWTs, HighEntropy(24, 4)}
+
+var (
+	awsKeyRe     = regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b|(?i:aws_?secret_?access_?key)["']?\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}\b`)
+	privateKeyRe = regexp.MustCompile(`(?s)-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----.*?(?:-----END [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----|\z)`)
+	jwtRe        = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	tokenRe      = regexp.MustCompile(`[A-Za-z0-9+/=_-]+`)
+)
+
+// AWSKeys finds AWS access key IDs and secret access keys.
+func AWSKeys(text string) []Finding {
+	return find(awsKeyRe, "aws-key", text)
+}
+
+// PrivateKeys finds PEM and PGP private key blocks,
+// including truncated ones.
+func PrivateKeys(text string) []Finding {
+	return find(privateKeyRe, "private-key", text)
+}
+
+// JWTs finds JSON Web Tokens.
+func JWTs(text string) []Finding {
+	return find(jwtRe, "jwt", text)
+}
+
+// HighEntropy returns a detector finding tokens of at least minLength
+// base64 or hex characters whose Shannon entropy is at least minBits
+// per character, such as API keys and passwords. Random base64 tokens
+// have an entropy of about 5 bits per character, English words of less
+// than 4. Hex tokens can't exceed 4 bits per character, so they only
+// need two thirds of minBits, as base64 has 6, but must mix digits and
+// letters. Hex tokens as long as SHA-1 and SHA-2 digests, such as git
+// commit IDs and checksums, are not secrets and are left out.
+func HighEntropy(minLength int, minBits float64) Detector {
+	return func(text string) []Finding {
+		var findings []Finding
+		for _, loc := range tokenRe.FindAllStringIndex(text, -1) {
+			token := text[loc[0]:loc[1]]
+			if len(token) < minLength {
+				continue
+			}
+			threshold := minBits
+			if isHex(token) {
+				if digestLengths[len(token)] || !strings.ContainsAny(token, "0123456789") || !strings.ContainsAny(token, "abcdefABCDEF") {
+					continue
+				}
+				threshold = minBits * 4 / 6
+			}
+			if entropy(token) >= threshold {
+				findings = append(findings, Finding{Kind: "high-entropy", Start: loc[0], End: loc[1]})
+			}
+		}
+		return findings
+	}
+}
+
+// digestLengths are the lengths of SHA-1 and SHA-2 digests in hex.
+var digestLengths = map[int]bool{40: true, 56: true, 64: true, 96: true, 128: true}
+
+// isHex reports whether s is made of hex digits only.
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", rune(s[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// find returns the matches of re in text as findings of the given kind.
+func find(re *regexp.Regexp, kind, text string) []Finding {
+	var findings []Finding
+	for _, loc := range re.FindAllStringIndex(text, -1) {
+		findings = append(findings, Finding{Kind: kind, Start: loc[0], End: loc[1]})
+	}
+	return findings
+}
+
+// entropy returns the Shannon entropy of s, in bits per byte.
+func entropy(s string) float64 {
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	var bits float64
+	for _, n := range counts {
+		if n > 0 {
+			p := float64(n) / float64(len(s))
+			bits -= p * math.Log2(p)
+		}
+	}
+	return bits
+}
+
+// kinds returns the distinct kinds of the findings, joined with commas.
+func kinds(findings []Finding) string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, f := range findings {
+		if !seen[f.Kind] {
+			seen[f.Kind] = true
+			names = append(names, f.Kind)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// redact replaces the findings in text with Redacted.
+// Overlapping findings are replaced once.
+func redact(text string, findings []Finding) string {
+	sorted := append([]Finding{}, findings...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+	var b strings.Builder
+	pos := 0 // end of the text written so far
+	for i, f := range sorted {
+		if i > 0 && f.Start <= pos {
+			if f.End > pos {
+				pos = f.End
+			}
+			continue
+		}
+		b.WriteString(text[pos:f.Start])
+		b.WriteString(Redacted)
+		pos = f.End
+	}
+	b.WriteString(text[pos:])
+	return b.String()
+}
+
+// checkPolicies runs the policies on text about to be copied. It returns
+// the text to copy, whether it is sensitive, and a *PolicyError if a
+// policy denies the copy.
+func (c *clipboard) checkPolicies(text string) (string, bool, error) {
+	sensitive := false
+	for _, policy := range c.opts.Policies {
+		d := policy(text)
+		switch d.Action {
+		case Deny:
+			reason := d.Reason
+			if reason == "" {
+				reason = "not allowed"
+			}
+			return "", false, &PolicyError{Reason: reason, Findings: d.Findings}
+		case Redact:
+			text = redact(text, d.Findings)
+		case Sensitive:
+			sensitive = true
+		}
+	}
+	return text, sensitive, nil
+}
+
+// checkItems runs the policies on the items about to be copied that hold
+// text: the text/* targets and the X11 string targets, decoded from their
+// charset, and the items of other targets that are valid UTF-8, such as
+// application/json. It returns the items to copy, whether they are
+// sensitive, and a *PolicyError if a policy denies the copy, or redacts
+// text whose charset can't be encoded.
+func (c *clipboard) checkItems(items []Item) ([]Item, bool, error) {
+	if len(c.opts.Policies) == 0 {
+		return items, false, nil
+	}
+	checked := append([]Item{}, items...)
+	sensitive := false
+	for i, item := range checked {
+		textTarget := isTextTarget(item.Target)
+		var text string
+		switch {
+		case textTarget:
+			var err error
+			if text, err = charset.Decode(item.Data, item.Target, false); err != nil {
+				return nil, false, err
+			}
+		case utf8.Valid(item.Data):
+			text = string(item.Data)
+		default:
+			continue
+		}
+		allowed, s, err := c.checkPolicies(text)
+		if err != nil {
+			return nil, false, err
+		}
+		sensitive = sensitive || s
+		if allowed == text {
+			continue
+		}
+		if !textTarget {
+			checked[i].Data = []byte(allowed)
+			continue
+		}
+		if checked[i].Data, err = charset.Encode(allowed, charset.FromTarget(item.Target)); err != nil {
+			return nil, false, &PolicyError{Reason: "can't redact " + item.Target}
+		}
+	}
+	return checked, sensitive, nil
+}
+
+// stringTargets are the X11 targets holding text.
+var stringTargets = map[string]bool{"STRING": true, "UTF8_STRING": true, "TEXT": true, "COMPOUND_TEXT": true}
+
+// isTextTarget reports whether target holds text.
+func isTextTarget(target string) bool {
+	return stringTargets[target] || strings.HasPrefix(strings.ToLower(target), "text/")
+}
+
+// ErrReadOnly is returned when copying to or clearing
+// a clipboard created with ClipboardOptions.ReadOnly.
+var ErrReadOnly = errors.New("clipboard is read-only")
+
+// ErrWriteOnly is returned when pasting from or watching
+// a clipboard created with ClipboardOptions.WriteOnly.
+var ErrWriteOnly = errors.New("clipboard is write-only")
+
+// blocked returns an error if op is blocked by ClipboardOptions.ReadOnly
+// or ClipboardOptions.WriteOnly.
+func (c *clipboard) blocked(op string) error {
+	switch {
+	case c.opts.ReadOnly && (op == "copy" || op == "clear"):
+		return fmt.Errorf("%s: %w", op, ErrReadOnly)
+	case c.opts.WriteOnly && (op == "paste" || op == "types" || op == "watch"):
+		return fmt.Errorf("%s: %w", op, ErrWriteOnly)
+	}
+	return nil
+}
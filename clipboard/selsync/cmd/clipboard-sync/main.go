@@ -0,0 +1,79 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+// clipboard-sync keeps the primary selection and the clipboard in sync,
+// until it receives SIGINT or SIGTERM. Types are comma-separated, and may
+// end with "*" to match every type they prefix.
+//
+//	clipboard-sync [-direction both|primary-to-clipboard|clipboard-to-primary]
+//		[-debounce d] [-poll d] [-types t,...] [-exclude-types t,...]
+//		[-min-size n] [-max-size n] [-v]
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/selsync"
+)
+
+func main() {
+	direction := flag.String("direction", selsync.Both.String(), "direction the selections are synced in")
+	debounce := flag.Duration("debounce", selsync.DefaultDebounce, "how long a selection must stay unchanged before it is synced")
+	poll := flag.Duration("poll", selsync.DefaultPollInterval, "how often selections that can't be watched are checked")
+	types := flag.String("types", "", "comma-separated types a selection must be offered as to be synced")
+	excludeTypes := flag.String("exclude-types", "", "comma-separated types a selection must not be offered as to be synced")
+	minSize := flag.Int("min-size", 0, "size in bytes of the shortest text synced")
+	maxSize := flag.Int("max-size", 0, "size in bytes of the longest text synced, 0 for no limit")
+	verbose := flag.Bool("v", false, "log what is not synced, and why")
+	flag.Parse()
+
+	level := slog.LevelInfo
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	d, err := selsync.ParseDirection(*direction)
+	if err != nil {
+		logger.Error("invalid direction", "error", err)
+		os.Exit(2)
+	}
+	s, err := selsync.New(selsync.Options{
+		Direction:    d,
+		Debounce:     *debounce,
+		PollInterval: *poll,
+		Types:        split(*types),
+		ExcludeTypes: split(*excludeTypes),
+		MinSize:      *minSize,
+		MaxSize:      *maxSize,
+		Logger:       logger,
+	})
+	if err != nil {
+		logger.Error("starting", "error", err)
+		os.Exit(1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	logger.Info("syncing", "direction", d)
+	if err := s.Run(ctx); err != nil {
+		logger.Error("syncing", "error", err)
+		os.Exit(1)
+	}
+}
+
+// split returns the comma-separated values of s.
+func split(s string) []string {
+	var values []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
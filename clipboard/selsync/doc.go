@@ -0,0 +1,11 @@
+// Package selsync keeps the primary selection and the clipboard in sync,
+// like autocutsel: text selected with the mouse can be pasted with Ctrl+V,
+// and text copied with Ctrl+C can be pasted with the middle button.
+//
+// A Syncer watches the selections, or polls them when they can't be
+// watched, and copies what changed in one to the other once it stopped
+// changing for a while, so that a selection still being made with the
+// mouse isn't copied at every move. What it copies is recognized by its
+// hash when the other selection reports it back, so that syncing both
+// ways doesn't loop.
+package selsync
@@ -0,0 +1,290 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package selsync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/tiagomelo/go-clipboard/clipboard"
+)
+
+const (
+	// DefaultDebounce is how long a selection must stay
+	// unchanged before it is synced, by default.
+	DefaultDebounce = 300 * time.Millisecond
+
+	// DefaultPollInterval is how often selections that
+	// can't be watched are checked, by default.
+	DefaultPollInterval = 500 * time.Millisecond
+)
+
+// Direction is the direction the selections are synced in.
+type Direction int
+
+const (
+	// PrimaryToClipboard copies the primary selection to the clipboard.
+	PrimaryToClipboard Direction = iota
+	// ClipboardToPrimary copies the clipboard to the primary selection.
+	ClipboardToPrimary
+	// Both copies each selection to the other.
+	Both
+)
+
+// String returns the name of the direction, as parsed by ParseDirection.
+func (d Direction) String() string {
+	switch d {
+	case PrimaryToClipboard:
+		return "primary-to-clipboard"
+	case ClipboardToPrimary:
+		return "clipboard-to-primary"
+	case Both:
+		return "both"
+	}
+	return fmt.Sprintf("Direction(%d)", int(d))
+}
+
+// ParseDirection returns the direction with the given name:
+// "primary-to-clipboard", "clipboard-to-primary" or "both".
+func ParseDirection(name string) (Direction, error) {
+	for _, d := range []Direction{PrimaryToClipboard, ClipboardToPrimary, Both} {
+		if d.String() == name {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown direction %q", name)
+}
+
+// Options configures a Syncer.
+type Options struct {
+	Direction Direction
+
+	// Debounce is how long a selection must stay unchanged before it is
+	// synced. Zero means DefaultDebounce.
+	Debounce time.Duration
+
+	// PollInterval is how often selections that can't be watched, e.g.
+	// with xsel or xclip, are checked. Zero means DefaultPollInterval.
+	PollInterval time.Duration
+
+	// Types are the MIME types or targets a selection must be offered as
+	// to be synced, e.g. "text/plain" or "text/*"; ExcludeTypes are those
+	// it must not be offered as, e.g. "x-kde-passwordManagerHint".
+	// Selections whose types can't be listed are considered plain text.
+	Types        []string
+	ExcludeTypes []string
+
+	// MinSize and MaxSize are the sizes, in bytes, of the shortest and
+	// longest texts synced. Zero means no limit. Empty text, e.g. when a
+	// selection is cleared, is never synced.
+	MinSize int
+	MaxSize int
+
+	// Clipboard configures the clipboards of the selections. Primary is
+	// set by New for the primary selection.
+	Clipboard clipboard.ClipboardOptions
+
+	// Clipboards are the clipboards of the selections,
+	// used instead of creating them, e.g. in tests.
+	Clipboards map[clipboard.Selection]clipboard.Clipboard
+
+	// Logger logs what is synced, and what is not.
+	// Nil means nothing is logged.
+	Logger *slog.Logger
+}
+
+// Syncer syncs the primary selection and the clipboard.
+type Syncer struct {
+	opts       Options
+	clipboards map[clipboard.Selection]clipboard.Clipboard
+	known      map[clipboard.Selection]clipboard.Fingerprint
+}
+
+// change is a new text held by a selection.
+type change struct {
+	from clipboard.Selection
+	text string
+}
+
+// settled reports that the given generation of changes of
+// a selection was not followed by another one in time.
+type settled struct {
+	from       clipboard.Selection
+	generation int
+}
+
+// New returns a Syncer configured with opts.
+func New(opts Options) (*Syncer, error) {
+	if opts.Debounce <= 0 {
+		opts.Debounce = DefaultDebounce
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultPollInterval
+	}
+	s := &Syncer{
+		opts:       opts,
+		clipboards: make(map[clipboard.Selection]clipboard.Clipboard),
+		known:      make(map[clipboard.Selection]clipboard.Fingerprint),
+	}
+	for _, selection := range []clipboard.Selection{clipboard.SelectionClipboard, clipboard.SelectionPrimary} {
+		if cb, ok := opts.Clipboards[selection]; ok {
+			s.clipboards[selection] = cb
+			continue
+		}
+		cbOpts := opts.Clipboard
+		cbOpts.Primary = selection == clipboard.SelectionPrimary
+		cb, err := clipboard.New(cbOpts)
+		if err != nil {
+			return nil, err
+		}
+		s.clipboards[selection] = cb
+	}
+	return s, nil
+}
+
+// Run syncs the selections until ctx is done, or a selection
+// can no longer be watched. It must not be called concurrently.
+func (s *Syncer) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for selection, cb := range s.clipboards {
+		if text, err := cb.PasteText(); err == nil {
+			s.known[selection] = clipboard.FingerprintOf(text)
+		}
+	}
+
+	changes := make(chan change)
+	stopped := make(chan error, 2)
+	for _, from := range s.sources() {
+		texts, err := clipboard.WatchOrPoll(ctx, s.clipboards[from], s.opts.PollInterval)
+		if err != nil {
+			return fmt.Errorf("watching %s: %w", from, err)
+		}
+		from := from
+		go func() {
+			for text := range texts {
+				select {
+				case changes <- change{from: from, text: text}:
+				case <-ctx.Done():
+					return
+				}
+			}
+			stopped <- fmt.Errorf("watching %s stopped", from)
+		}()
+	}
+
+	// A change is synced once no other change of its
+	// selection followed it for the debounce duration.
+	pending := make(map[clipboard.Selection]change)
+	generations := make(map[clipboard.Selection]int)
+	timers := make(map[clipboard.Selection]*time.Timer)
+	settledChanges := make(chan settled)
+	defer func() {
+		for _, t := range timers {
+			t.Stop()
+		}
+	}()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-stopped:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		case c := <-changes:
+			fp := clipboard.FingerprintOf(c.text)
+			if fp == s.known[c.from] {
+				continue
+			}
+			s.known[c.from] = fp
+			pending[c.from] = c
+			generations[c.from]++
+			if t := timers[c.from]; t != nil {
+				t.Stop()
+			}
+			generation := generations[c.from]
+			timers[c.from] = time.AfterFunc(s.opts.Debounce, func() {
+				select {
+				case settledChanges <- settled{from: c.from, generation: generation}:
+				case <-ctx.Done():
+				}
+			})
+		case st := <-settledChanges:
+			if st.generation != generations[st.from] {
+				continue
+			}
+			s.sync(pending[st.from])
+			delete(pending, st.from)
+		}
+	}
+}
+
+// sources returns the selections copied to the other one.
+func (s *Syncer) sources() []clipboard.Selection {
+	switch s.opts.Direction {
+	case PrimaryToClipboard:
+		return []clipboard.Selection{clipboard.SelectionPrimary}
+	case ClipboardToPrimary:
+		return []clipboard.Selection{clipboard.SelectionClipboard}
+	}
+	return []clipboard.Selection{clipboard.SelectionPrimary, clipboard.SelectionClipboard}
+}
+
+// sync copies a change to the other selection, unless
+// it is filtered out or the other selection holds it.
+func (s *Syncer) sync(c change) {
+	to := clipboard.SelectionPrimary
+	if c.from == clipboard.SelectionPrimary {
+		to = clipboard.SelectionClipboard
+	}
+	if reason := s.filter(c); reason != "" {
+		s.log(slog.LevelDebug, "not synced", "from", c.from, "size", len(c.text), "reason", reason)
+		return
+	}
+	fp := clipboard.FingerprintOf(c.text)
+	if s.known[to] == fp {
+		return
+	}
+	if err := s.clipboards[to].CopyText(c.text); err != nil {
+		s.log(slog.LevelWarn, "sync failed", "from", c.from, "to", to, "error", err)
+		return
+	}
+	s.known[to] = fp
+	s.log(slog.LevelInfo, "synced", "from", c.from, "to", to, "size", len(c.text))
+}
+
+// filter returns why a change is not synced, or "" if it is.
+func (s *Syncer) filter(c change) string {
+	switch {
+	case c.text == "":
+		return "empty"
+	case s.opts.MinSize > 0 && len(c.text) < s.opts.MinSize:
+		return "too short"
+	case s.opts.MaxSize > 0 && len(c.text) > s.opts.MaxSize:
+		return "too long"
+	}
+	if len(s.opts.Types) == 0 && len(s.opts.ExcludeTypes) == 0 {
+		return ""
+	}
+	types := clipboard.ContentTypes(s.clipboards[c.from])
+	if len(s.opts.Types) > 0 && !clipboard.MatchTypes(s.opts.Types, types) {
+		return "type not synced"
+	}
+	if clipboard.MatchTypes(s.opts.ExcludeTypes, types) {
+		return "type excluded"
+	}
+	return ""
+}
+
+// log logs a message, if a logger is set.
+func (s *Syncer) log(level slog.Level, msg string, args ...any) {
+	if s.opts.Logger != nil {
+		s.opts.Logger.Log(context.Background(), level, msg, args...)
+	}
+}
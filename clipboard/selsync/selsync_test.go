@@ -0,0 +1,174 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package selsync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard"
+	"github.com/tiagomelo/go-clipboard/clipboard/clipboardtest"
+)
+
+// testDebounce is the debounce duration used by the tests.
+const testDebounce = 20 * time.Millisecond
+
+func TestSyncer(t *testing.T) {
+	testCases := []struct {
+		desc                string
+		opts                Options
+		types               []string
+		selected, copied    string
+		expectedInClipboard []string
+		expectedInPrimary   []string
+	}{
+		{
+			desc:                "primary to clipboard",
+			opts:                Options{Direction: PrimaryToClipboard},
+			selected:            "selected",
+			copied:              "copied",
+			expectedInClipboard: []string{"selected"},
+		},
+		{
+			desc:              "clipboard to primary",
+			opts:              Options{Direction: ClipboardToPrimary},
+			selected:          "selected",
+			copied:            "copied",
+			expectedInPrimary: []string{"copied"},
+		},
+		{
+			desc:                "both",
+			opts:                Options{Direction: Both},
+			selected:            "selected",
+			copied:              "copied",
+			expectedInClipboard: []string{"selected"},
+			expectedInPrimary:   []string{"copied"},
+		},
+		{
+			desc:     "too short",
+			opts:     Options{Direction: Both, MinSize: 2},
+			selected: "s",
+			copied:   "c",
+		},
+		{
+			desc:              "too long",
+			opts:              Options{Direction: Both, MaxSize: 6},
+			selected:          "selected",
+			copied:            "copied",
+			expectedInPrimary: []string{"copied"},
+		},
+		{
+			desc:     "type not synced",
+			opts:     Options{Direction: Both, Types: []string{"text/html"}},
+			types:    []string{"text/plain;charset=utf-8", "UTF8_STRING"},
+			selected: "selected",
+			copied:   "copied",
+		},
+		{
+			desc:                "type synced",
+			opts:                Options{Direction: Both, Types: []string{"text/plain"}},
+			types:               []string{"text/plain;charset=utf-8", "UTF8_STRING"},
+			selected:            "selected",
+			copied:              "copied",
+			expectedInClipboard: []string{"selected"},
+			expectedInPrimary:   []string{"copied"},
+		},
+		{
+			desc:     "type excluded",
+			opts:     Options{Direction: Both, ExcludeTypes: []string{"x-kde-*"}},
+			types:    []string{"text/plain;charset=utf-8", "x-kde-passwordManagerHint"},
+			selected: "selected",
+			copied:   "copied",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			primary, clip := clipboardtest.NewMemory(tc.types...), clipboardtest.NewMemory(tc.types...)
+			primary.Set("old selection")
+			run(t, primary, clip, tc.opts)
+
+			primary.Set(tc.selected)
+			time.Sleep(5 * testDebounce)
+			clip.Set(tc.copied)
+			time.Sleep(5 * testDebounce)
+			require.Equal(t, tc.expectedInClipboard, clip.Copies())
+			require.Equal(t, tc.expectedInPrimary, primary.Copies())
+		})
+	}
+}
+
+func TestSyncer_debounce(t *testing.T) {
+	primary, clip := clipboardtest.NewMemory(), clipboardtest.NewMemory()
+	run(t, primary, clip, Options{Direction: PrimaryToClipboard})
+
+	// A selection made with the mouse grows until the button is released.
+	for _, text := range []string{"s", "se", "sel", "sele", "selected"} {
+		primary.Set(text)
+		time.Sleep(testDebounce / 4)
+	}
+	require.Eventually(t, func() bool { return len(clip.Copies()) > 0 }, 5*time.Second, testDebounce)
+	time.Sleep(5 * testDebounce)
+	require.Equal(t, []string{"selected"}, clip.Copies())
+}
+
+func TestSyncer_polling(t *testing.T) {
+	primary, clip := clipboardtest.NewMemory(), clipboardtest.NewMemory()
+	run(t, struct{ clipboard.Clipboard }{primary}, struct{ clipboard.Clipboard }{clip}, Options{Direction: Both})
+
+	primary.Set("selected")
+	require.Eventually(t, func() bool { return len(clip.Copies()) == 1 }, 5*time.Second, testDebounce)
+	clip.Set("copied")
+	require.Eventually(t, func() bool { return len(primary.Copies()) == 1 }, 5*time.Second, testDebounce)
+	time.Sleep(5 * testDebounce)
+	require.Equal(t, []string{"selected"}, clip.Copies())
+	require.Equal(t, []string{"copied"}, primary.Copies())
+}
+
+func TestSyncer_watchStopped(t *testing.T) {
+	primary, clip := clipboardtest.NewMemory(), clipboardtest.NewMemory()
+	s, err := New(Options{Clipboards: map[clipboard.Selection]clipboard.Clipboard{
+		clipboard.SelectionPrimary:   primary,
+		clipboard.SelectionClipboard: clip,
+	}})
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() { done <- s.Run(context.Background()) }()
+	require.Eventually(t, func() bool { return primary.Watched() }, 5*time.Second, testDebounce)
+	primary.StopWatchers()
+	require.EqualError(t, <-done, "watching primary stopped")
+}
+
+func TestParseDirection(t *testing.T) {
+	for _, d := range []Direction{PrimaryToClipboard, ClipboardToPrimary, Both} {
+		parsed, err := ParseDirection(d.String())
+		require.NoError(t, err)
+		require.Equal(t, d, parsed)
+	}
+	_, err := ParseDirection("sideways")
+	require.EqualError(t, err, `unknown direction "sideways"`)
+}
+
+// run runs a syncer of the given clipboards until the test ends.
+func run(t *testing.T, primary, clip clipboard.Clipboard, opts Options) {
+	opts.Debounce = testDebounce
+	opts.PollInterval = testDebounce / 4
+	opts.Clipboards = map[clipboard.Selection]clipboard.Clipboard{
+		clipboard.SelectionPrimary:   primary,
+		clipboard.SelectionClipboard: clip,
+	}
+	s, err := New(opts)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		require.NoError(t, <-done)
+	})
+	// Let the syncer read the selections before they change.
+	time.Sleep(testDebounce)
+}
@@ -0,0 +1,289 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/charset"
+)
+
+// Item is the content of a selection as one target.
+type Item struct {
+	Target string // Target or MIME type, e.g. "image/png"
+	Data   []byte // Content, as is
+}
+
+// Snapshot is the content of every selection at some point in time.
+// Each selection holds an item for every target it was offered as,
+// and none if it was empty.
+type Snapshot struct {
+	Time       time.Time
+	Selections map[Selection][]Item
+}
+
+// Snapshotter is implemented by clipboards that can save the content of
+// every selection and put it back, e.g. to use the clipboard temporarily
+// without losing what the user copied.
+type Snapshotter interface {
+	// Snapshot returns the content of every selection that can be used.
+	Snapshot() (*Snapshot, error)
+
+	// Restore puts the content of every selection in s back.
+	Restore(s *Snapshot) error
+}
+
+// metaTargets are the X11 targets that describe a selection
+// instead of holding its content.
+var metaTargets = map[string]bool{
+	"TARGETS":          true,
+	"TIMESTAMP":        true,
+	"MULTIPLE":         true,
+	"SAVE_TARGETS":     true,
+	"DELETE":           true,
+	"INSERT_PROPERTY":  true,
+	"INSERT_SELECTION": true,
+}
+
+// textTargets are the targets holding text, in the order
+// they are preferred when only text can be restored.
+var textTargets = []string{"text/plain;charset=utf-8", "UTF8_STRING", "text/plain", "STRING", "TEXT"}
+
+// WithTemporary runs fn, and then puts back what every selection of c
+// held before, even if fn panics. c must implement Snapshotter. fn is
+// not run if what the selections hold couldn't be put back.
+func WithTemporary(c Clipboard, fn func() error) (err error) {
+	s, ok := c.(Snapshotter)
+	if !ok {
+		return fmt.Errorf("snapshot: %w", errors.ErrUnsupported)
+	}
+	snapshot, err := s.Snapshot()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if restoreErr := s.Restore(snapshot); restoreErr != nil {
+			err = errors.Join(err, restoreErr)
+		}
+	}()
+	return fn()
+}
+
+// Snapshot implements the Snapshotter interface. Every target a selection
+// is offered as is pasted as is, when the backend can list them; otherwise
+// its text is. Targets that can't be pasted, or hold nothing, are left
+// out. It fails with an error wrapping errors.ErrUnsupported if a
+// selection holds targets other than plain text, such as an image, and
+// the backend can't copy several targets together to restore them.
+func (c *clipboard) Snapshot() (*Snapshot, error) {
+	if _, err := c.backends(); err != nil {
+		return nil, err
+	}
+	s := &Snapshot{Time: time.Now(), Selections: make(map[Selection][]Item)}
+	for _, selection := range c.Capabilities().Selections {
+		sc, err := c.selectionClipboard(selection)
+		if err != nil {
+			return nil, err
+		}
+		items, err := sc.snapshot()
+		if err == nil {
+			err = sc.restorable(items)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("snapshot of %s: %w", selection, err)
+		}
+		s.Selections[selection] = items
+	}
+	return s, nil
+}
+
+// Restore implements the Snapshotter interface. The targets of a
+// selection are copied together by backends implementing ItemCopier;
+// the other backends, such as the clipboard tools, can only offer one
+// target at a time, so they copy the text of the selection, and fail
+// if it held anything but plain text. A selection that was empty is
+// cleared.
+func (c *clipboard) Restore(s *Snapshot) error {
+	var errs []error
+	for _, selection := range []Selection{SelectionClipboard, SelectionPrimary} {
+		items, ok := s.Selections[selection]
+		if !ok {
+			continue
+		}
+		sc, err := c.selectionClipboard(selection)
+		if err == nil {
+			err = sc.restore(items)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("restoring %s: %w", selection, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// selectionClipboard returns a clipboard using the given selection,
+// and the default target, with the other options of c: c itself if
+// it does, or another clipboard created on first use.
+func (c *clipboard) selectionClipboard(selection Selection) (*clipboard, error) {
+	if Selection(c.selection()) == selection && c.opts.Target == "" {
+		return c, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if sc, ok := c.siblings[selection]; ok {
+		return sc, nil
+	}
+	opts := c.opts
+	opts.Primary = selection == SelectionPrimary
+	opts.Target = ""
+	cb, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+	if c.siblings == nil {
+		c.siblings = make(map[Selection]*clipboard)
+	}
+	c.siblings[selection] = cb.(*clipboard)
+	return c.siblings[selection], nil
+}
+
+// snapshot returns the content of the selection of c.
+func (c *clipboard) snapshot() ([]Item, error) {
+	types, err := c.Types()
+	if err != nil {
+		if errors.Is(err, errors.ErrUnsupported) || ClassifyError(err) == ClassFailed {
+			return c.snapshotText()
+		}
+		return nil, err
+	}
+	items := []Item{}
+	for _, typ := range types {
+		if metaTargets[typ] {
+			continue
+		}
+		data, err := c.pasteData(typ)
+		if errors.Is(err, errors.ErrUnsupported) {
+			return c.snapshotText()
+		}
+		if err == nil && len(data) > 0 {
+			items = append(items, Item{Target: typ, Data: data})
+		}
+	}
+	return items, nil
+}
+
+// snapshotText returns the text of the selection of c as the only
+// item, or no item if it is empty, or if the tools failed to paste
+// it, as some do when the selection is empty.
+func (c *clipboard) snapshotText() ([]Item, error) {
+	text, err := c.PasteText()
+	if err != nil && ClassifyError(err) != ClassFailed {
+		return nil, err
+	}
+	if err != nil || text == "" {
+		return []Item{}, nil
+	}
+	return []Item{{Target: c.mime(), Data: []byte(text)}}, nil
+}
+
+// pasteData pastes the content of the selection of c as target.
+func (c *clipboard) pasteData(target string) ([]byte, error) {
+	var data []byte
+	err := c.withFallback("paste", false, func(b Backend) (string, error) {
+		var err error
+		data, err = Wrapper{b}.PasteData(target)
+		return string(data), err
+	})
+	return data, err
+}
+
+// restore puts items back in the selection of c, as one copy,
+// or clears it if there are none.
+func (c *clipboard) restore(items []Item) error {
+	op := "copy"
+	if len(items) == 0 {
+		op = "clear"
+	}
+	unlock, err := c.lock(op)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if len(items) == 0 {
+		return c.withFallback(op, false, func(b Backend) (string, error) {
+			return "", b.Clear()
+		})
+	}
+	items, sensitive, err := c.checkItems(items)
+	if err != nil {
+		c.record(op, nil, "", err)
+		return err
+	}
+	return c.withFallback(op, sensitive, func(b Backend) (string, error) {
+		err := Wrapper{b}.CopyItems(items)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return "", err
+		}
+		for _, item := range items {
+			if !isPlainText(item.Target) {
+				return "", fmt.Errorf("%s: copying %s: %w", b.Name(), item.Target, errors.ErrUnsupported)
+			}
+		}
+		text, ok := itemsText(items)
+		if !ok {
+			return "", fmt.Errorf("%s: copying %s: %w", b.Name(), items[0].Target, errors.ErrUnsupported)
+		}
+		return text, b.CopyText(text)
+	})
+}
+
+// restorable returns an error wrapping errors.ErrUnsupported if the
+// backend c tries first can't restore items, i.e. they hold targets
+// other than plain text and it can't copy several targets together.
+func (c *clipboard) restorable(items []Item) error {
+	backends, err := c.backends()
+	if err != nil {
+		return err
+	}
+	if backends[0].Capabilities().Items {
+		return nil
+	}
+	for _, item := range items {
+		if !isPlainText(item.Target) {
+			return fmt.Errorf("%s can't restore %s: %w", backends[0].Name(), item.Target, errors.ErrUnsupported)
+		}
+	}
+	return nil
+}
+
+// isPlainText reports whether target holds plain text, which
+// is restored by copying the text, whatever the backend.
+func isPlainText(target string) bool {
+	mediaType, _, _ := strings.Cut(target, ";")
+	return stringTargets[target] || strings.EqualFold(strings.TrimSpace(mediaType), "text/plain")
+}
+
+// itemsText returns the text held by items, from the preferred
+// text target, and whether they hold any.
+func itemsText(items []Item) (string, bool) {
+	for _, target := range textTargets {
+		for _, item := range items {
+			if item.Target == target {
+				text, err := charset.Decode(item.Data, item.Target, false)
+				return text, err == nil
+			}
+		}
+	}
+	for _, item := range items {
+		if strings.HasPrefix(item.Target, "text/") {
+			text, err := charset.Decode(item.Data, item.Target, false)
+			return text, err == nil
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,153 @@
+//go:build freebsd || linux || netbsd || openbsd || solaris || dragonfly
+
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tiagomelo/go-clipboard/clipboard"
+	"github.com/tiagomelo/go-clipboard/clipboard/clipboardtest"
+	"github.com/tiagomelo/go-clipboard/clipboard/clipboardtest/fakebin"
+)
+
+func TestClipboard_Snapshot(t *testing.T) {
+	testCases := []struct {
+		desc            string
+		tools           []string
+		primary         string
+		expectedTargets []string
+	}{
+		{
+			desc:            "xsel",
+			tools:           []string{"xsel"},
+			primary:         "primary text",
+			expectedTargets: []string{"text/plain;charset=utf-8"},
+		},
+		{
+			desc:            "xclip",
+			tools:           []string{"xclip"},
+			primary:         "primary text",
+			expectedTargets: []string{"UTF8_STRING", "STRING", "TEXT", "text/plain;charset=utf-8", "text/plain"},
+		},
+		{
+			desc:            "wayland",
+			tools:           []string{"wl-copy", "wl-paste"},
+			primary:         "primary text",
+			expectedTargets: []string{"text/plain;charset=utf-8", "text/plain", "UTF8_STRING", "STRING", "TEXT"},
+		},
+		{
+			desc:            "empty primary selection",
+			tools:           []string{"xclip"},
+			expectedTargets: []string{"UTF8_STRING", "STRING", "TEXT", "text/plain;charset=utf-8", "text/plain"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			c, primary := fakeSelections(t, tc.tools...)
+			require.NoError(t, c.CopyText("héllo wörld"))
+			if tc.primary != "" {
+				require.NoError(t, primary.CopyText(tc.primary))
+			} else {
+				require.NoError(t, primary.Clear())
+			}
+
+			s, err := c.(clipboard.Snapshotter).Snapshot()
+			require.NoError(t, err)
+			var targets []string
+			for _, item := range s.Selections[clipboard.SelectionClipboard] {
+				targets = append(targets, item.Target)
+				if item.Target == "text/plain;charset=utf-8" {
+					require.Equal(t, "héllo wörld", string(item.Data))
+				}
+			}
+			require.Equal(t, tc.expectedTargets, targets)
+			require.Equal(t, tc.primary == "", len(s.Selections[clipboard.SelectionPrimary]) == 0)
+
+			require.NoError(t, c.CopyText("temporary"))
+			require.NoError(t, primary.CopyText("temporary"))
+			require.NoError(t, c.(clipboard.Snapshotter).Restore(s))
+			text, err := c.PasteText()
+			require.NoError(t, err)
+			require.Equal(t, "héllo wörld", text)
+			text, _ = primary.PasteText()
+			require.Equal(t, tc.primary, text)
+		})
+	}
+}
+
+func TestWithTemporary(t *testing.T) {
+	c, _ := fakeSelections(t, "xsel")
+	require.NoError(t, c.CopyText("copied by the user"))
+
+	err := clipboard.WithTemporary(c, func() error {
+		if err := c.CopyText("temporary"); err != nil {
+			return err
+		}
+		text, err := c.PasteText()
+		require.NoError(t, err)
+		require.Equal(t, "temporary", text)
+		return errors.New("failed")
+	})
+	require.EqualError(t, err, "failed")
+	text, err := c.PasteText()
+	require.NoError(t, err)
+	require.Equal(t, "copied by the user", text)
+
+	t.Run("preserved", func(t *testing.T) {
+		clipboardtest.Preserve(t, c)
+		require.NoError(t, c.CopyText("temporary"))
+	})
+	text, err = c.PasteText()
+	require.NoError(t, err)
+	require.Equal(t, "copied by the user", text)
+}
+
+func TestClipboard_Snapshot_unrestorable(t *testing.T) {
+	c, _ := fakeSelections(t, "xclip")
+	require.NoError(t, c.(clipboard.DataCopier).CopyData("image/png", []byte("\x89PNG")))
+
+	_, err := c.(clipboard.Snapshotter).Snapshot()
+	require.True(t, errors.Is(err, errors.ErrUnsupported))
+	require.EqualError(t, err, "snapshot of clipboard: xclip can't restore image/png: unsupported operation")
+
+	err = clipboard.WithTemporary(c, func() error {
+		t.Fatal("fn ran although the clipboard couldn't be restored")
+		return nil
+	})
+	require.True(t, errors.Is(err, errors.ErrUnsupported))
+
+	s := &clipboard.Snapshot{Selections: map[clipboard.Selection][]clipboard.Item{
+		clipboard.SelectionClipboard: {
+			{Target: "text/plain", Data: []byte("text")},
+			{Target: "text/html", Data: []byte("<b>text</b>")},
+		},
+	}}
+	err = c.(clipboard.Snapshotter).Restore(s)
+	require.True(t, errors.Is(err, errors.ErrUnsupported))
+	types, err := c.(clipboard.TypeLister).Types()
+	require.NoError(t, err)
+	require.Equal(t, []string{"TARGETS", "image/png"}, types)
+}
+
+// fakeSelections puts the given fake tools in the PATH, and returns
+// the clipboards using them for the clipboard and primary selections.
+func fakeSelections(t *testing.T, tools ...string) (clipboard.Clipboard, clipboard.Clipboard) {
+	dir := t.TempDir()
+	require.NoError(t, os.Chmod(dir, 0o700))
+	require.NoError(t, fakebin.Install(dir, tools...))
+	t.Setenv("PATH", dir)
+	t.Setenv("XDG_RUNTIME_DIR", dir)
+	t.Setenv(fakebin.StateDirEnv, t.TempDir())
+	c, err := clipboard.New(clipboard.ClipboardOptions{Tools: tools[:1], NoDaemon: true})
+	require.NoError(t, err)
+	primary, err := clipboard.New(clipboard.ClipboardOptions{Tools: tools[:1], NoDaemon: true, Primary: true})
+	require.NoError(t, err)
+	return c, primary
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import "strings"
+
+// ContentTypes returns the MIME types or targets held by c, when it
+// implements TypeLister and can list them, or else plain text.
+func ContentTypes(c Clipboard) []string {
+	if l, ok := c.(TypeLister); ok {
+		if types, err := l.Types(); err == nil {
+			return types
+		}
+	}
+	return []string{"text/plain;charset=utf-8"}
+}
+
+// MatchTypes reports whether any of the given types matches any of the
+// patterns, ignoring case. A pattern ending with "*" matches the types
+// it prefixes, and a pattern without parameters ignores those of the
+// types, so that "text/plain" matches "text/plain;charset=utf-8".
+func MatchTypes(patterns, types []string) bool {
+	for _, pattern := range patterns {
+		for _, typ := range types {
+			if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+				if strings.HasPrefix(strings.ToLower(typ), strings.ToLower(prefix)) {
+					return true
+				}
+				continue
+			}
+			if base, _, ok := strings.Cut(typ, ";"); ok && !strings.Contains(pattern, ";") {
+				typ = strings.TrimSpace(base)
+			}
+			if strings.EqualFold(pattern, typ) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchTypes(t *testing.T) {
+	testCases := []struct {
+		desc           string
+		patterns       []string
+		types          []string
+		expectedOutput bool
+	}{
+		{
+			desc:           "same type",
+			patterns:       []string{"image/png"},
+			types:          []string{"text/plain", "image/png"},
+			expectedOutput: true,
+		},
+		{
+			desc:           "parameters ignored",
+			patterns:       []string{"text/plain"},
+			types:          []string{"text/plain;charset=utf-8"},
+			expectedOutput: true,
+		},
+		{
+			desc:     "other parameters",
+			patterns: []string{"text/plain;charset=utf-16"},
+			types:    []string{"text/plain;charset=utf-8"},
+		},
+		{
+			desc:           "prefix",
+			patterns:       []string{"x-kde-*"},
+			types:          []string{"UTF8_STRING", "x-kde-passwordManagerHint"},
+			expectedOutput: true,
+		},
+		{
+			desc:           "case ignored",
+			patterns:       []string{"Text/HTML"},
+			types:          []string{"text/html"},
+			expectedOutput: true,
+		},
+		{
+			desc:     "no match",
+			patterns: []string{"text/html", "image/*"},
+			types:    []string{"text/plain;charset=utf-8", "UTF8_STRING"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			require.Equal(t, tc.expectedOutput, MatchTypes(tc.patterns, tc.types))
+		})
+	}
+}
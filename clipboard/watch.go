@@ -0,0 +1,49 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package clipboard
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WatchOrPoll returns a channel receiving the text of c every time it
+// changes, until ctx is done, when the channel is closed. c is watched if
+// it implements Watcher and its backend can; otherwise it is pasted every
+// interval, and failed pastes are ignored.
+func WatchOrPoll(ctx context.Context, c Clipboard, interval time.Duration) (<-chan string, error) {
+	if w, ok := c.(Watcher); ok {
+		texts, err := w.Watch(ctx)
+		if !errors.Is(err, errors.ErrUnsupported) {
+			return texts, err
+		}
+	}
+	texts := make(chan string)
+	go func() {
+		defer close(texts)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		last, _ := c.PasteText()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			text, err := c.PasteText()
+			if err != nil || text == last {
+				continue
+			}
+			last = text
+			select {
+			case texts <- text:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return texts, nil
+}
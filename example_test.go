@@ -9,7 +9,11 @@ import (
 
 func Example() {
 	text := "some text"
-	c := clipboard.New()
+	c, err := clipboard.New()
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 	if err := c.CopyText(text); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
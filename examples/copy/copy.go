@@ -13,10 +13,16 @@ import (
 
 func main() {
 	text := "some text"
-	c := clipboard.New()
+	opts := clipboard.ClipboardOptions{}
 
 	if len(os.Args) > 0 {
-		c = clipboard.New(clipboard.ClipboardOptions{Primary: true})
+		opts.Primary = true
+	}
+
+	c, err := clipboard.New(opts)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
 
 	if err := c.CopyText(text); err != nil {
@@ -0,0 +1,37 @@
+// Copyright (c) 2023 Tiago Melo. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+// go-clipboard-backend-file is the reference clipboard backend plugin.
+// It keeps the clipboard in files, in the directory named by the
+// GO_CLIPBOARD_FILE_DIR environment variable, or else in the user's
+// cache directory, so that it works without any display server.
+//
+// Install it in the PATH to have it discovered:
+//
+//	go install ./examples/go-clipboard-backend-file
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/tiagomelo/go-clipboard/clipboard/plugin"
+)
+
+func main() {
+	dir := os.Getenv("GO_CLIPBOARD_FILE_DIR")
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		dir = filepath.Join(cacheDir, "go-clipboard")
+	}
+	if err := plugin.Serve(os.Stdin, os.Stdout, &plugin.FileHandler{Dir: dir}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -12,10 +12,16 @@ import (
 )
 
 func main() {
-	c := clipboard.New()
+	opts := clipboard.ClipboardOptions{}
 
 	if len(os.Args) > 0 {
-		c = clipboard.New(clipboard.ClipboardOptions{Primary: true})
+		opts.Primary = true
+	}
+
+	c, err := clipboard.New(opts)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
 	}
 
 	text, err := c.PasteText()
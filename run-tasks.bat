@@ -6,6 +6,7 @@ if "%1"=="" goto help
 :run
 if "%1"=="help" goto help
 if "%1"=="test" goto test
+if "%1"=="bench" goto bench
 if "%1"=="copy-example" goto copy-example
 if "%1"=="paste-example" goto paste-example
 echo Invalid target: %1
@@ -15,6 +16,10 @@ goto end
 go test -v ./... -count=1
 goto end
 
+:bench
+go test -run "^$" -bench . -benchmem ./...
+goto end
+
 :copy-example
 go run examples/copy/copy.go
 goto end
@@ -28,6 +33,7 @@ echo Usage: %0 [target]
 echo.
 echo    help: shows this help message
 echo    test: runs unit tests
+echo    bench: runs benchmarks
 echo    copy-example: runs copy example
 echo    paste-example: runs paste example
 echo.